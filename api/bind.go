@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hxdcloud/gost-x/internal/util/bind"
+)
+
+// successful operation.
+// swagger:response getBindStatesResponse
+type getBindStatesResponse struct {
+	States []bind.Info
+}
+
+func getBindStates(ctx *gin.Context) {
+	// swagger:route GET /bind/states Bind getBindStatesRequest
+	//
+	// Get the states of the BIND sessions of the reverse listeners (rtcp, rudp).
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getBindStatesResponse
+
+	var resp getBindStatesResponse
+	resp.States = bind.States()
+
+	ctx.JSON(http.StatusOK, resp.States)
+}
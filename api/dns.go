@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hxdcloud/gost-x/internal/util/stats"
+)
+
+const (
+	defaultDNSStatsTop = 10
+)
+
+// swagger:parameters getDNSStatsRequest
+type getDNSStatsRequest struct {
+	// time window of the statistics, e.g. 10m, default and max is 1h.
+	// in: query
+	Window string `form:"window" json:"window"`
+	// number of the top items, default is 10.
+	// in: query
+	Top int `form:"top" json:"top"`
+}
+
+// successful operation.
+// swagger:response getDNSStatsResponse
+type getDNSStatsResponse struct {
+	Stats *stats.DNSStats
+}
+
+func getDNSStats(ctx *gin.Context) {
+	// swagger:route GET /dns/stats DNS getDNSStatsRequest
+	//
+	// Get the statistics of DNS queries over a sliding window.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getDNSStatsResponse
+
+	var req getDNSStatsRequest
+	ctx.ShouldBindQuery(&req)
+
+	var window time.Duration
+	if req.Window != "" {
+		var err error
+		if window, err = time.ParseDuration(req.Window); err != nil {
+			writeError(ctx, ErrInvalid)
+			return
+		}
+	}
+	if req.Top <= 0 {
+		req.Top = defaultDNSStatsTop
+	}
+
+	var resp getDNSStatsResponse
+	resp.Stats = stats.GetDNSStats(window, req.Top)
+
+	ctx.JSON(http.StatusOK, resp.Stats)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
+)
+
+// swagger:parameters getQUICConnsRequest
+type getQUICConnsRequest struct {
+	// in: path
+	// required: true
+	Service string `uri:"service" json:"service"`
+}
+
+// successful operation.
+// swagger:response getQUICConnsResponse
+type getQUICConnsResponse struct {
+	Conns []quic_util.ConnStats
+}
+
+func getQUICConns(ctx *gin.Context) {
+	// swagger:route GET /quic/{service}/conns QUIC getQUICConnsRequest
+	//
+	// Get the statistics of the active QUIC connections of the service.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getQUICConnsResponse
+
+	var req getQUICConnsRequest
+	ctx.ShouldBindUri(&req)
+
+	stats := quic_util.GetStats(req.Service)
+	if stats == nil {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	var resp getQUICConnsResponse
+	resp.Conns = stats.Conns()
+
+	ctx.JSON(http.StatusOK, resp.Conns)
+}
@@ -78,6 +78,36 @@ func NewService(addr string, opts ...Option) (service.Service, error) {
 	config.Use(mwBasicAuth(options.auther))
 	registerConfig(config)
 
+	dns := router.Group("/dns")
+	dns.Use(mwBasicAuth(options.auther))
+	dns.GET("/stats", getDNSStats)
+
+	bind := router.Group("/bind")
+	bind.Use(mwBasicAuth(options.auther))
+	bind.GET("/states", getBindStates)
+
+	tunnels := router.Group("/tunnels")
+	tunnels.Use(mwBasicAuth(options.auther))
+	tunnels.GET("", getTunnels)
+
+	tun := router.Group("/tun")
+	tun.Use(mwBasicAuth(options.auther))
+	tun.GET("/:service/routes", getTunRoutes)
+	tun.POST("/:service/routes", createTunRoute)
+	tun.DELETE("/:service/routes", deleteTunRoute)
+
+	tap := router.Group("/tap")
+	tap.Use(mwBasicAuth(options.auther))
+	tap.GET("/:service/macs", getTapMACs)
+
+	udp := router.Group("/udp")
+	udp.Use(mwBasicAuth(options.auther))
+	udp.GET("/:service/flows", getUDPFlows)
+
+	quic := router.Group("/quic")
+	quic.Use(mwBasicAuth(options.auther))
+	quic.GET("/:service/conns", getQUICConns)
+
 	return &server{
 		s: &http.Server{
 			Handler: r,
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	tap_util "github.com/hxdcloud/gost-x/internal/util/tap"
+)
+
+// swagger:parameters getTapMACsRequest
+type getTapMACsRequest struct {
+	// in: path
+	// required: true
+	Service string `uri:"service" json:"service"`
+}
+
+// successful operation.
+// swagger:response getTapMACsResponse
+type getTapMACsResponse struct {
+	Peers []tap_util.PeerMACs
+}
+
+func getTapMACs(ctx *gin.Context) {
+	// swagger:route GET /tap/{service}/macs Tap getTapMACsRequest
+	//
+	// Get the MAC addresses learned by the tap service grouped by the peer,
+	// the MAC addresses of the local tap device are listed with empty peer.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getTapMACsResponse
+
+	var req getTapMACsRequest
+	ctx.ShouldBindUri(&req)
+
+	table := tap_util.GetMACTable(req.Service)
+	if table == nil {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	var resp getTapMACsResponse
+	resp.Peers = table.Peers()
+
+	ctx.JSON(http.StatusOK, resp.Peers)
+}
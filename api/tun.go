@@ -0,0 +1,193 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	tun_util "github.com/hxdcloud/gost-x/internal/util/tun"
+)
+
+// swagger:parameters getTunRoutesRequest
+type getTunRoutesRequest struct {
+	// in: path
+	// required: true
+	Service string `uri:"service" json:"service"`
+}
+
+// successful operation.
+// swagger:response getTunRoutesResponse
+type getTunRoutesResponse struct {
+	Routes []tun_util.RouteEntry
+}
+
+func getTunRoutes(ctx *gin.Context) {
+	// swagger:route GET /tun/{service}/routes Tun getTunRoutesRequest
+	//
+	// Get the routing table of the tun service.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getTunRoutesResponse
+
+	var req getTunRoutesRequest
+	ctx.ShouldBindUri(&req)
+
+	table := tun_util.GetRouteTable(req.Service)
+	if table == nil {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	var resp getTunRoutesResponse
+	resp.Routes = table.Routes()
+
+	ctx.JSON(http.StatusOK, resp.Routes)
+}
+
+// TunRoute is the route added to the routing table of the tun service.
+type TunRoute struct {
+	// destination network in CIDR notation or a single IP.
+	Net string `json:"net"`
+	// IP of the tun device which the traffic is sent to, such as the IP of a peer.
+	Gateway string `json:"gateway,omitempty"`
+	// address of the peer which the traffic is sent to.
+	Peer string `json:"peer,omitempty"`
+	// lifetime of the route, e.g. 1h, the route never expires if it is not set.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// swagger:parameters createTunRouteRequest
+type createTunRouteRequest struct {
+	// in: path
+	// required: true
+	Service string `uri:"service" json:"service"`
+	// in: body
+	Data TunRoute `json:"data"`
+}
+
+// successful operation.
+// swagger:response createTunRouteResponse
+type createTunRouteResponse struct {
+	Data Response
+}
+
+func createTunRoute(ctx *gin.Context) {
+	// swagger:route POST /tun/{service}/routes Tun createTunRouteRequest
+	//
+	// Add a static route to the routing table of the tun service,
+	// the traffic to the network is sent to the peer or the peer of the gateway.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: createTunRouteResponse
+
+	var req createTunRouteRequest
+	ctx.ShouldBindUri(&req)
+	ctx.ShouldBindJSON(&req.Data)
+
+	table := tun_util.GetRouteTable(req.Service)
+	if table == nil {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	ipNet, err := tun_util.ParseNet(req.Data.Net)
+	if err != nil {
+		writeError(ctx, ErrInvalid)
+		return
+	}
+
+	var gw net.IP
+	if req.Data.Gateway != "" {
+		if gw = net.ParseIP(req.Data.Gateway); gw == nil {
+			writeError(ctx, ErrInvalid)
+			return
+		}
+	}
+	var peer net.Addr
+	if req.Data.Peer != "" {
+		if peer, err = net.ResolveUDPAddr("udp", req.Data.Peer); err != nil {
+			writeError(ctx, ErrInvalid)
+			return
+		}
+	}
+	var ttl time.Duration
+	if req.Data.TTL != "" {
+		if ttl, err = time.ParseDuration(req.Data.TTL); err != nil {
+			writeError(ctx, ErrInvalid)
+			return
+		}
+	}
+
+	if err := table.AddRoute(ipNet, gw, peer, "", ttl); err != nil {
+		if err == tun_util.ErrRouteExists {
+			writeError(ctx, ErrDup)
+		} else {
+			writeError(ctx, ErrInvalid)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
+
+// swagger:parameters deleteTunRouteRequest
+type deleteTunRouteRequest struct {
+	// in: path
+	// required: true
+	Service string `uri:"service" json:"service"`
+	// destination network of the route in CIDR notation or a single IP.
+	// in: query
+	// required: true
+	Net string `form:"net" json:"net"`
+}
+
+// successful operation.
+// swagger:response deleteTunRouteResponse
+type deleteTunRouteResponse struct {
+	Data Response
+}
+
+func deleteTunRoute(ctx *gin.Context) {
+	// swagger:route DELETE /tun/{service}/routes Tun deleteTunRouteRequest
+	//
+	// Delete the route of the network from the routing table of the tun service.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: deleteTunRouteResponse
+
+	var req deleteTunRouteRequest
+	ctx.ShouldBindUri(&req)
+	ctx.ShouldBindQuery(&req)
+
+	table := tun_util.GetRouteTable(req.Service)
+	if table == nil {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	ipNet, err := tun_util.ParseNet(req.Net)
+	if err != nil {
+		writeError(ctx, ErrInvalid)
+		return
+	}
+	if !table.DelRoute(ipNet) {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hxdcloud/gost-x/internal/util/tunnel"
+)
+
+// successful operation.
+// swagger:response getTunnelsResponse
+type getTunnelsResponse struct {
+	Tunnels []tunnel.Info
+}
+
+func getTunnels(ctx *gin.Context) {
+	// swagger:route GET /tunnels Tunnel getTunnelsRequest
+	//
+	// Get the named tunnels registered on the relay handlers and the connectors of them.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getTunnelsResponse
+
+	var resp getTunnelsResponse
+	resp.Tunnels = tunnel.Tunnels()
+
+	ctx.JSON(http.StatusOK, resp.Tunnels)
+}
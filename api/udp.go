@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+)
+
+// swagger:parameters getUDPFlowsRequest
+type getUDPFlowsRequest struct {
+	// in: path
+	// required: true
+	Service string `uri:"service" json:"service"`
+}
+
+// successful operation.
+// swagger:response getUDPFlowsResponse
+type getUDPFlowsResponse struct {
+	Flows []udp.FlowInfo
+}
+
+func getUDPFlows(ctx *gin.Context) {
+	// swagger:route GET /udp/{service}/flows UDP getUDPFlowsRequest
+	//
+	// Get the active UDP flows in the NAT table of the service.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getUDPFlowsResponse
+
+	var req getUDPFlowsRequest
+	ctx.ShouldBindUri(&req)
+
+	table := udp.GetSessionTable(req.Service)
+	if table == nil {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	var resp getUDPFlowsResponse
+	resp.Flows = table.Flows()
+	if resp.Flows == nil {
+		resp.Flows = []udp.FlowInfo{}
+	}
+
+	ctx.JSON(http.StatusOK, resp.Flows)
+}
@@ -98,6 +98,20 @@ func (p *authenticator) Authenticate(user, password string) bool {
 	return ok && (v == "" || password == v)
 }
 
+// AuthenticateIdentity checks whether the user exists, the password is not checked,
+// it is used for the clients authenticated by the certificate.
+func (p *authenticator) AuthenticateIdentity(user string) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.kvs[user]
+	return ok
+}
+
 func (p *authenticator) periodReload(ctx context.Context) error {
 	period := p.options.period
 	if period < time.Second {
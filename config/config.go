@@ -71,6 +71,32 @@ type TLSConfig struct {
 	CAFile     string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
 	Secure     bool   `yaml:",omitempty" json:"secure,omitempty"`
 	ServerName string `yaml:"serverName,omitempty" json:"serverName,omitempty"`
+	// Reload is the period to check the certificate, key and CA files for changes.
+	Reload time.Duration `yaml:",omitempty" json:"reload,omitempty"`
+	// ACME obtains the certificate automatically, the certificate files are used until it is obtained.
+	ACME *ACMEConfig `yaml:"acme,omitempty" json:"acme,omitempty"`
+}
+
+type ACMEConfig struct {
+	Domains []string `json:"domains"`
+	Email   string   `yaml:"email,omitempty" json:"email,omitempty"`
+	// CA is the ACME directory URL, default is Let's Encrypt.
+	CA string `yaml:"ca,omitempty" json:"ca,omitempty"`
+	// CAFile is the root CA certificates to verify the ACME server.
+	CAFile   string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+	StoreDir string `yaml:"storeDir,omitempty" json:"storeDir,omitempty"`
+	// Challenge is one of http-01, tls-alpn-01 (default) and dns-01.
+	Challenge   string         `yaml:"challenge,omitempty" json:"challenge,omitempty"`
+	RenewBefore time.Duration  `yaml:"renewBefore,omitempty" json:"renewBefore,omitempty"`
+	DNS         *ACMEDNSConfig `yaml:"dns,omitempty" json:"dns,omitempty"`
+}
+
+// ACMEDNSConfig is the webhook provider of the DNS-01 challenge.
+type ACMEDNSConfig struct {
+	Webhook string        `json:"webhook"`
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Propagation is the time to wait for the TXT record to propagate.
+	Propagation time.Duration `yaml:"propagation,omitempty" json:"propagation,omitempty"`
 }
 
 type AutherConfig struct {
@@ -121,6 +147,11 @@ type RedisLoader struct {
 	Key      string `yaml:",omitempty" json:"key,omitempty"`
 }
 
+type HTTPLoader struct {
+	URL     string        `yaml:"url" json:"url"`
+	Timeout time.Duration `yaml:",omitempty" json:"timeout,omitempty"`
+}
+
 type NameserverConfig struct {
 	Addr     string        `json:"addr"`
 	Chain    string        `yaml:",omitempty" json:"chain,omitempty"`
@@ -129,11 +160,22 @@ type NameserverConfig struct {
 	Hostname string        `yaml:",omitempty" json:"hostname,omitempty"`
 	TTL      time.Duration `yaml:",omitempty" json:"ttl,omitempty"`
 	Timeout  time.Duration `yaml:",omitempty" json:"timeout,omitempty"`
+	DNSSEC   *DNSSECConfig `yaml:"dnssec,omitempty" json:"dnssec,omitempty"`
+}
+
+type DNSSECConfig struct {
+	TrustAnchors []string `yaml:"trustAnchors,omitempty" json:"trustAnchors,omitempty"`
+	// Bogus answers handling, reject (default) or flag.
+	Bogus string `yaml:",omitempty" json:"bogus,omitempty"`
 }
 
 type ResolverConfig struct {
 	Name        string              `json:"name"`
 	Nameservers []*NameserverConfig `json:"nameservers"`
+	Reload      time.Duration       `yaml:",omitempty" json:"reload,omitempty"`
+	File        *FileLoader         `yaml:",omitempty" json:"file,omitempty"`
+	Redis       *RedisLoader        `yaml:",omitempty" json:"redis,omitempty"`
+	HTTP        *HTTPLoader         `yaml:"http,omitempty" json:"http,omitempty"`
 }
 
 type HostMappingConfig struct {
@@ -145,6 +187,10 @@ type HostMappingConfig struct {
 type HostsConfig struct {
 	Name     string               `json:"name"`
 	Mappings []*HostMappingConfig `json:"mappings"`
+	Reload   time.Duration        `yaml:",omitempty" json:"reload,omitempty"`
+	File     *FileLoader          `yaml:",omitempty" json:"file,omitempty"`
+	Redis    *RedisLoader         `yaml:",omitempty" json:"redis,omitempty"`
+	HTTP     *HTTPLoader          `yaml:"http,omitempty" json:"http,omitempty"`
 }
 
 type RecorderConfig struct {
@@ -191,6 +237,18 @@ type HandlerConfig struct {
 }
 
 type ForwarderConfig struct {
+	Targets  []string              `json:"targets"`
+	Selector *SelectorConfig       `yaml:",omitempty" json:"selector,omitempty"`
+	Routes   []*ForwardRouteConfig `yaml:",omitempty" json:"routes,omitempty"`
+}
+
+// ForwardRouteConfig is a route of the reverse proxy handler,
+// the request is matched by the Host header, the path prefix and the client certificate names.
+type ForwardRouteConfig struct {
+	Name     string          `yaml:",omitempty" json:"name,omitempty"`
+	Hosts    []string        `yaml:",omitempty" json:"hosts,omitempty"`
+	Path     string          `yaml:",omitempty" json:"path,omitempty"`
+	Clients  []string        `yaml:",omitempty" json:"clients,omitempty"`
 	Targets  []string        `json:"targets"`
 	Selector *SelectorConfig `yaml:",omitempty" json:"selector,omitempty"`
 }
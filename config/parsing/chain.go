@@ -6,7 +6,6 @@ import (
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	"github.com/hxdcloud/gost-x/config"
-	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	"github.com/hxdcloud/gost-x/metadata"
 	"github.com/hxdcloud/gost-x/registry"
 )
@@ -41,9 +40,7 @@ func ParseChain(cfg *config.ChainConfig) (chain.Chainer, error) {
 			if tlsCfg == nil {
 				tlsCfg = &config.TLSConfig{}
 			}
-			tlsConfig, err := tls_util.LoadClientConfig(
-				tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile,
-				tlsCfg.Secure, tlsCfg.ServerName)
+			tlsConfig, err := parseClientTLSConfig(tlsCfg)
 			if err != nil {
 				chainLogger.Error(err)
 				return nil, err
@@ -71,9 +68,7 @@ func ParseChain(cfg *config.ChainConfig) (chain.Chainer, error) {
 			if tlsCfg == nil {
 				tlsCfg = &config.TLSConfig{}
 			}
-			tlsConfig, err = tls_util.LoadClientConfig(
-				tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile,
-				tlsCfg.Secure, tlsCfg.ServerName)
+			tlsConfig, err = parseClientTLSConfig(tlsCfg)
 			if err != nil {
 				chainLogger.Error(err)
 				return nil, err
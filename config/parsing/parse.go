@@ -168,7 +168,7 @@ func ParseResolver(cfg *config.ResolverConfig) (resolver.Resolver, error) {
 	}
 	var nameservers []resolver_impl.NameServer
 	for _, server := range cfg.Nameservers {
-		nameservers = append(nameservers, resolver_impl.NameServer{
+		ns := resolver_impl.NameServer{
 			Addr:     server.Addr,
 			Chain:    registry.ChainRegistry().Get(server.Chain),
 			TTL:      server.TTL,
@@ -176,30 +176,52 @@ func ParseResolver(cfg *config.ResolverConfig) (resolver.Resolver, error) {
 			ClientIP: net.ParseIP(server.ClientIP),
 			Prefer:   server.Prefer,
 			Hostname: server.Hostname,
-		})
+		}
+		if server.DNSSEC != nil {
+			ns.DNSSEC = &resolver_impl.DNSSEC{
+				TrustAnchors: server.DNSSEC.TrustAnchors,
+				Bogus:        server.DNSSEC.Bogus,
+			}
+		}
+		nameservers = append(nameservers, ns)
 	}
 
-	return resolver_impl.NewResolver(
-		nameservers,
+	opts := []resolver_impl.ResolverOption{
+		resolver_impl.ReloadPeriodResolverOption(cfg.Reload),
 		resolver_impl.LoggerResolverOption(
 			logger.Default().WithFields(map[string]any{
 				"kind":     "resolver",
 				"resolver": cfg.Name,
 			}),
 		),
-	)
+	}
+	if cfg.File != nil && cfg.File.Path != "" {
+		opts = append(opts, resolver_impl.FileLoaderResolverOption(loader.FileLoader(cfg.File.Path)))
+	}
+	if cfg.Redis != nil && cfg.Redis.Addr != "" {
+		opts = append(opts, resolver_impl.RedisLoaderResolverOption(loader.RedisHashLoader(
+			cfg.Redis.Addr,
+			loader.DBRedisLoaderOption(cfg.Redis.DB),
+			loader.PasswordRedisLoaderOption(cfg.Redis.Password),
+			loader.KeyRedisLoaderOption(cfg.Redis.Key),
+		)))
+	}
+	if cfg.HTTP != nil && cfg.HTTP.URL != "" {
+		opts = append(opts, resolver_impl.HTTPLoaderResolverOption(loader.HTTPLoader(
+			cfg.HTTP.URL,
+			loader.TimeoutHTTPLoaderOption(cfg.HTTP.Timeout),
+		)))
+	}
+
+	return resolver_impl.NewResolver(nameservers, opts...)
 }
 
 func ParseHosts(cfg *config.HostsConfig) hosts.HostMapper {
-	if cfg == nil || len(cfg.Mappings) == 0 {
+	if cfg == nil {
 		return nil
 	}
-	hosts := hosts_impl.NewHosts()
-	hosts.Logger = logger.Default().WithFields(map[string]any{
-		"kind":  "hosts",
-		"hosts": cfg.Name,
-	})
 
+	var mappings []hosts_impl.Mapping
 	for _, host := range cfg.Mappings {
 		if host.IP == "" || host.Hostname == "" {
 			continue
@@ -209,9 +231,39 @@ func ParseHosts(cfg *config.HostsConfig) hosts.HostMapper {
 		if ip == nil {
 			continue
 		}
-		hosts.Map(ip, host.Hostname, host.Aliases...)
+		mappings = append(mappings, hosts_impl.Mapping{
+			IP:       ip,
+			Hostname: host.Hostname,
+			Aliases:  host.Aliases,
+		})
+	}
+
+	opts := []hosts_impl.Option{
+		hosts_impl.MappingsOption(mappings),
+		hosts_impl.ReloadPeriodOption(cfg.Reload),
+		hosts_impl.LoggerOption(logger.Default().WithFields(map[string]any{
+			"kind":  "hosts",
+			"hosts": cfg.Name,
+		})),
+	}
+	if cfg.File != nil && cfg.File.Path != "" {
+		opts = append(opts, hosts_impl.FileLoaderOption(loader.FileLoader(cfg.File.Path)))
+	}
+	if cfg.Redis != nil && cfg.Redis.Addr != "" {
+		opts = append(opts, hosts_impl.RedisLoaderOption(loader.RedisHashLoader(
+			cfg.Redis.Addr,
+			loader.DBRedisLoaderOption(cfg.Redis.DB),
+			loader.PasswordRedisLoaderOption(cfg.Redis.Password),
+			loader.KeyRedisLoaderOption(cfg.Redis.Key),
+		)))
+	}
+	if cfg.HTTP != nil && cfg.HTTP.URL != "" {
+		opts = append(opts, hosts_impl.HTTPLoaderOption(loader.HTTPLoader(
+			cfg.HTTP.URL,
+			loader.TimeoutHTTPLoaderOption(cfg.HTTP.Timeout),
+		)))
 	}
-	return hosts
+	return hosts_impl.NewHostMapper(opts...)
 }
 
 func ParseRecorder(cfg *config.RecorderConfig) (r recorder.Recorder) {
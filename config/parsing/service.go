@@ -1,7 +1,9 @@
 package parsing
 
 import (
+	"io"
 	"strings"
+	"sync"
 
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/handler"
@@ -10,12 +12,13 @@ import (
 	"github.com/go-gost/core/recorder"
 	"github.com/go-gost/core/service"
 	"github.com/hxdcloud/gost-x/config"
-	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	acme_util "github.com/hxdcloud/gost-x/internal/util/acme"
 	"github.com/hxdcloud/gost-x/metadata"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
-func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
+func ParseService(cfg *config.ServiceConfig) (_ service.Service, err error) {
 	if cfg.Listener == nil {
 		cfg.Listener = &config.ListenerConfig{
 			Type: "tcp",
@@ -41,14 +44,23 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 	if tlsCfg == nil {
 		tlsCfg = &config.TLSConfig{}
 	}
-	tlsConfig, err := tls_util.LoadServerConfig(
-		tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile)
+	// the ACME managers are released with the service, or if the service fails to be created.
+	var managers []*acme_util.Manager
+	defer func() {
+		if err != nil {
+			for _, m := range managers {
+				m.Release()
+			}
+		}
+	}()
+
+	tlsConfig, m, err := parseServerTLSConfig(tlsCfg)
 	if err != nil {
 		listenerLogger.Error(err)
 		return nil, err
 	}
-	if tlsConfig == nil {
-		tlsConfig = defaultTLSConfig.Clone()
+	if m != nil {
+		managers = append(managers, m)
 	}
 
 	auther := ParseAutherFromAuth(cfg.Listener.Auth)
@@ -83,14 +95,13 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 	if tlsCfg == nil {
 		tlsCfg = &config.TLSConfig{}
 	}
-	tlsConfig, err = tls_util.LoadServerConfig(
-		tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile)
+	tlsConfig, m, err = parseServerTLSConfig(tlsCfg)
 	if err != nil {
 		handlerLogger.Error(err)
 		return nil, err
 	}
-	if tlsConfig == nil {
-		tlsConfig = defaultTLSConfig.Clone()
+	if m != nil {
+		managers = append(managers, m)
 	}
 
 	auther = ParseAutherFromAuth(cfg.Handler.Auth)
@@ -135,6 +146,13 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 	if forwarder, ok := h.(handler.Forwarder); ok {
 		forwarder.Forward(parseForwarder(cfg.Forwarder))
 	}
+	if extender, ok := h.(xhandler.Extender); ok {
+		extender.Extend(
+			xhandler.ServiceOption(cfg.Name),
+			xhandler.RecordersOption(recorders...),
+			xhandler.RoutesOption(parseRoutes(cfg.Forwarder)...),
+		)
+	}
 
 	if cfg.Handler.Metadata == nil {
 		cfg.Handler.Metadata = make(map[string]any)
@@ -148,11 +166,38 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 		service.AdmissionOption(registry.AdmissionRegistry().Get(cfg.Admission)),
 		service.LoggerOption(serviceLogger),
 	)
+	closer, _ := h.(io.Closer)
+	if closer != nil || len(managers) > 0 {
+		s = &closerService{Service: s, handler: closer, managers: managers}
+	}
 
 	serviceLogger.Infof("listening on %s/%s", s.Addr().String(), s.Addr().Network())
 	return s, nil
 }
 
+// closerService closes the handler with the service,
+// such as the tables registered by the handler for the service,
+// and releases the ACME managers of the TLS configs of the service.
+type closerService struct {
+	service.Service
+	handler  io.Closer
+	managers []*acme_util.Manager
+	once     sync.Once
+}
+
+func (s *closerService) Close() error {
+	err := s.Service.Close()
+	s.once.Do(func() {
+		if s.handler != nil {
+			s.handler.Close()
+		}
+		for _, m := range s.managers {
+			m.Release()
+		}
+	})
+	return err
+}
+
 func parseForwarder(cfg *config.ForwarderConfig) *chain.NodeGroup {
 	if cfg == nil || len(cfg.Targets) == 0 {
 		return nil
@@ -170,3 +215,30 @@ func parseForwarder(cfg *config.ForwarderConfig) *chain.NodeGroup {
 	}
 	return group.WithSelector(parseSelector(cfg.Selector))
 }
+
+func parseRoutes(cfg *config.ForwarderConfig) (routes []xhandler.Route) {
+	if cfg == nil {
+		return
+	}
+
+	for _, r := range cfg.Routes {
+		if r == nil {
+			continue
+		}
+		group := parseForwarder(&config.ForwarderConfig{
+			Targets:  r.Targets,
+			Selector: r.Selector,
+		})
+		if group == nil {
+			continue
+		}
+		routes = append(routes, xhandler.Route{
+			Name:    r.Name,
+			Hosts:   r.Hosts,
+			Path:    r.Path,
+			Clients: r.Clients,
+			Group:   group,
+		})
+	}
+	return
+}
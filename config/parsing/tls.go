@@ -8,10 +8,13 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"math/big"
+	"net/http"
 	"time"
 
 	"github.com/go-gost/core/logger"
 	"github.com/hxdcloud/gost-x/config"
+	acme_util "github.com/hxdcloud/gost-x/internal/util/acme"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 )
 
 var (
@@ -42,9 +45,110 @@ func BuildDefaultTLSConfig(cfg *config.TLSConfig) {
 	} else {
 		log.Info("load TLS certificate files OK")
 	}
+
+	if cfg.ACME != nil {
+		m, err := parseACME(cfg.ACME)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tlsConfig = m.ServerConfig(tlsConfig)
+	}
 	defaultTLSConfig = tlsConfig
 }
 
+// parseServerTLSConfig loads the server TLS config, the default TLS config is used if no certificate is set.
+// The ACME manager of the config is returned to be released with the service.
+func parseServerTLSConfig(cfg *config.TLSConfig) (*tls.Config, *acme_util.Manager, error) {
+	var tlsConfig *tls.Config
+	if cfg.Reload > 0 && (cfg.CertFile != "" || cfg.KeyFile != "" || cfg.CAFile != "") {
+		r, err := tls_util.GetReloader(cfg.CertFile, cfg.KeyFile, cfg.CAFile, cfg.Reload, tlsLogger())
+		if err != nil {
+			return nil, nil, err
+		}
+		var base *tls.Config
+		if r.Certificate() == nil {
+			// only the client CA is watched, the default certificate is used.
+			base = defaultTLSConfig
+		}
+		tlsConfig = r.ServerConfig(base)
+	} else {
+		var err error
+		tlsConfig, err = tls_util.LoadServerConfig(cfg.CertFile, cfg.KeyFile, cfg.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if tlsConfig == nil {
+		tlsConfig = defaultTLSConfig.Clone()
+	}
+
+	if cfg.ACME == nil {
+		return tlsConfig, nil, nil
+	}
+	m, err := parseACME(cfg.ACME)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.ServerConfig(tlsConfig), m, nil
+}
+
+// parseClientTLSConfig loads the client TLS config.
+func parseClientTLSConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	tlsConfig, err := tls_util.LoadClientConfig(
+		cfg.CertFile, cfg.KeyFile, cfg.CAFile,
+		cfg.Secure, cfg.ServerName)
+	if err != nil || cfg.Reload <= 0 ||
+		(cfg.CertFile == "" && cfg.KeyFile == "" && cfg.CAFile == "") {
+		return tlsConfig, err
+	}
+
+	r, err := tls_util.GetReloader(cfg.CertFile, cfg.KeyFile, cfg.CAFile, cfg.Reload, tlsLogger())
+	if err != nil {
+		return nil, err
+	}
+	return r.ClientConfig(tlsConfig, cfg.Secure), nil
+}
+
+func tlsLogger() logger.Logger {
+	return logger.Default().WithFields(map[string]any{
+		"kind": "tls",
+	})
+}
+
+func parseACME(cfg *config.ACMEConfig) (*acme_util.Manager, error) {
+	opts := []acme_util.Option{
+		acme_util.DomainsOption(cfg.Domains...),
+		acme_util.EmailOption(cfg.Email),
+		acme_util.DirectoryOption(cfg.CA),
+		acme_util.StoreDirOption(cfg.StoreDir),
+		acme_util.ChallengeOption(cfg.Challenge),
+		acme_util.RenewBeforeOption(cfg.RenewBefore),
+		acme_util.LoggerOption(logger.Default().WithFields(map[string]any{
+			"kind": "acme",
+		})),
+	}
+	if cfg.DNS != nil && cfg.DNS.Webhook != "" {
+		opts = append(opts, acme_util.DNSProviderOption(
+			acme_util.WebhookDNSProvider(cfg.DNS.Webhook, cfg.DNS.Timeout),
+			cfg.DNS.Propagation,
+		))
+	}
+	if cfg.CAFile != "" {
+		tlsConfig, err := tls_util.LoadClientConfig("", "", cfg.CAFile, true, "")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, acme_util.HTTPClientOption(&http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}))
+	}
+
+	return acme_util.Get(opts...)
+}
+
 func loadConfig(certFile, keyFile string) (*tls.Config, error) {
 	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err != nil {
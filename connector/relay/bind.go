@@ -12,6 +12,7 @@ import (
 	"github.com/go-gost/relay"
 	"github.com/hxdcloud/gost-x/internal/util/mux"
 	relay_util "github.com/hxdcloud/gost-x/internal/util/relay"
+	"github.com/hxdcloud/gost-x/internal/util/tunnel"
 )
 
 // Bind implements connector.Binder.
@@ -40,13 +41,23 @@ func (c *relayConnector) Bind(ctx context.Context, conn net.Conn, network, addre
 }
 
 func (c *relayConnector) bindTCP(ctx context.Context, conn net.Conn, network, address string, log logger.Logger) (net.Listener, error) {
-	laddr, err := c.bind(conn, relay.BIND, network, address)
+	cmd := relay.BIND
+	if id := tunnel.IDFromContext(ctx); id != "" {
+		// the tunnel ID is sent as the host of the address.
+		cmd |= relay_util.FTunnel
+		address = net.JoinHostPort(id, "0")
+		log = log.WithFields(map[string]any{
+			"tunnel": id,
+		})
+	}
+
+	laddr, err := c.bind(conn, cmd, network, address)
 	if err != nil {
 		return nil, err
 	}
 	log.Debugf("bind on %s/%s OK", laddr, laddr.Network())
 
-	session, err := mux.ServerSession(conn)
+	session, err := mux.ServerSession(conn, mux.ConfigFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/go-gost/core/connector"
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/relay"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	relay_util "github.com/hxdcloud/gost-x/internal/util/relay"
 	"github.com/hxdcloud/gost-x/registry"
 )
@@ -18,8 +21,10 @@ func init() {
 }
 
 type relayConnector struct {
-	md      metadata
-	options connector.Options
+	pool         *mux.Pool
+	sessionMutex sync.Mutex
+	md           metadata
+	options      connector.Options
 }
 
 func NewConnector(opts ...connector.Option) connector.Connector {
@@ -34,7 +39,14 @@ func NewConnector(opts ...connector.Option) connector.Connector {
 }
 
 func (c *relayConnector) Init(md md.Metadata) (err error) {
-	return c.parseMetadata(md)
+	if err = c.parseMetadata(md); err != nil {
+		return
+	}
+
+	if c.md.mux {
+		c.pool = mux.NewPool(c.md.muxMaxSessions, c.md.muxMaxStreams)
+	}
+	return
 }
 
 func (c *relayConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
@@ -66,7 +78,7 @@ func (c *relayConnector) Connect(ctx context.Context, conn net.Conn, network, ad
 			}
 			log.Debugf("associate on %s OK", baddr)
 
-			return relay_util.UDPTunClientConn(conn, nil), nil
+			return udp.UDPClientConn(conn, relay_util.UDPTunClientConn(conn, nil), nil), nil
 		}
 	}
 
@@ -17,18 +17,22 @@ type tcpListener struct {
 }
 
 func (p *tcpListener) Accept() (net.Conn, error) {
-	cc, err := p.session.Accept()
-	if err != nil {
-		return nil, err
-	}
+	for {
+		cc, err := p.session.Accept()
+		if err != nil {
+			return nil, err
+		}
 
-	conn, err := p.getPeerConn(cc)
-	if err != nil {
-		cc.Close()
-		return nil, err
-	}
+		conn, err := p.getPeerConn(cc)
+		if err != nil {
+			// only the stream is broken, the session and the other streams are still alive.
+			p.logger.Warn(err)
+			cc.Close()
+			continue
+		}
 
-	return conn, nil
+		return conn, nil
+	}
 }
 
 func (p *tcpListener) getPeerConn(conn net.Conn) (net.Conn, error) {
@@ -71,3 +75,8 @@ func (p *tcpListener) Addr() net.Addr {
 func (p *tcpListener) Close() error {
 	return p.session.Close()
 }
+
+// NumStreams returns the number of the streams of the session.
+func (p *tcpListener) NumStreams() int {
+	return p.session.NumStreams()
+}
@@ -4,22 +4,37 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
 	connectTimeout time.Duration
 	noDelay        bool
+
+	mux            bool
+	muxCfg         *mux.Config
+	muxMaxSessions int
+	muxMaxStreams  int
 }
 
 func (c *relayConnector) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		connectTimeout = "connectTimeout"
 		noDelay        = "nodelay"
+
+		enableMux      = "mux"
+		muxMaxSessions = "muxMaxSessions"
+		muxMaxStreams  = "muxMaxStreams"
 	)
 
 	c.md.connectTimeout = mdx.GetDuration(md, connectTimeout)
 	c.md.noDelay = mdx.GetBool(md, noDelay)
 
+	c.md.mux = mdx.GetBool(md, enableMux)
+	c.md.muxCfg = mux.ConfigFromMetadata(md)
+	c.md.muxMaxSessions = mdx.GetInt(md, muxMaxSessions)
+	c.md.muxMaxStreams = mdx.GetInt(md, muxMaxStreams)
+
 	return
 }
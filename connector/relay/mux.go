@@ -0,0 +1,87 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/go-gost/relay"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	relay_util "github.com/hxdcloud/gost-x/internal/util/relay"
+)
+
+// Handshake implements connector.Handshaker.
+// If mux is enabled, the requests to the node are sent over the streams of the pooled mux sessions,
+// the connection is upgraded to a new session or closed if a pooled session is used.
+func (c *relayConnector) Handshake(ctx context.Context, conn net.Conn) (net.Conn, error) {
+	if c.pool == nil {
+		return conn, nil
+	}
+
+	addr := conn.RemoteAddr().String()
+
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+
+	e, err := c.pool.Get(addr)
+	if err != nil {
+		c.options.Logger.Error(err)
+		conn.Close()
+		return nil, err
+	}
+	if e != nil {
+		cc, err := e.Session.GetConn()
+		if err == nil {
+			conn.Close()
+			return cc, nil
+		}
+		c.options.Logger.Debug(err)
+		e.Session.Close()
+		c.pool.Remove(addr, e)
+	}
+
+	if err := c.muxHandshake(conn); err != nil {
+		c.options.Logger.Error(err)
+		conn.Close()
+		return nil, err
+	}
+
+	session, err := mux.ClientSession(conn, c.md.muxCfg)
+	if err != nil {
+		c.options.Logger.Error(err)
+		conn.Close()
+		return nil, err
+	}
+	cc, err := session.GetConn()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	c.pool.SetSession(c.pool.Add(addr, conn), session)
+
+	return cc, nil
+}
+
+// muxHandshake sends the request to upgrade the connection to a mux session.
+func (c *relayConnector) muxHandshake(conn net.Conn) error {
+	if c.md.connectTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.md.connectTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := relay.Request{
+		Version: relay.Version1,
+		Flags:   relay.CONNECT | relay_util.FMux,
+	}
+	if c.options.Auth != nil {
+		pwd, _ := c.options.Auth.Password()
+		req.Features = append(req.Features, &relay.UserAuthFeature{
+			Username: c.options.Auth.Username(),
+			Password: pwd,
+		})
+	}
+	if _, err := req.WriteTo(conn); err != nil {
+		return err
+	}
+	return readResponse(conn)
+}
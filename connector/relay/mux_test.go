@@ -0,0 +1,115 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/connector"
+	"github.com/go-gost/core/handler"
+	relay_handler "github.com/hxdcloud/gost-x/handler/relay"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+// listen starts a TCP server handling the connections by the handler.
+func listen(t *testing.T, handle func(net.Conn)) net.Addr {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return ln.Addr()
+}
+
+func TestConnectorMux(t *testing.T) {
+	echo := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		io.Copy(conn, conn)
+	})
+
+	tests := []struct {
+		name     string
+		md       map[string]any
+		sessions int
+	}{
+		{name: "no mux", md: nil, sessions: 0},
+		{name: "mux", md: map[string]any{"mux": true}, sessions: 1},
+		{name: "max streams", md: map[string]any{"mux": true, "muxMaxSessions": 2, "muxMaxStreams": 2}, sessions: 2},
+		{name: "yamux", md: map[string]any{"mux": true, "muxProtocol": "yamux"}, sessions: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := relay_handler.NewHandler(handler.LoggerOption(xlogger.Nop()))
+			if err := h.Init(mdx.NewMetadata(tt.md)); err != nil {
+				t.Fatal(err)
+			}
+			server := listen(t, func(conn net.Conn) {
+				h.Handle(context.Background(), conn)
+			})
+
+			c := NewConnector(
+				connector.AuthOption(url.UserPassword("user", "pass")),
+				connector.LoggerOption(xlogger.Nop()),
+			).(*relayConnector)
+			if err := c.Init(mdx.NewMetadata(tt.md)); err != nil {
+				t.Fatal(err)
+			}
+
+			var conns []net.Conn
+			defer func() {
+				for _, conn := range conns {
+					conn.Close()
+				}
+			}()
+			for i := 0; i < 3; i++ {
+				conn, err := net.Dial("tcp", server.String())
+				if err != nil {
+					t.Fatal(err)
+				}
+				conn, err = c.Handshake(context.Background(), conn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				conn, err = c.Connect(context.Background(), conn, "tcp", echo.String())
+				if err != nil {
+					t.Fatal(err)
+				}
+				conns = append(conns, conn)
+
+				conn.SetDeadline(time.Now().Add(3 * time.Second))
+				if _, err := conn.Write([]byte("ping")); err != nil {
+					t.Fatal(err)
+				}
+				buf := make([]byte, 4)
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sessions := 0
+			if c.pool != nil {
+				sessions = c.pool.Len(server.String())
+			}
+			if sessions != tt.sessions {
+				t.Errorf("sessions: got %d, want %d", sessions, tt.sessions)
+			}
+		})
+	}
+}
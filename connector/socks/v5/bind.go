@@ -62,7 +62,7 @@ func (c *socks5Connector) muxBindTCP(ctx context.Context, conn net.Conn, network
 		return nil, err
 	}
 
-	session, err := mux.ServerSession(conn)
+	session, err := mux.ServerSession(conn, mux.ConfigFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -12,6 +12,7 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/gosocks5"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	"github.com/hxdcloud/gost-x/internal/util/socks"
 	"github.com/hxdcloud/gost-x/registry"
 )
@@ -169,5 +170,5 @@ func (c *socks5Connector) connectUDP(ctx context.Context, conn net.Conn, network
 		return nil, errors.New("get socks5 UDP tunnel failure")
 	}
 
-	return socks.UDPTunClientConn(conn, addr), nil
+	return udp.UDPClientConn(conn, socks.UDPTunClientConn(conn, addr), addr), nil
 }
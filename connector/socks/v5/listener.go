@@ -54,18 +54,22 @@ type tcpMuxListener struct {
 }
 
 func (p *tcpMuxListener) Accept() (net.Conn, error) {
-	cc, err := p.session.Accept()
-	if err != nil {
-		return nil, err
+	for {
+		cc, err := p.session.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := p.getPeerConn(cc)
+		if err != nil {
+			// only the stream is broken, the session and the other streams are still alive.
+			p.logger.Warn(err)
+			cc.Close()
+			continue
+		}
+
+		return conn, nil
 	}
-
-	conn, err := p.getPeerConn(cc)
-	if err != nil {
-		cc.Close()
-		return nil, err
-	}
-
-	return conn, nil
 }
 
 func (p *tcpMuxListener) getPeerConn(conn net.Conn) (net.Conn, error) {
@@ -100,3 +104,8 @@ func (p *tcpMuxListener) Addr() net.Addr {
 func (p *tcpMuxListener) Close() error {
 	return p.session.Close()
 }
+
+// NumStreams returns the number of the streams of the session.
+func (p *tcpMuxListener) NumStreams() int {
+	return p.session.NumStreams()
+}
@@ -92,10 +92,25 @@ func (d *h2Dialer) Dial(ctx context.Context, address string, opts ...dialer.Dial
 		client = &http.Client{}
 		if d.h2c {
 			client.Transport = &http2.Transport{
-				DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
 					return options.NetDialer.Dial(ctx, network, addr)
 				},
 			}
+		} else if d.md.clientHello != nil {
+			// http.Transport only negotiates HTTP2 for the crypto/tls connection.
+			client.Transport = &http2.Transport{
+				TLSClientConfig: d.options.TLSConfig,
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+					ctx, cancel := context.WithTimeout(ctx, d.md.handshakeTimeout)
+					defer cancel()
+
+					conn, err := options.NetDialer.Dial(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					return d.md.clientHello.Client(ctx, conn, cfg, http2.NextProtoTLS)
+				},
+			}
 		} else {
 			client.Transport = &http.Transport{
 				TLSClientConfig: d.options.TLSConfig,
@@ -105,7 +120,7 @@ func (d *h2Dialer) Dial(ctx context.Context, address string, opts ...dialer.Dial
 				ForceAttemptHTTP2:     true,
 				MaxIdleConns:          100,
 				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
+				TLSHandshakeTimeout:   d.md.handshakeTimeout,
 				ExpectContinueTimeout: 1 * time.Second,
 			}
 		}
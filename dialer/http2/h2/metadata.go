@@ -2,22 +2,32 @@ package h2
 
 import (
 	"net/http"
+	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
+const (
+	defaultHandshakeTimeout = 10 * time.Second
+)
+
 type metadata struct {
-	host   string
-	path   string
-	header http.Header
+	host        string
+	path        string
+	header      http.Header
+	clientHello *tls_util.ClientHello
+	// handshakeTimeout is the timeout of the TLS handshake.
+	handshakeTimeout time.Duration
 }
 
 func (d *h2Dialer) parseMetadata(md mdata.Metadata) (err error) {
 	const (
-		host   = "host"
-		path   = "path"
-		header = "header"
+		host             = "host"
+		path             = "path"
+		header           = "header"
+		handshakeTimeout = "handshakeTimeout"
 	)
 
 	d.md.host = mdx.GetString(md, host)
@@ -29,5 +39,10 @@ func (d *h2Dialer) parseMetadata(md mdata.Metadata) (err error) {
 		}
 		d.md.header = h
 	}
+	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
+	if d.md.handshakeTimeout <= 0 {
+		d.md.handshakeTimeout = defaultHandshakeTimeout
+	}
+	d.md.clientHello, err = tls_util.ClientHelloFromMetadata(md)
 	return
 }
@@ -11,8 +11,8 @@ import (
 	md "github.com/go-gost/core/metadata"
 	pht_util "github.com/hxdcloud/gost-x/internal/util/pht"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/lucas-clemente/quic-go"
-	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 )
 
 func init() {
@@ -66,25 +66,32 @@ func (d *http3Dialer) Dial(ctx context.Context, addr string, opts ...dialer.Dial
 			host = h
 		}
 
+		tlsCfg := d.options.TLSConfig
+		if d.md.zeroRTT && tlsCfg != nil && tlsCfg.ClientSessionCache == nil {
+			// 0-RTT needs the session ticket of the previous connection.
+			tlsCfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+
 		client = &pht_util.Client{
 			Host: host,
 			Client: &http.Client{
 				// Timeout:   60 * time.Second,
-				Transport: &http3.RoundTripper{
-					TLSClientConfig: d.options.TLSConfig,
-					Dial: func(network, adr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlySession, error) {
+				Transport: &http3.Transport{
+					TLSClientConfig: tlsCfg,
+					QUICConfig:      d.md.config,
+					Dial: func(ctx context.Context, adr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
 						// d.options.Logger.Infof("dial: %s/%s, %s", addr, network, host)
 						udpAddr, err := net.ResolveUDPAddr("udp", addr)
 						if err != nil {
 							return nil, err
 						}
 
-						udpConn, err := options.NetDialer.Dial(context.Background(), "udp", "")
+						udpConn, err := options.NetDialer.Dial(ctx, "udp", "")
 						if err != nil {
 							return nil, err
 						}
 
-						return quic.DialEarly(udpConn.(net.PacketConn), udpAddr, host, tlsCfg, cfg)
+						return quic.DialEarly(ctx, udpConn.(net.PacketConn), udpAddr, tlsCfg, cfg)
 					},
 				},
 			},
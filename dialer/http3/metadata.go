@@ -5,7 +5,9 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
 	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/quic-go/quic-go"
 )
 
 const (
@@ -25,14 +27,19 @@ type metadata struct {
 	pushPath      string
 	pullPath      string
 	host          string
+	config        *quic.Config
+	zeroRTT       bool
 }
 
 func (d *http3Dialer) parseMetadata(md mdata.Metadata) (err error) {
+	d.md.config = quic_util.ConfigFromMetadata(md)
+
 	const (
 		authorizePath = "authorizePath"
 		pushPath      = "pushPath"
 		pullPath      = "pullPath"
 		host          = "host"
+		zeroRTT       = "zeroRTT"
 	)
 
 	d.md.authorizePath = mdx.GetString(md, authorizePath)
@@ -49,5 +56,6 @@ func (d *http3Dialer) parseMetadata(md mdata.Metadata) (err error) {
 	}
 
 	d.md.host = mdx.GetString(md, host)
+	d.md.zeroRTT = mdx.GetBool(md, zeroRTT)
 	return
 }
@@ -13,7 +13,7 @@ import (
 	md "github.com/go-gost/core/metadata"
 	icmp_pkg "github.com/hxdcloud/gost-x/internal/util/icmp"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/lucas-clemente/quic-go"
+	"github.com/quic-go/quic-go"
 	"golang.org/x/net/icmp"
 )
 
@@ -104,20 +104,10 @@ func (d *icmpDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 }
 
 func (d *icmpDialer) initSession(ctx context.Context, addr net.Addr, conn net.PacketConn) (*quicSession, error) {
-	quicConfig := &quic.Config{
-		KeepAlive:            d.md.keepAlive,
-		HandshakeIdleTimeout: d.md.handshakeTimeout,
-		MaxIdleTimeout:       d.md.maxIdleTimeout,
-		Versions: []quic.VersionNumber{
-			quic.Version1,
-			quic.VersionDraft29,
-		},
-	}
-
 	tlsCfg := d.options.TLSConfig
 	tlsCfg.NextProtos = []string{"http/3", "quic/v1"}
 
-	session, err := quic.DialContext(ctx, conn, addr, addr.String(), tlsCfg, quicConfig)
+	session, err := quic.Dial(ctx, conn, addr, tlsCfg, d.md.config)
 	if err != nil {
 		return nil, err
 	}
@@ -1,30 +1,17 @@
 package quic
 
 import (
-	"time"
-
 	mdata "github.com/go-gost/core/metadata"
-	mdx "github.com/hxdcloud/gost-x/metadata"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
+	"github.com/quic-go/quic-go"
 )
 
 type metadata struct {
-	keepAlive        bool
-	maxIdleTimeout   time.Duration
-	handshakeTimeout time.Duration
+	config *quic.Config
 }
 
 func (d *icmpDialer) parseMetadata(md mdata.Metadata) (err error) {
-	const (
-		keepAlive        = "keepAlive"
-		handshakeTimeout = "handshakeTimeout"
-		maxIdleTimeout   = "maxIdleTimeout"
-	)
-
-	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
-
-	d.md.keepAlive = mdx.GetBool(md, keepAlive)
-	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
-	d.md.maxIdleTimeout = mdx.GetDuration(md, maxIdleTimeout)
+	d.md.config = quic_util.ConfigFromMetadata(md)
 
 	return
 }
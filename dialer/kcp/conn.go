@@ -3,11 +3,15 @@ package kcp
 import (
 	"net"
 
+	kcp_util "github.com/hxdcloud/gost-x/internal/util/kcp"
+	"github.com/xtaci/kcp-go/v5"
 	"github.com/xtaci/smux"
 )
 
 type muxSession struct {
 	session *smux.Session
+	conn    *kcp.UDPSession
+	monitor *kcp_util.Monitor
 }
 
 func (session *muxSession) GetConn() (net.Conn, error) {
@@ -19,6 +23,9 @@ func (session *muxSession) Accept() (net.Conn, error) {
 }
 
 func (session *muxSession) Close() error {
+	if session.monitor != nil {
+		session.monitor.Remove(session.conn)
+	}
 	if session.session == nil {
 		return nil
 	}
@@ -23,6 +23,7 @@ func init() {
 
 type kcpDialer struct {
 	sessions     map[string]*muxSession
+	monitor      *kcp_util.Monitor
 	sessionMutex sync.Mutex
 	logger       logger.Logger
 	md           metadata
@@ -48,6 +49,7 @@ func (d *kcpDialer) Init(md md.Metadata) (err error) {
 	}
 
 	d.md.config.Init()
+	d.monitor = kcp_util.NewMonitor("", d.md.config)
 
 	return nil
 }
@@ -63,6 +65,7 @@ func (d *kcpDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 
 	session, ok := d.sessions[addr]
 	if session != nil && session.IsClosed() {
+		session.Close()
 		delete(d.sessions, addr) // session is dead
 		ok = false
 	}
@@ -156,7 +159,13 @@ func (d *kcpDialer) initSession(ctx context.Context, addr net.Addr, conn net.Pac
 	if err != nil {
 		return nil, err
 	}
-	return &muxSession{session: session}, nil
+
+	d.monitor.Add(kcpconn)
+	return &muxSession{
+		session: session,
+		conn:    kcpconn,
+		monitor: d.monitor,
+	}, nil
 }
 
 // Multiplex implements dialer.Multiplexer interface.
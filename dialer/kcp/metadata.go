@@ -1,7 +1,6 @@
 package kcp
 
 import (
-	"encoding/json"
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
@@ -16,23 +15,11 @@ type metadata struct {
 
 func (d *kcpDialer) parseMetadata(md mdata.Metadata) (err error) {
 	const (
-		config           = "config"
 		handshakeTimeout = "handshakeTimeout"
 	)
 
-	if m := mdx.GetStringMap(md, config); len(m) > 0 {
-		b, err := json.Marshal(m)
-		if err != nil {
-			return err
-		}
-		cfg := &kcp_util.Config{}
-		if err := json.Unmarshal(b, cfg); err != nil {
-			return err
-		}
-		d.md.config = cfg
-	}
-	if d.md.config == nil {
-		d.md.config = kcp_util.DefaultConfig
+	if d.md.config, err = kcp_util.ConfigFromMetadata(md); err != nil {
+		return
 	}
 
 	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
@@ -11,8 +11,8 @@ import (
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/xtaci/smux"
 )
 
 func init() {
@@ -20,7 +20,7 @@ func init() {
 }
 
 type mtlsDialer struct {
-	sessions     map[string]*muxSession
+	pool         *mux.Pool
 	sessionMutex sync.Mutex
 	logger       logger.Logger
 	md           metadata
@@ -34,9 +34,8 @@ func NewDialer(opts ...dialer.Option) dialer.Dialer {
 	}
 
 	return &mtlsDialer{
-		sessions: make(map[string]*muxSession),
-		logger:   options.Logger,
-		options:  options,
+		logger:  options.Logger,
+		options: options,
 	}
 }
 
@@ -45,6 +44,7 @@ func (d *mtlsDialer) Init(md md.Metadata) (err error) {
 		return
 	}
 
+	d.pool = mux.NewPool(d.md.muxMaxSessions, d.md.muxMaxStreams)
 	return nil
 }
 
@@ -57,27 +57,26 @@ func (d *mtlsDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 	d.sessionMutex.Lock()
 	defer d.sessionMutex.Unlock()
 
-	session, ok := d.sessions[addr]
-	if session != nil && session.IsClosed() {
-		delete(d.sessions, addr) // session is dead
-		ok = false
+	e, err := d.pool.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		return e.Conn, nil
 	}
-	if !ok {
-		var options dialer.DialOptions
-		for _, opt := range opts {
-			opt(&options)
-		}
 
-		conn, err = options.NetDialer.Dial(ctx, "tcp", addr)
-		if err != nil {
-			return
-		}
+	var options dialer.DialOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-		session = &muxSession{conn: conn}
-		d.sessions[addr] = session
+	conn, err = options.NetDialer.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return
 	}
+	d.pool.Add(addr, conn)
 
-	return session.conn, err
+	return conn, nil
 }
 
 // Handshake implements dialer.Handshaker
@@ -90,67 +89,44 @@ func (d *mtlsDialer) Handshake(ctx context.Context, conn net.Conn, options ...di
 	d.sessionMutex.Lock()
 	defer d.sessionMutex.Unlock()
 
-	if d.md.handshakeTimeout > 0 {
-		conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
-		defer conn.SetDeadline(time.Time{})
-	}
-
-	session, ok := d.sessions[opts.Addr]
-	if session != nil && session.conn != conn {
+	e := d.pool.Find(opts.Addr, conn)
+	if e == nil {
 		conn.Close()
 		return nil, errors.New("mtls: unrecognized connection")
 	}
 
-	if !ok || session.session == nil {
+	if e.Session == nil {
+		if d.md.handshakeTimeout > 0 {
+			conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
+		}
 		s, err := d.initSession(ctx, conn)
 		if err != nil {
 			d.logger.Error(err)
 			conn.Close()
-			delete(d.sessions, opts.Addr)
+			d.pool.Remove(opts.Addr, e)
 			return nil, err
 		}
-		session = s
-		d.sessions[opts.Addr] = session
+		if d.md.handshakeTimeout > 0 {
+			conn.SetDeadline(time.Time{})
+		}
+		d.pool.SetSession(e, s)
 	}
-	cc, err := session.GetConn()
+	cc, err := e.Session.GetConn()
 	if err != nil {
-		session.Close()
-		delete(d.sessions, opts.Addr)
+		e.Session.Close()
+		d.pool.Remove(opts.Addr, e)
 		return nil, err
 	}
 
 	return cc, nil
 }
 
-func (d *mtlsDialer) initSession(ctx context.Context, conn net.Conn) (*muxSession, error) {
+func (d *mtlsDialer) initSession(ctx context.Context, conn net.Conn) (*mux.Session, error) {
 	tlsConn := tls.Client(conn, d.options.TLSConfig)
 	if err := tlsConn.HandshakeContext(ctx); err != nil {
 		return nil, err
 	}
-	conn = tlsConn
 
 	// stream multiplex
-	smuxConfig := smux.DefaultConfig()
-	smuxConfig.KeepAliveDisabled = d.md.muxKeepAliveDisabled
-	if d.md.muxKeepAliveInterval > 0 {
-		smuxConfig.KeepAliveInterval = d.md.muxKeepAliveInterval
-	}
-	if d.md.muxKeepAliveTimeout > 0 {
-		smuxConfig.KeepAliveTimeout = d.md.muxKeepAliveTimeout
-	}
-	if d.md.muxMaxFrameSize > 0 {
-		smuxConfig.MaxFrameSize = d.md.muxMaxFrameSize
-	}
-	if d.md.muxMaxReceiveBuffer > 0 {
-		smuxConfig.MaxReceiveBuffer = d.md.muxMaxReceiveBuffer
-	}
-	if d.md.muxMaxStreamBuffer > 0 {
-		smuxConfig.MaxStreamBuffer = d.md.muxMaxStreamBuffer
-	}
-
-	session, err := smux.Client(conn, smuxConfig)
-	if err != nil {
-		return nil, err
-	}
-	return &muxSession{conn: conn, session: session}, nil
+	return mux.ClientSession(tlsConn, d.md.muxCfg)
 }
@@ -11,9 +11,10 @@ import (
 	"github.com/go-gost/core/dialer"
 	md "github.com/go-gost/core/metadata"
 	"github.com/gorilla/websocket"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	ws_util "github.com/hxdcloud/gost-x/internal/util/ws"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/xtaci/smux"
 )
 
 func init() {
@@ -22,7 +23,7 @@ func init() {
 }
 
 type mwsDialer struct {
-	sessions     map[string]*muxSession
+	pool         *mux.Pool
 	sessionMutex sync.Mutex
 	tlsEnabled   bool
 	md           metadata
@@ -36,8 +37,7 @@ func NewDialer(opts ...dialer.Option) dialer.Dialer {
 	}
 
 	return &mwsDialer{
-		sessions: make(map[string]*muxSession),
-		options:  options,
+		options: options,
 	}
 }
 
@@ -49,7 +49,6 @@ func NewTLSDialer(opts ...dialer.Option) dialer.Dialer {
 
 	return &mwsDialer{
 		tlsEnabled: true,
-		sessions:   make(map[string]*muxSession),
 		options:    options,
 	}
 }
@@ -58,6 +57,7 @@ func (d *mwsDialer) Init(md md.Metadata) (err error) {
 		return
 	}
 
+	d.pool = mux.NewPool(d.md.muxMaxSessions, d.md.muxMaxStreams)
 	return nil
 }
 
@@ -70,27 +70,26 @@ func (d *mwsDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 	d.sessionMutex.Lock()
 	defer d.sessionMutex.Unlock()
 
-	session, ok := d.sessions[addr]
-	if session != nil && session.IsClosed() {
-		delete(d.sessions, addr) // session is dead
-		ok = false
+	e, err := d.pool.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		return e.Conn, nil
 	}
-	if !ok {
-		var options dialer.DialOptions
-		for _, opt := range opts {
-			opt(&options)
-		}
 
-		conn, err = options.NetDialer.Dial(ctx, "tcp", addr)
-		if err != nil {
-			return
-		}
+	var options dialer.DialOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-		session = &muxSession{conn: conn}
-		d.sessions[addr] = session
+	conn, err = options.NetDialer.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return
 	}
+	d.pool.Add(addr, conn)
 
-	return session.conn, err
+	return conn, nil
 }
 
 // Handshake implements dialer.Handshaker
@@ -103,13 +102,13 @@ func (d *mwsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dia
 	d.sessionMutex.Lock()
 	defer d.sessionMutex.Unlock()
 
-	session, ok := d.sessions[opts.Addr]
-	if session != nil && session.conn != conn {
+	e := d.pool.Find(opts.Addr, conn)
+	if e == nil {
 		conn.Close()
-		return nil, errors.New("mtls: unrecognized connection")
+		return nil, errors.New("mws: unrecognized connection")
 	}
 
-	if !ok || session.session == nil {
+	if e.Session == nil {
 		host := d.md.host
 		if host == "" {
 			host = opts.Addr
@@ -118,23 +117,22 @@ func (d *mwsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dia
 		if err != nil {
 			d.options.Logger.Error(err)
 			conn.Close()
-			delete(d.sessions, opts.Addr)
+			d.pool.Remove(opts.Addr, e)
 			return nil, err
 		}
-		session = s
-		d.sessions[opts.Addr] = session
+		d.pool.SetSession(e, s)
 	}
-	cc, err := session.GetConn()
+	cc, err := e.Session.GetConn()
 	if err != nil {
-		session.Close()
-		delete(d.sessions, opts.Addr)
+		e.Session.Close()
+		d.pool.Remove(opts.Addr, e)
 		return nil, err
 	}
 
 	return cc, nil
 }
 
-func (d *mwsDialer) initSession(ctx context.Context, host string, conn net.Conn) (*muxSession, error) {
+func (d *mwsDialer) initSession(ctx context.Context, host string, conn net.Conn) (*mux.Session, error) {
 	dialer := websocket.Dialer{
 		HandshakeTimeout:  d.md.handshakeTimeout,
 		ReadBufferSize:    d.md.readBufferSize,
@@ -149,6 +147,11 @@ func (d *mwsDialer) initSession(ctx context.Context, host string, conn net.Conn)
 	if d.tlsEnabled {
 		url.Scheme = "wss"
 		dialer.TLSClientConfig = d.options.TLSConfig
+		if d.md.clientHello != nil {
+			dialer.NetDialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return d.md.clientHello.Client(ctx, conn, tls_util.ServerNameConfig(d.options.TLSConfig, addr), "http/1.1")
+			}
+		}
 	}
 
 	if d.md.handshakeTimeout > 0 {
@@ -177,29 +180,7 @@ func (d *mwsDialer) initSession(ctx context.Context, host string, conn net.Conn)
 	}
 
 	// stream multiplex
-	smuxConfig := smux.DefaultConfig()
-	smuxConfig.KeepAliveDisabled = d.md.muxKeepAliveDisabled
-	if d.md.muxKeepAliveInterval > 0 {
-		smuxConfig.KeepAliveInterval = d.md.muxKeepAliveInterval
-	}
-	if d.md.muxKeepAliveTimeout > 0 {
-		smuxConfig.KeepAliveTimeout = d.md.muxKeepAliveTimeout
-	}
-	if d.md.muxMaxFrameSize > 0 {
-		smuxConfig.MaxFrameSize = d.md.muxMaxFrameSize
-	}
-	if d.md.muxMaxReceiveBuffer > 0 {
-		smuxConfig.MaxReceiveBuffer = d.md.muxMaxReceiveBuffer
-	}
-	if d.md.muxMaxStreamBuffer > 0 {
-		smuxConfig.MaxStreamBuffer = d.md.muxMaxStreamBuffer
-	}
-
-	session, err := smux.Client(cc, smuxConfig)
-	if err != nil {
-		return nil, err
-	}
-	return &muxSession{conn: cc, session: session}, nil
+	return mux.ClientSession(cc, d.md.muxCfg)
 }
 
 func (d *mwsDialer) keepAlive(conn ws_util.WebsocketConn) {
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
@@ -22,15 +24,14 @@ type metadata struct {
 	writeBufferSize   int
 	enableCompression bool
 
-	muxKeepAliveDisabled bool
-	muxKeepAliveInterval time.Duration
-	muxKeepAliveTimeout  time.Duration
-	muxMaxFrameSize      int
-	muxMaxReceiveBuffer  int
-	muxMaxStreamBuffer   int
+	muxCfg         *mux.Config
+	muxMaxSessions int
+	muxMaxStreams  int
 
 	header    http.Header
 	keepAlive time.Duration
+
+	clientHello *tls_util.ClientHello
 }
 
 func (d *mwsDialer) parseMetadata(md mdata.Metadata) (err error) {
@@ -47,12 +48,8 @@ func (d *mwsDialer) parseMetadata(md mdata.Metadata) (err error) {
 		header    = "header"
 		keepAlive = "keepAlive"
 
-		muxKeepAliveDisabled = "muxKeepAliveDisabled"
-		muxKeepAliveInterval = "muxKeepAliveInterval"
-		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
-		muxMaxFrameSize      = "muxMaxFrameSize"
-		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
-		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
+		muxMaxSessions = "muxMaxSessions"
+		muxMaxStreams  = "muxMaxStreams"
 	)
 
 	d.md.host = mdx.GetString(md, host)
@@ -62,12 +59,9 @@ func (d *mwsDialer) parseMetadata(md mdata.Metadata) (err error) {
 		d.md.path = defaultPath
 	}
 
-	d.md.muxKeepAliveDisabled = mdx.GetBool(md, muxKeepAliveDisabled)
-	d.md.muxKeepAliveInterval = mdx.GetDuration(md, muxKeepAliveInterval)
-	d.md.muxKeepAliveTimeout = mdx.GetDuration(md, muxKeepAliveTimeout)
-	d.md.muxMaxFrameSize = mdx.GetInt(md, muxMaxFrameSize)
-	d.md.muxMaxReceiveBuffer = mdx.GetInt(md, muxMaxReceiveBuffer)
-	d.md.muxMaxStreamBuffer = mdx.GetInt(md, muxMaxStreamBuffer)
+	d.md.muxCfg = mux.ConfigFromMetadata(md)
+	d.md.muxMaxSessions = mdx.GetInt(md, muxMaxSessions)
+	d.md.muxMaxStreams = mdx.GetInt(md, muxMaxStreams)
 
 	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
 	d.md.readHeaderTimeout = mdx.GetDuration(md, readHeaderTimeout)
@@ -84,5 +78,7 @@ func (d *mwsDialer) parseMetadata(md mdata.Metadata) (err error) {
 	}
 	d.md.keepAlive = mdx.GetDuration(md, keepAlive)
 
+	d.md.clientHello, err = tls_util.ClientHelloFromMetadata(md)
+
 	return
 }
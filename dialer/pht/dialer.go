@@ -11,6 +11,7 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	pht_util "github.com/hxdcloud/gost-x/internal/util/pht"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -93,6 +94,20 @@ func (d *phtDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 		}
 		if d.tlsEnabled {
 			tr.TLSClientConfig = d.options.TLSConfig
+			if d.md.clientHello != nil {
+				// the connection is made to the node address, the host of the URL (adr) is the server name.
+				tr.DialTLSContext = func(ctx context.Context, network, adr string) (net.Conn, error) {
+					serverName, _, err := net.SplitHostPort(adr)
+					if err != nil {
+						return nil, err
+					}
+					conn, err := options.NetDialer.Dial(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					return d.md.clientHello.Client(ctx, conn, tls_util.ServerNameConfig(d.options.TLSConfig, serverName), "http/1.1")
+				}
+			}
 		}
 
 		client = &pht_util.Client{
@@ -0,0 +1,69 @@
+package pht
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	xdialer "github.com/go-gost/core/common/net/dialer"
+	"github.com/go-gost/core/dialer"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+func TestDialServerName(t *testing.T) {
+	names := make(chan string, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(chi *tls.ClientHelloInfo) (*tls.Config, error) {
+			names <- chi.ServerName
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	tests := []struct {
+		name string
+		host string
+		addr string
+		want string
+	}{
+		{name: "host", host: "example.com", addr: "127.0.0.1:" + port, want: "example.com"},
+		{name: "host with port", host: "example.com:443", addr: "127.0.0.1:" + port, want: "example.com"},
+		{name: "node address", addr: "localhost:" + port, want: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewTLSDialer(
+				dialer.TLSConfigOption(&tls.Config{InsecureSkipVerify: true}),
+				dialer.LoggerOption(xlogger.Nop()),
+			)
+			if err := d.Init(mdx.NewMetadata(map[string]any{
+				"host":        tt.host,
+				"fingerprint": "chrome",
+			})); err != nil {
+				t.Fatal(err)
+			}
+
+			// the server does not authorize, only the TLS handshake matters.
+			d.Dial(context.Background(), tt.addr,
+				dialer.NetDialerDialOption(&xdialer.NetDialer{Logger: xlogger.Nop()}))
+
+			select {
+			case name := <-names:
+				if name != tt.want {
+					t.Errorf("got %q, want %q", name, tt.want)
+				}
+			default:
+				t.Fatal("no TLS handshake")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
@@ -25,6 +26,7 @@ type metadata struct {
 	pushPath      string
 	pullPath      string
 	host          string
+	clientHello   *tls_util.ClientHello
 }
 
 func (d *phtDialer) parseMetadata(md mdata.Metadata) (err error) {
@@ -49,5 +51,7 @@ func (d *phtDialer) parseMetadata(md mdata.Metadata) (err error) {
 	}
 
 	d.md.host = mdx.GetString(md, host)
+	d.md.clientHello, err = tls_util.ClientHelloFromMetadata(md)
+
 	return
 }
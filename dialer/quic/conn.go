@@ -3,12 +3,36 @@ package quic
 import (
 	"context"
 	"net"
+	"sync"
+	"time"
 
-	"github.com/lucas-clemente/quic-go"
+	"github.com/go-gost/core/logger"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
+	"github.com/quic-go/quic-go"
 )
 
 type quicSession struct {
-	session quic.Session
+	session *quic.Conn
+	dgram   *quic_util.DatagramSession
+	// transports[0] is the transport the session is dialed on,
+	// transports[1] is the alternate one for connection migration.
+	transports [2]*quic.Transport
+	paths      [2]*quic.Path
+	mu         sync.Mutex
+	// the addresses of the session are cached,
+	// quic.Conn.RemoteAddr is not safe to call while switching the path.
+	laddr net.Addr
+	raddr net.Addr
+}
+
+func newQUICSession(session *quic.Conn, tr *quic.Transport) *quicSession {
+	return &quicSession{
+		session:    session,
+		dgram:      quic_util.NewDatagramSession(session),
+		transports: [2]*quic.Transport{tr},
+		laddr:      session.LocalAddr(),
+		raddr:      session.RemoteAddr(),
+	}
 }
 
 func (session *quicSession) GetConn() (*quicConn, error) {
@@ -17,20 +41,90 @@ func (session *quicSession) GetConn() (*quicConn, error) {
 		return nil, err
 	}
 	return &quicConn{
-		Stream: stream,
-		laddr:  session.session.LocalAddr(),
-		raddr:  session.session.RemoteAddr(),
+		Stream:  stream,
+		laddr:   session.laddr,
+		raddr:   session.raddr,
+		session: session,
 	}, nil
 }
 
+// migrate switches the session between the two transports every interval,
+// the alternate transport is created by newPacketConn on the first migration.
+// The transports can not be closed before the session, as they terminate the session,
+// so only two of them are used.
+func (session *quicSession) migrate(interval time.Duration, newPacketConn func(ctx context.Context) (net.PacketConn, error), log logger.Logger) {
+	ctx := session.session.Context()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i := 1; ; i = 1 - i {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := session.switchPath(ctx, i, newPacketConn); err != nil {
+			log.Warnf("migrate %s: %v", session.raddr, err)
+			// stay on the current path and try again later.
+			i = 1 - i
+			continue
+		}
+		log.Debugf("migrate %s: %s", session.raddr, session.transports[i].Conn.LocalAddr())
+	}
+}
+
+func (session *quicSession) switchPath(ctx context.Context, i int, newPacketConn func(ctx context.Context) (net.PacketConn, error)) error {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.paths[i] == nil {
+		if session.transports[i] == nil {
+			pc, err := newPacketConn(ctx)
+			if err != nil {
+				return err
+			}
+			session.transports[i] = &quic.Transport{Conn: pc}
+		}
+
+		path, err := session.session.AddPath(session.transports[i])
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := path.Probe(ctx); err != nil {
+			path.Close()
+			return err
+		}
+		session.paths[i] = path
+	}
+
+	return session.paths[i].Switch()
+}
+
 func (session *quicSession) Close() error {
-	return session.session.CloseWithError(quic.ApplicationErrorCode(0), "closed")
+	err := session.session.CloseWithError(quic.ApplicationErrorCode(0), "closed")
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	for _, tr := range session.transports {
+		if tr != nil {
+			tr.Close()
+			tr.Conn.Close()
+		}
+	}
+	return err
 }
 
 type quicConn struct {
-	quic.Stream
-	laddr net.Addr
-	raddr net.Addr
+	*quic.Stream
+	laddr   net.Addr
+	raddr   net.Addr
+	session *quicSession
 }
 
 func (c *quicConn) LocalAddr() net.Addr {
@@ -40,3 +134,14 @@ func (c *quicConn) LocalAddr() net.Addr {
 func (c *quicConn) RemoteAddr() net.Addr {
 	return c.raddr
 }
+
+// Datagrams implements udp.Datagrammer interface.
+func (c *quicConn) Datagrams() net.PacketConn {
+	// the DATAGRAM frames are negotiated in the handshake, which may be incomplete in 0-RTT.
+	select {
+	case <-c.session.session.HandshakeComplete():
+	case <-c.Context().Done():
+		return nil
+	}
+	return c.session.dgram.Conn(c.Stream)
+}
@@ -2,6 +2,7 @@ package quic
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"sync"
@@ -11,7 +12,7 @@ import (
 	md "github.com/go-gost/core/metadata"
 	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/lucas-clemente/quic-go"
+	"github.com/quic-go/quic-go"
 )
 
 func init() {
@@ -67,19 +68,10 @@ func (d *quicDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 			opt(options)
 		}
 
-		c, err := options.NetDialer.Dial(ctx, "udp", "")
+		pc, err := d.packetConn(ctx, options)
 		if err != nil {
 			return nil, err
 		}
-		pc, ok := c.(net.PacketConn)
-		if !ok {
-			c.Close()
-			return nil, errors.New("quic: wrong connection type")
-		}
-
-		if d.md.cipherKey != nil {
-			pc = quic_util.CipherPacketConn(pc, d.md.cipherKey)
-		}
 
 		session, err = d.initSession(ctx, udpAddr, pc)
 		if err != nil {
@@ -88,6 +80,12 @@ func (d *quicDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 			return nil, err
 		}
 
+		if d.md.migrateInterval > 0 {
+			go session.migrate(d.md.migrateInterval, func(ctx context.Context) (net.PacketConn, error) {
+				return d.packetConn(ctx, options)
+			}, d.logger)
+		}
+
 		d.sessions[addr] = session
 	}
 
@@ -101,25 +99,43 @@ func (d *quicDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 	return
 }
 
-func (d *quicDialer) initSession(ctx context.Context, addr net.Addr, conn net.PacketConn) (*quicSession, error) {
-	quicConfig := &quic.Config{
-		KeepAlive:            d.md.keepAlive,
-		HandshakeIdleTimeout: d.md.handshakeTimeout,
-		MaxIdleTimeout:       d.md.maxIdleTimeout,
-		Versions: []quic.VersionNumber{
-			quic.Version1,
-			quic.VersionDraft29,
-		},
+func (d *quicDialer) packetConn(ctx context.Context, options *dialer.DialOptions) (net.PacketConn, error) {
+	c, err := options.NetDialer.Dial(ctx, "udp", "")
+	if err != nil {
+		return nil, err
 	}
+	pc, ok := c.(net.PacketConn)
+	if !ok {
+		c.Close()
+		return nil, errors.New("quic: wrong connection type")
+	}
+
+	if d.md.cipherKey != nil {
+		pc = quic_util.CipherPacketConn(pc, d.md.cipherKey)
+	}
+	return pc, nil
+}
 
+func (d *quicDialer) initSession(ctx context.Context, addr net.Addr, conn net.PacketConn) (*quicSession, error) {
 	tlsCfg := d.options.TLSConfig
 	tlsCfg.NextProtos = []string{"http/3", "quic/v1"}
 
-	session, err := quic.DialContext(ctx, conn, addr, addr.String(), tlsCfg, quicConfig)
+	tr := &quic.Transport{Conn: conn}
+
+	dial := tr.Dial
+	if d.md.zeroRTT {
+		// 0-RTT needs the session ticket of the previous connection.
+		if tlsCfg.ClientSessionCache == nil {
+			tlsCfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+		dial = tr.DialEarly
+	}
+	session, err := dial(ctx, addr, tlsCfg, d.md.config)
 	if err != nil {
+		tr.Close()
 		return nil, err
 	}
-	return &quicSession{session: session}, nil
+	return newQUICSession(session, tr), nil
 }
 
 // Multiplex implements dialer.Multiplexer interface.
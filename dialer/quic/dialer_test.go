@@ -0,0 +1,133 @@
+package quic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/net/dialer"
+	core_dialer "github.com/go-gost/core/dialer"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/quic-go/quic-go"
+)
+
+// echoServer starts a QUIC server echoing the streams back.
+func echoServer(t *testing.T) net.Addr {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		NextProtos:   []string{"quic/v1"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go func() {
+						defer stream.Close()
+						io.Copy(stream, stream)
+					}()
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr()
+}
+
+func TestDialerMigrate(t *testing.T) {
+	addr := echoServer(t)
+
+	tests := []struct {
+		name     string
+		md       map[string]any
+		migrated bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "migrateInterval",
+			md:       map[string]any{"migrateInterval": "50ms"},
+			migrated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDialer(
+				core_dialer.TLSConfigOption(&tls.Config{InsecureSkipVerify: true}),
+				core_dialer.LoggerOption(xlogger.Nop()),
+			).(*quicDialer)
+			if err := d.Init(mdx.NewMetadata(tt.md)); err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := d.Dial(context.Background(), addr.String(), core_dialer.NetDialerDialOption(&dialer.NetDialer{Logger: xlogger.Nop()}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			session := conn.(*quicConn).session
+			defer session.Close()
+			laddr := session.transports[0].Conn.LocalAddr().String()
+
+			buf := make([]byte, 4)
+			for i := 0; i < 5; i++ {
+				time.Sleep(100 * time.Millisecond)
+
+				conn.SetDeadline(time.Now().Add(3 * time.Second))
+				if _, err := conn.Write([]byte("ping")); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			session.mu.Lock()
+			migrated := session.transports[1] != nil &&
+				session.transports[1].Conn.LocalAddr().String() != laddr
+			session.mu.Unlock()
+			if migrated != tt.migrated {
+				t.Errorf("migrated: got %v, want %v", migrated, tt.migrated)
+			}
+		})
+	}
+}
@@ -4,35 +4,36 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
 	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/quic-go/quic-go"
 )
 
 type metadata struct {
-	keepAlive        bool
-	maxIdleTimeout   time.Duration
-	handshakeTimeout time.Duration
+	config  *quic.Config
+	zeroRTT bool
+	// the interval of switching the session between two local sockets.
+	migrateInterval time.Duration
 
 	cipherKey []byte
 }
 
 func (d *quicDialer) parseMetadata(md mdata.Metadata) (err error) {
+	d.md.config = quic_util.ConfigFromMetadata(md)
+
 	const (
-		keepAlive        = "keepAlive"
-		handshakeTimeout = "handshakeTimeout"
-		maxIdleTimeout   = "maxIdleTimeout"
+		zeroRTT         = "zeroRTT"
+		migrateInterval = "migrateInterval"
 
 		cipherKey = "cipherKey"
 	)
 
-	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
-
 	if key := mdx.GetString(md, cipherKey); key != "" {
 		d.md.cipherKey = []byte(key)
 	}
 
-	d.md.keepAlive = mdx.GetBool(md, keepAlive)
-	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
-	d.md.maxIdleTimeout = mdx.GetDuration(md, maxIdleTimeout)
+	d.md.zeroRTT = mdx.GetBool(md, zeroRTT)
+	d.md.migrateInterval = mdx.GetDuration(md, migrateInterval)
 
 	return
 }
@@ -2,16 +2,39 @@ package ssh
 
 import (
 	"net"
+	"sync"
+	"sync/atomic"
 
+	ssh_util "github.com/hxdcloud/gost-x/internal/util/ssh"
 	"golang.org/x/crypto/ssh"
 )
 
 type sshSession struct {
-	addr   string
-	conn   net.Conn
-	client *ssh.Client
-	closed chan struct{}
-	dead   chan struct{}
+	addr    string
+	conn    net.Conn
+	client  *ssh.Client
+	closed  chan struct{}
+	dead    chan struct{}
+	streams int32
+}
+
+// GetConn opens a tunnel channel of the session.
+func (s *sshSession) GetConn() (net.Conn, error) {
+	channel, reqs, err := s.client.OpenChannel(ssh_util.GostSSHTunnelRequest, nil)
+	if err != nil {
+		return nil, err
+	}
+	go ssh.DiscardRequests(reqs)
+
+	atomic.AddInt32(&s.streams, 1)
+	return &streamConn{
+		Conn:    ssh_util.NewConn(s.conn, channel),
+		session: s,
+	}, nil
+}
+
+func (s *sshSession) Close() error {
+	return s.client.Close()
 }
 
 func (s *sshSession) IsClosed() bool {
@@ -25,7 +48,25 @@ func (s *sshSession) IsClosed() bool {
 	return false
 }
 
+// NumStreams returns the number of the opened channels.
+func (s *sshSession) NumStreams() int {
+	return int(atomic.LoadInt32(&s.streams))
+}
+
 func (s *sshSession) wait() error {
 	defer close(s.closed)
 	return s.client.Wait()
 }
+
+type streamConn struct {
+	net.Conn
+	session *sshSession
+	once    sync.Once
+}
+
+func (c *streamConn) Close() error {
+	c.once.Do(func() {
+		atomic.AddInt32(&c.session.streams, -1)
+	})
+	return c.Conn.Close()
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	ssh_util "github.com/hxdcloud/gost-x/internal/util/ssh"
 	"github.com/hxdcloud/gost-x/registry"
 	"golang.org/x/crypto/ssh"
@@ -20,7 +21,7 @@ func init() {
 }
 
 type sshDialer struct {
-	sessions     map[string]*sshSession
+	pool         *mux.Pool
 	sessionMutex sync.Mutex
 	logger       logger.Logger
 	md           metadata
@@ -34,9 +35,8 @@ func NewDialer(opts ...dialer.Option) dialer.Dialer {
 	}
 
 	return &sshDialer{
-		sessions: make(map[string]*sshSession),
-		logger:   options.Logger,
-		options:  options,
+		logger:  options.Logger,
+		options: options,
 	}
 }
 
@@ -45,6 +45,7 @@ func (d *sshDialer) Init(md md.Metadata) (err error) {
 		return
 	}
 
+	d.pool = mux.NewPool(d.md.muxMaxSessions, d.md.muxMaxStreams)
 	return nil
 }
 
@@ -57,30 +58,26 @@ func (d *sshDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 	d.sessionMutex.Lock()
 	defer d.sessionMutex.Unlock()
 
-	session, ok := d.sessions[addr]
-	if session != nil && session.IsClosed() {
-		delete(d.sessions, addr) // session is dead
-		ok = false
+	e, err := d.pool.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		return e.Conn, nil
 	}
-	if !ok {
-		var options dialer.DialOptions
-		for _, opt := range opts {
-			opt(&options)
-		}
 
-		conn, err = options.NetDialer.Dial(ctx, "tcp", addr)
-		if err != nil {
-			return
-		}
+	var options dialer.DialOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-		session = &sshSession{
-			addr: addr,
-			conn: conn,
-		}
-		d.sessions[addr] = session
+	conn, err = options.NetDialer.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return
 	}
+	d.pool.Add(addr, conn)
 
-	return session.conn, err
+	return conn, nil
 }
 
 // Handshake implements dialer.Handshaker
@@ -93,47 +90,40 @@ func (d *sshDialer) Handshake(ctx context.Context, conn net.Conn, options ...dia
 	d.sessionMutex.Lock()
 	defer d.sessionMutex.Unlock()
 
-	if d.md.handshakeTimeout > 0 {
-		conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
-		defer conn.SetDeadline(time.Time{})
-	}
-
-	session, ok := d.sessions[opts.Addr]
-	if session != nil && session.conn != conn {
+	e := d.pool.Find(opts.Addr, conn)
+	if e == nil {
 		err := errors.New("ssh: unrecognized connection")
 		d.logger.Error(err)
 		conn.Close()
-		delete(d.sessions, opts.Addr)
 		return nil, err
 	}
 
-	if !ok || session.client == nil {
+	if e.Session == nil {
+		if d.md.handshakeTimeout > 0 {
+			conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
+		}
 		s, err := d.initSession(ctx, opts.Addr, conn)
 		if err != nil {
 			d.logger.Error(err)
 			conn.Close()
-			delete(d.sessions, opts.Addr)
+			d.pool.Remove(opts.Addr, e)
 			return nil, err
 		}
-		session = s
+		if d.md.handshakeTimeout > 0 {
+			conn.SetDeadline(time.Time{})
+		}
 		go func() {
 			s.wait()
 			d.logger.Debug("session closed")
 		}()
-		d.sessions[opts.Addr] = session
+		d.pool.SetSession(e, s)
 	}
-	if session.IsClosed() {
-		delete(d.sessions, opts.Addr)
+	if e.Session.IsClosed() {
+		d.pool.Remove(opts.Addr, e)
 		return nil, ssh_util.ErrSessionDead
 	}
 
-	channel, reqs, err := session.client.OpenChannel(ssh_util.GostSSHTunnelRequest, nil)
-	if err != nil {
-		return nil, err
-	}
-	go ssh.DiscardRequests(reqs)
-
-	return ssh_util.NewConn(conn, channel), nil
+	return e.Session.GetConn()
 }
 
 func (d *sshDialer) initSession(ctx context.Context, addr string, conn net.Conn) (*sshSession, error) {
@@ -160,6 +150,7 @@ func (d *sshDialer) initSession(ctx context.Context, addr string, conn net.Conn)
 
 	return &sshSession{
 		conn:   conn,
+		addr:   addr,
 		client: ssh.NewClient(sshConn, chans, reqs),
 		closed: make(chan struct{}),
 		dead:   make(chan struct{}),
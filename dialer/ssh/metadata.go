@@ -12,6 +12,8 @@ import (
 type metadata struct {
 	handshakeTimeout time.Duration
 	signer           ssh.Signer
+	muxMaxSessions   int
+	muxMaxStreams    int
 }
 
 func (d *sshDialer) parseMetadata(md mdata.Metadata) (err error) {
@@ -19,6 +21,8 @@ func (d *sshDialer) parseMetadata(md mdata.Metadata) (err error) {
 		handshakeTimeout = "handshakeTimeout"
 		privateKeyFile   = "privateKeyFile"
 		passphrase       = "passphrase"
+		muxMaxSessions   = "muxMaxSessions"
+		muxMaxStreams    = "muxMaxStreams"
 	)
 
 	if key := mdx.GetString(md, privateKeyFile); key != "" {
@@ -39,6 +43,8 @@ func (d *sshDialer) parseMetadata(md mdata.Metadata) (err error) {
 	}
 
 	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
+	d.md.muxMaxSessions = mdx.GetInt(md, muxMaxSessions)
+	d.md.muxMaxStreams = mdx.GetInt(md, muxMaxStreams)
 
 	return
 }
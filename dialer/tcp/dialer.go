@@ -2,11 +2,14 @@ package tcp
 
 import (
 	"context"
+	"errors"
 	"net"
+	"sync"
 
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -15,8 +18,10 @@ func init() {
 }
 
 type tcpDialer struct {
-	md     metadata
-	logger logger.Logger
+	pool         *mux.Pool
+	sessionMutex sync.Mutex
+	md           metadata
+	logger       logger.Logger
 }
 
 func NewDialer(opts ...dialer.Option) dialer.Dialer {
@@ -31,10 +36,35 @@ func NewDialer(opts ...dialer.Option) dialer.Dialer {
 }
 
 func (d *tcpDialer) Init(md md.Metadata) (err error) {
-	return d.parseMetadata(md)
+	if err = d.parseMetadata(md); err != nil {
+		return
+	}
+
+	if d.md.mux {
+		d.pool = mux.NewPool(d.md.muxMaxSessions, d.md.muxMaxStreams)
+	}
+	return
+}
+
+// Multiplex implements dialer.Multiplexer interface.
+func (d *tcpDialer) Multiplex() bool {
+	return d.md.mux
 }
 
 func (d *tcpDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	if d.md.mux {
+		d.sessionMutex.Lock()
+		defer d.sessionMutex.Unlock()
+
+		e, err := d.pool.Get(addr)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			return e.Conn, nil
+		}
+	}
+
 	var options dialer.DialOptions
 	for _, opt := range opts {
 		opt(&options)
@@ -43,6 +73,50 @@ func (d *tcpDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 	conn, err := options.NetDialer.Dial(ctx, "tcp", addr)
 	if err != nil {
 		d.logger.Error(err)
+		return nil, err
+	}
+	if d.md.mux {
+		d.pool.Add(addr, conn)
+	}
+	return conn, nil
+}
+
+// Handshake implements dialer.Handshaker
+func (d *tcpDialer) Handshake(ctx context.Context, conn net.Conn, options ...dialer.HandshakeOption) (net.Conn, error) {
+	if !d.md.mux {
+		return conn, nil
+	}
+
+	opts := &dialer.HandshakeOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
+	e := d.pool.Find(opts.Addr, conn)
+	if e == nil {
+		conn.Close()
+		return nil, errors.New("tcp: unrecognized connection")
 	}
-	return conn, err
+
+	if e.Session == nil {
+		s, err := mux.ClientSession(conn, d.md.muxCfg)
+		if err != nil {
+			d.logger.Error(err)
+			conn.Close()
+			d.pool.Remove(opts.Addr, e)
+			return nil, err
+		}
+		d.pool.SetSession(e, s)
+	}
+	cc, err := e.Session.GetConn()
+	if err != nil {
+		e.Session.Close()
+		d.pool.Remove(opts.Addr, e)
+		return nil, err
+	}
+
+	return cc, nil
 }
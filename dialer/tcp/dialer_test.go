@@ -0,0 +1,109 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/go-gost/core/common/net/dialer"
+	core_dialer "github.com/go-gost/core/dialer"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+// serveMux accepts the mux sessions.
+func serveMux(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				s, err := mux.ServerSession(conn, &mux.Config{})
+				if err != nil {
+					conn.Close()
+					return
+				}
+				defer s.Close()
+				for {
+					if _, err := s.Accept(); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDialMuxConcurrent(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       map[string]any
+		dials    int
+		sessions int
+	}{
+		{name: "default", md: map[string]any{"mux": true}, dials: 16, sessions: 1},
+		{name: "max sessions", md: map[string]any{"mux": true, "muxMaxSessions": 2}, dials: 16, sessions: 2},
+		{name: "max streams", md: map[string]any{"mux": true, "muxMaxSessions": 4, "muxMaxStreams": 64}, dials: 16, sessions: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveMux(t)
+
+			d := NewDialer(core_dialer.LoggerOption(xlogger.Nop()))
+			if err := d.Init(mdx.NewMetadata(tt.md)); err != nil {
+				t.Fatal(err)
+			}
+			nd := core_dialer.NetDialerDialOption(&dialer.NetDialer{Logger: xlogger.Nop()})
+
+			// all the connections are dialed before any handshake,
+			// so the later dials see the pending sessions.
+			var cs []net.Conn
+			for i := 0; i < tt.dials; i++ {
+				conn, err := d.Dial(context.Background(), addr, nd)
+				if err != nil {
+					t.Fatalf("dial %d: %v", i, err)
+				}
+				cs = append(cs, conn)
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, len(cs))
+			for _, conn := range cs {
+				wg.Add(1)
+				go func(conn net.Conn) {
+					defer wg.Done()
+
+					cc, err := d.(core_dialer.Handshaker).Handshake(context.Background(), conn, core_dialer.AddrHandshakeOption(addr))
+					if err != nil {
+						errs <- err
+						return
+					}
+					cc.Close()
+				}(conn)
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Errorf("handshake: %v", err)
+			}
+			if n := d.(*tcpDialer).pool.Len(addr); n != tt.sessions {
+				t.Errorf("got %d sessions, want %d", n, tt.sessions)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package tcp
 import (
 	"time"
 
-	md "github.com/go-gost/core/metadata"
+	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 const (
@@ -16,8 +18,23 @@ const (
 
 type metadata struct {
 	dialTimeout time.Duration
+
+	mux            bool
+	muxCfg         *mux.Config
+	muxMaxSessions int
+	muxMaxStreams  int
 }
 
-func (d *tcpDialer) parseMetadata(md md.Metadata) (err error) {
+func (d *tcpDialer) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		enableMux      = "mux"
+		muxMaxSessions = "muxMaxSessions"
+		muxMaxStreams  = "muxMaxStreams"
+	)
+
+	d.md.mux = mdx.GetBool(md, enableMux)
+	d.md.muxCfg = mux.ConfigFromMetadata(md)
+	d.md.muxMaxSessions = mdx.GetInt(md, muxMaxSessions)
+	d.md.muxMaxStreams = mdx.GetInt(md, muxMaxStreams)
 	return
 }
@@ -3,12 +3,15 @@ package tls
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -17,9 +20,11 @@ func init() {
 }
 
 type tlsDialer struct {
-	md      metadata
-	logger  logger.Logger
-	options dialer.Options
+	pool         *mux.Pool
+	sessionMutex sync.Mutex
+	md           metadata
+	logger       logger.Logger
+	options      dialer.Options
 }
 
 func NewDialer(opts ...dialer.Option) dialer.Dialer {
@@ -35,10 +40,35 @@ func NewDialer(opts ...dialer.Option) dialer.Dialer {
 }
 
 func (d *tlsDialer) Init(md md.Metadata) (err error) {
-	return d.parseMetadata(md)
+	if err = d.parseMetadata(md); err != nil {
+		return
+	}
+
+	if d.md.mux {
+		d.pool = mux.NewPool(d.md.muxMaxSessions, d.md.muxMaxStreams)
+	}
+	return
+}
+
+// Multiplex implements dialer.Multiplexer interface.
+func (d *tlsDialer) Multiplex() bool {
+	return d.md.mux
 }
 
 func (d *tlsDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	if d.md.mux {
+		d.sessionMutex.Lock()
+		defer d.sessionMutex.Unlock()
+
+		e, err := d.pool.Get(addr)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			return e.Conn, nil
+		}
+	}
+
 	var options dialer.DialOptions
 	for _, opt := range opts {
 		opt(&options)
@@ -47,17 +77,74 @@ func (d *tlsDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 	conn, err := options.NetDialer.Dial(ctx, "tcp", addr)
 	if err != nil {
 		d.logger.Error(err)
+		return nil, err
 	}
-	return conn, err
+	if d.md.mux {
+		d.pool.Add(addr, conn)
+	}
+	return conn, nil
 }
 
 // Handshake implements dialer.Handshaker
 func (d *tlsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dialer.HandshakeOption) (net.Conn, error) {
+	if !d.md.mux {
+		return d.handshake(ctx, conn)
+	}
+
+	opts := &dialer.HandshakeOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
+	e := d.pool.Find(opts.Addr, conn)
+	if e == nil {
+		conn.Close()
+		return nil, errors.New("tls: unrecognized connection")
+	}
+
+	if e.Session == nil {
+		s, err := d.initSession(ctx, conn)
+		if err != nil {
+			d.logger.Error(err)
+			conn.Close()
+			d.pool.Remove(opts.Addr, e)
+			return nil, err
+		}
+		d.pool.SetSession(e, s)
+	}
+	cc, err := e.Session.GetConn()
+	if err != nil {
+		e.Session.Close()
+		d.pool.Remove(opts.Addr, e)
+		return nil, err
+	}
+
+	return cc, nil
+}
+
+func (d *tlsDialer) initSession(ctx context.Context, conn net.Conn) (*mux.Session, error) {
+	tlsConn, err := d.handshake(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// stream multiplex
+	return mux.ClientSession(tlsConn, d.md.muxCfg)
+}
+
+func (d *tlsDialer) handshake(ctx context.Context, conn net.Conn) (net.Conn, error) {
 	if d.md.handshakeTimeout > 0 {
 		conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
 		defer conn.SetDeadline(time.Time{})
 	}
 
+	if d.md.clientHello != nil {
+		return d.md.clientHello.Client(ctx, conn, d.options.TLSConfig)
+	}
+
 	tlsConn := tls.Client(conn, d.options.TLSConfig)
 	if err := tlsConn.HandshakeContext(ctx); err != nil {
 		conn.Close()
@@ -4,19 +4,37 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
 	handshakeTimeout time.Duration
+	clientHello      *tls_util.ClientHello
+
+	mux            bool
+	muxCfg         *mux.Config
+	muxMaxSessions int
+	muxMaxStreams  int
 }
 
 func (d *tlsDialer) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		handshakeTimeout = "handshakeTimeout"
+
+		enableMux      = "mux"
+		muxMaxSessions = "muxMaxSessions"
+		muxMaxStreams  = "muxMaxStreams"
 	)
 
 	d.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
+	d.md.mux = mdx.GetBool(md, enableMux)
+	d.md.muxCfg = mux.ConfigFromMetadata(md)
+	d.md.muxMaxSessions = mdx.GetInt(md, muxMaxSessions)
+	d.md.muxMaxStreams = mdx.GetInt(md, muxMaxStreams)
+
+	d.md.clientHello, err = tls_util.ClientHelloFromMetadata(md)
 
 	return
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/go-gost/core/dialer"
 	md "github.com/go-gost/core/metadata"
 	"github.com/gorilla/websocket"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	ws_util "github.com/hxdcloud/gost-x/internal/util/ws"
 	"github.com/hxdcloud/gost-x/registry"
 )
@@ -95,6 +96,11 @@ func (d *wsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dial
 	if d.tlsEnabled {
 		url.Scheme = "wss"
 		dialer.TLSClientConfig = d.options.TLSConfig
+		if d.md.clientHello != nil {
+			dialer.NetDialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return d.md.clientHello.Client(ctx, conn, tls_util.ServerNameConfig(d.options.TLSConfig, addr), "http/1.1")
+			}
+		}
 	}
 
 	c, resp, err := dialer.DialContext(ctx, url.String(), d.md.header)
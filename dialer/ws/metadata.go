@@ -5,6 +5,7 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
@@ -24,6 +25,8 @@ type metadata struct {
 
 	header    http.Header
 	keepAlive time.Duration
+
+	clientHello *tls_util.ClientHello
 }
 
 func (d *wsDialer) parseMetadata(md mdata.Metadata) (err error) {
@@ -63,5 +66,7 @@ func (d *wsDialer) parseMetadata(md mdata.Metadata) (err error) {
 	}
 	d.md.keepAlive = mdx.GetDuration(md, keepAlive)
 
+	d.md.clientHello, err = tls_util.ClientHelloFromMetadata(md)
+
 	return
 }
@@ -0,0 +1,106 @@
+package acme
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/handler"
+	md "github.com/go-gost/core/metadata"
+	acme_util "github.com/hxdcloud/gost-x/internal/util/acme"
+	"github.com/hxdcloud/gost-x/registry"
+)
+
+func init() {
+	registry.HandlerRegistry().Register("acme", NewHandler)
+}
+
+// acmeHandler answers the ACME HTTP-01 challenges of the certificates managed by the ACME managers,
+// it should be served on port 80 of the domains.
+type acmeHandler struct {
+	md      metadata
+	options handler.Options
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &acmeHandler{
+		options: options,
+	}
+}
+
+func (h *acmeHandler) Init(md md.Metadata) (err error) {
+	return h.parseMetadata(md)
+}
+
+func (h *acmeHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+	log.Debugf("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Debugf("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	br := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		conn.SetReadDeadline(time.Time{})
+		req.Body.Close()
+
+		resp := &http.Response{
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Close:      req.Close,
+		}
+
+		if v, ok := acme_util.HTTPChallengeResponse(req.URL.Path); ok {
+			log.Infof("acme: challenge %s%s", req.Host, req.URL.Path)
+			resp.StatusCode = http.StatusOK
+			resp.Header.Set("Content-Type", "text/plain")
+			resp.ContentLength = int64(len(v))
+			resp.Body = io.NopCloser(strings.NewReader(v))
+		} else if h.md.redirect && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+			host := req.Host
+			if v, _, err := net.SplitHostPort(host); err == nil {
+				host = v
+			}
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+			resp.StatusCode = http.StatusMovedPermanently
+			resp.Header.Set("Location", "https://"+host+req.URL.RequestURI())
+		} else {
+			resp.StatusCode = http.StatusNotFound
+		}
+
+		if err := resp.Write(conn); err != nil {
+			return err
+		}
+		if resp.Close {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package acme
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+const (
+	defaultReadTimeout = 30 * time.Second
+)
+
+type metadata struct {
+	readTimeout time.Duration
+	redirect    bool
+}
+
+func (h *acmeHandler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		readTimeout = "readTimeout"
+		redirect    = "redirect"
+	)
+
+	h.md.readTimeout = mdx.GetDuration(md, readTimeout)
+	if h.md.readTimeout <= 0 {
+		h.md.readTimeout = defaultReadTimeout
+	}
+
+	// redirect the other requests to HTTPS by default.
+	h.md.redirect = true
+	if md != nil && md.IsExists(redirect) {
+		h.md.redirect = mdx.GetBool(md, redirect)
+	}
+	return
+}
@@ -3,6 +3,7 @@ package auto
 import (
 	"bufio"
 	"context"
+	"io"
 	"net"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/gosocks4"
 	"github.com/go-gost/gosocks5"
+	xhandler "github.com/hxdcloud/gost-x/handler"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
 	"github.com/hxdcloud/gost-x/registry"
 )
@@ -54,6 +56,14 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	return h
 }
 
+func (h *autoHandler) Extend(opts ...xhandler.Option) {
+	for _, v := range []handler.Handler{h.httpHandler, h.socks4Handler, h.socks5Handler} {
+		if extender, ok := v.(xhandler.Extender); ok {
+			extender.Extend(opts...)
+		}
+	}
+}
+
 func (h *autoHandler) Init(md md.Metadata) error {
 	if h.httpHandler != nil {
 		if err := h.httpHandler.Init(md); err != nil {
@@ -74,6 +84,16 @@ func (h *autoHandler) Init(md md.Metadata) error {
 	return nil
 }
 
+// Close implements io.Closer interface.
+func (h *autoHandler) Close() error {
+	for _, v := range []handler.Handler{h.httpHandler, h.socks4Handler, h.socks5Handler} {
+		if closer, ok := v.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	return nil
+}
+
 func (h *autoHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
 	log := h.options.Logger.WithFields(map[string]any{
 		"remote": conn.RemoteAddr().String(),
@@ -3,6 +3,7 @@ package dns
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -16,7 +17,13 @@ import (
 	"github.com/go-gost/core/hosts"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/metrics"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	"github.com/hxdcloud/gost-x/internal/util/fakeip"
 	resolver_util "github.com/hxdcloud/gost-x/internal/util/resolver"
+	"github.com/hxdcloud/gost-x/internal/util/stats"
+	xmetrics "github.com/hxdcloud/gost-x/metrics"
+	xrecorder "github.com/hxdcloud/gost-x/recorder"
 	"github.com/hxdcloud/gost-x/registry"
 	"github.com/hxdcloud/gost-x/resolver/exchanger"
 	"github.com/miekg/dns"
@@ -24,6 +31,17 @@ import (
 
 const (
 	defaultNameserver = "udp://127.0.0.1:53"
+
+	upstreamCache  = "cache"
+	upstreamHosts  = "hosts"
+	upstreamFakeIP = "fakeip"
+
+	// ECSModeStatic sets the client subnet to the static clientIP.
+	ECSModeStatic = "static"
+	// ECSModeClient derives the client subnet from the address of the querying client.
+	ECSModeClient = "client"
+	// ECSModePassthrough keeps the client subnet supplied by the client.
+	ECSModePassthrough = "passthrough"
 )
 
 func init() {
@@ -35,8 +53,10 @@ type dnsHandler struct {
 	cache      *resolver_util.Cache
 	router     *chain.Router
 	hosts      hosts.HostMapper
+	fakeIPs    *fakeip.Pool
 	md         metadata
 	options    handler.Options
+	xoptions   xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -50,6 +70,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *dnsHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *dnsHandler) Init(md md.Metadata) (err error) {
 	if err = h.parseMetadata(md); err != nil {
 		return
@@ -64,6 +90,16 @@ func (h *dnsHandler) Init(md md.Metadata) (err error) {
 	}
 	h.hosts = h.router.Hosts()
 
+	if h.md.fakeIP != "" {
+		h.fakeIPs, err = fakeip.Get(h.md.fakeIP,
+			fakeip.FileOption(h.md.fakeIPFile),
+			fakeip.LoggerOption(log),
+		)
+		if err != nil {
+			return
+		}
+	}
+
 	for _, server := range h.md.dns {
 		server = strings.TrimSpace(server)
 		if server == "" {
@@ -98,6 +134,11 @@ func (h *dnsHandler) Init(md md.Metadata) (err error) {
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *dnsHandler) Close() error {
+	return h.fakeIPs.Release()
+}
+
 func (h *dnsHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
 	defer conn.Close()
 
@@ -123,7 +164,14 @@ func (h *dnsHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		return err
 	}
 
-	reply, err := h.exchange(ctx, (*b)[:n], log)
+	client, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	q := &stats.DNSQuery{
+		Service: h.xoptions.Service,
+		Client:  client,
+		Time:    time.Now(),
+	}
+	reply, err := h.exchange(ctx, (*b)[:n], q, log)
+	h.record(ctx, q, log)
 	if err != nil {
 		return err
 	}
@@ -136,7 +184,7 @@ func (h *dnsHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 	return nil
 }
 
-func (h *dnsHandler) exchange(ctx context.Context, msg []byte, log logger.Logger) ([]byte, error) {
+func (h *dnsHandler) exchange(ctx context.Context, msg []byte, q *stats.DNSQuery, log logger.Logger) ([]byte, error) {
 	mq := dns.Msg{}
 	if err := mq.Unpack(msg); err != nil {
 		log.Error(err)
@@ -147,7 +195,10 @@ func (h *dnsHandler) exchange(ctx context.Context, msg []byte, log logger.Logger
 		return nil, errors.New("msg: empty question")
 	}
 
-	resolver_util.AddSubnetOpt(&mq, h.md.clientIP)
+	q.Name = mq.Question[0].Name
+	q.Type = dns.TypeToString[mq.Question[0].Qtype]
+
+	h.setSubnet(&mq, net.ParseIP(q.Client))
 
 	if log.IsLevelEnabled(logger.DebugLevel) {
 		log.Debug(mq.String())
@@ -155,27 +206,49 @@ func (h *dnsHandler) exchange(ctx context.Context, msg []byte, log logger.Logger
 
 	var mr *dns.Msg
 
-	if log.IsLevelEnabled(logger.DebugLevel) {
-		defer func() {
-			if mr != nil {
-				log.Debug(mr.String())
+	defer func() {
+		if mr == nil {
+			return
+		}
+		if log.IsLevelEnabled(logger.DebugLevel) {
+			log.Debug(mr.String())
+		}
+		q.Rcode = dns.RcodeToString[mr.Rcode]
+		for _, ans := range mr.Answer {
+			switch rr := ans.(type) {
+			case *dns.A:
+				q.Answers = append(q.Answers, rr.A.String())
+			case *dns.AAAA:
+				q.Answers = append(q.Answers, rr.AAAA.String())
 			}
-		}()
-	}
+		}
+	}()
 
 	mr = h.lookupHosts(&mq, log)
 	if mr != nil {
+		q.Upstream = upstreamHosts
+		q.Blocked = isBlocked(mr)
+		b := bufpool.Get(defaultBufferSize)
+		return mr.PackBuffer(*b)
+	}
+
+	mr = h.lookupFakeIP(&mq, log)
+	if mr != nil {
+		q.Upstream = upstreamFakeIP
 		b := bufpool.Get(defaultBufferSize)
 		return mr.PackBuffer(*b)
 	}
 
 	// only cache for single question message.
 	if len(mq.Question) == 1 {
-		key := resolver_util.NewCacheKey(&mq.Question[0])
+		key := resolver_util.NewCacheKey(&mq.Question[0]).
+			WithSubnet(resolver_util.GetSubnetOpt(&mq))
 		mr = h.cache.Load(key)
 		if mr != nil {
 			log.Debugf("exchange message %d (cached): %s", mq.Id, mq.Question[0].String())
 			mr.Id = mq.Id
+			q.Upstream = upstreamCache
+			q.Cached = true
 
 			b := bufpool.Get(defaultBufferSize)
 			return mr.PackBuffer(*b)
@@ -202,6 +275,7 @@ func (h *dnsHandler) exchange(ctx context.Context, msg []byte, log logger.Logger
 		log.Debugf("exchange message %d via %s: %s", mq.Id, ex.String(), mq.Question[0].String())
 		reply, err = ex.Exchange(ctx, query)
 		if err == nil {
+			q.Upstream = ex.String()
 			break
 		}
 		log.Error(err)
@@ -215,10 +289,97 @@ func (h *dnsHandler) exchange(ctx context.Context, msg []byte, log logger.Logger
 		log.Error(err)
 		return nil, err
 	}
+	q.Blocked = isBlocked(mr)
 
 	return reply, nil
 }
 
+// setSubnet sets the EDNS0 client subnet option of the query according to the ECS mode.
+// In client mode, the client subnet is derived from the client address truncated to the prefix length,
+// the static clientIP is used for the private or loopback client address.
+// In passthrough mode, the client subnet supplied by the client is kept,
+// the static clientIP is used if the client does not supply it.
+func (h *dnsHandler) setSubnet(m *dns.Msg, client net.IP) {
+	switch h.md.ecsMode {
+	case ECSModeClient:
+		if client != nil && !client.IsLoopback() && !client.IsPrivate() &&
+			!client.IsLinkLocalUnicast() && !client.IsUnspecified() {
+			prefix := h.md.ecsPrefix4
+			if client.To4() == nil {
+				prefix = h.md.ecsPrefix6
+			}
+			resolver_util.SetSubnetOpt(m, client, prefix)
+			return
+		}
+	case ECSModePassthrough:
+		if resolver_util.GetSubnetOpt(m) != nil {
+			return
+		}
+	}
+
+	resolver_util.AddSubnetOpt(m, h.md.clientIP)
+}
+
+// record sends the query record to the recorders, and updates the metrics and statistics.
+func (h *dnsHandler) record(ctx context.Context, q *stats.DNSQuery, log logger.Logger) {
+	if q.Name == "" {
+		return
+	}
+	duration := time.Since(q.Time)
+	q.Duration = duration.Seconds()
+	if q.Rcode == "" {
+		q.Rcode = dns.RcodeToString[dns.RcodeServerFailure]
+	}
+
+	metrics.GetCounter(xmetrics.MetricDNSQueriesCounter, metrics.Labels{
+		"service":  h.xoptions.Service,
+		"upstream": q.Upstream,
+		"qtype":    q.Type,
+		"rcode":    q.Rcode,
+	}).Inc()
+	metrics.GetObserver(xmetrics.MetricDNSQueryDurationObserver, metrics.Labels{
+		"service":  h.xoptions.Service,
+		"upstream": q.Upstream,
+		"qtype":    q.Type,
+	}).Observe(duration.Seconds())
+
+	stats.AddDNSQuery(q)
+
+	var data []byte
+	for _, rec := range h.xoptions.Recorders {
+		if rec.Recorder == nil || rec.Record != xrecorder.RecorderServiceHandlerDNS {
+			continue
+		}
+		if data == nil {
+			data, _ = json.Marshal(q)
+		}
+		if err := rec.Recorder.Record(ctx, data); err != nil {
+			log.Errorf("record %s: %v", rec.Record, err)
+		}
+	}
+}
+
+// isBlocked reports whether the answer is a blocked response,
+// the query is refused or the name is mapped to an unspecified address (e.g. 0.0.0.0 in hosts).
+func isBlocked(m *dns.Msg) bool {
+	if m.Rcode == dns.RcodeRefused {
+		return true
+	}
+	for _, ans := range m.Answer {
+		switch rr := ans.(type) {
+		case *dns.A:
+			if rr.A.IsUnspecified() {
+				return true
+			}
+		case *dns.AAAA:
+			if rr.AAAA.IsUnspecified() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // lookup host mapper
 func (h *dnsHandler) lookupHosts(r *dns.Msg, log logger.Logger) (m *dns.Msg) {
 	if h.hosts == nil ||
@@ -269,6 +430,52 @@ func (h *dnsHandler) lookupHosts(r *dns.Msg, log logger.Logger) (m *dns.Msg) {
 	return
 }
 
+// lookupFakeIP allocates a fake IP from the pool for the queried name.
+// The names matched by the bypass are resolved by the nameservers as usual.
+func (h *dnsHandler) lookupFakeIP(r *dns.Msg, log logger.Logger) (m *dns.Msg) {
+	if h.fakeIPs == nil ||
+		r.Question[0].Qclass != dns.ClassINET ||
+		(r.Question[0].Qtype != dns.TypeA && r.Question[0].Qtype != dns.TypeAAAA) {
+		return nil
+	}
+
+	host := strings.TrimSuffix(r.Question[0].Name, ".")
+	if h.options.Bypass != nil && h.options.Bypass.Contains(host) {
+		log.Debugf("fakeip bypass: %s", host)
+		return nil
+	}
+
+	m = &dns.Msg{}
+	m.SetReply(r)
+
+	hdr := dns.RR_Header{
+		Name:   r.Question[0].Name,
+		Rrtype: r.Question[0].Qtype,
+		Class:  dns.ClassINET,
+		Ttl:    uint32(h.md.fakeIPTTL.Seconds()),
+	}
+
+	switch r.Question[0].Qtype {
+	case dns.TypeA:
+		if !h.fakeIPs.IsIPv4() {
+			return // empty answer
+		}
+		ip := h.fakeIPs.Allocate(host)
+		log.Debugf("fakeip: %s -> %s", host, ip)
+		m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: ip})
+
+	case dns.TypeAAAA:
+		if h.fakeIPs.IsIPv4() {
+			return // empty answer
+		}
+		ip := h.fakeIPs.Allocate(host)
+		log.Debugf("fakeip: %s -> %s", host, ip)
+		m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+	}
+
+	return
+}
+
 func (h *dnsHandler) dumpMsgHeader(m *dns.Msg) string {
 	buf := new(bytes.Buffer)
 	buf.WriteString(m.MsgHdr.String() + " ")
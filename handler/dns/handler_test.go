@@ -0,0 +1,295 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-gost/core/handler"
+	resolver_util "github.com/hxdcloud/gost-x/internal/util/resolver"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/miekg/dns"
+)
+
+func newHandler(t *testing.T, md map[string]any) *dnsHandler {
+	t.Helper()
+
+	h := NewHandler(handler.LoggerOption(xlogger.Nop())).(*dnsHandler)
+	if err := h.Init(mdx.NewMetadata(md)); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// remoteConn overrides the client address of the connection.
+type remoteConn struct {
+	net.Conn
+	raddr net.Addr
+}
+
+func (c *remoteConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+// query sends the message to the handler and returns the reply.
+func query(t *testing.T, h *dnsHandler, m *dns.Msg) *dns.Msg {
+	t.Helper()
+
+	return queryFrom(t, h, m, nil)
+}
+
+// queryFrom sends the message to the handler from the client address raddr.
+func queryFrom(t *testing.T, h *dnsHandler, m *dns.Msg, raddr net.Addr) *dns.Msg {
+	t.Helper()
+
+	b, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1, c2 := net.Pipe()
+	var conn net.Conn = c2
+	if raddr != nil {
+		conn = &remoteConn{Conn: c2, raddr: raddr}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Handle(context.Background(), conn)
+	}()
+	defer func() {
+		c1.Close()
+		<-done
+	}()
+
+	if _, err := c1.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	n, err := c1.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := &dns.Msg{}
+	if err := reply.Unpack(buf[:n]); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestHandlerFakeIP(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fakeip.txt")
+	md := map[string]any{
+		"fakeIP":     "10.2.0.0/24",
+		"fakeIPFile": file,
+		"dns":        []string{"udp://127.0.0.1:1"},
+	}
+
+	lookup := func(h *dnsHandler, name string) string {
+		m := &dns.Msg{}
+		m.SetQuestion(dns.Fqdn(name), dns.TypeA)
+		reply := query(t, h, m)
+		if len(reply.Answer) != 1 {
+			t.Fatalf("%s: got %d answers", name, len(reply.Answer))
+		}
+		return reply.Answer[0].(*dns.A).A.String()
+	}
+
+	h := newHandler(t, md)
+	if ip := lookup(h, "a.example.com"); ip != "10.2.0.2" {
+		t.Errorf("got %s, want 10.2.0.2", ip)
+	}
+	if ip := lookup(h, "b.example.com"); ip != "10.2.0.3" {
+		t.Errorf("got %s, want 10.2.0.3", ip)
+	}
+
+	// closing the handler saves the mappings allocated after the last periodic save.
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatal(err)
+	}
+
+	h = newHandler(t, md)
+	defer h.Close()
+	if ip := lookup(h, "b.example.com"); ip != "10.2.0.3" {
+		t.Errorf("got %s, want 10.2.0.3", ip)
+	}
+	if ip := lookup(h, "c.example.com"); ip != "10.2.0.4" {
+		t.Errorf("got %s, want 10.2.0.4", ip)
+	}
+}
+
+// subnetServer starts a UDP nameserver which answers the A queries with the address of the client subnet,
+// n counts the queries.
+func subnetServer(t *testing.T, n *int32) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			atomic.AddInt32(n, 1)
+
+			ip := net.IPv4zero
+			if e := resolver_util.GetSubnetOpt(r); e != nil && e.Family == 1 {
+				ip = e.Address
+			}
+			m := &dns.Msg{}
+			m.SetReply(r)
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   ip,
+			})
+			w.WriteMsg(m)
+		}),
+	}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return "udp://" + pc.LocalAddr().String()
+}
+
+func TestHandlerECSMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+		err  bool
+	}{
+		{mode: "", want: ""},
+		{mode: "static", want: ECSModeStatic},
+		{mode: "Client", want: ECSModeClient},
+		{mode: "passthrough", want: ECSModePassthrough},
+		{mode: "subnet", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			h := NewHandler(handler.LoggerOption(xlogger.Nop())).(*dnsHandler)
+			err := h.parseMetadata(mdx.NewMetadata(map[string]any{"ecsMode": tt.mode}))
+			if (err != nil) != tt.err {
+				t.Fatalf("got %v, want error %v", err, tt.err)
+			}
+			if err == nil && h.md.ecsMode != tt.want {
+				t.Errorf("got %q, want %q", h.md.ecsMode, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetSubnet(t *testing.T) {
+	tests := []struct {
+		name   string
+		md     map[string]any
+		client string
+		subnet string
+		want   string
+	}{
+		{name: "none"},
+		{name: "static", md: map[string]any{"clientIP": "1.2.3.4"}, client: "8.8.8.8", want: "1.2.3.0/24"},
+		{name: "static replaces client subnet", md: map[string]any{"clientIP": "1.2.3.4"}, subnet: "5.6.7.8", want: "1.2.3.0/24"},
+		{name: "client", md: map[string]any{"ecsMode": "client"}, client: "8.8.8.8", want: "8.8.8.0/24"},
+		{name: "client prefix", md: map[string]any{"ecsMode": "client", "ecsPrefix4": 16}, client: "8.8.8.8", want: "8.8.0.0/16"},
+		{name: "client ipv6", md: map[string]any{"ecsMode": "client"}, client: "2001:db8:1:2::1", want: "2001:db8:1::/56"},
+		{name: "client private", md: map[string]any{"ecsMode": "client", "clientIP": "1.2.3.4"}, client: "192.168.1.1", want: "1.2.3.0/24"},
+		{name: "client loopback", md: map[string]any{"ecsMode": "client"}, client: "127.0.0.1"},
+		{name: "passthrough", md: map[string]any{"ecsMode": "passthrough", "clientIP": "1.2.3.4"}, client: "8.8.8.8", subnet: "5.6.7.8", want: "5.6.7.0/24"},
+		{name: "passthrough fallback", md: map[string]any{"ecsMode": "passthrough", "clientIP": "1.2.3.4"}, client: "8.8.8.8", want: "1.2.3.0/24"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(handler.LoggerOption(xlogger.Nop())).(*dnsHandler)
+			if err := h.parseMetadata(mdx.NewMetadata(tt.md)); err != nil {
+				t.Fatal(err)
+			}
+
+			m := &dns.Msg{}
+			m.SetQuestion("example.com.", dns.TypeA)
+			if tt.subnet != "" {
+				resolver_util.AddSubnetOpt(m, net.ParseIP(tt.subnet))
+			}
+			h.setSubnet(m, net.ParseIP(tt.client))
+
+			var got string
+			if e := resolver_util.GetSubnetOpt(m); e != nil {
+				got = fmt.Sprintf("%s/%d", e.Address, e.SourceNetmask)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerECSCache(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		// queries are the client addresses or the client subnets of the queries.
+		queries []string
+		// answers are the answers of the queries.
+		answers []string
+		// n is the number of the upstream queries.
+		n int32
+	}{
+		{
+			name:    "client",
+			mode:    "client",
+			queries: []string{"8.8.8.8", "9.9.9.9", "8.8.8.1"},
+			answers: []string{"8.8.8.0", "9.9.9.0", "8.8.8.0"},
+			n:       2,
+		},
+		{
+			name:    "passthrough",
+			mode:    "passthrough",
+			queries: []string{"1.2.3.4", "5.6.7.8", "1.2.3.4"},
+			answers: []string{"1.2.3.0", "5.6.7.0", "1.2.3.0"},
+			n:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int32
+			h := newHandler(t, map[string]any{
+				"dns":     []string{subnetServer(t, &n)},
+				"ecsMode": tt.mode,
+			})
+			defer h.Close()
+
+			for i, v := range tt.queries {
+				m := &dns.Msg{}
+				m.SetQuestion("example.com.", dns.TypeA)
+
+				var raddr net.Addr
+				if tt.mode == ECSModeClient {
+					raddr = &net.UDPAddr{IP: net.ParseIP(v), Port: 53}
+				} else {
+					resolver_util.AddSubnetOpt(m, net.ParseIP(v))
+				}
+
+				reply := queryFrom(t, h, m, raddr)
+				if len(reply.Answer) != 1 {
+					t.Fatalf("#%d: got %d answers", i, len(reply.Answer))
+				}
+				if ip := reply.Answer[0].(*dns.A).A.String(); ip != tt.answers[i] {
+					t.Errorf("#%d: got %s, want %s", i, ip, tt.answers[i])
+				}
+			}
+			if v := atomic.LoadInt32(&n); v != tt.n {
+				t.Errorf("got %d upstream queries, want %d", v, tt.n)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package dns
 
 import (
+	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
@@ -11,6 +13,9 @@ import (
 const (
 	defaultTimeout    = 5 * time.Second
 	defaultBufferSize = 1024
+	defaultFakeIPTTL  = time.Second
+	defaultECSPrefix4 = 24
+	defaultECSPrefix6 = 56
 )
 
 type metadata struct {
@@ -18,8 +23,16 @@ type metadata struct {
 	ttl         time.Duration
 	timeout     time.Duration
 	clientIP    net.IP
+	// EDNS0 client subnet mode: static, client or passthrough
+	ecsMode    string
+	ecsPrefix4 int
+	ecsPrefix6 int
 	// nameservers
 	dns []string
+	// fake-IP pool CIDR
+	fakeIP     string
+	fakeIPFile string
+	fakeIPTTL  time.Duration
 }
 
 func (h *dnsHandler) parseMetadata(md mdata.Metadata) (err error) {
@@ -28,7 +41,13 @@ func (h *dnsHandler) parseMetadata(md mdata.Metadata) (err error) {
 		ttl         = "ttl"
 		timeout     = "timeout"
 		clientIP    = "clientIP"
+		ecsMode     = "ecsMode"
+		ecsPrefix4  = "ecsPrefix4"
+		ecsPrefix6  = "ecsPrefix6"
 		dns         = "dns"
+		fakeIP      = "fakeIP"
+		fakeIPFile  = "fakeIPFile"
+		fakeIPTTL   = "fakeIPTTL"
 	)
 
 	h.md.readTimeout = mdx.GetDuration(md, readTimeout)
@@ -41,7 +60,28 @@ func (h *dnsHandler) parseMetadata(md mdata.Metadata) (err error) {
 	if sip != "" {
 		h.md.clientIP = net.ParseIP(sip)
 	}
+	h.md.ecsMode = strings.ToLower(mdx.GetString(md, ecsMode))
+	switch h.md.ecsMode {
+	case "", ECSModeStatic, ECSModeClient, ECSModePassthrough:
+	default:
+		return fmt.Errorf("unknown ecsMode %s", h.md.ecsMode)
+	}
+	h.md.ecsPrefix4 = mdx.GetInt(md, ecsPrefix4)
+	if h.md.ecsPrefix4 <= 0 || h.md.ecsPrefix4 > 32 {
+		h.md.ecsPrefix4 = defaultECSPrefix4
+	}
+	h.md.ecsPrefix6 = mdx.GetInt(md, ecsPrefix6)
+	if h.md.ecsPrefix6 <= 0 || h.md.ecsPrefix6 > 128 {
+		h.md.ecsPrefix6 = defaultECSPrefix6
+	}
 	h.md.dns = mdx.GetStrings(md, dns)
 
+	h.md.fakeIP = mdx.GetString(md, fakeIP)
+	h.md.fakeIPFile = mdx.GetString(md, fakeIPFile)
+	h.md.fakeIPTTL = mdx.GetDuration(md, fakeIPTTL)
+	if h.md.fakeIPTTL <= 0 {
+		h.md.fakeIPTTL = defaultFakeIPTTL
+	}
+
 	return
 }
@@ -10,7 +10,9 @@ import (
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/handler"
 	md "github.com/go-gost/core/metadata"
+	xhandler "github.com/hxdcloud/gost-x/handler"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -21,10 +23,12 @@ func init() {
 }
 
 type forwardHandler struct {
-	group   *chain.NodeGroup
-	router  *chain.Router
-	md      metadata
-	options handler.Options
+	group    *chain.NodeGroup
+	router   *chain.Router
+	sessions *udp.SessionTable
+	md       metadata
+	options  handler.Options
+	xoptions xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -38,6 +42,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *forwardHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *forwardHandler) Init(md md.Metadata) (err error) {
 	if err = h.parseMetadata(md); err != nil {
 		return
@@ -52,10 +62,16 @@ func (h *forwardHandler) Init(md md.Metadata) (err error) {
 	if h.router == nil {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
+	h.sessions = udp.NewSessionTable(h.xoptions.Service, h.md.sessionOptions)
 
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *forwardHandler) Close() error {
+	return h.sessions.Close()
+}
+
 // Forward implements handler.Forwarder.
 func (h *forwardHandler) Forward(group *chain.NodeGroup) {
 	h.group = group
@@ -95,6 +111,16 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 
 	log.Infof("%s >> %s", conn.RemoteAddr(), target.Addr)
 
+	if network == "udp" {
+		fc, err := h.sessions.FlowConn(conn, conn.RemoteAddr(), target.Addr)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		defer fc.Close()
+		conn = fc
+	}
+
 	cc, err := h.router.Dial(ctx, network, target.Addr)
 	if err != nil {
 		log.Error(err)
@@ -108,9 +134,11 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), target.Addr)
-	netpkg.Transport(conn, cc)
+	stats, _ := netpkg.Relay(conn, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", conn.RemoteAddr(), target.Addr)
 
 	return nil
@@ -4,14 +4,21 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	readTimeout time.Duration
+	readTimeout      time.Duration
+	transportOptions []netpkg.TransportOption
+	sessionOptions   udp.SessionOptions
 }
 
 func (h *forwardHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+	h.md.sessionOptions = udp.SessionOptionsFromMetadata(md)
+
 	const (
 		readTimeout = "readTimeout"
 	)
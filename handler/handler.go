@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/recorder"
+)
+
+// Options are the handler options which are not covered by the handler.Options of the core.
+type Options struct {
+	Service   string
+	Recorders []recorder.RecorderObject
+	Routes    []Route
+}
+
+// Route is a forwarding route selected by the request host and path prefix.
+type Route struct {
+	Name string
+	// Hosts are the virtual hosts of the route, a host with the "*." prefix matches all the subdomains.
+	// The route matches any host if it is empty.
+	Hosts []string
+	// Path is the path prefix of the route.
+	Path string
+	// Clients are the name patterns of the client certificate, such as the common name, SAN or fingerprint.
+	// The route matches any client if it is empty.
+	Clients []string
+	Group   *chain.NodeGroup
+}
+
+type Option func(opts *Options)
+
+func ServiceOption(service string) Option {
+	return func(opts *Options) {
+		opts.Service = service
+	}
+}
+
+func RecordersOption(recorders ...recorder.RecorderObject) Option {
+	return func(opts *Options) {
+		opts.Recorders = recorders
+	}
+}
+
+func RoutesOption(routes ...Route) Option {
+	return func(opts *Options) {
+		opts.Routes = routes
+	}
+}
+
+// Extender is implemented by the handlers which accept the extended options.
+type Extender interface {
+	Extend(opts ...Option)
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"hash/crc32"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -20,7 +21,10 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xhandler "github.com/hxdcloud/gost-x/handler"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -29,9 +33,11 @@ func init() {
 }
 
 type httpHandler struct {
-	router  *chain.Router
-	md      metadata
-	options handler.Options
+	router   *chain.Router
+	sessions *udp.SessionTable
+	md       metadata
+	options  handler.Options
+	xoptions xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -45,6 +51,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *httpHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *httpHandler) Init(md md.Metadata) error {
 	if err := h.parseMetadata(md); err != nil {
 		return err
@@ -54,10 +66,16 @@ func (h *httpHandler) Init(md md.Metadata) error {
 	if h.router == nil {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
+	h.sessions = udp.NewSessionTable(h.xoptions.Service, h.md.sessionOptions)
 
 	return nil
 }
 
+// Close implements io.Closer interface.
+func (h *httpHandler) Close() error {
+	return h.sessions.Close()
+}
+
 func (h *httpHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
 	defer conn.Close()
 
@@ -73,17 +91,43 @@ func (h *httpHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler
 		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
 	}()
 
-	req, err := http.ReadRequest(bufio.NewReader(conn))
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 	defer req.Body.Close()
 
-	return h.handleRequest(ctx, conn, req, log)
+	return h.handleRequests(ctx, conn, br, req, log)
+}
+
+// handleRequests serves the requests on the client connection until it is closed or hijacked.
+// The plain HTTP requests are forwarded one by one, and the upstream connections are reused per target.
+func (h *httpHandler) handleRequests(ctx context.Context, conn net.Conn, br *bufio.Reader, req *http.Request, log logger.Logger) error {
+	pool := newConnPool()
+	defer pool.Close()
+
+	for {
+		keepAlive, err := h.handleRequest(ctx, conn, br, req, pool, log)
+		req.Body.Close()
+		if err != nil || !keepAlive {
+			return err
+		}
+
+		req, err = http.ReadRequest(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			log.Error(err)
+			return err
+		}
+	}
 }
 
-func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *http.Request, log logger.Logger) error {
+// handleRequest handles a single request, keepAlive reports whether the next request can be read from the client connection.
+func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, br *bufio.Reader, req *http.Request, pool *connPool, log logger.Logger) (keepAlive bool, err error) {
 	if h.md.sni && !req.URL.IsAbs() && govalidator.IsDNSName(req.Host) {
 		req.URL.Scheme = "http"
 	}
@@ -146,15 +190,15 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 		}
 		log.Info("bypass: ", addr)
 
-		return resp.Write(conn)
+		return false, resp.Write(conn)
 	}
 
 	if !h.authenticate(conn, req, resp, log) {
-		return nil
+		return false, nil
 	}
 
 	if network == "udp" {
-		return h.handleUDP(ctx, conn, log)
+		return false, h.handleUDP(ctx, conn, log)
 	}
 
 	if req.Method == "PRI" ||
@@ -166,11 +210,15 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 			log.Debug(string(dump))
 		}
 
-		return resp.Write(conn)
+		return false, resp.Write(conn)
 	}
 
 	req.Header.Del("Proxy-Authorization")
 
+	if req.Method != http.MethodConnect {
+		return h.proxyRequest(ctx, conn, br, req, addr, pool, log)
+	}
+
 	cc, err := h.router.Dial(ctx, network, addr)
 	if err != nil {
 		resp.StatusCode = http.StatusServiceUnavailable
@@ -180,38 +228,32 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 			log.Debug(string(dump))
 		}
 		resp.Write(conn)
-		return err
+		return false, err
 	}
 	defer cc.Close()
 
-	if req.Method == http.MethodConnect {
-		resp.StatusCode = http.StatusOK
-		resp.Status = "200 Connection established"
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 Connection established"
 
-		if log.IsLevelEnabled(logger.DebugLevel) {
-			dump, _ := httputil.DumpResponse(resp, false)
-			log.Debug(string(dump))
-		}
-		if err = resp.Write(conn); err != nil {
-			log.Error(err)
-			return err
-		}
-	} else {
-		req.Header.Del("Proxy-Connection")
-		if err = req.Write(cc); err != nil {
-			log.Error(err)
-			return err
-		}
+	if log.IsLevelEnabled(logger.DebugLevel) {
+		dump, _ := httputil.DumpResponse(resp, false)
+		log.Debug(string(dump))
+	}
+	if err = resp.Write(conn); err != nil {
+		log.Error(err)
+		return false, err
 	}
 
 	start := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), addr)
-	netpkg.Transport(conn, cc)
+	stats, _ := netpkg.Relay(bufferedConn(conn, br), cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(start),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", conn.RemoteAddr(), addr)
 
-	return nil
+	return false, nil
 }
 
 func (h *httpHandler) decodeServerName(s string) (string, error) {
@@ -254,6 +296,12 @@ func (h *httpHandler) basicProxyAuth(proxyAuth string, log logger.Logger) (usern
 }
 
 func (h *httpHandler) authenticate(conn net.Conn, req *http.Request, resp *http.Response, log logger.Logger) (ok bool) {
+	// the verified client certificate is preferred.
+	if name, ok := tls_util.IdentityFromConn(conn).Authenticate(h.options.Auther); ok {
+		log.Debugf("client certificate %s authenticated", name)
+		return true
+	}
+
 	u, p, _ := h.basicProxyAuth(req.Header.Get("Proxy-Authorization"), log)
 	if h.options.Auther == nil || h.options.Auther.Authenticate(u, p) {
 		return true
@@ -288,7 +336,7 @@ func (h *httpHandler) authenticate(conn net.Conn, req *http.Request, resp *http.
 			defer cc.Close()
 
 			req.Write(cc)
-			netpkg.Transport(conn, cc)
+			netpkg.Transport(conn, cc, h.md.transportOptions...)
 			return
 		case "file":
 			f, _ := os.Open(pr.Value)
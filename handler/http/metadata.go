@@ -5,17 +5,27 @@ import (
 	"strings"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	xhttp "github.com/hxdcloud/gost-x/internal/net/http"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	probeResistance *probeResistance
-	sni             bool
-	enableUDP       bool
-	header          http.Header
+	probeResistance  *probeResistance
+	sni              bool
+	enableUDP        bool
+	header           http.Header
+	transportOptions []netpkg.TransportOption
+	headerOptions    *xhttp.HeaderOptions
+	sessionOptions   udp.SessionOptions
 }
 
 func (h *httpHandler) parseMetadata(md mdata.Metadata) error {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+	h.md.headerOptions = xhttp.HeaderOptionsFromMetadata(md)
+	h.md.sessionOptions = udp.SessionOptionsFromMetadata(md)
+
 	const (
 		header         = "header"
 		probeResistKey = "probeResistance"
@@ -0,0 +1,242 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	xhttp "github.com/hxdcloud/gost-x/internal/net/http"
+)
+
+// proxyRequest forwards a plain HTTP request to the target addr and writes the response back to the client.
+func (h *httpHandler) proxyRequest(ctx context.Context, conn net.Conn, br *bufio.Reader, req *http.Request, addr string, pool *connPool, log logger.Logger) (keepAlive bool, err error) {
+	upgrade := xhttp.UpgradeType(req.Header)
+	expectContinue := strings.EqualFold(req.Header.Get("Expect"), "100-continue")
+	xhttp.RemoveHopHeaders(req.Header)
+	if upgrade != "" {
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", upgrade)
+	}
+	h.md.headerOptions.ApplyRequest(req, conn.RemoteAddr().String())
+	xhttp.KeepUserAgent(req.Header)
+
+	cc := pool.Get(addr)
+	reused := cc != nil
+	if cc == nil {
+		if cc, err = h.dialUpstream(ctx, addr); err != nil {
+			h.writeError(conn, http.StatusServiceUnavailable, log)
+			return false, err
+		}
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- req.Write(cc)
+	}()
+
+	var resp *http.Response
+	continued := false
+	for {
+		resp, err = http.ReadResponse(cc.br, req)
+		if err != nil {
+			cc.Close()
+			// the pooled connection may be closed by the server, retry with a new connection
+			// if the request can be sent again.
+			if reused && (req.Body == nil || req.Body == http.NoBody) {
+				<-errc
+				reused = false
+				if cc, err = h.dialUpstream(ctx, addr); err != nil {
+					h.writeError(conn, http.StatusServiceUnavailable, log)
+					return false, err
+				}
+				go func() {
+					errc <- req.Write(cc)
+				}()
+				continue
+			}
+			log.Error(err)
+			h.writeError(conn, http.StatusBadGateway, log)
+			return false, err
+		}
+
+		if resp.StatusCode == http.StatusSwitchingProtocols || resp.StatusCode < 100 || resp.StatusCode >= 200 {
+			break
+		}
+		// interim responses are not defined in HTTP/1.0
+		if req.ProtoAtLeast(1, 1) {
+			if err = writeInterimResponse(conn, resp); err != nil {
+				cc.Close()
+				return false, err
+			}
+		}
+		if resp.StatusCode == http.StatusContinue {
+			continued = true
+		}
+	}
+
+	if log.IsLevelEnabled(logger.DebugLevel) {
+		dump, _ := httputil.DumpResponse(resp, false)
+		log.Debug(string(dump))
+	}
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		defer cc.Close()
+
+		if upgrade == "" || !strings.EqualFold(xhttp.UpgradeType(resp.Header), upgrade) {
+			err = fmt.Errorf("unexpected protocol switch to %q", xhttp.UpgradeType(resp.Header))
+			log.Error(err)
+			return false, err
+		}
+		if err = writeInterimResponse(conn, resp); err != nil {
+			return false, err
+		}
+		if err = <-errc; err != nil {
+			return false, err
+		}
+
+		start := time.Now()
+		log.Infof("%s <-> %s", conn.RemoteAddr(), addr)
+		stats, _ := netpkg.Relay(bufferedConn(conn, br), bufferedConn(cc.Conn, cc.br), h.md.transportOptions...)
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+			"up":       stats.Up,
+			"down":     stats.Down,
+		}).Infof("%s >-< %s", conn.RemoteAddr(), addr)
+		return false, nil
+	}
+
+	// Connection is hop-by-hop, closing the upstream connection does not close the client connection.
+	upstreamClose := resp.Close
+	xhttp.RemoveHopHeaders(resp.Header)
+	h.md.headerOptions.ApplyResponse(resp)
+	resp.Close = req.Close
+	if !req.ProtoAtLeast(1, 1) && len(resp.TransferEncoding) > 0 {
+		// HTTP/1.0 client does not support chunked encoding, the body is delimited by closing the connection.
+		resp.TransferEncoding = nil
+		resp.ContentLength = -1
+		resp.Close = true
+	}
+
+	err = resp.Write(conn)
+	resp.Body.Close()
+	if err != nil {
+		cc.Close()
+		return false, err
+	}
+
+	// the client may never send the body if the server rejected the request before 100 Continue.
+	if expectContinue && !continued {
+		cc.Close()
+		return false, nil
+	}
+	if err = <-errc; err != nil {
+		cc.Close()
+		return false, err
+	}
+
+	if upstreamClose {
+		cc.Close()
+	} else {
+		pool.Put(addr, cc)
+	}
+
+	return !resp.Close, nil
+}
+
+func (h *httpHandler) dialUpstream(ctx context.Context, addr string) (*upstreamConn, error) {
+	c, err := h.router.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamConn{
+		Conn: c,
+		br:   bufio.NewReader(c),
+	}, nil
+}
+
+func (h *httpHandler) writeError(conn net.Conn, code int, log logger.Logger) error {
+	resp := &http.Response{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		StatusCode: code,
+		Header:     h.md.header,
+		Close:      true,
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+
+	if log.IsLevelEnabled(logger.DebugLevel) {
+		dump, _ := httputil.DumpResponse(resp, false)
+		log.Debug(string(dump))
+	}
+	return resp.Write(conn)
+}
+
+func writeInterimResponse(w io.Writer, resp *http.Response) error {
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	if _, err := fmt.Fprintf(w, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, status); err != nil {
+		return err
+	}
+	if err := resp.Header.Write(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// bufferedConn returns a connection that reads the data buffered in br first.
+func bufferedConn(conn net.Conn, br *bufio.Reader) net.Conn {
+	if br == nil || br.Buffered() == 0 {
+		return conn
+	}
+	return netpkg.NewBufferReaderConn(conn, br)
+}
+
+type upstreamConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+// connPool holds the idle upstream connections of a client connection, one per target.
+type connPool struct {
+	conns map[string]*upstreamConn
+}
+
+func newConnPool() *connPool {
+	return &connPool{
+		conns: make(map[string]*upstreamConn),
+	}
+}
+
+func (p *connPool) Get(addr string) *upstreamConn {
+	c := p.conns[addr]
+	delete(p.conns, addr)
+	return c
+}
+
+func (p *connPool) Put(addr string, c *upstreamConn) {
+	if old := p.conns[addr]; old != nil {
+		old.Close()
+	}
+	p.conns[addr] = c
+}
+
+func (p *connPool) Close() error {
+	for addr, c := range p.conns {
+		c.Close()
+		delete(p.conns, addr)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/handler"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+// newBackend starts the upstream server, conns counts the connections it accepted.
+func newBackend(t *testing.T, conns *int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/close":
+			w.Header().Set("Connection", "close")
+		case "/echo":
+			io.Copy(w, r.Body)
+			return
+		case "/upgrade":
+			if r.Header.Get("Upgrade") != "echo" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			conn, brw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			fmt.Fprint(brw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+			brw.Flush()
+			io.Copy(conn, brw)
+			return
+		}
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.Header.Get("User-Agent"))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(conns, 1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// proxyConn runs the handler on a connection and returns the client side of it.
+func proxyConn(t *testing.T) (net.Conn, <-chan error) {
+	t.Helper()
+
+	h := NewHandler(handler.LoggerOption(xlogger.Nop()))
+	if err := h.Init(mdx.NewMetadata(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		errc <- h.Handle(context.Background(), conn)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return conn, errc
+}
+
+func TestProxyRequests(t *testing.T) {
+	type step struct {
+		path   string
+		header string
+		// body is the response body, the response is not checked if empty.
+		body string
+		// restart closes the idle connections of the backend before the request.
+		restart bool
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+		// conns is the number of the upstream connections.
+		conns int32
+		// closed reports whether the client connection is closed by the handler.
+		closed bool
+	}{
+		{
+			name:  "keep-alive",
+			steps: []step{{path: "/a", body: "/a "}, {path: "/b", body: "/b "}},
+			conns: 1,
+		},
+		{
+			name:  "user agent",
+			steps: []step{{path: "/a", header: "User-Agent: test\r\n", body: "/a test"}},
+			conns: 1,
+		},
+		{
+			name:  "upstream close",
+			steps: []step{{path: "/close", body: "/close "}, {path: "/b", body: "/b "}},
+			conns: 2,
+		},
+		{
+			name:  "upstream closed idle",
+			steps: []step{{path: "/a", body: "/a "}, {path: "/b", body: "/b ", restart: true}},
+			conns: 2,
+		},
+		{
+			name:   "client close",
+			steps:  []step{{path: "/a", header: "Connection: close\r\n", body: "/a "}},
+			conns:  1,
+			closed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conns int32
+			srv := newBackend(t, &conns)
+			conn, errc := proxyConn(t)
+			br := bufio.NewReader(conn)
+
+			for _, s := range tt.steps {
+				if s.restart {
+					srv.CloseClientConnections()
+					time.Sleep(10 * time.Millisecond)
+				}
+				fmt.Fprintf(conn, "GET %s%s HTTP/1.1\r\nHost: %s\r\n%s\r\n", srv.URL, s.path, srv.Listener.Addr(), s.header)
+
+				resp, err := http.ReadResponse(br, nil)
+				if err != nil {
+					t.Fatalf("%s: %v", s.path, err)
+				}
+				b, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK || string(b) != s.body {
+					t.Fatalf("%s: got %d %q, want %q", s.path, resp.StatusCode, b, s.body)
+				}
+			}
+
+			if tt.closed {
+				if err := <-errc; err != nil {
+					t.Errorf("handle: %v", err)
+				}
+				if _, err := br.ReadByte(); err != io.EOF {
+					t.Errorf("got %v, want EOF", err)
+				}
+			}
+			if n := atomic.LoadInt32(&conns); n != tt.conns {
+				t.Errorf("got %d upstream connections, want %d", n, tt.conns)
+			}
+		})
+	}
+}
+
+func TestProxyExpectContinue(t *testing.T) {
+	var conns int32
+	srv := newBackend(t, &conns)
+	conn, _ := proxyConn(t)
+	br := bufio.NewReader(conn)
+
+	fmt.Fprintf(conn, "POST %s/echo HTTP/1.1\r\nHost: %s\r\nContent-Length: 4\r\nExpect: 100-continue\r\n\r\n",
+		srv.URL, srv.Listener.Addr())
+
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusContinue {
+		t.Fatalf("got %d, want 100", resp.StatusCode)
+	}
+
+	io.WriteString(conn, "ping")
+	if resp, err = http.ReadResponse(br, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(b) != "ping" {
+		t.Errorf("got %d %q", resp.StatusCode, b)
+	}
+}
+
+func TestProxyUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		upgrade string
+		status  int
+	}{
+		{name: "upgrade", upgrade: "echo", status: http.StatusSwitchingProtocols},
+		{name: "rejected", upgrade: "other", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conns int32
+			srv := newBackend(t, &conns)
+			conn, _ := proxyConn(t)
+			br := bufio.NewReader(conn)
+
+			fmt.Fprintf(conn, "GET %s/upgrade HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n",
+				srv.URL, srv.Listener.Addr(), tt.upgrade)
+			resp, err := http.ReadResponse(br, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got %d, want %d", resp.StatusCode, tt.status)
+			}
+			if resp.StatusCode != http.StatusSwitchingProtocols {
+				return
+			}
+			if !strings.EqualFold(resp.Header.Get("Upgrade"), "echo") {
+				t.Errorf("got upgrade %q", resp.Header.Get("Upgrade"))
+			}
+
+			io.WriteString(conn, "ping")
+			b := make([]byte, 4)
+			if _, err := io.ReadFull(br, b); err != nil || string(b) != "ping" {
+				t.Errorf("got %q, %v", b, err)
+			}
+		})
+	}
+}
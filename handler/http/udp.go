@@ -65,8 +65,12 @@ func (h *httpHandler) handleUDP(ctx context.Context, conn net.Conn, log logger.L
 		return err
 	}
 
+	session := h.sessions.NewSession(conn.RemoteAddr())
+	defer session.Close()
+
 	relay := udp.NewRelay(socks.UDPTunServerConn(conn), pc).
 		WithBypass(h.options.Bypass).
+		WithSession(session).
 		WithLogger(log)
 
 	t := time.Now()
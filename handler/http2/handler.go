@@ -23,6 +23,8 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	xhttp "github.com/hxdcloud/gost-x/internal/net/http"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -180,9 +182,11 @@ func (h *http2Handler) roundTrip(ctx context.Context, w http.ResponseWriter, req
 
 			start := time.Now()
 			log.Infof("%s <-> %s", conn.RemoteAddr(), addr)
-			netpkg.Transport(conn, cc)
+			stats, _ := netpkg.Relay(conn, cc, h.md.transportOptions...)
 			log.WithFields(map[string]any{
 				"duration": time.Since(start),
+				"up":       stats.Up,
+				"down":     stats.Down,
 			}).Infof("%s >-< %s", conn.RemoteAddr(), addr)
 
 			return nil
@@ -190,15 +194,42 @@ func (h *http2Handler) roundTrip(ctx context.Context, w http.ResponseWriter, req
 
 		start := time.Now()
 		log.Infof("%s <-> %s", req.RemoteAddr, addr)
-		netpkg.Transport(&readWriter{r: req.Body, w: flushWriter{w}}, cc)
+		stats, _ := netpkg.Relay(&readWriter{r: req.Body, w: flushWriter{w}}, cc, h.md.transportOptions...)
 		log.WithFields(map[string]any{
 			"duration": time.Since(start),
+			"up":       stats.Up,
+			"down":     stats.Down,
 		}).Infof("%s >-< %s", req.RemoteAddr, addr)
 		return nil
 	}
 
-	// TODO: forward request
-	return nil
+	xhttp.RemoveHopHeaders(req.Header)
+	h.md.headerOptions.ApplyRequest(req, req.RemoteAddr)
+	xhttp.KeepUserAgent(req.Header)
+
+	if err = req.Write(cc); err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+
+	resp, err = http.ReadResponse(bufio.NewReader(cc), req)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if log.IsLevelEnabled(logger.DebugLevel) {
+		dump, _ := httputil.DumpResponse(resp, false)
+		log.Debug(string(dump))
+	}
+
+	xhttp.RemoveHopHeaders(resp.Header)
+	h.md.headerOptions.ApplyResponse(resp)
+
+	return h.writeResponse(w, resp)
 }
 
 func (h *http2Handler) decodeServerName(s string) (string, error) {
@@ -241,6 +272,12 @@ func (h *http2Handler) basicProxyAuth(proxyAuth string) (username, password stri
 }
 
 func (h *http2Handler) authenticate(w http.ResponseWriter, r *http.Request, resp *http.Response, log logger.Logger) (ok bool) {
+	// the verified client certificate is preferred.
+	if name, ok := tls_util.IdentityFromState(r.TLS).Authenticate(h.options.Auther); ok {
+		log.Debugf("client certificate %s authenticated", name)
+		return true
+	}
+
 	u, p, _ := h.basicProxyAuth(r.Header.Get("Proxy-Authorization"))
 	if h.options.Auther == nil || h.options.Auther.Authenticate(u, p) {
 		return true
@@ -5,15 +5,22 @@ import (
 	"strings"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	xhttp "github.com/hxdcloud/gost-x/internal/net/http"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	probeResistance *probeResistance
-	header          http.Header
+	probeResistance  *probeResistance
+	header           http.Header
+	transportOptions []netpkg.TransportOption
+	headerOptions    *xhttp.HeaderOptions
 }
 
 func (h *http2Handler) parseMetadata(md mdata.Metadata) error {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+	h.md.headerOptions = xhttp.HeaderOptionsFromMetadata(md)
+
 	const (
 		header         = "header"
 		probeResistKey = "probeResistance"
@@ -19,6 +19,8 @@ import (
 	md "github.com/go-gost/core/metadata"
 	dissector "github.com/go-gost/tls-dissector"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	xhttp "github.com/hxdcloud/gost-x/internal/net/http"
+	"github.com/hxdcloud/gost-x/internal/util/fakeip"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -30,6 +32,7 @@ func init() {
 
 type redirectHandler struct {
 	router  *chain.Router
+	fakeIPs *fakeip.Pool
 	md      metadata
 	options handler.Options
 }
@@ -55,9 +58,18 @@ func (h *redirectHandler) Init(md md.Metadata) (err error) {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
 
+	if h.md.fakeIP != "" {
+		h.fakeIPs, err = fakeip.Get(h.md.fakeIP, fakeip.LoggerOption(h.options.Logger))
+	}
+
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *redirectHandler) Close() error {
+	return h.fakeIPs.Release()
+}
+
 func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) (err error) {
 	defer conn.Close()
 
@@ -86,6 +98,13 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 		}
 	}
 
+	if h.fakeIPs != nil {
+		if dstAddr, err = h.fakeIPs.ReverseAddr(dstAddr); err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
 	log = log.WithFields(map[string]any{
 		"dst": fmt.Sprintf("%s/%s", dstAddr, dstAddr.Network()),
 	})
@@ -133,9 +152,11 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), dstAddr)
-	netpkg.Transport(rw, cc)
+	stats, _ := netpkg.Relay(rw, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", conn.RemoteAddr(), dstAddr)
 
 	return nil
@@ -180,6 +201,7 @@ func (h *redirectHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, radd
 		}).Infof("%s >-< %s", raddr, host)
 	}()
 
+	xhttp.KeepUserAgent(req.Header)
 	if err := req.Write(cc); err != nil {
 		log.Error(err)
 		return err
@@ -235,12 +257,14 @@ func (h *redirectHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, rad
 
 	t := time.Now()
 	log.Infof("%s <-> %s", raddr, host)
-	netpkg.Transport(&readWriter{
+	stats, _ := netpkg.Relay(&readWriter{
 		Reader: io.MultiReader(buf, rw),
 		Writer: rw,
-	}, cc)
+	}, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", raddr, host)
 
 	return nil
@@ -2,20 +2,27 @@ package redirect
 
 import (
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	sniffing bool
-	tproxy   bool
+	sniffing         bool
+	tproxy           bool
+	fakeIP           string
+	transportOptions []netpkg.TransportOption
 }
 
 func (h *redirectHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+
 	const (
 		sniffing = "sniffing"
 		tproxy   = "tproxy"
+		fakeIP   = "fakeIP"
 	)
 	h.md.sniffing = mdx.GetBool(md, sniffing)
 	h.md.tproxy = mdx.GetBool(md, tproxy)
+	h.md.fakeIP = mdx.GetString(md, fakeIP)
 	return
 }
@@ -9,7 +9,10 @@ import (
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/handler"
 	md "github.com/go-gost/core/metadata"
+	xhandler "github.com/hxdcloud/gost-x/handler"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	"github.com/hxdcloud/gost-x/internal/util/fakeip"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -18,9 +21,12 @@ func init() {
 }
 
 type redirectHandler struct {
-	router  *chain.Router
-	md      metadata
-	options handler.Options
+	router   *chain.Router
+	fakeIPs  *fakeip.Pool
+	sessions *udp.SessionTable
+	md       metadata
+	options  handler.Options
+	xoptions xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -34,6 +40,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *redirectHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *redirectHandler) Init(md md.Metadata) (err error) {
 	if err = h.parseMetadata(md); err != nil {
 		return
@@ -43,10 +55,21 @@ func (h *redirectHandler) Init(md md.Metadata) (err error) {
 	if h.router == nil {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
+	h.sessions = udp.NewSessionTable(h.xoptions.Service, h.md.sessionOptions)
+
+	if h.md.fakeIP != "" {
+		h.fakeIPs, err = fakeip.Get(h.md.fakeIP, fakeip.LoggerOption(h.options.Logger))
+	}
 
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *redirectHandler) Close() error {
+	h.fakeIPs.Release()
+	return h.sessions.Close()
+}
+
 func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
 	defer conn.Close()
 
@@ -64,6 +87,13 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 	}()
 
 	dstAddr := conn.LocalAddr()
+	if h.fakeIPs != nil {
+		var err error
+		if dstAddr, err = h.fakeIPs.ReverseAddr(dstAddr); err != nil {
+			log.Error(err)
+			return err
+		}
+	}
 
 	log = log.WithFields(map[string]any{
 		"dst": fmt.Sprintf("%s/%s", dstAddr, dstAddr.Network()),
@@ -76,6 +106,14 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 		return nil
 	}
 
+	fc, err := h.sessions.FlowConn(conn, conn.RemoteAddr(), dstAddr.String())
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer fc.Close()
+	conn = fc
+
 	cc, err := h.router.Dial(ctx, dstAddr.Network(), dstAddr.String())
 	if err != nil {
 		log.Error(err)
@@ -85,9 +123,11 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), dstAddr)
-	netpkg.Transport(conn, cc)
+	stats, _ := netpkg.Relay(conn, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", conn.RemoteAddr(), dstAddr)
 
 	return nil
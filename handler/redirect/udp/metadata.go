@@ -2,10 +2,24 @@ package redirect
 
 import (
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
-type metadata struct{}
+type metadata struct {
+	fakeIP           string
+	transportOptions []netpkg.TransportOption
+	sessionOptions   udp.SessionOptions
+}
 
 func (h *redirectHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+	h.md.sessionOptions = udp.SessionOptionsFromMetadata(md)
+
+	const (
+		fakeIP = "fakeIP"
+	)
+	h.md.fakeIP = mdx.GetString(md, fakeIP)
 	return
 }
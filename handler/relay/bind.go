@@ -113,8 +113,12 @@ func (h *relayHandler) bindUDP(ctx context.Context, conn net.Conn, network, addr
 	})
 	log.Debugf("bind on %s OK", pc.LocalAddr())
 
-	r := udp.NewRelay(relay_util.UDPTunServerConn(conn), pc).
+	session := h.sessions.NewSession(conn.RemoteAddr())
+	defer session.Close()
+
+	r := udp.NewRelay(udp.UDPServerConn(conn, relay_util.UDPTunServerConn(conn)), pc).
 		WithBypass(h.options.Bypass).
+		WithSession(session).
 		WithLogger(log)
 	r.SetBufferSize(h.md.udpBufferSize)
 
@@ -129,7 +133,7 @@ func (h *relayHandler) bindUDP(ctx context.Context, conn net.Conn, network, addr
 
 func (h *relayHandler) serveTCPBind(ctx context.Context, conn net.Conn, ln net.Listener, log logger.Logger) error {
 	// Upgrade connection to multiplex stream.
-	session, err := mux.ClientSession(conn)
+	session, err := mux.ClientSession(conn, h.md.muxCfg)
 	if err != nil {
 		log.Error(err)
 		return err
@@ -185,8 +189,8 @@ func (h *relayHandler) serveTCPBind(ctx context.Context, conn net.Conn, ln net.L
 
 			t := time.Now()
 			log.Infof("%s <-> %s", c.LocalAddr(), c.RemoteAddr())
-			netpkg.Transport(sc, c)
-			log.WithFields(map[string]any{"duration": time.Since(t)}).
+			stats, _ := netpkg.Relay(sc, c, h.md.transportOptions...)
+			log.WithFields(map[string]any{"duration": time.Since(t), "up": stats.Up, "down": stats.Down}).
 				Infof("%s >-< %s", c.LocalAddr(), c.RemoteAddr())
 		}(rc)
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/relay"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 )
 
 func (h *relayHandler) handleConnect(ctx context.Context, conn net.Conn, network, address string, log logger.Logger) error {
@@ -40,6 +41,20 @@ func (h *relayHandler) handleConnect(ctx context.Context, conn net.Conn, network
 		return err
 	}
 
+	var session *udp.Session
+	switch network {
+	case "udp", "udp4", "udp6":
+		session = h.sessions.NewSession(conn.RemoteAddr())
+		defer session.Close()
+
+		if !session.Outbound(address, 0) {
+			resp.Status = relay.StatusForbidden
+			resp.WriteTo(conn)
+			log.Error(udp.ErrMaxFlows)
+			return udp.ErrMaxFlows
+		}
+	}
+
 	cc, err := h.router.Dial(ctx, network, address)
 	if err != nil {
 		resp.Status = relay.StatusNetworkUnreachable
@@ -66,7 +81,7 @@ func (h *relayHandler) handleConnect(ctx context.Context, conn net.Conn, network
 				return err
 			}
 		}
-		conn = rc
+		conn = session.Conn(rc, address)
 	default:
 		if !h.md.noDelay {
 			rc := &tcpConn{
@@ -82,9 +97,11 @@ func (h *relayHandler) handleConnect(ctx context.Context, conn net.Conn, network
 
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), address)
-	netpkg.Transport(conn, cc)
+	stats, _ := netpkg.Relay(conn, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", conn.RemoteAddr(), address)
 
 	return nil
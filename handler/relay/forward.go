@@ -10,6 +10,7 @@ import (
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/relay"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 )
 
 func (h *relayHandler) handleForward(ctx context.Context, conn net.Conn, network string, log logger.Logger) error {
@@ -33,6 +34,20 @@ func (h *relayHandler) handleForward(ctx context.Context, conn net.Conn, network
 
 	log.Infof("%s >> %s", conn.RemoteAddr(), target.Addr)
 
+	var session *udp.Session
+	switch network {
+	case "udp", "udp4", "udp6":
+		session = h.sessions.NewSession(conn.RemoteAddr())
+		defer session.Close()
+
+		if !session.Outbound(target.Addr, 0) {
+			resp.Status = relay.StatusForbidden
+			resp.WriteTo(conn)
+			log.Error(udp.ErrMaxFlows)
+			return udp.ErrMaxFlows
+		}
+	}
+
 	cc, err := h.router.Dial(ctx, network, target.Addr)
 	if err != nil {
 		// TODO: the router itself may be failed due to the failed node in the router,
@@ -66,7 +81,7 @@ func (h *relayHandler) handleForward(ctx context.Context, conn net.Conn, network
 				return err
 			}
 		}
-		conn = rc
+		conn = session.Conn(rc, target.Addr)
 	default:
 		rc := &tcpConn{
 			Conn: conn,
@@ -82,9 +97,11 @@ func (h *relayHandler) handleForward(ctx context.Context, conn net.Conn, network
 
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), target.Addr)
-	netpkg.Transport(conn, cc)
+	stats, _ := netpkg.Relay(conn, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", conn.RemoteAddr(), target.Addr)
 
 	return nil
@@ -11,6 +11,10 @@ import (
 	"github.com/go-gost/core/handler"
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/relay"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	relay_util "github.com/hxdcloud/gost-x/internal/util/relay"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -24,10 +28,12 @@ func init() {
 }
 
 type relayHandler struct {
-	group   *chain.NodeGroup
-	router  *chain.Router
-	md      metadata
-	options handler.Options
+	group    *chain.NodeGroup
+	router   *chain.Router
+	sessions *udp.SessionTable
+	md       metadata
+	options  handler.Options
+	xoptions xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -41,6 +47,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *relayHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *relayHandler) Init(md md.Metadata) (err error) {
 	if err := h.parseMetadata(md); err != nil {
 		return err
@@ -50,10 +62,16 @@ func (h *relayHandler) Init(md md.Metadata) (err error) {
 	if h.router == nil {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
+	h.sessions = udp.NewSessionTable(h.xoptions.Service, h.md.sessionOptions)
 
 	return nil
 }
 
+// Close implements io.Closer interface.
+func (h *relayHandler) Close() error {
+	return h.sessions.Close()
+}
+
 // Forward implements handler.Forwarder.
 func (h *relayHandler) Forward(group *chain.NodeGroup) {
 	h.group = group
@@ -94,7 +112,7 @@ func (h *relayHandler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 	}
 
 	var user, pass string
-	var address string
+	var host, address string
 	for _, f := range req.Features {
 		if f.Type() == relay.FeatureUserAuth {
 			feature := f.(*relay.UserAuthFeature)
@@ -102,6 +120,7 @@ func (h *relayHandler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 		}
 		if f.Type() == relay.FeatureAddr {
 			feature := f.(*relay.AddrFeature)
+			host = feature.Host
 			address = net.JoinHostPort(feature.Host, strconv.Itoa(int(feature.Port)))
 		}
 	}
@@ -114,7 +133,9 @@ func (h *relayHandler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 		Version: relay.Version1,
 		Status:  relay.StatusOK,
 	}
-	if h.options.Auther != nil && !h.options.Auther.Authenticate(user, pass) {
+	if name, ok := tls_util.IdentityFromConn(conn).Authenticate(h.options.Auther); ok {
+		log.Debugf("client certificate %s authenticated", name)
+	} else if h.options.Auther != nil && !h.options.Auther.Authenticate(user, pass) {
 		resp.Status = relay.StatusUnauthorized
 		log.Error("unauthorized")
 		_, err := resp.WriteTo(conn)
@@ -126,6 +147,10 @@ func (h *relayHandler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 		network = "udp"
 	}
 
+	if req.Flags&relay_util.FMux != 0 {
+		return h.handleMux(ctx, conn, log)
+	}
+
 	if h.group != nil {
 		if address != "" {
 			resp.Status = relay.StatusForbidden
@@ -141,6 +166,9 @@ func (h *relayHandler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 	case 0, relay.CONNECT:
 		return h.handleConnect(ctx, conn, network, address, log)
 	case relay.BIND:
+		if req.Flags&relay_util.FTunnel != 0 {
+			return h.handleTunnel(ctx, conn, host, user, log)
+		}
 		return h.handleBind(ctx, conn, network, address, log)
 	}
 	return ErrUnknownCmd
@@ -5,17 +5,27 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	readTimeout   time.Duration
-	enableBind    bool
-	udpBufferSize int
-	noDelay       bool
+	readTimeout      time.Duration
+	enableBind       bool
+	udpBufferSize    int
+	noDelay          bool
+	transportOptions []netpkg.TransportOption
+	sessionOptions   udp.SessionOptions
+	muxCfg           *mux.Config
 }
 
 func (h *relayHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+	h.md.sessionOptions = udp.SessionOptionsFromMetadata(md)
+	h.md.muxCfg = mux.ConfigFromMetadata(md)
+
 	const (
 		readTimeout   = "readTimeout"
 		enableBind    = "bind"
@@ -0,0 +1,42 @@
+package relay
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/relay"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+)
+
+// handleMux upgrades the connection to a mux session, each stream of the session is handled as a relay connection.
+func (h *relayHandler) handleMux(ctx context.Context, conn net.Conn, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"cmd": "mux",
+	})
+
+	resp := relay.Response{
+		Version: relay.Version1,
+		Status:  relay.StatusOK,
+	}
+	if _, err := resp.WriteTo(conn); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	session, err := mux.ServerSession(conn, h.md.muxCfg)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer session.Close()
+
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			log.Debug(err)
+			return err
+		}
+		go h.Handle(ctx, stream)
+	}
+}
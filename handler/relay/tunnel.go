@@ -0,0 +1,90 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/relay"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	"github.com/hxdcloud/gost-x/internal/util/tunnel"
+)
+
+// handleTunnel registers the connection as a connector of the tunnel,
+// the connections of the tunnel ingress are sent to the client as the mux streams.
+func (h *relayHandler) handleTunnel(ctx context.Context, conn net.Conn, id, user string, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"tunnel": id,
+		"cmd":    "bind",
+	})
+
+	log.Infof("%s >> tunnel %s", conn.RemoteAddr(), id)
+
+	resp := relay.Response{
+		Version: relay.Version1,
+		Status:  relay.StatusOK,
+	}
+
+	if !h.md.enableBind {
+		resp.Status = relay.StatusForbidden
+		log.Error("relay: BIND is disabled")
+		_, err := resp.WriteTo(conn)
+		return err
+	}
+
+	if !tunnel.ValidID(id) {
+		resp.Status = relay.StatusBadRequest
+		log.Error(tunnel.ErrInvalidID)
+		_, err := resp.WriteTo(conn)
+		return err
+	}
+
+	if !tunnel.Allowed(id, user) {
+		resp.Status = relay.StatusForbidden
+		log.Error(tunnel.ErrForbidden)
+		_, err := resp.WriteTo(conn)
+		return err
+	}
+
+	af := &relay.AddrFeature{}
+	if err := af.ParseFrom(conn.LocalAddr().String()); err != nil {
+		log.Warn(err)
+	}
+	resp.Features = append(resp.Features, af)
+	if _, err := resp.WriteTo(conn); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// Upgrade connection to multiplex stream.
+	session, err := mux.ClientSession(conn, h.md.muxCfg)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer session.Close()
+
+	c := tunnel.NewConnector(conn.RemoteAddr(), session)
+	if err := tunnel.Add(id, user, c); err != nil {
+		log.Error(err)
+		return err
+	}
+	defer tunnel.Del(id, c)
+
+	t := time.Now()
+	log.Infof("%s <-> tunnel %s", conn.RemoteAddr(), id)
+	for {
+		sc, err := session.Accept()
+		if err != nil {
+			log.Debug(err)
+			break
+		}
+		sc.Close() // we do not handle incoming connections.
+	}
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Infof("%s >-< tunnel %s", conn.RemoteAddr(), id)
+
+	return nil
+}
@@ -0,0 +1,349 @@
+package reverse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+	"github.com/hxdcloud/gost-x/registry"
+)
+
+func init() {
+	registry.HandlerRegistry().Register("reverse", NewHandler)
+}
+
+type nodeKey struct{}
+
+type logKey struct{}
+
+type identityKey struct{}
+
+// reverseHandler is an HTTP reverse proxy, the requests are routed to the target groups
+// by the Host header and the path prefix.
+type reverseHandler struct {
+	group    *chain.NodeGroup
+	routes   []xhandler.Route
+	router   *chain.Router
+	proxy    *httputil.ReverseProxy
+	md       metadata
+	options  handler.Options
+	xoptions xhandler.Options
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &reverseHandler{
+		options: options,
+	}
+}
+
+// Forward implements handler.Forwarder, the group is used if no route is matched.
+func (h *reverseHandler) Forward(group *chain.NodeGroup) {
+	h.group = group
+}
+
+func (h *reverseHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
+func (h *reverseHandler) Init(md md.Metadata) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
+	}
+
+	h.routes = append(h.routes, h.xoptions.Routes...)
+	// the longest path prefix is matched first.
+	sort.SliceStable(h.routes, func(i, j int) bool {
+		return len(h.routes[i].Path) > len(h.routes[j].Path)
+	})
+
+	h.proxy = &httputil.ReverseProxy{
+		Director: h.director,
+		Transport: &http.Transport{
+			DialContext:           h.dial,
+			ResponseHeaderTimeout: h.md.responseHeaderTimeout,
+			IdleConnTimeout:       h.md.idleConnTimeout,
+			MaxIdleConnsPerHost:   h.md.maxIdleConnsPerHost,
+			ExpectContinueTimeout: time.Second,
+		},
+		FlushInterval: h.md.flushInterval,
+		ErrorHandler:  h.errorHandler,
+	}
+
+	return
+}
+
+func (h *reverseHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	// HTTP2 listener
+	if v, ok := conn.(md.Metadatable); ok && v != nil {
+		if md := v.GetMetadata(); md != nil {
+			w, _ := md.Get("w").(http.ResponseWriter)
+			r, _ := md.Get("r").(*http.Request)
+			if w != nil && r != nil {
+				h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), logKey{}, log)))
+				return nil
+			}
+		}
+	}
+
+	// the client certificate is used to match the routes.
+	conn.SetReadDeadline(time.Now().Add(h.md.readHeaderTimeout))
+	id := tls_util.IdentityFromConn(conn)
+	conn.SetReadDeadline(time.Time{})
+	if id != nil {
+		log = log.WithFields(map[string]any{
+			"client": id.String(),
+		})
+	}
+
+	sc := &serverConn{
+		Conn:   conn,
+		closed: make(chan struct{}),
+	}
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: h.md.readHeaderTimeout,
+		IdleTimeout:       h.md.idleTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			ctx := context.WithValue(ctx, logKey{}, log)
+			return context.WithValue(ctx, identityKey{}, id)
+		},
+	}
+	srv.Serve(&singleConnListener{conn: sc, addr: conn.LocalAddr()})
+	<-sc.closed
+
+	return nil
+}
+
+func (h *reverseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log, _ := r.Context().Value(logKey{}).(logger.Logger)
+	if log == nil {
+		log = h.options.Logger
+	}
+
+	route := h.match(r)
+	group := h.group
+	if route != nil {
+		group = route.Group
+	}
+	node := group.Next()
+	if node == nil {
+		log.Errorf("%s %s%s: target not available", r.Method, r.Host, r.URL.Path)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	fields := map[string]any{
+		"host": r.Host,
+		"path": r.URL.Path,
+		"dst":  node.Addr,
+	}
+	if route != nil && route.Name != "" {
+		fields["route"] = route.Name
+	}
+	log = log.WithFields(fields)
+
+	if log.IsLevelEnabled(logger.DebugLevel) {
+		dump, _ := httputil.DumpRequest(r, false)
+		log.Debug(string(dump))
+	}
+	log.Infof("%s >> %s", r.RemoteAddr, node.Addr)
+
+	ctx := context.WithValue(r.Context(), nodeKey{}, node)
+	ctx = context.WithValue(ctx, logKey{}, log)
+	h.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// match returns the route of the request, the route with the exact host is preferred over the wildcard host,
+// and the route restricted to the client certificate is preferred for the same host.
+func (h *reverseHandler) match(r *http.Request) *xhandler.Route {
+	id, _ := r.Context().Value(identityKey{}).(*tls_util.Identity)
+	if id == nil {
+		id = tls_util.IdentityFromState(r.TLS)
+	}
+
+	host := r.Host
+	if v, _, err := net.SplitHostPort(host); err == nil {
+		host = v
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	var matched *xhandler.Route
+	var score int
+	for i := range h.routes {
+		route := &h.routes[i]
+		if !matchPath(route.Path, r.URL.Path) {
+			continue
+		}
+		if len(route.Clients) > 0 && !id.Match(route.Clients) {
+			continue
+		}
+		v := matchHost(route.Hosts, host) * 2
+		if v == 0 {
+			continue
+		}
+		if len(route.Clients) > 0 {
+			v++
+		}
+		// routes are sorted by the path length, a longer path always wins.
+		if matched != nil && len(matched.Path) > len(route.Path) {
+			break
+		}
+		if v > score {
+			matched, score = route, v
+		}
+	}
+	return matched
+}
+
+// matchPath reports whether the path is under the prefix, the prefix is matched by path segments.
+func matchPath(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) ||
+		strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// matchHost returns the priority of the matched host: 3 for exact host, 2 for wildcard host,
+// 1 for any host and 0 if it is not matched.
+func matchHost(hosts []string, host string) int {
+	if len(hosts) == 0 {
+		return 1
+	}
+	score := 0
+	for _, v := range hosts {
+		v = strings.ToLower(v)
+		if v == host {
+			return 3
+		}
+		if strings.HasPrefix(v, "*.") && strings.HasSuffix(host, v[1:]) {
+			score = 2
+		}
+	}
+	return score
+}
+
+func (h *reverseHandler) director(r *http.Request) {
+	node, _ := r.Context().Value(nodeKey{}).(*chain.Node)
+	if node == nil {
+		return
+	}
+
+	r.URL.Scheme = "http"
+	r.URL.Host = node.Addr
+}
+
+func (h *reverseHandler) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if h.md.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.md.dialTimeout)
+		defer cancel()
+	}
+
+	node, _ := ctx.Value(nodeKey{}).(*chain.Node)
+
+	conn, err := h.router.Dial(ctx, network, addr)
+	if err != nil {
+		if node != nil {
+			node.Marker.Mark()
+		}
+		return nil, err
+	}
+	if node != nil {
+		node.Marker.Reset()
+	}
+	return conn, nil
+}
+
+func (h *reverseHandler) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	log, _ := r.Context().Value(logKey{}).(logger.Logger)
+	if log == nil {
+		log = h.options.Logger
+	}
+	log.Error(err)
+
+	code := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = http.StatusGatewayTimeout
+	}
+	w.WriteHeader(code)
+}
+
+// serverConn notifies when the connection is closed by the server.
+type serverConn struct {
+	net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *serverConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return c.Conn.Close()
+}
+
+// singleConnListener serves a single connection.
+type singleConnListener struct {
+	conn net.Conn
+	addr net.Addr
+	mu   sync.Mutex
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil, net.ErrClosed
+	}
+	conn := l.conn
+	l.conn = nil
+	return conn, nil
+}
+
+func (l *singleConnListener) Close() error {
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.addr
+}
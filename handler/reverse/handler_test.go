@@ -0,0 +1,269 @@
+package reverse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/handler"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+// newBackend starts a backend which replies with its name and the request it received.
+func newBackend(t *testing.T, name string) *chain.NodeGroup {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s %s", name, r.Host, r.URL.RequestURI(), r.Header.Get("X-Forwarded-For"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return chain.NewNodeGroup(&chain.Node{
+		Name:   name,
+		Addr:   srv.Listener.Addr().String(),
+		Marker: &chain.FailMarker{},
+	})
+}
+
+// serve runs the handler on a listener and returns the listener address.
+func serve(t *testing.T, h handler.Handler) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go h.Handle(context.Background(), conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func newHandler(t *testing.T, group *chain.NodeGroup, routes ...xhandler.Route) *reverseHandler {
+	t.Helper()
+
+	h := NewHandler(handler.LoggerOption(xlogger.Nop())).(*reverseHandler)
+	h.Extend(xhandler.RoutesOption(routes...))
+	if group != nil {
+		h.Forward(group)
+	}
+	if err := h.Init(mdx.NewMetadata(nil)); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestReverseRoutes(t *testing.T) {
+	web := newBackend(t, "web")
+	api := newBackend(t, "api")
+	apiV2 := newBackend(t, "api-v2")
+	wildcard := newBackend(t, "wildcard")
+	other := newBackend(t, "any")
+	fallback := newBackend(t, "fallback")
+
+	h := newHandler(t, fallback,
+		xhandler.Route{Name: "web", Hosts: []string{"www.example.com"}, Group: web},
+		xhandler.Route{Name: "wildcard", Hosts: []string{"*.example.com"}, Group: wildcard},
+		xhandler.Route{Name: "api", Hosts: []string{"www.example.com"}, Path: "/api", Group: api},
+		xhandler.Route{Name: "api-v2", Path: "/api/v2/", Group: apiV2},
+		xhandler.Route{Name: "any", Hosts: []string{"Other.com"}, Path: "/", Group: other},
+	)
+	addr := serve(t, h)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "exact host",
+			url:  "http://www.example.com/index.html",
+			want: "web www.example.com /index.html 127.0.0.1",
+		},
+		{
+			name: "host with port",
+			url:  "http://www.example.com:8080/",
+			want: "web www.example.com:8080 / 127.0.0.1",
+		},
+		{
+			name: "host case and trailing dot",
+			url:  "http://WWW.Example.COM./",
+			want: "web WWW.Example.COM. / 127.0.0.1",
+		},
+		{
+			name: "wildcard host",
+			url:  "http://img.example.com/a.png",
+			want: "wildcard img.example.com /a.png 127.0.0.1",
+		},
+		{
+			name: "wildcard does not match the apex",
+			url:  "http://example.com/",
+			want: "fallback example.com / 127.0.0.1",
+		},
+		{
+			name: "path prefix",
+			url:  "http://www.example.com/api/users?id=1",
+			want: "api www.example.com /api/users?id=1 127.0.0.1",
+		},
+		{
+			name: "path prefix exact",
+			url:  "http://www.example.com/api",
+			want: "api www.example.com /api 127.0.0.1",
+		},
+		{
+			name: "path prefix by segment",
+			url:  "http://www.example.com/apis",
+			want: "web www.example.com /apis 127.0.0.1",
+		},
+		{
+			name: "other host of path route",
+			url:  "http://img.example.com/api/users",
+			want: "wildcard img.example.com /api/users 127.0.0.1",
+		},
+		{
+			name: "longer path over exact host",
+			url:  "http://www.example.com/api/v2/users",
+			want: "api-v2 www.example.com /api/v2/users 127.0.0.1",
+		},
+		{
+			name: "path with slash prefix",
+			url:  "http://www.example.com/api/v2",
+			want: "api www.example.com /api/v2 127.0.0.1",
+		},
+		{
+			name: "host of root path",
+			url:  "http://other.com/x",
+			want: "any other.com /x 127.0.0.1",
+		},
+		{
+			name: "fallback",
+			url:  "http://unknown.com/x",
+			want: "fallback unknown.com /x 127.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			b, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || string(b) != tt.want {
+				t.Errorf("got %d %q, want %q", resp.StatusCode, b, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverseUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := ln.Addr().String()
+	ln.Close()
+	marker := &chain.FailMarker{}
+
+	tests := []struct {
+		name   string
+		group  *chain.NodeGroup
+		routes []xhandler.Route
+		status int
+	}{
+		{
+			name:   "no target",
+			routes: []xhandler.Route{{Hosts: []string{"www.example.com"}, Group: newBackend(t, "web")}},
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "dial error",
+			group:  chain.NewNodeGroup(&chain.Node{Name: "dead", Addr: dead, Marker: marker}),
+			status: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHandler(t, tt.group, tt.routes...)
+			addr := serve(t, h)
+
+			resp, err := http.Get("http://" + addr + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("got %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+
+	if marker.FailCount() != 1 {
+		t.Errorf("got fail count %d, want 1", marker.FailCount())
+	}
+}
+
+func TestReverseMatchClients(t *testing.T) {
+	h := newHandler(t, nil,
+		xhandler.Route{Name: "public", Hosts: []string{"www.example.com"}},
+		xhandler.Route{Name: "internal", Hosts: []string{"www.example.com"}, Clients: []string{"*.internal"}},
+		xhandler.Route{Name: "admin", Hosts: []string{"*.example.com"}, Path: "/admin", Clients: []string{"admin"}},
+	)
+
+	tests := []struct {
+		name string
+		url  string
+		id   *tls_util.Identity
+		want string
+	}{
+		{name: "no certificate", url: "http://www.example.com/", want: "public"},
+		{name: "client route", url: "http://www.example.com/", id: &tls_util.Identity{CommonName: "a.internal"}, want: "internal"},
+		{name: "client not matched", url: "http://www.example.com/", id: &tls_util.Identity{CommonName: "other"}, want: "public"},
+		{name: "client path route", url: "http://www.example.com/admin/", id: &tls_util.Identity{CommonName: "admin"}, want: "admin"},
+		{name: "client path route without certificate", url: "http://www.example.com/admin/", want: "public"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.id != nil {
+				r = r.WithContext(context.WithValue(r.Context(), identityKey{}, tt.id))
+			}
+
+			var name string
+			if route := h.match(r); route != nil {
+				name = route.Name
+			}
+			if name != tt.want {
+				t.Errorf("got %q, want %q", name, tt.want)
+			}
+		})
+	}
+}
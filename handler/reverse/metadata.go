@@ -0,0 +1,55 @@
+package reverse
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+const (
+	defaultReadHeaderTimeout = 30 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultIdleConnTimeout   = 90 * time.Second
+)
+
+type metadata struct {
+	readHeaderTimeout     time.Duration
+	idleTimeout           time.Duration
+	dialTimeout           time.Duration
+	responseHeaderTimeout time.Duration
+	idleConnTimeout       time.Duration
+	maxIdleConnsPerHost   int
+	flushInterval         time.Duration
+}
+
+func (h *reverseHandler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		readHeaderTimeout     = "readHeaderTimeout"
+		idleTimeout           = "idleTimeout"
+		dialTimeout           = "dialTimeout"
+		responseHeaderTimeout = "responseHeaderTimeout"
+		idleConnTimeout       = "idleConnTimeout"
+		maxIdleConnsPerHost   = "maxIdleConnsPerHost"
+		flushInterval         = "flushInterval"
+	)
+
+	h.md.readHeaderTimeout = mdx.GetDuration(md, readHeaderTimeout)
+	if h.md.readHeaderTimeout <= 0 {
+		h.md.readHeaderTimeout = defaultReadHeaderTimeout
+	}
+	h.md.idleTimeout = mdx.GetDuration(md, idleTimeout)
+	if h.md.idleTimeout <= 0 {
+		h.md.idleTimeout = defaultIdleTimeout
+	}
+	h.md.dialTimeout = mdx.GetDuration(md, dialTimeout)
+	h.md.responseHeaderTimeout = mdx.GetDuration(md, responseHeaderTimeout)
+	h.md.idleConnTimeout = mdx.GetDuration(md, idleConnTimeout)
+	if h.md.idleConnTimeout <= 0 {
+		h.md.idleConnTimeout = defaultIdleConnTimeout
+	}
+	h.md.maxIdleConnsPerHost = mdx.GetInt(md, maxIdleConnsPerHost)
+	h.md.flushInterval = mdx.GetDuration(md, flushInterval)
+
+	return
+}
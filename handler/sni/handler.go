@@ -3,17 +3,21 @@ package sni
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"io"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/common/bufpool"
 	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	dissector "github.com/go-gost/tls-dissector"
 	netpkg "github.com/hxdcloud/gost-x/internal/net"
@@ -26,8 +30,11 @@ func init() {
 
 type sniHandler struct {
 	httpHandler handler.Handler
+	handlers    map[string]handler.Handler
+	tlsConfig   *tls.Config
 	router      *chain.Router
 	md          metadata
+	handlerOpts []handler.Option
 	options     handler.Options
 }
 
@@ -38,11 +45,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 
 	h := &sniHandler{
-		options: options,
+		handlerOpts: opts,
+		options:     options,
 	}
 
 	if f := registry.HandlerRegistry().Get("http"); f != nil {
-		v := append(opts,
+		v := append(append([]handler.Option{}, opts...),
 			handler.LoggerOption(h.options.Logger.WithFields(map[string]any{"type": "http"})))
 		h.httpHandler = f(v...)
 	}
@@ -68,6 +76,31 @@ func (h *sniHandler) Init(md md.Metadata) (err error) {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
 
+	h.tlsConfig = h.options.TLSConfig
+	if h.md.certificates != nil {
+		h.tlsConfig = h.md.certificates.ServerConfig(h.tlsConfig)
+	}
+
+	// handlers serving the decrypted streams
+	h.handlers = make(map[string]handler.Handler)
+	for _, r := range h.md.routes {
+		if r.handler == "" || h.handlers[r.handler] != nil {
+			continue
+		}
+		f := registry.HandlerRegistry().Get(r.handler)
+		if f == nil || r.handler == "sni" {
+			return fmt.Errorf("sni: unknown handler %s", r.handler)
+		}
+		// copy the options, the appended logger must not be shared by the handlers.
+		v := append(append([]handler.Option{}, h.handlerOpts...),
+			handler.LoggerOption(h.options.Logger.WithFields(map[string]any{"type": r.handler})))
+		hd := f(v...)
+		if err = hd.Init(md); err != nil {
+			return
+		}
+		h.handlers[r.handler] = hd
+	}
+
 	return nil
 }
 
@@ -121,7 +154,16 @@ func (h *sniHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		log.Error(err)
 		return err
 	}
+
+	r := h.route(host)
+	if r != nil && !r.passthrough {
+		return h.terminate(ctx, conn, *buf, host, r, log)
+	}
+
 	target := net.JoinHostPort(host, "443")
+	if r != nil && r.addr != "" {
+		target = r.addr
+	}
 
 	log = log.WithFields(map[string]any{
 		"dst": target,
@@ -147,14 +189,89 @@ func (h *sniHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), target)
-	netpkg.Transport(conn, cc)
+	stats, _ := netpkg.Relay(conn, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", conn.RemoteAddr(), target)
 
 	return nil
 }
 
+// route returns the routing rule of the server name,
+// the exact name is matched first, then the wildcard names and the * rule.
+func (h *sniHandler) route(host string) *route {
+	if len(h.md.routes) == 0 {
+		return nil
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if r := h.md.routes[host]; r != nil {
+		return r
+	}
+	for s := host; ; {
+		n := strings.IndexByte(s, '.')
+		if n <= 0 {
+			break
+		}
+		s = s[n+1:]
+		if r := h.md.routes["*."+s]; r != nil {
+			return r
+		}
+	}
+	return h.md.routes["*"]
+}
+
+// terminate terminates the TLS connection with the certificate of the server name,
+// and forwards the decrypted stream to the backend or the handler of the route.
+func (h *sniHandler) terminate(ctx context.Context, conn net.Conn, hello []byte, host string, r *route, log logger.Logger) error {
+	tlsConn := tls.Server(&cacheConn{
+		Conn: conn,
+		buf:  hello,
+	}, h.tlsConfig)
+
+	conn.SetDeadline(time.Now().Add(h.md.handshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		log.Errorf("tls handshake %s: %v", host, err)
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	if r.handler != "" {
+		log.Debugf("%s: %s >> handler %s", host, conn.RemoteAddr(), r.handler)
+		return h.handlers[r.handler].Handle(ctx, tlsConn)
+	}
+
+	log = log.WithFields(map[string]any{
+		"dst": r.addr,
+	})
+	log.Infof("%s >> %s", conn.RemoteAddr(), r.addr)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(r.addr) {
+		log.Info("bypass: ", r.addr)
+		return nil
+	}
+
+	cc, err := h.router.Dial(ctx, "tcp", r.addr)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Infof("%s <-> %s", conn.RemoteAddr(), r.addr)
+	stats, _ := netpkg.Relay(tlsConn, cc, h.md.transportOptions...)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
+	}).Infof("%s >-< %s", conn.RemoteAddr(), r.addr)
+
+	return nil
+}
+
 func (h *sniHandler) decodeHost(r io.Reader) (opaque []byte, host string, err error) {
 	record, err := dissector.ReadRecord(r)
 	if err != nil {
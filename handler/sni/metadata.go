@@ -1,21 +1,84 @@
 package sni
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
+const (
+	defaultHandshakeTimeout = 10 * time.Second
+
+	routePassthrough = "passthrough"
+	routeHandler     = "handler:"
+)
+
 type metadata struct {
-	readTimeout time.Duration
+	readTimeout      time.Duration
+	handshakeTimeout time.Duration
+	certificates     *tls_util.Certificates
+	routes           map[string]*route
+	transportOptions []netpkg.TransportOption
+}
+
+// route is the routing rule of a server name.
+type route struct {
+	// passthrough forwards the TLS stream as is, otherwise the TLS is terminated.
+	passthrough bool
+	// addr is the backend address, the server name with port 443 is used for passthrough if it is empty.
+	addr string
+	// handler is the type of the handler serving the decrypted stream.
+	handler string
 }
 
 func (h *sniHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+
 	const (
-		readTimeout = "readTimeout"
+		readTimeout      = "readTimeout"
+		handshakeTimeout = "handshakeTimeout"
+		routes           = "routes"
 	)
 
 	h.md.readTimeout = mdx.GetDuration(md, readTimeout)
+	h.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
+	if h.md.handshakeTimeout <= 0 {
+		h.md.handshakeTimeout = defaultHandshakeTimeout
+	}
+
+	if h.md.certificates, err = tls_util.CertificatesFromMetadata(md); err != nil {
+		return
+	}
+
+	// routes is a map of server name to the rule, the rule is one of:
+	// passthrough, passthrough:host:port, handler:type or host:port.
+	// The server name can be a wildcard name (*.example.com), or * to match all the names.
+	if m := mdx.GetStringMapString(md, routes); len(m) > 0 {
+		h.md.routes = make(map[string]*route)
+		for name, v := range m {
+			r := &route{}
+			switch {
+			case v == routePassthrough:
+				r.passthrough = true
+			case strings.HasPrefix(v, routePassthrough+":"):
+				r.passthrough = true
+				r.addr = strings.TrimPrefix(v, routePassthrough+":")
+			case strings.HasPrefix(v, routeHandler):
+				r.handler = strings.TrimPrefix(v, routeHandler)
+			default:
+				r.addr = v
+			}
+			if !r.passthrough && r.addr == "" && r.handler == "" {
+				return fmt.Errorf("sni: invalid route %q of %s", v, name)
+			}
+			h.md.routes[strings.ToLower(name)] = r
+		}
+	}
+
 	return
 }
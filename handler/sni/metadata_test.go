@@ -0,0 +1,41 @@
+package sni
+
+import (
+	"testing"
+
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name  string
+		route string
+		want  *route
+		err   bool
+	}{
+		{name: "passthrough", route: "passthrough", want: &route{passthrough: true}},
+		{name: "passthrough addr", route: "passthrough:10.0.0.1:443", want: &route{passthrough: true, addr: "10.0.0.1:443"}},
+		{name: "handler", route: "handler:http", want: &route{handler: "http"}},
+		{name: "addr", route: "10.0.0.1:80", want: &route{addr: "10.0.0.1:80"}},
+		{name: "empty", route: "", err: true},
+		{name: "empty handler", route: "handler:", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &sniHandler{}
+			err := h.parseMetadata(mdx.NewMetadata(map[string]any{
+				"routes": map[string]any{"Example.com": tt.route},
+			}))
+			if (err != nil) != tt.err {
+				t.Fatalf("err: got %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if r := h.md.routes["example.com"]; r == nil || *r != *tt.want {
+				t.Errorf("got %+v, want %+v", r, tt.want)
+			}
+		})
+	}
+}
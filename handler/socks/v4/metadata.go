@@ -4,14 +4,18 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	readTimeout time.Duration
+	readTimeout      time.Duration
+	transportOptions []netpkg.TransportOption
 }
 
 func (h *socks4Handler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+
 	const (
 		readTimeout = "readTimeout"
 	)
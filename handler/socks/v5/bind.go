@@ -95,7 +95,7 @@ func (h *socks5Handler) serveBind(ctx context.Context, conn net.Conn, ln net.Lis
 			defer close(errc)
 			defer pc1.Close()
 
-			errc <- netpkg.Transport(conn, pc1)
+			errc <- netpkg.Transport(conn, pc1, h.md.transportOptions...)
 		}()
 
 		return errc
@@ -135,8 +135,8 @@ func (h *socks5Handler) serveBind(ctx context.Context, conn net.Conn, ln net.Lis
 
 		start := time.Now()
 		log.Infof("%s <-> %s", rc.LocalAddr(), rc.RemoteAddr())
-		netpkg.Transport(pc2, rc)
-		log.WithFields(map[string]any{"duration": time.Since(start)}).
+		stats, _ := netpkg.Relay(pc2, rc, h.md.transportOptions...)
+		log.WithFields(map[string]any{"duration": time.Since(start), "up": stats.Up, "down": stats.Down}).
 			Infof("%s >-< %s", rc.LocalAddr(), rc.RemoteAddr())
 
 	case err := <-pipe():
@@ -10,7 +10,10 @@ import (
 	"github.com/go-gost/core/handler"
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/gosocks5"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	"github.com/hxdcloud/gost-x/internal/util/socks"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -26,8 +29,10 @@ func init() {
 type socks5Handler struct {
 	selector gosocks5.Selector
 	router   *chain.Router
+	sessions *udp.SessionTable
 	md       metadata
 	options  handler.Options
+	xoptions xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -41,6 +46,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *socks5Handler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *socks5Handler) Init(md md.Metadata) (err error) {
 	if err = h.parseMetadata(md); err != nil {
 		return
@@ -57,10 +68,16 @@ func (h *socks5Handler) Init(md md.Metadata) (err error) {
 		logger:        h.options.Logger,
 		noTLS:         h.md.noTLS,
 	}
+	h.sessions = udp.NewSessionTable(h.xoptions.Service, h.md.sessionOptions)
 
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *socks5Handler) Close() error {
+	return h.sessions.Close()
+}
+
 func (h *socks5Handler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
 	defer conn.Close()
 
@@ -82,7 +99,21 @@ func (h *socks5Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
 	}
 
-	conn = gosocks5.ServerConn(conn, h.selector)
+	selector := h.selector
+	// the client authenticated by the verified certificate needs no more authentication.
+	if name, ok := tls_util.IdentityFromConn(conn).Authenticate(h.options.Auther); ok {
+		log.Debugf("client certificate %s authenticated", name)
+		if v, ok := h.selector.(*serverSelector); ok {
+			sel := *v
+			sel.Authenticator = nil
+			selector = &sel
+		}
+	}
+
+	// the raw conn may carry the UDP datagrams in the QUIC DATAGRAM frames.
+	raw := conn
+	cs := &connSelector{Selector: selector}
+	conn = gosocks5.ServerConn(conn, cs)
 	req, err := gosocks5.ReadRequest(conn)
 	if err != nil {
 		log.Error(err)
@@ -95,6 +126,10 @@ func (h *socks5Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 
 	switch req.Cmd {
 	case gosocks5.CmdConnect:
+		// relay through the negotiated conn, which may be spliced.
+		if cs.conn != nil {
+			conn = cs.conn
+		}
 		return h.handleConnect(ctx, conn, "tcp", address, log)
 	case gosocks5.CmdBind:
 		return h.handleBind(ctx, conn, "tcp", address, log)
@@ -103,7 +138,7 @@ func (h *socks5Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 	case gosocks5.CmdUdp:
 		return h.handleUDP(ctx, conn, log)
 	case socks.CmdUDPTun:
-		return h.handleUDPTun(ctx, conn, "udp", address, log)
+		return h.handleUDPTun(ctx, conn, raw, "udp", address, log)
 	default:
 		err = ErrUnknownCmd
 		log.Error(err)
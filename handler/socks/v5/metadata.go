@@ -5,6 +5,9 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
@@ -15,9 +18,16 @@ type metadata struct {
 	enableUDP         bool
 	udpBufferSize     int
 	compatibilityMode bool
+	transportOptions  []netpkg.TransportOption
+	sessionOptions    udp.SessionOptions
+	muxCfg            *mux.Config
 }
 
 func (h *socks5Handler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+	h.md.sessionOptions = udp.SessionOptionsFromMetadata(md)
+	h.md.muxCfg = mux.ConfigFromMetadata(md)
+
 	const (
 		readTimeout       = "readTimeout"
 		noTLS             = "notls"
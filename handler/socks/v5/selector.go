@@ -88,3 +88,18 @@ func (s *serverSelector) OnSelected(method uint8, conn net.Conn) (net.Conn, erro
 
 	return conn, nil
 }
+
+// connSelector records the conn returned by the selector,
+// which the socks5 conn reads and writes through after the handshake.
+type connSelector struct {
+	gosocks5.Selector
+	conn net.Conn
+}
+
+func (s *connSelector) OnSelected(method uint8, conn net.Conn) (net.Conn, error) {
+	c, err := s.Selector.OnSelected(method, conn)
+	if err == nil {
+		s.conn = c
+	}
+	return c, err
+}
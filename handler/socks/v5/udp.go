@@ -68,8 +68,12 @@ func (h *socks5Handler) handleUDP(ctx context.Context, conn net.Conn, log logger
 		return err
 	}
 
+	session := h.sessions.NewSession(conn.RemoteAddr())
+	defer session.Close()
+
 	r := udp.NewRelay(socks.UDPConn(cc, h.md.udpBufferSize), pc).
 		WithBypass(h.options.Bypass).
+		WithSession(session).
 		WithLogger(log)
 	r.SetBufferSize(h.md.udpBufferSize)
 
@@ -11,7 +11,7 @@ import (
 	"github.com/hxdcloud/gost-x/internal/util/socks"
 )
 
-func (h *socks5Handler) handleUDPTun(ctx context.Context, conn net.Conn, network, address string, log logger.Logger) error {
+func (h *socks5Handler) handleUDPTun(ctx context.Context, conn, raw net.Conn, network, address string, log logger.Logger) error {
 	log = log.WithFields(map[string]any{
 		"cmd": "udp-tun",
 	})
@@ -56,8 +56,12 @@ func (h *socks5Handler) handleUDPTun(ctx context.Context, conn net.Conn, network
 	log.Debug(reply)
 	log.Debugf("bind on %s OK", pc.LocalAddr())
 
-	r := udp.NewRelay(socks.UDPTunServerConn(conn), pc).
+	session := h.sessions.NewSession(conn.RemoteAddr())
+	defer session.Close()
+
+	r := udp.NewRelay(udp.UDPServerConn(raw, socks.UDPTunServerConn(conn)), pc).
 		WithBypass(h.options.Bypass).
+		WithSession(session).
 		WithLogger(log)
 	r.SetBufferSize(h.md.udpBufferSize)
 
@@ -4,15 +4,19 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	key         string
-	readTimeout time.Duration
+	key              string
+	readTimeout      time.Duration
+	transportOptions []netpkg.TransportOption
 }
 
 func (h *ssHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+
 	const (
 		key         = "key"
 		readTimeout = "readTimeout"
@@ -11,6 +11,8 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	"github.com/hxdcloud/gost-x/internal/util/relay"
 	"github.com/hxdcloud/gost-x/internal/util/ss"
 	"github.com/hxdcloud/gost-x/registry"
@@ -22,10 +24,12 @@ func init() {
 }
 
 type ssuHandler struct {
-	cipher  core.Cipher
-	router  *chain.Router
-	md      metadata
-	options handler.Options
+	cipher   core.Cipher
+	router   *chain.Router
+	sessions *udp.SessionTable
+	md       metadata
+	options  handler.Options
+	xoptions xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -39,6 +43,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *ssuHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *ssuHandler) Init(md md.Metadata) (err error) {
 	if err = h.parseMetadata(md); err != nil {
 		return
@@ -57,10 +67,16 @@ func (h *ssuHandler) Init(md md.Metadata) (err error) {
 	if h.router == nil {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
+	h.sessions = udp.NewSessionTable(h.xoptions.Service, h.md.sessionOptions)
 
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *ssuHandler) Close() error {
+	return h.sessions.Close()
+}
+
 func (h *ssuHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
 	defer conn.Close()
 
@@ -107,16 +123,19 @@ func (h *ssuHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		return err
 	}
 
+	session := h.sessions.NewSession(conn.RemoteAddr())
+	defer session.Close()
+
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.LocalAddr(), cc.LocalAddr())
-	h.relayPacket(pc, cc, log)
+	h.relayPacket(pc, cc, session, log)
 	log.WithFields(map[string]any{"duration": time.Since(t)}).
 		Infof("%s >-< %s", conn.LocalAddr(), cc.LocalAddr())
 
 	return nil
 }
 
-func (h *ssuHandler) relayPacket(pc1, pc2 net.PacketConn, log logger.Logger) (err error) {
+func (h *ssuHandler) relayPacket(pc1, pc2 net.PacketConn, session *udp.Session, log logger.Logger) (err error) {
 	bufSize := h.md.bufferSize
 	errc := make(chan error, 2)
 
@@ -136,6 +155,11 @@ func (h *ssuHandler) relayPacket(pc1, pc2 net.PacketConn, log logger.Logger) (er
 					return nil
 				}
 
+				if !session.Outbound(addr.String(), n) {
+					log.Debugf("max flows exceeded, drop datagram to %s", addr)
+					return nil
+				}
+
 				if _, err = pc2.WriteTo((*b)[:n], addr); err != nil {
 					return err
 				}
@@ -168,6 +192,11 @@ func (h *ssuHandler) relayPacket(pc1, pc2 net.PacketConn, log logger.Logger) (er
 					return nil
 				}
 
+				if !session.Inbound(raddr.String(), n) {
+					log.Debugf("port restricted NAT, drop datagram from %s", raddr)
+					return nil
+				}
+
 				if _, err = pc1.WriteTo((*b)[:n], raddr); err != nil {
 					return err
 				}
@@ -5,13 +5,15 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
 type metadata struct {
-	key         string
-	readTimeout time.Duration
-	bufferSize  int
+	key            string
+	readTimeout    time.Duration
+	bufferSize     int
+	sessionOptions udp.SessionOptions
 }
 
 func (h *ssuHandler) parseMetadata(md mdata.Metadata) (err error) {
@@ -21,6 +23,8 @@ func (h *ssuHandler) parseMetadata(md mdata.Metadata) (err error) {
 		bufferSize  = "bufferSize"
 	)
 
+	h.md.sessionOptions = udp.SessionOptionsFromMetadata(md)
+
 	h.md.key = mdx.GetString(md, key)
 	h.md.readTimeout = mdx.GetDuration(md, readTimeout)
 
@@ -101,9 +101,11 @@ func (h *forwardHandler) handleDirectForward(ctx context.Context, conn *sshd_uti
 
 	t := time.Now()
 	log.Infof("%s <-> %s", cc.LocalAddr(), targetAddr)
-	netpkg.Transport(conn, cc)
+	stats, _ := netpkg.Relay(conn, cc, h.md.transportOptions...)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
 	}).Infof("%s >-< %s", cc.LocalAddr(), targetAddr)
 
 	return nil
@@ -199,9 +201,11 @@ func (h *forwardHandler) handleRemoteForward(ctx context.Context, conn *sshd_uti
 
 				t := time.Now()
 				log.Infof("%s <-> %s", conn.LocalAddr(), conn.RemoteAddr())
-				netpkg.Transport(ch, conn)
+				stats, _ := netpkg.Relay(ch, conn, h.md.transportOptions...)
 				log.WithFields(map[string]any{
 					"duration": time.Since(t),
+					"up":       stats.Up,
+					"down":     stats.Down,
 				}).Infof("%s >-< %s", conn.LocalAddr(), conn.RemoteAddr())
 			}(cc)
 		}
@@ -2,11 +2,15 @@ package ssh
 
 import (
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
 )
 
 type metadata struct {
+	transportOptions []netpkg.TransportOption
 }
 
 func (h *forwardHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+
 	return
 }
@@ -0,0 +1,100 @@
+package tap
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	tap_util "github.com/hxdcloud/gost-x/internal/util/tap"
+)
+
+// tapPeer is a remote peer of the bridge on the server side.
+type tapPeer struct {
+	addr    net.Addr
+	vlans   map[uint16]bool
+	limiter *tap_util.Limiter
+	seen    time.Time
+	mu      sync.Mutex
+}
+
+func (p *tapPeer) touch() {
+	p.mu.Lock()
+	p.seen = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *tapPeer) expired(aging time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return time.Since(p.seen) > aging
+}
+
+// member reports whether the peer is a member of the VLAN.
+// The untagged frames are allowed only for the unrestricted peers,
+// a restricted peer must tag its frames, the untagged ones are neither accepted from nor delivered to it.
+func (p *tapPeer) member(vlan uint16) bool {
+	if p.vlans == nil {
+		return true
+	}
+	return vlan != 0 && p.vlans[vlan]
+}
+
+func (h *tapHandler) getPeer(addr net.Addr) *tapPeer {
+	if v, ok := h.peers.Load(addr.String()); ok {
+		p := v.(*tapPeer)
+		p.touch()
+		return p
+	}
+
+	p := &tapPeer{
+		addr:    addr,
+		vlans:   h.peerVLANs(addr),
+		limiter: tap_util.NewLimiter(h.md.broadcastRate, h.md.broadcastBurst),
+		seen:    time.Now(),
+	}
+	if v, loaded := h.peers.LoadOrStore(addr.String(), p); loaded {
+		return v.(*tapPeer)
+	}
+	return p
+}
+
+// peerVLANs returns the VLAN membership of the peer, nil means the peer is a member of all the VLANs.
+func (h *tapHandler) peerVLANs(addr net.Addr) map[uint16]bool {
+	var ip net.IP
+	if v, _ := addr.(*net.UDPAddr); v != nil {
+		ip = v.IP
+	}
+	for _, m := range h.md.peerVLANs {
+		if (m.addr != "" && m.addr == addr.String()) || (m.net != nil && ip != nil && m.net.Contains(ip)) {
+			return m.vlans
+		}
+	}
+	return nil
+}
+
+// allowedVLAN reports whether the VLAN is allowed on the bridge.
+func (h *tapHandler) allowedVLAN(vlan uint16) bool {
+	return vlan == 0 || h.md.vlans == nil || h.md.vlans[vlan]
+}
+
+// flood sends the frame to all the peers which are members of the VLAN except the source.
+func (h *tapHandler) flood(conn net.PacketConn, b []byte, vlan uint16, except net.Addr, log logger.Logger) {
+	h.peers.Range(func(k, v any) bool {
+		p := v.(*tapPeer)
+		if p.expired(h.md.macAge) {
+			h.peers.Delete(k)
+			h.macs.DelPeer(p.addr)
+			log.Debugf("peer %s expired", p.addr)
+			return true
+		}
+		if (except != nil && k.(string) == except.String()) || !p.member(vlan) {
+			return true
+		}
+		if _, err := conn.WriteTo(b, p.addr); err != nil {
+			log.Debug(err)
+		}
+		return true
+	})
+}
@@ -0,0 +1,121 @@
+package tap
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+// recordConn records the destinations of the written packets.
+type recordConn struct {
+	net.PacketConn
+	dsts []string
+}
+
+func (c *recordConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.dsts = append(c.dsts, addr.String())
+	return len(b), nil
+}
+
+func newTestHandler(t *testing.T, md map[string]any) *tapHandler {
+	t.Helper()
+
+	h := NewHandler().(*tapHandler)
+	if err := h.parseMetadata(mdx.NewMetadata(md)); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestVLANFiltering(t *testing.T) {
+	h := newTestHandler(t, map[string]any{
+		"vlans": "10,20-21",
+		"peerVLANs": map[string]any{
+			"10.0.0.0/24":   "10",
+			"10.0.0.2":      "20-21",
+			"10.0.1.1:8000": "21",
+		},
+	})
+
+	tests := []struct {
+		name    string
+		addr    string
+		vlan    uint16
+		allowed bool
+		member  bool
+	}{
+		{name: "unrestricted untagged", addr: "10.0.2.1:8000", vlan: 0, allowed: true, member: true},
+		{name: "unrestricted tagged", addr: "10.0.2.1:8000", vlan: 10, allowed: true, member: true},
+		{name: "network member", addr: "10.0.0.1:8000", vlan: 10, allowed: true, member: true},
+		{name: "network not member", addr: "10.0.0.1:8000", vlan: 20, allowed: true, member: false},
+		{name: "network untagged", addr: "10.0.0.1:8000", vlan: 0, allowed: true, member: false},
+		{name: "ip overrides network", addr: "10.0.0.2:8000", vlan: 21, allowed: true, member: true},
+		{name: "ip overrides network not member", addr: "10.0.0.2:8000", vlan: 10, allowed: true, member: false},
+		{name: "addr member", addr: "10.0.1.1:8000", vlan: 21, allowed: true, member: true},
+		{name: "addr untagged", addr: "10.0.1.1:8000", vlan: 0, allowed: true, member: false},
+		{name: "not allowed", addr: "10.0.2.1:8000", vlan: 30, allowed: false, member: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, _ := net.ResolveUDPAddr("udp", tt.addr)
+			p := &tapPeer{vlans: h.peerVLANs(addr)}
+			if v := h.allowedVLAN(tt.vlan); v != tt.allowed {
+				t.Errorf("allowed: got %v, want %v", v, tt.allowed)
+			}
+			if v := p.member(tt.vlan); v != tt.member {
+				t.Errorf("member: got %v, want %v", v, tt.member)
+			}
+		})
+	}
+}
+
+func TestFlood(t *testing.T) {
+	h := newTestHandler(t, map[string]any{
+		"peerVLANs": map[string]any{
+			"10.0.0.1": "10",
+			"10.0.0.2": "20",
+		},
+	})
+
+	peers := []string{"10.0.0.1:8000", "10.0.0.2:8000", "10.0.0.3:8000"}
+	for _, s := range peers {
+		addr, _ := net.ResolveUDPAddr("udp", s)
+		h.getPeer(addr)
+	}
+	except, _ := net.ResolveUDPAddr("udp", "10.0.0.3:8000")
+	h.md.macAge = time.Hour
+
+	tests := []struct {
+		name   string
+		vlan   uint16
+		except net.Addr
+		dsts   []string
+	}{
+		{name: "untagged", vlan: 0, dsts: []string{"10.0.0.3:8000"}},
+		{name: "vlan 10", vlan: 10, dsts: []string{"10.0.0.1:8000", "10.0.0.3:8000"}},
+		{name: "vlan 20 except", vlan: 20, except: except, dsts: []string{"10.0.0.2:8000"}},
+		{name: "vlan 30", vlan: 30, dsts: []string{"10.0.0.3:8000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &recordConn{}
+			h.flood(conn, make([]byte, 14), tt.vlan, tt.except, xlogger.Nop())
+
+			sort.Strings(conn.dsts)
+			if len(conn.dsts) != len(tt.dsts) {
+				t.Fatalf("got %v, want %v", conn.dsts, tt.dsts)
+			}
+			for i := range tt.dsts {
+				if conn.dsts[i] != tt.dsts[i] {
+					t.Fatalf("got %v, want %v", conn.dsts, tt.dsts)
+				}
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xhandler "github.com/hxdcloud/gost-x/handler"
 	"github.com/hxdcloud/gost-x/internal/util/ss"
 	tap_util "github.com/hxdcloud/gost-x/internal/util/tap"
 	"github.com/hxdcloud/gost-x/registry"
@@ -28,13 +29,16 @@ func init() {
 }
 
 type tapHandler struct {
-	group   *chain.NodeGroup
-	routes  sync.Map
-	exit    chan struct{}
-	cipher  core.Cipher
-	router  *chain.Router
-	md      metadata
-	options handler.Options
+	group    *chain.NodeGroup
+	macs     *tap_util.MACTable
+	peers    sync.Map
+	limiter  *tap_util.Limiter
+	exit     chan struct{}
+	cipher   core.Cipher
+	router   *chain.Router
+	md       metadata
+	options  handler.Options
+	xoptions xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -49,6 +53,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *tapHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *tapHandler) Init(md md.Metadata) (err error) {
 	if err = h.parseMetadata(md); err != nil {
 		return
@@ -68,6 +78,10 @@ func (h *tapHandler) Init(md md.Metadata) (err error) {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
 
+	h.macs = tap_util.NewMACTable(h.xoptions.Service, h.md.macAge)
+	// the broadcast frames from the local tap device.
+	h.limiter = tap_util.NewLimiter(h.md.broadcastRate, h.md.broadcastBurst)
+
 	return
 }
 
@@ -201,37 +215,57 @@ func (h *tapHandler) transport(tap net.Conn, conn net.PacketConn, raddr net.Addr
 					return err
 				}
 
-				src := waterutil.MACSource((*b)[:n])
-				dst := waterutil.MACDestination((*b)[:n])
-				eType := etherType(waterutil.MACEthertype((*b)[:n]))
+				frame := (*b)[:n]
+				if len(frame) < 14 {
+					return nil
+				}
 
-				log.Debugf("%s >> %s %s %d", src, dst, eType, n)
+				src := waterutil.MACSource(frame)
+				dst := waterutil.MACDestination(frame)
+				eType := etherType(waterutil.MACEthertype(frame))
+				vlan := tap_util.VLAN(frame)
+
+				log.Debugf("%s >> %s %s %d vlan %d", src, dst, eType, n, vlan)
 
 				// client side, deliver frame directly.
 				if raddr != nil {
-					_, err := conn.WriteTo((*b)[:n], raddr)
+					if h.md.vlan > 0 {
+						frame = tap_util.TagVLAN(frame, h.md.vlan)
+					}
+					_, err := conn.WriteTo(frame, raddr)
 					return err
 				}
 
+				if !h.allowedVLAN(vlan) {
+					log.Debugf("vlan %d not allowed, discarded", vlan)
+					return nil
+				}
+
+				if h.macs.Learn(src, vlan, nil) {
+					log.Debugf("new route: %s vlan %d -> local", src, vlan)
+				}
+
 				// server side, broadcast.
-				if waterutil.IsBroadcast(dst) {
-					go h.routes.Range(func(k, v any) bool {
-						conn.WriteTo((*b)[:n], v.(net.Addr))
-						return true
-					})
+				if tap_util.IsMulticast(dst) {
+					if !h.limiter.Allow() {
+						log.Debugf("broadcast rate limit exceeded: %s -> %s", src, dst)
+						return nil
+					}
+					h.flood(conn, frame, vlan, nil, log)
 					return nil
 				}
 
-				var addr net.Addr
-				if v, ok := h.routes.Load(hwAddrToTapRouteKey(dst)); ok {
-					addr = v.(net.Addr)
+				addr, ok := h.macs.Lookup(dst, vlan)
+				if !ok {
+					// unknown unicast.
+					h.flood(conn, frame, vlan, nil, log)
+					return nil
 				}
 				if addr == nil {
-					log.Warnf("no route for %s -> %s %s %d", src, dst, eType, n)
 					return nil
 				}
 
-				if _, err := conn.WriteTo((*b)[:n], addr); err != nil {
+				if _, err := conn.WriteTo(frame, addr); err != nil {
 					return err
 				}
 
@@ -257,46 +291,62 @@ func (h *tapHandler) transport(tap net.Conn, conn net.PacketConn, raddr net.Addr
 					return err
 				}
 
-				src := waterutil.MACSource((*b)[:n])
-				dst := waterutil.MACDestination((*b)[:n])
-				eType := etherType(waterutil.MACEthertype((*b)[:n]))
+				frame := (*b)[:n]
+				if len(frame) < 14 {
+					return nil
+				}
+
+				src := waterutil.MACSource(frame)
+				dst := waterutil.MACDestination(frame)
+				eType := etherType(waterutil.MACEthertype(frame))
+				vlan := tap_util.VLAN(frame)
 
-				log.Debugf("%s >> %s %s %d", src, dst, eType, n)
+				log.Debugf("%s >> %s %s %d vlan %d", src, dst, eType, n, vlan)
 
 				// client side, deliver frame to tap device.
 				if raddr != nil {
-					_, err := tap.Write((*b)[:n])
+					if h.md.vlan > 0 {
+						if vlan != h.md.vlan {
+							return nil
+						}
+						frame = tap_util.UntagVLAN(frame)
+					}
+					_, err := tap.Write(frame)
 					return err
 				}
 
-				// server side, record route.
-				rkey := hwAddrToTapRouteKey(src)
-				if actual, loaded := h.routes.LoadOrStore(rkey, addr); loaded {
-					if actual.(net.Addr).String() != addr.String() {
-						log.Debugf("update route: %s -> %s (old %s)",
-							src, addr, actual.(net.Addr))
-						h.routes.Store(rkey, addr)
-					}
-				} else {
-					log.Debugf("new route: %s -> %s", src, addr)
+				if !h.allowedVLAN(vlan) {
+					log.Debugf("%s: vlan %d not allowed, discarded", addr, vlan)
+					return nil
 				}
 
-				if waterutil.IsBroadcast(dst) {
-					go h.routes.Range(func(k, v any) bool {
-						if k.(tapRouteKey) != rkey {
-							conn.WriteTo((*b)[:n], v.(net.Addr))
-						}
-						return true
-					})
+				peer := h.getPeer(addr)
+				if !peer.member(vlan) {
+					log.Debugf("%s: not a member of vlan %d, discarded", addr, vlan)
+					return nil
 				}
 
-				if v, ok := h.routes.Load(hwAddrToTapRouteKey(dst)); ok {
+				// server side, record route.
+				if h.macs.Learn(src, vlan, addr) {
+					log.Debugf("new route: %s vlan %d -> %s", src, vlan, addr)
+				}
+
+				if tap_util.IsMulticast(dst) {
+					if !peer.limiter.Allow() {
+						log.Debugf("%s: broadcast rate limit exceeded: %s -> %s", addr, src, dst)
+						return nil
+					}
+					h.flood(conn, frame, vlan, addr, log)
+				} else if v, ok := h.macs.Lookup(dst, vlan); ok && v != nil {
 					log.Debugf("find route: %s -> %s", dst, v)
-					_, err := conn.WriteTo((*b)[:n], v.(net.Addr))
+					_, err := conn.WriteTo(frame, v)
 					return err
+				} else if !ok {
+					// unknown unicast.
+					h.flood(conn, frame, vlan, addr, log)
 				}
 
-				if _, err := tap.Write((*b)[:n]); err != nil {
+				if _, err := tap.Write(frame); err != nil {
 					select {
 					case h.exit <- struct{}{}:
 					default:
@@ -333,10 +383,3 @@ func etherType(et waterutil.Ethertype) string {
 	}
 	return fmt.Sprintf("unknown(%v)", et)
 }
-
-type tapRouteKey [6]byte
-
-func hwAddrToTapRouteKey(addr net.HardwareAddr) (key tapRouteKey) {
-	copy(key[:], addr)
-	return
-}
@@ -1,19 +1,50 @@
 package tap
 
 import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	mdata "github.com/go-gost/core/metadata"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
+const (
+	defaultMACAge = 300 * time.Second
+	maxVLAN       = 4094
+)
+
 type metadata struct {
-	key        string
-	bufferSize int
+	key            string
+	bufferSize     int
+	macAge         time.Duration
+	vlan           uint16
+	vlans          map[uint16]bool
+	peerVLANs      []vlanMembership
+	broadcastRate  float64
+	broadcastBurst int
+}
+
+// vlanMembership is the VLAN membership of the peers matched by the address or the network.
+type vlanMembership struct {
+	addr  string
+	net   *net.IPNet
+	vlans map[uint16]bool
 }
 
 func (h *tapHandler) parseMetadata(md mdata.Metadata) (err error) {
 	const (
-		key        = "key"
-		bufferSize = "bufferSize"
+		key            = "key"
+		bufferSize     = "bufferSize"
+		macAge         = "macAge"
+		vlan           = "vlan"
+		vlans          = "vlans"
+		peerVLANs      = "peerVLANs"
+		broadcastRate  = "broadcastRate"
+		broadcastBurst = "broadcastBurst"
 	)
 
 	h.md.key = mdx.GetString(md, key)
@@ -21,5 +52,91 @@ func (h *tapHandler) parseMetadata(md mdata.Metadata) (err error) {
 	if h.md.bufferSize <= 0 {
 		h.md.bufferSize = 1500
 	}
+
+	h.md.macAge = mdx.GetDuration(md, macAge)
+	if h.md.macAge <= 0 {
+		h.md.macAge = defaultMACAge
+	}
+
+	if v := mdx.GetInt(md, vlan); v > 0 {
+		if v > maxVLAN {
+			return fmt.Errorf("tap: invalid vlan %d", v)
+		}
+		h.md.vlan = uint16(v)
+	}
+	if md.IsExists(vlans) {
+		if h.md.vlans, err = parseVLANs(md.Get(vlans)); err != nil {
+			return
+		}
+	}
+
+	for k, v := range mdx.GetStringMap(md, peerVLANs) {
+		m := vlanMembership{}
+		if _, ipNet, err := net.ParseCIDR(k); err == nil {
+			m.net = ipNet
+		} else if ip := net.ParseIP(k); ip != nil {
+			if v := ip.To4(); v != nil {
+				ip = v
+			}
+			m.net = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+		} else {
+			m.addr = k
+		}
+		if m.vlans, err = parseVLANs(v); err != nil {
+			return
+		}
+		h.md.peerVLANs = append(h.md.peerVLANs, m)
+	}
+	// the most specific one wins.
+	sort.Slice(h.md.peerVLANs, func(i, j int) bool {
+		return prefixLen(h.md.peerVLANs[i]) > prefixLen(h.md.peerVLANs[j])
+	})
+
+	h.md.broadcastRate = mdx.GetFloat(md, broadcastRate)
+	h.md.broadcastBurst = mdx.GetInt(md, broadcastBurst)
 	return
 }
+
+func prefixLen(m vlanMembership) int {
+	if m.net == nil {
+		return 129
+	}
+	ones, _ := m.net.Mask.Size()
+	return ones
+}
+
+// parseVLANs parses the VLAN IDs in a list or a comma separated string, the ranges such as 100-200 are allowed.
+func parseVLANs(v any) (map[uint16]bool, error) {
+	var items []string
+	switch vv := v.(type) {
+	case []any:
+		for _, s := range vv {
+			items = append(items, fmt.Sprintf("%v", s))
+		}
+	case []string:
+		items = vv
+	default:
+		items = strings.Split(fmt.Sprintf("%v", vv), ",")
+	}
+
+	vlans := make(map[uint16]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		lo, hi, found := strings.Cut(item, "-")
+		if !found {
+			hi = lo
+		}
+		start, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		end, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || start < 1 || end > maxVLAN || start > end {
+			return nil, fmt.Errorf("tap: invalid vlan %s", item)
+		}
+		for i := start; i <= end; i++ {
+			vlans[uint16(i)] = true
+		}
+	}
+	return vlans, nil
+}
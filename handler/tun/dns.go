@@ -0,0 +1,97 @@
+package tun
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+	"github.com/miekg/dns"
+)
+
+// handleDNS answers the DNS queries hijacked from the tun device.
+// The A and AAAA queries are answered from the fake-IP pool if it is set,
+// the others are exchanged with the DNS server through the router.
+func (h *tunHandler) handleDNS(ctx context.Context, conn net.Conn, log logger.Logger) error {
+	b := bufpool.Get(h.md.bufferSize)
+	defer bufpool.Put(b)
+
+	for {
+		if h.md.sessionOptions.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(h.md.sessionOptions.IdleTimeout))
+		}
+		n, err := conn.Read(*b)
+		if err != nil {
+			return err
+		}
+
+		reply, err := h.exchangeDNS(ctx, (*b)[:n], log)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *tunHandler) exchangeDNS(ctx context.Context, msg []byte, log logger.Logger) ([]byte, error) {
+	mq := dns.Msg{}
+	if err := mq.Unpack(msg); err != nil {
+		return nil, err
+	}
+
+	if mr := h.lookupFakeIP(&mq, log); mr != nil {
+		return mr.Pack()
+	}
+
+	log.Debugf("dns hijack: exchange message %d via %s", mq.Id, h.exchanger.String())
+	return h.exchanger.Exchange(ctx, msg)
+}
+
+// lookupFakeIP allocates a fake IP from the pool for the queried name,
+// the names matched by the bypass are resolved by the DNS server as usual.
+func (h *tunHandler) lookupFakeIP(r *dns.Msg, log logger.Logger) *dns.Msg {
+	if h.fakeIPs == nil || len(r.Question) != 1 ||
+		r.Question[0].Qclass != dns.ClassINET ||
+		(r.Question[0].Qtype != dns.TypeA && r.Question[0].Qtype != dns.TypeAAAA) {
+		return nil
+	}
+
+	host := strings.TrimSuffix(r.Question[0].Name, ".")
+	if h.options.Bypass != nil && h.options.Bypass.Contains(host) {
+		log.Debugf("fakeip bypass: %s", host)
+		return nil
+	}
+
+	m := &dns.Msg{}
+	m.SetReply(r)
+
+	hdr := dns.RR_Header{
+		Name:   r.Question[0].Name,
+		Rrtype: r.Question[0].Qtype,
+		Class:  dns.ClassINET,
+		Ttl:    uint32(h.md.fakeIPTTL.Seconds()),
+	}
+
+	// the query of the other address family gets an empty answer.
+	switch r.Question[0].Qtype {
+	case dns.TypeA:
+		if h.fakeIPs.IsIPv4() {
+			ip := h.fakeIPs.Allocate(host)
+			log.Debugf("fakeip: %s -> %s", host, ip)
+			m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: ip})
+		}
+	case dns.TypeAAAA:
+		if !h.fakeIPs.IsIPv4() {
+			ip := h.fakeIPs.Allocate(host)
+			log.Debugf("fakeip: %s -> %s", host, ip)
+			m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,105 @@
+package tun
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/go-gost/core/handler"
+	"github.com/hxdcloud/gost-x/bypass"
+	"github.com/hxdcloud/gost-x/internal/util/fakeip"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	"github.com/miekg/dns"
+)
+
+// testExchanger answers all the queries with the fixed address.
+type testExchanger struct {
+	n int
+}
+
+func (ex *testExchanger) Exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	ex.n++
+
+	mq := dns.Msg{}
+	if err := mq.Unpack(msg); err != nil {
+		return nil, err
+	}
+	m := &dns.Msg{}
+	m.SetReply(&mq)
+	m.Answer = append(m.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: mq.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.IPv4(1, 1, 1, 1),
+	})
+	return m.Pack()
+}
+
+func (ex *testExchanger) String() string {
+	return "test"
+}
+
+func TestExchangeDNS(t *testing.T) {
+	_, inet, _ := net.ParseCIDR("198.18.0.0/16")
+	pool, err := fakeip.NewPool(inet, fakeip.LoggerOption(xlogger.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		qname    string
+		qtype    uint16
+		fakeIP   bool
+		answers  int
+		exchange bool
+	}{
+		{name: "fakeip", qname: "example.com.", qtype: dns.TypeA, fakeIP: true, answers: 1},
+		{name: "fakeip other family", qname: "example.com.", qtype: dns.TypeAAAA, fakeIP: true, answers: 0},
+		{name: "fakeip bypass", qname: "bypass.example.com.", qtype: dns.TypeA, fakeIP: true, answers: 1, exchange: true},
+		{name: "fakeip other type", qname: "example.com.", qtype: dns.TypeMX, fakeIP: true, answers: 1, exchange: true},
+		{name: "no fakeip", qname: "example.com.", qtype: dns.TypeA, answers: 1, exchange: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ex := &testExchanger{}
+			h := NewHandler(
+				handler.BypassOption(bypass.NewBypass(
+					bypass.MatchersOption([]string{"bypass.example.com"}),
+					bypass.LoggerOption(xlogger.Nop()),
+				)),
+			).(*tunHandler)
+			h.exchanger = ex
+			if tt.fakeIP {
+				h.fakeIPs = pool
+			}
+
+			mq := &dns.Msg{}
+			mq.SetQuestion(tt.qname, tt.qtype)
+			msg, err := mq.Pack()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reply, err := h.exchangeDNS(context.Background(), msg, xlogger.Nop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			mr := &dns.Msg{}
+			if err := mr.Unpack(reply); err != nil {
+				t.Fatal(err)
+			}
+
+			if mr.Id != mq.Id || len(mr.Answer) != tt.answers {
+				t.Fatalf("got %v", mr)
+			}
+			if exchanged := ex.n > 0; exchanged != tt.exchange {
+				t.Errorf("exchanged: got %v, want %v", exchanged, tt.exchange)
+			}
+			if !tt.exchange && tt.answers > 0 {
+				if ip := mr.Answer[0].(*dns.A).A; !pool.Contains(ip) {
+					t.Errorf("%s is not a fake IP", ip)
+				}
+			}
+		})
+	}
+}
@@ -15,9 +15,13 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xhandler "github.com/hxdcloud/gost-x/handler"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	"github.com/hxdcloud/gost-x/internal/util/fakeip"
 	"github.com/hxdcloud/gost-x/internal/util/ss"
 	tun_util "github.com/hxdcloud/gost-x/internal/util/tun"
 	"github.com/hxdcloud/gost-x/registry"
+	"github.com/hxdcloud/gost-x/resolver/exchanger"
 	"github.com/shadowsocks/go-shadowsocks2/core"
 	"github.com/shadowsocks/go-shadowsocks2/shadowaead"
 	"github.com/songgao/water/waterutil"
@@ -30,13 +34,25 @@ func init() {
 }
 
 type tunHandler struct {
-	group   *chain.NodeGroup
-	routes  sync.Map
-	exit    chan struct{}
-	cipher  core.Cipher
-	router  *chain.Router
-	md      metadata
-	options handler.Options
+	group *chain.NodeGroup
+	table *tun_util.RouteTable
+	// device is the tun device, the client adds the static IPs assigned by the server to it.
+	device  tun_util.Device
+	auth    *tun_util.PeerAuthenticator
+	fakeIPs *fakeip.Pool
+	// exchanger sends the hijacked DNS queries to the DNS server through the router.
+	exchanger exchanger.Exchanger
+	sessions  sync.Map
+	// udpSessions is the NAT table of the UDP flows in the stack mode.
+	udpSessions *udp.SessionTable
+	// authenticated is set by the client after the server accepts the authentication.
+	authenticated uint32
+	exit          chan struct{}
+	cipher        core.Cipher
+	router        *chain.Router
+	md            metadata
+	options       handler.Options
+	xoptions      xhandler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.Handler {
@@ -51,6 +67,12 @@ func NewHandler(opts ...handler.Option) handler.Handler {
 	}
 }
 
+func (h *tunHandler) Extend(opts ...xhandler.Option) {
+	for _, opt := range opts {
+		opt(&h.xoptions)
+	}
+}
+
 func (h *tunHandler) Init(md md.Metadata) (err error) {
 	if err = h.parseMetadata(md); err != nil {
 		return
@@ -70,9 +92,44 @@ func (h *tunHandler) Init(md md.Metadata) (err error) {
 		h.router = (&chain.Router{}).WithLogger(h.options.Logger)
 	}
 
+	if h.md.fakeIP != "" {
+		if h.fakeIPs, err = fakeip.Get(h.md.fakeIP, fakeip.LoggerOption(h.options.Logger)); err != nil {
+			return
+		}
+	}
+
+	if h.md.dns != "" {
+		h.exchanger, err = exchanger.NewExchanger(h.md.dns,
+			exchanger.RouterOption(h.router),
+			exchanger.LoggerOption(h.options.Logger),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	h.table = tun_util.NewRouteTable(h.xoptions.Service, h.md.routeTTL)
+	if h.md.stack {
+		h.udpSessions = udp.NewSessionTable(h.xoptions.Service, h.md.sessionOptions)
+	}
+	if len(h.md.peers) > 0 {
+		h.auth = tun_util.NewPeerAuthenticator(h.md.peers, 0)
+	}
+
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *tunHandler) Close() error {
+	if h.udpSessions != nil {
+		h.udpSessions.Close()
+	}
+	if h.table != nil {
+		h.table.Close()
+	}
+	return h.fakeIPs.Release()
+}
+
 // Forward implements handler.Forwarder.
 func (h *tunHandler) Forward(group *chain.NodeGroup) {
 	h.group = group
@@ -104,6 +161,12 @@ func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
 	}()
 
+	config := v.GetMetadata().Get("config").(*tun_util.Config)
+	h.device, _ = v.GetMetadata().Get("device").(tun_util.Device)
+	if h.md.stack {
+		return h.handleStack(ctx, conn, config, log)
+	}
+
 	network := "udp"
 	var raddr net.Addr
 	var err error
@@ -121,7 +184,20 @@ func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		log.Infof("%s >> %s", conn.RemoteAddr(), target.Addr)
 	}
 
-	config := v.GetMetadata().Get("config").(*tun_util.Config)
+	if raddr == nil {
+		for i := range config.Routes {
+			route := &config.Routes[i]
+			if err := h.table.AddRoute(&route.Net, route.Gateway, nil, "", 0); err != nil {
+				log.Warnf("route %s via %s: %v", route.Net.String(), route.Gateway, err)
+			}
+		}
+		if h.auth != nil {
+			done := make(chan struct{})
+			defer close(done)
+			go h.expirePeers(done, log)
+		}
+	}
+
 	h.handleLoop(ctx, conn, raddr, config, log)
 	return nil
 }
@@ -189,6 +265,13 @@ func (h *tunHandler) handleLoop(ctx context.Context, conn net.Conn, addr net.Add
 func (h *tunHandler) transport(tun net.Conn, conn net.PacketConn, raddr net.Addr, config *tun_util.Config, log logger.Logger) error {
 	errc := make(chan error, 1)
 
+	// client side, authenticate to the server periodically.
+	if raddr != nil && h.md.peerUser != "" {
+		done := make(chan struct{})
+		defer close(done)
+		go h.keepAlive(conn, raddr, done, log)
+	}
+
 	go func() {
 		for {
 			err := func() error {
@@ -235,11 +318,15 @@ func (h *tunHandler) transport(tun net.Conn, conn net.PacketConn, raddr net.Addr
 
 				// client side, deliver packet directly.
 				if raddr != nil {
-					_, err := conn.WriteTo((*b)[:n], raddr)
+					pkt := (*b)[:n]
+					if h.md.peerUser != "" {
+						pkt = tun_util.SignPacket(pkt, h.md.peerKey)
+					}
+					_, err := conn.WriteTo(pkt, raddr)
 					return err
 				}
 
-				addr := h.findRouteFor(dst, config.Routes...)
+				addr := h.table.Find(dst)
 				if addr == nil {
 					log.Warnf("no route for %s -> %s", src, dst)
 					return nil
@@ -247,10 +334,7 @@ func (h *tunHandler) transport(tun net.Conn, conn net.PacketConn, raddr net.Addr
 
 				log.Debugf("find route: %s -> %s", dst, addr)
 
-				if _, err := conn.WriteTo((*b)[:n], addr); err != nil {
-					return err
-				}
-				return nil
+				return h.writeToPeer(conn, (*b)[:n], addr, log)
 			}()
 
 			if err != nil {
@@ -263,7 +347,7 @@ func (h *tunHandler) transport(tun net.Conn, conn net.PacketConn, raddr net.Addr
 	go func() {
 		for {
 			err := func() error {
-				b := bufpool.Get(h.md.bufferSize)
+				b := bufpool.Get(h.md.bufferSize + tun_util.PacketMACLen)
 				defer bufpool.Put(b)
 
 				n, addr, err := conn.ReadFrom(*b)
@@ -272,6 +356,38 @@ func (h *tunHandler) transport(tun net.Conn, conn net.PacketConn, raddr net.Addr
 					return err
 				}
 
+				if tun_util.IsControlPacket((*b)[:n]) {
+					if raddr != nil {
+						h.handleAuthResponse((*b)[:n], log)
+					} else {
+						h.handleAuthRequest(conn, (*b)[:n], addr, log)
+					}
+					return nil
+				}
+
+				// the data packets of the authenticated peers are signed by the key of the peer.
+				var sess *peerSession
+				switch {
+				case raddr != nil && h.md.peerUser != "":
+					pkt, ok := tun_util.VerifyPacket((*b)[:n], h.md.peerKey)
+					if !ok {
+						log.Debugf("bad packet from %s, discarded", addr)
+						return nil
+					}
+					n = len(pkt)
+				case raddr == nil && h.auth != nil:
+					if sess = h.getSession(addr); sess == nil {
+						log.Debugf("unauthenticated peer %s, discarded", addr)
+						return nil
+					}
+					pkt, ok := tun_util.VerifyPacket((*b)[:n], sess.peer.Key)
+					if !ok {
+						log.Debugf("peer %s@%s: bad packet, discarded", sess.peer.User, addr)
+						return nil
+					}
+					n = len(pkt)
+				}
+
 				var src, dst net.IP
 				if waterutil.IsIPv4((*b)[:n]) {
 					header, err := ipv4.ParseHeader((*b)[:n])
@@ -309,22 +425,28 @@ func (h *tunHandler) transport(tun net.Conn, conn net.PacketConn, raddr net.Addr
 					return err
 				}
 
-				rkey := ipToTunRouteKey(src)
-				if actual, loaded := h.routes.LoadOrStore(rkey, addr); loaded {
-					if actual.(net.Addr).String() != addr.String() {
-						log.Debugf("update route: %s -> %s (old %s)",
-							src, addr, actual.(net.Addr))
-						h.routes.Store(rkey, addr)
+				var user string
+				if sess != nil {
+					if !sess.peer.Allowed(src) {
+						log.Warnf("peer %s@%s: source %s not allowed, discarded", sess.peer.User, addr, src)
+						return nil
+					}
+					sess.touch()
+					user = sess.peer.User
+				}
+
+				if old, changed := h.table.Learn(src, addr, user); changed {
+					if old != nil {
+						log.Debugf("update route: %s -> %s (old %s)", src, addr, old)
+					} else {
+						log.Debugf("new route: %s -> %s", src, addr)
 					}
-				} else {
-					log.Warnf("no route for %s -> %s", src, addr)
 				}
 
-				if addr := h.findRouteFor(dst, config.Routes...); addr != nil {
+				if addr := h.table.Find(dst); addr != nil {
 					log.Debugf("find route: %s -> %s", dst, addr)
 
-					_, err := conn.WriteTo((*b)[:n], addr)
-					return err
+					return h.writeToPeer(conn, (*b)[:n], addr, log)
 				}
 
 				if _, err := tun.Write((*b)[:n]); err != nil {
@@ -351,20 +473,6 @@ func (h *tunHandler) transport(tun net.Conn, conn net.PacketConn, raddr net.Addr
 	return err
 }
 
-func (h *tunHandler) findRouteFor(dst net.IP, routes ...tun_util.Route) net.Addr {
-	if v, ok := h.routes.Load(ipToTunRouteKey(dst)); ok {
-		return v.(net.Addr)
-	}
-	for _, route := range routes {
-		if route.Net.Contains(dst) && route.Gateway != nil {
-			if v, ok := h.routes.Load(ipToTunRouteKey(route.Gateway)); ok {
-				return v.(net.Addr)
-			}
-		}
-	}
-	return nil
-}
-
 var mIPProts = map[waterutil.IPProtocol]string{
 	waterutil.HOPOPT:     "HOPOPT",
 	waterutil.ICMP:       "ICMP",
@@ -383,10 +491,3 @@ func ipProtocol(p waterutil.IPProtocol) string {
 	}
 	return fmt.Sprintf("unknown(%d)", p)
 }
-
-type tunRouteKey [16]byte
-
-func ipToTunRouteKey(ip net.IP) (key tunRouteKey) {
-	copy(key[:], ip.To16())
-	return
-}
@@ -1,19 +1,59 @@
 package tun
 
 import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	tun_util "github.com/hxdcloud/gost-x/internal/util/tun"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
+const (
+	defaultKeepAliveInterval = 10 * time.Second
+	defaultPeerTimeout       = 60 * time.Second
+	defaultFakeIPTTL         = time.Second
+)
+
 type metadata struct {
-	key        string
-	bufferSize int
+	key               string
+	bufferSize        int
+	routeTTL          time.Duration
+	peers             []*tun_util.Peer
+	peerTimeout       time.Duration
+	peerUser          string
+	peerKey           string
+	keepAliveInterval time.Duration
+	stack             bool
+	fakeIP            string
+	fakeIPTTL         time.Duration
+	dns               string
+	dnsHijack         []*net.UDPAddr
+	transportOptions  []netpkg.TransportOption
+	sessionOptions    udp.SessionOptions
 }
 
 func (h *tunHandler) parseMetadata(md mdata.Metadata) (err error) {
 	const (
-		key        = "key"
-		bufferSize = "bufferSize"
+		key               = "key"
+		bufferSize        = "bufferSize"
+		routeTTL          = "routeTTL"
+		peers             = "peers"
+		peerTimeout       = "peerTimeout"
+		peerUser          = "peerUser"
+		peerKey           = "peerKey"
+		keepAliveInterval = "keepAliveInterval"
+		stack             = "stack"
+		fakeIP            = "fakeIP"
+		fakeIPTTL         = "fakeIPTTL"
+		dns               = "dns"
+		dnsHijack         = "dnsHijack"
+		udpTimeout        = "udpTimeout"
+		udpIdleTimeout    = "udpIdleTimeout"
 	)
 
 	h.md.key = mdx.GetString(md, key)
@@ -21,5 +61,121 @@ func (h *tunHandler) parseMetadata(md mdata.Metadata) (err error) {
 	if h.md.bufferSize <= 0 {
 		h.md.bufferSize = 1500
 	}
+
+	h.md.routeTTL = mdx.GetDuration(md, routeTTL)
+
+	for user, v := range mdx.GetStringMap(md, peers) {
+		peer, err := parsePeer(user, v)
+		if err != nil {
+			return err
+		}
+		h.md.peers = append(h.md.peers, peer)
+	}
+	h.md.peerTimeout = mdx.GetDuration(md, peerTimeout)
+	if h.md.peerTimeout <= 0 {
+		h.md.peerTimeout = defaultPeerTimeout
+	}
+
+	h.md.peerUser = mdx.GetString(md, peerUser)
+	h.md.peerKey = mdx.GetString(md, peerKey)
+	h.md.keepAliveInterval = mdx.GetDuration(md, keepAliveInterval)
+	if h.md.keepAliveInterval <= 0 {
+		h.md.keepAliveInterval = defaultKeepAliveInterval
+	}
+
+	h.md.stack = mdx.GetBool(md, stack)
+	h.md.fakeIP = mdx.GetString(md, fakeIP)
+	h.md.fakeIPTTL = mdx.GetDuration(md, fakeIPTTL)
+	if h.md.fakeIPTTL <= 0 {
+		h.md.fakeIPTTL = defaultFakeIPTTL
+	}
+	h.md.dns = mdx.GetString(md, dns)
+	hijack := getList(md, dnsHijack)
+	if len(hijack) == 0 && h.md.dns != "" {
+		hijack = []string{":53"}
+	}
+	for _, s := range hijack {
+		addr, err := net.ResolveUDPAddr("udp", s)
+		if err != nil {
+			return fmt.Errorf("tun: invalid dnsHijack %s: %v", s, err)
+		}
+		h.md.dnsHijack = append(h.md.dnsHijack, addr)
+	}
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+	h.md.sessionOptions = udp.SessionOptionsFromMetadata(md)
+	// udpTimeout is the former name of udpIdleTimeout.
+	if v := mdx.GetDuration(md, udpTimeout); v > 0 && !md.IsExists(udpIdleTimeout) {
+		h.md.sessionOptions.IdleTimeout = v
+	}
+	return
+}
+
+// parsePeer parses the peer of the user, the value is the key or a map with the key, ip and routes.
+//
+//	peers:
+//	  site1: secret1
+//	  site2:
+//	    key: secret2
+//	    ip: 192.168.123.3,fd00::3
+//	    routes: [10.2.0.0/16]
+func parsePeer(user string, v any) (*tun_util.Peer, error) {
+	const (
+		key    = "key"
+		ip     = "ip"
+		routes = "routes"
+	)
+
+	peer := &tun_util.Peer{
+		User: user,
+	}
+
+	var md mdata.Metadata
+	switch vv := v.(type) {
+	case string:
+		peer.Key = vv
+		return peer, nil
+	case map[string]any:
+		md = mdx.NewMetadata(vv)
+	case map[any]any:
+		m := make(map[string]any)
+		for k, v := range vv {
+			m[fmt.Sprintf("%v", k)] = v
+		}
+		md = mdx.NewMetadata(m)
+	default:
+		return nil, fmt.Errorf("tun: invalid peer %s", user)
+	}
+
+	peer.Key = mdx.GetString(md, key)
+	for _, s := range getList(md, ip) {
+		v := net.ParseIP(s)
+		if v == nil {
+			return nil, fmt.Errorf("tun: invalid IP %s of peer %s", s, user)
+		}
+		peer.IPs = append(peer.IPs, v)
+	}
+	for _, s := range getList(md, routes) {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("tun: invalid route %s of peer %s", s, user)
+		}
+		peer.Routes = append(peer.Routes, ipNet)
+	}
+	return peer, nil
+}
+
+// getList returns the list or the comma separated values of the key.
+func getList(md mdata.Metadata, key string) (ss []string) {
+	vs := mdx.GetStrings(md, key)
+	if len(vs) == 0 {
+		if v := mdx.GetString(md, key); v != "" {
+			vs = strings.Split(v, ",")
+		}
+	}
+	for _, v := range vs {
+		if v = strings.TrimSpace(v); v != "" {
+			ss = append(ss, v)
+		}
+	}
 	return
 }
@@ -0,0 +1,198 @@
+package tun
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	tun_util "github.com/hxdcloud/gost-x/internal/util/tun"
+)
+
+// peerSession is the authenticated peer of the server side.
+type peerSession struct {
+	peer *tun_util.Peer
+	addr net.Addr
+	// seen is the last time in unix nanoseconds the peer is active.
+	seen int64
+}
+
+func (s *peerSession) touch() {
+	atomic.StoreInt64(&s.seen, time.Now().UnixNano())
+}
+
+func (s *peerSession) expired(timeout time.Duration) bool {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.seen))) > timeout
+}
+
+func (h *tunHandler) getSession(addr net.Addr) *peerSession {
+	v, ok := h.sessions.Load(addr.String())
+	if !ok {
+		return nil
+	}
+	sess := v.(*peerSession)
+	if sess.expired(h.md.peerTimeout) {
+		return nil
+	}
+	return sess
+}
+
+// handleAuthRequest authenticates the peer and adds the routes to the static IPs and networks of the peer.
+// The previous session of the same user is replaced, so the peer can roam between addresses.
+func (h *tunHandler) handleAuthRequest(conn net.PacketConn, b []byte, addr net.Addr, log logger.Logger) {
+	if h.auth == nil || !tun_util.IsAuthRequest(b) {
+		log.Debugf("control packet from %s, discarded", addr)
+		return
+	}
+
+	req, err := tun_util.ParseAuthRequest(b)
+	if err != nil {
+		log.Warnf("peer %s: %v", addr, err)
+		return
+	}
+	peer := h.auth.Authenticate(req)
+	if peer == nil {
+		log.Warnf("peer %s@%s: authentication failed", req.User, addr)
+		conn.WriteTo(tun_util.NewAuthResponse(tun_util.AuthFailed), addr)
+		return
+	}
+
+	if sess := h.getSession(addr); sess != nil && sess.peer == peer {
+		sess.touch()
+	} else {
+		h.sessions.Range(func(k, v any) bool {
+			if v := v.(*peerSession); v.peer == peer || k.(string) == addr.String() {
+				h.delSession(v)
+			}
+			return true
+		})
+
+		sess = &peerSession{
+			peer: peer,
+			addr: addr,
+		}
+		sess.touch()
+		h.sessions.Store(addr.String(), sess)
+
+		for _, ip := range peer.IPs {
+			ipNet, _ := tun_util.ParseNet(ip.String())
+			if err := h.table.AddRoute(ipNet, nil, addr, peer.User, 0); err != nil {
+				log.Warnf("peer %s@%s: route %s: %v", peer.User, addr, ip, err)
+			}
+		}
+		for _, ipNet := range peer.Routes {
+			if err := h.table.AddRoute(ipNet, nil, addr, peer.User, 0); err != nil {
+				log.Warnf("peer %s@%s: route %s: %v", peer.User, addr, ipNet, err)
+			}
+		}
+		log.Infof("peer %s@%s authenticated, ips: %v", peer.User, addr, peer.IPs)
+	}
+
+	if _, err := conn.WriteTo(tun_util.NewAuthResponse(tun_util.AuthOK, peer.IPs...), addr); err != nil {
+		log.Warnf("peer %s@%s: %v", peer.User, addr, err)
+	}
+}
+
+// writeToPeer sends the packet to the peer of the addr on the server side,
+// the packet is signed by the key of the peer if the peers are authenticated.
+func (h *tunHandler) writeToPeer(conn net.PacketConn, b []byte, addr net.Addr, log logger.Logger) error {
+	if h.auth != nil {
+		sess := h.getSession(addr)
+		if sess == nil {
+			log.Debugf("unauthenticated peer %s, discarded", addr)
+			return nil
+		}
+		b = tun_util.SignPacket(b, sess.peer.Key)
+	}
+	_, err := conn.WriteTo(b, addr)
+	return err
+}
+
+func (h *tunHandler) delSession(sess *peerSession) {
+	h.sessions.Delete(sess.addr.String())
+	h.table.DelPeer(sess.addr)
+}
+
+// expirePeers removes the sessions and the routes of the inactive peers.
+func (h *tunHandler) expirePeers(done chan struct{}, log logger.Logger) {
+	ticker := time.NewTicker(h.md.peerTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.sessions.Range(func(k, v any) bool {
+				if sess := v.(*peerSession); sess.expired(h.md.peerTimeout) {
+					h.delSession(sess)
+					log.Infof("peer %s@%s expired", sess.peer.User, sess.addr)
+				}
+				return true
+			})
+		case <-done:
+			return
+		}
+	}
+}
+
+// keepAlive sends the authentication request to the server periodically.
+func (h *tunHandler) keepAlive(conn net.PacketConn, raddr net.Addr, done chan struct{}, log logger.Logger) {
+	ticker := time.NewTicker(h.md.keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		b := tun_util.NewAuthRequest(h.md.peerUser, h.md.peerKey, time.Now())
+		if _, err := conn.WriteTo(b, raddr); err != nil {
+			log.Warn(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (h *tunHandler) handleAuthResponse(b []byte, log logger.Logger) {
+	if !tun_util.IsAuthResponse(b) {
+		log.Debug("control packet discarded")
+		return
+	}
+
+	status, ips, err := tun_util.ParseAuthResponse(b)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	if status != tun_util.AuthOK {
+		atomic.StoreUint32(&h.authenticated, 0)
+		log.Errorf("peer %s: authentication failed", h.md.peerUser)
+		return
+	}
+
+	// handle the first response only, the requests are sent periodically.
+	if !atomic.CompareAndSwapUint32(&h.authenticated, 0, 1) {
+		return
+	}
+	log.Infof("peer %s authenticated, ips: %v", h.md.peerUser, ips)
+
+	h.addAddrs(ips, log)
+}
+
+// addAddrs adds the static IPs assigned by the server to the tun device.
+func (h *tunHandler) addAddrs(ips []net.IP, log logger.Logger) {
+	if len(ips) == 0 {
+		return
+	}
+	if h.device == nil {
+		log.Warnf("peer %s: the IPs %v can not be assigned to the tun device", h.md.peerUser, ips)
+		return
+	}
+	for _, ip := range ips {
+		if err := h.device.AddAddr(ip); err != nil {
+			log.Warnf("peer %s: assign IP %s: %v", h.md.peerUser, ip, err)
+			continue
+		}
+		log.Debugf("peer %s: IP %s assigned", h.md.peerUser, ip)
+	}
+}
@@ -0,0 +1,140 @@
+package tun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	tun_util "github.com/hxdcloud/gost-x/internal/util/tun"
+	"github.com/hxdcloud/gost-x/internal/util/tun/stack"
+)
+
+// handleStack dispatches the TCP connections and UDP flows of the tun device through the router,
+// like the requests of the socks5 handler.
+func (h *tunHandler) handleStack(ctx context.Context, conn net.Conn, config *tun_util.Config, log logger.Logger) error {
+	s, err := stack.New(conn, &stackHandler{
+		h:   h,
+		ctx: ctx,
+		log: log,
+	}, stack.Options{
+		Net:    config.Net,
+		Net6:   config.Net6,
+		MTU:    config.MTU,
+		Logger: log,
+	})
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	log.Infof("%s: stack mode", conn.LocalAddr())
+	err = s.Run(ctx)
+	log.Debug(err)
+	return err
+}
+
+type stackHandler struct {
+	h   *tunHandler
+	ctx context.Context
+	log logger.Logger
+}
+
+func (sh *stackHandler) HandleTCP(conn net.Conn) {
+	sh.handle(conn, "tcp")
+}
+
+func (sh *stackHandler) HandleUDP(conn net.Conn) {
+	sh.handle(conn, "udp")
+}
+
+func (sh *stackHandler) handle(conn net.Conn, network string) {
+	defer conn.Close()
+
+	h := sh.h
+	start := time.Now()
+	log := sh.log.WithFields(map[string]any{
+		"src": conn.RemoteAddr().String(),
+		"dst": fmt.Sprintf("%s/%s", conn.LocalAddr(), network),
+	})
+
+	log.Debugf("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Debugf("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	opts := append([]netpkg.TransportOption{}, h.md.transportOptions...)
+	if network == "udp" {
+		opts = append(opts, netpkg.IdleTimeoutTransportOption(h.md.sessionOptions.IdleTimeout))
+	}
+
+	if network == "udp" && h.hijackDNS(conn.LocalAddr()) {
+		log.Debugf("dns hijack: %s -> %s", conn.LocalAddr(), h.exchanger.String())
+		err := h.handleDNS(sh.ctx, conn, log)
+		log.Debug(err)
+		return
+	}
+
+	var err error
+	dstAddr := conn.LocalAddr()
+	if h.fakeIPs != nil {
+		if dstAddr, err = h.fakeIPs.ReverseAddr(dstAddr); err != nil {
+			log.Error(err)
+			return
+		}
+	}
+	address := dstAddr.String()
+
+	log.Infof("%s >> %s/%s", conn.RemoteAddr(), address, network)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(address) {
+		log.Info("bypass: ", address)
+		return
+	}
+	if network == "udp" {
+		fc, err := h.udpSessions.FlowConn(conn, conn.RemoteAddr(), address)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		defer fc.Close()
+		conn = fc
+	}
+
+	cc, err := h.router.Dial(sh.ctx, network, address)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Infof("%s <-> %s", conn.RemoteAddr(), conn.LocalAddr())
+	stats, _ := netpkg.Relay(conn, cc, opts...)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
+	}).Infof("%s >-< %s", conn.RemoteAddr(), conn.LocalAddr())
+}
+
+// hijackDNS reports whether the datagrams to the addr are answered by the hijacking DNS.
+func (h *tunHandler) hijackDNS(addr net.Addr) bool {
+	if h.exchanger == nil {
+		return false
+	}
+	udpAddr, _ := addr.(*net.UDPAddr)
+	if udpAddr == nil {
+		return false
+	}
+	for _, v := range h.md.dnsHijack {
+		if v.Port == udpAddr.Port && (v.IP == nil || v.IP.IsUnspecified() || v.IP.Equal(udpAddr.IP)) {
+			return true
+		}
+	}
+	return false
+}
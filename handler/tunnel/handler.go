@@ -0,0 +1,217 @@
+package tunnel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/handler"
+	md "github.com/go-gost/core/metadata"
+	"github.com/go-gost/relay"
+	dissector "github.com/go-gost/tls-dissector"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/util/tunnel"
+	"github.com/hxdcloud/gost-x/registry"
+)
+
+var (
+	ErrNoTunnel = errors.New("tunnel: no tunnel matched")
+)
+
+func init() {
+	registry.HandlerRegistry().Register("tunnel", NewHandler)
+}
+
+// tunnelHandler is the ingress of the tunnels registered on the relay handler,
+// the connections are routed to the tunnels by the Host header of HTTP, the server name of TLS,
+// or to the tunnel of the service for the other traffic.
+type tunnelHandler struct {
+	md      metadata
+	options handler.Options
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &tunnelHandler{
+		options: options,
+	}
+}
+
+func (h *tunnelHandler) Init(md md.Metadata) (err error) {
+	return h.parseMetadata(md)
+}
+
+func (h *tunnelHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	id := h.md.tunnel
+	var rw io.ReadWriter = conn
+	if len(h.md.ingress) > 0 {
+		buf := new(bytes.Buffer)
+		host, err := h.sniffHost(conn, bufio.NewReader(io.TeeReader(conn, buf)))
+		conn.SetReadDeadline(time.Time{})
+		rw = &readWriter{
+			Reader: io.MultiReader(buf, conn),
+			Writer: conn,
+		}
+		if err != nil {
+			log.Debugf("sniffing: %v", err)
+		}
+		if host != "" {
+			log = log.WithFields(map[string]any{
+				"host": host,
+			})
+			if v := h.route(host); v != "" {
+				id = v
+			}
+		}
+	}
+	if id == "" {
+		log.Error(ErrNoTunnel)
+		return ErrNoTunnel
+	}
+
+	log = log.WithFields(map[string]any{
+		"tunnel": id,
+	})
+	log.Infof("%s >> tunnel %s", conn.RemoteAddr(), id)
+
+	cc, err := tunnel.Dial(id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	// the client of the tunnel reads the peer address as the BIND connection.
+	af := &relay.AddrFeature{}
+	af.ParseFrom(conn.RemoteAddr().String())
+	resp := relay.Response{
+		Version:  relay.Version1,
+		Status:   relay.StatusOK,
+		Features: []relay.Feature{af},
+	}
+	if _, err := resp.WriteTo(cc); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	t := time.Now()
+	log.Infof("%s <-> tunnel %s", conn.RemoteAddr(), id)
+	stats, _ := netpkg.Relay(rw, cc, h.md.transportOptions...)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+		"up":       stats.Up,
+		"down":     stats.Down,
+	}).Infof("%s >-< tunnel %s", conn.RemoteAddr(), id)
+
+	return nil
+}
+
+// sniffHost returns the server name of the TLS ClientHello or the Host header of the HTTP request.
+// The first bytes are peeked within the peek timeout, so the protocols of which the server speaks first
+// are not delayed, and the sniffing is skipped if the data is neither TLS nor HTTP.
+func (h *tunnelHandler) sniffHost(conn net.Conn, br *bufio.Reader) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(h.md.sniffingPeekTimeout))
+	b, err := br.Peek(sniffingPeekSize)
+	if len(b) == 0 {
+		return "", err
+	}
+
+	switch {
+	case b[0] == dissector.Handshake:
+	case len(b) == sniffingPeekSize && isHTTP(b):
+	default:
+		return "", nil
+	}
+
+	conn.SetReadDeadline(time.Now().Add(h.md.sniffingTimeout))
+
+	if b[0] == dissector.Handshake {
+		record, err := dissector.ReadRecord(br)
+		if err != nil {
+			return "", err
+		}
+		clientHello := dissector.ClientHelloMsg{}
+		if err := clientHello.Decode(record.Opaque); err != nil {
+			return "", err
+		}
+		for _, ext := range clientHello.Extensions {
+			if ext.Type() == dissector.ExtServerName {
+				return ext.(*dissector.ServerNameExtension).Name, nil
+			}
+		}
+		return "", nil
+	}
+
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return "", err
+	}
+	host := req.Host
+	if v, _, err := net.SplitHostPort(host); err == nil {
+		host = v
+	}
+	return host, nil
+}
+
+// isHTTP reports whether b starts with the method of an HTTP request.
+func isHTTP(b []byte) bool {
+	for _, method := range []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+	} {
+		if bytes.HasPrefix(b, []byte(method+" ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// route returns the tunnel ID of the host,
+// the exact name is matched first, then the wildcard names and the * rule.
+func (h *tunnelHandler) route(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if id := h.md.ingress[host]; id != "" {
+		return id
+	}
+	for s := host; ; {
+		n := strings.IndexByte(s, '.')
+		if n <= 0 {
+			break
+		}
+		s = s[n+1:]
+		if id := h.md.ingress["*."+s]; id != "" {
+			return id
+		}
+	}
+	return h.md.ingress["*"]
+}
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
@@ -0,0 +1,71 @@
+package tunnel
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+func TestSniffHost(t *testing.T) {
+	h := NewHandler().(*tunnelHandler)
+	if err := h.parseMetadata(mdx.NewMetadata(map[string]any{
+		"sniffingPeekTimeout": "100ms",
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// send writes the first data of the client.
+		send func(conn net.Conn)
+		host string
+	}{
+		{
+			name: "http",
+			send: func(conn net.Conn) {
+				conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com:8080\r\n\r\n"))
+			},
+			host: "example.com",
+		},
+		{
+			name: "tls",
+			send: func(conn net.Conn) {
+				tls.Client(conn, &tls.Config{ServerName: "example.com"}).Handshake()
+			},
+			host: "example.com",
+		},
+		{
+			name: "server speaks first",
+			send: func(conn net.Conn) {},
+		},
+		{
+			name: "other protocol",
+			send: func(conn net.Conn) {
+				conn.Write([]byte("SSH-2.0-OpenSSH_9.0\r\n"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c1, c2 := net.Pipe()
+			defer c1.Close()
+			defer c2.Close()
+
+			go tt.send(c1)
+
+			start := time.Now()
+			host, _ := h.sniffHost(c2, bufio.NewReader(c2))
+			if host != tt.host {
+				t.Errorf("host: got %q, want %q", host, tt.host)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("sniffing took %v", d)
+			}
+		})
+	}
+}
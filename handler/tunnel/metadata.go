@@ -0,0 +1,59 @@
+package tunnel
+
+import (
+	"strings"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+const (
+	defaultSniffingTimeout     = 10 * time.Second
+	defaultSniffingPeekTimeout = 500 * time.Millisecond
+	// sniffingPeekSize is the length of the longest HTTP method with the trailing space.
+	sniffingPeekSize = 8
+)
+
+type metadata struct {
+	tunnel          string
+	ingress         map[string]string
+	sniffingTimeout time.Duration
+	// sniffingPeekTimeout is the time to wait for the first bytes of the client.
+	sniffingPeekTimeout time.Duration
+	transportOptions    []netpkg.TransportOption
+}
+
+func (h *tunnelHandler) parseMetadata(md mdata.Metadata) (err error) {
+	h.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+
+	const (
+		tunnel              = "tunnel"
+		ingress             = "ingress"
+		sniffingTimeout     = "sniffingTimeout"
+		sniffingPeekTimeout = "sniffingPeekTimeout"
+	)
+
+	h.md.tunnel = mdx.GetString(md, tunnel)
+
+	// ingress is a map of host name to the tunnel ID, the host name is matched against
+	// the Host header of the HTTP request or the server name of the TLS ClientHello.
+	// The host name can be a wildcard name (*.example.com), or * to match all the names.
+	if m := mdx.GetStringMapString(md, ingress); len(m) > 0 {
+		h.md.ingress = make(map[string]string)
+		for host, id := range m {
+			h.md.ingress[strings.ToLower(host)] = id
+		}
+	}
+
+	h.md.sniffingTimeout = mdx.GetDuration(md, sniffingTimeout)
+	if h.md.sniffingTimeout <= 0 {
+		h.md.sniffingTimeout = defaultSniffingTimeout
+	}
+	h.md.sniffingPeekTimeout = mdx.GetDuration(md, sniffingPeekTimeout)
+	if h.md.sniffingPeekTimeout <= 0 {
+		h.md.sniffingPeekTimeout = defaultSniffingPeekTimeout
+	}
+	return
+}
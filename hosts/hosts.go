@@ -1,56 +1,241 @@
 package hosts
 
 import (
+	"bufio"
+	"context"
+	"io"
 	"net"
 	"strings"
 	"sync"
+	"time"
 
+	hosts_pkg "github.com/go-gost/core/hosts"
 	"github.com/go-gost/core/logger"
+	"github.com/hxdcloud/gost-x/internal/loader"
 )
 
+// Mapping is a static mapping of IP to hostname and its aliases.
+type Mapping struct {
+	IP       net.IP
+	Hostname string
+	Aliases  []string
+}
+
 type hostMapping struct {
 	IPs      []net.IP
 	Hostname string
 }
 
-// Hosts is a static table lookup for hostnames.
+type options struct {
+	mappings    []Mapping
+	fileLoader  loader.Loader
+	redisLoader loader.Loader
+	httpLoader  loader.Loader
+	period      time.Duration
+	logger      logger.Logger
+}
+
+type Option func(opts *options)
+
+func MappingsOption(mappings []Mapping) Option {
+	return func(opts *options) {
+		opts.mappings = mappings
+	}
+}
+
+func ReloadPeriodOption(period time.Duration) Option {
+	return func(opts *options) {
+		opts.period = period
+	}
+}
+
+func FileLoaderOption(fileLoader loader.Loader) Option {
+	return func(opts *options) {
+		opts.fileLoader = fileLoader
+	}
+}
+
+func RedisLoaderOption(redisLoader loader.Loader) Option {
+	return func(opts *options) {
+		opts.redisLoader = redisLoader
+	}
+}
+
+func HTTPLoaderOption(httpLoader loader.Loader) Option {
+	return func(opts *options) {
+		opts.httpLoader = httpLoader
+	}
+}
+
+func LoggerOption(logger logger.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// hosts is a static table lookup for hostnames.
 // For each host a single line should be present with the following information:
 // IP_address canonical_hostname [aliases...]
 // Fields of the entry are separated by any number of blanks and/or tab characters.
 // Text from a "#" character until the end of the line is a comment, and is ignored.
-type Hosts struct {
-	mappings sync.Map
-	Logger   logger.Logger
+type hosts struct {
+	mappings   map[string]*hostMapping
+	mu         sync.RWMutex
+	cancelFunc context.CancelFunc
+	options    options
+}
+
+// NewHostMapper creates and initializes a new HostMapper.
+// The mappings loaded from the file, redis or http loaders are merged with the static mappings,
+// and are swapped atomically on each reload.
+func NewHostMapper(opts ...Option) hosts_pkg.HostMapper {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	h := &hosts{
+		mappings:   make(map[string]*hostMapping),
+		cancelFunc: cancel,
+		options:    options,
+	}
+
+	if err := h.reload(ctx); err != nil {
+		options.logger.Warnf("reload: %v", err)
+	}
+	if h.options.period > 0 {
+		go h.periodReload(ctx)
+	}
+
+	return h
 }
 
-func NewHosts() *Hosts {
-	return &Hosts{}
+func (h *hosts) periodReload(ctx context.Context) error {
+	period := h.options.period
+	if period < time.Second {
+		period = time.Second
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.reload(ctx); err != nil {
+				h.options.logger.Warnf("reload: %v", err)
+				// return err
+			}
+			h.options.logger.Debugf("hosts reload done")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
 
-// Map maps ip to hostname or aliases.
-func (h *Hosts) Map(ip net.IP, hostname string, aliases ...string) {
-	if hostname == "" {
+func (h *hosts) reload(ctx context.Context) error {
+	v, err := h.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	mappings := make(map[string]*hostMapping)
+	for _, mapping := range append(h.options.mappings, v...) {
+		addMapping(mappings, mapping)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.mappings = mappings
+
+	return nil
+}
+
+func (h *hosts) load(ctx context.Context) (mappings []Mapping, err error) {
+	if h.options.fileLoader != nil {
+		r, er := h.options.fileLoader.Load(ctx)
+		if er != nil {
+			h.options.logger.Warnf("file loader: %v", er)
+		}
+		if v, _ := h.parseMappings(r); v != nil {
+			mappings = append(mappings, v...)
+		}
+	}
+	if h.options.redisLoader != nil {
+		r, er := h.options.redisLoader.Load(ctx)
+		if er != nil {
+			h.options.logger.Warnf("redis loader: %v", er)
+		}
+		if v, _ := h.parseMappings(r); v != nil {
+			mappings = append(mappings, v...)
+		}
+	}
+	if h.options.httpLoader != nil {
+		r, er := h.options.httpLoader.Load(ctx)
+		if er != nil {
+			h.options.logger.Warnf("http loader: %v", er)
+		}
+		if v, _ := h.parseMappings(r); v != nil {
+			mappings = append(mappings, v...)
+		}
+	}
+
+	return
+}
+
+func (h *hosts) parseMappings(r io.Reader) (mappings []Mapping, err error) {
+	if r == nil {
 		return
 	}
 
-	v, _ := h.mappings.Load(hostname)
-	m, _ := v.(*hostMapping)
-	if m == nil {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n := strings.IndexByte(line, '#'); n >= 0 {
+			line = line[:n]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			h.options.logger.Warnf("invalid IP address: %s", fields[0])
+			continue
+		}
+		mappings = append(mappings, Mapping{
+			IP:       ip,
+			Hostname: fields[1],
+			Aliases:  fields[2:],
+		})
+	}
+
+	err = scanner.Err()
+	return
+}
+
+func addMapping(mappings map[string]*hostMapping, mapping Mapping) {
+	if mapping.Hostname == "" || mapping.IP == nil {
+		return
+	}
+
+	m := mappings[mapping.Hostname]
+	if m == nil || len(m.IPs) == 0 {
 		m = &hostMapping{
-			IPs:      []net.IP{ip},
-			Hostname: hostname,
+			Hostname: mapping.Hostname,
 		}
-	} else {
-		m.IPs = append(m.IPs, ip)
+		mappings[mapping.Hostname] = m
 	}
-	h.mappings.Store(hostname, m)
+	m.IPs = append(m.IPs, mapping.IP)
 
-	for _, alias := range aliases {
+	for _, alias := range mapping.Aliases {
 		// indirect mapping from alias to hostname
 		if alias != "" {
-			h.mappings.Store(alias, &hostMapping{
-				Hostname: hostname,
-			})
+			mappings[alias] = &hostMapping{
+				Hostname: mapping.Hostname,
+			}
 		}
 	}
 }
@@ -58,16 +243,25 @@ func (h *Hosts) Map(ip net.IP, hostname string, aliases ...string) {
 // Lookup searches the IP address corresponds to the given network and host from the host table.
 // The network should be 'ip', 'ip4' or 'ip6', default network is 'ip'.
 // the host should be a hostname (example.org) or a hostname with dot prefix (.example.org).
-func (h *Hosts) Lookup(network, host string) (ips []net.IP, ok bool) {
-	m := h.lookup(host)
+// ok is true if any IP address of the network is found.
+func (h *hosts) Lookup(network, host string) (ips []net.IP, ok bool) {
+	if h == nil || host == "" {
+		return
+	}
+
+	h.mu.RLock()
+	mappings := h.mappings
+	h.mu.RUnlock()
+
+	m := mappings[host]
 	if m == nil {
-		m = h.lookup("." + host)
+		m = mappings["."+host]
 	}
 	if m == nil {
 		s := host
 		for {
 			if index := strings.IndexByte(s, '.'); index > 0 {
-				m = h.lookup(s[index:])
+				m = mappings[s[index:]]
 				s = s[index+1:]
 				if m == nil {
 					continue
@@ -83,7 +277,7 @@ func (h *Hosts) Lookup(network, host string) (ips []net.IP, ok bool) {
 
 	// hostname alias
 	if !strings.HasPrefix(m.Hostname, ".") && host != m.Hostname {
-		m = h.lookup(m.Hostname)
+		m = mappings[m.Hostname]
 		if m == nil {
 			return
 		}
@@ -107,21 +301,23 @@ func (h *Hosts) Lookup(network, host string) (ips []net.IP, ok bool) {
 	}
 
 	if len(ips) > 0 {
-		h.Logger.Debugf("host mapper: %s -> %s", host, ips)
+		ok = true
+		h.options.logger.Debugf("host mapper: %s -> %s", host, ips)
 	}
 
 	return
 }
 
-func (h *Hosts) lookup(host string) *hostMapping {
-	if h == nil || host == "" {
-		return nil
+func (h *hosts) Close() error {
+	h.cancelFunc()
+	if h.options.fileLoader != nil {
+		h.options.fileLoader.Close()
 	}
-
-	v, ok := h.mappings.Load(host)
-	if !ok {
-		return nil
+	if h.options.redisLoader != nil {
+		h.options.redisLoader.Close()
+	}
+	if h.options.httpLoader != nil {
+		h.options.httpLoader.Close()
 	}
-	m, _ := v.(*hostMapping)
-	return m
+	return nil
 }
@@ -0,0 +1,196 @@
+package hosts
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hxdcloud/gost-x/internal/loader"
+	"github.com/hxdcloud/gost-x/internal/loader/loadertest"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+)
+
+func ips(s ...string) (v []net.IP) {
+	for _, ip := range s {
+		v = append(v, net.ParseIP(ip))
+	}
+	return
+}
+
+func TestHostsLookup(t *testing.T) {
+	h := NewHostMapper(
+		MappingsOption([]Mapping{
+			{IP: net.ParseIP("192.168.1.1"), Hostname: "example.com", Aliases: []string{"www.example.com"}},
+			{IP: net.ParseIP("2001:db8::1"), Hostname: "example.com"},
+			{IP: net.ParseIP("192.168.1.2"), Hostname: ".example.org"},
+		}),
+		LoggerOption(xlogger.Nop()),
+	)
+	defer h.(io.Closer).Close()
+
+	tests := []struct {
+		name    string
+		network string
+		host    string
+		ips     []net.IP
+	}{
+		{name: "ip", network: "ip", host: "example.com", ips: ips("192.168.1.1", "2001:db8::1")},
+		{name: "ip4", network: "ip4", host: "example.com", ips: []net.IP{net.ParseIP("192.168.1.1").To4()}},
+		{name: "ip6", network: "ip6", host: "example.com", ips: ips("2001:db8::1")},
+		{name: "alias", network: "ip4", host: "www.example.com", ips: []net.IP{net.ParseIP("192.168.1.1").To4()}},
+		{name: "domain", network: "ip", host: "example.org", ips: ips("192.168.1.2")},
+		{name: "subdomain", network: "ip", host: "a.b.example.org", ips: ips("192.168.1.2")},
+		{name: "not found", network: "ip", host: "example.net"},
+		{name: "no address of network", network: "ip6", host: "example.org"},
+		{name: "empty", network: "ip", host: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := h.Lookup(tt.network, tt.host)
+			if !reflect.DeepEqual(v, tt.ips) || ok != (len(tt.ips) > 0) {
+				t.Errorf("got %v %v, want %v", v, ok, tt.ips)
+			}
+		})
+	}
+}
+
+func TestHostsReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hosts")
+
+	var data atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, data.Load().(string))
+	}))
+	defer srv.Close()
+
+	rs := loadertest.NewRedisServer(t)
+
+	type state struct {
+		file  string
+		http  string
+		redis map[string]string
+		// want are the addresses of the hosts.
+		want map[string][]net.IP
+	}
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		states []state
+	}{
+		{
+			name: "file",
+			opts: []Option{FileLoaderOption(loader.FileLoader(file))},
+			states: []state{
+				{
+					file: "192.168.1.1 a.example.com # comment\ninvalid b.example.com\n",
+					want: map[string][]net.IP{"a.example.com": ips("192.168.1.1"), "b.example.com": nil},
+				},
+				{
+					file: "192.168.1.2 a.example.com alias.example.com\n",
+					want: map[string][]net.IP{"a.example.com": ips("192.168.1.2"), "alias.example.com": ips("192.168.1.2")},
+				},
+			},
+		},
+		{
+			name: "http",
+			opts: []Option{HTTPLoaderOption(loader.HTTPLoader(srv.URL))},
+			states: []state{
+				{
+					http: "192.168.2.1 a.example.com\n",
+					want: map[string][]net.IP{"a.example.com": ips("192.168.2.1")},
+				},
+				{
+					http: "192.168.2.2 b.example.com\n",
+					want: map[string][]net.IP{"a.example.com": nil, "b.example.com": ips("192.168.2.2")},
+				},
+			},
+		},
+		{
+			name: "redis",
+			opts: []Option{RedisLoaderOption(loader.RedisHashLoader(rs.Addr, loader.KeyRedisLoaderOption("hosts")))},
+			states: []state{
+				{
+					redis: map[string]string{"192.168.3.1": "a.example.com", "2001:db8::3": "a.example.com"},
+					want:  map[string][]net.IP{"a.example.com": ips("192.168.3.1", "2001:db8::3")},
+				},
+				{
+					redis: map[string]string{"192.168.3.2": "a.example.com"},
+					want:  map[string][]net.IP{"a.example.com": ips("192.168.3.2")},
+				},
+			},
+		},
+		{
+			name: "merged with static",
+			opts: []Option{
+				MappingsOption([]Mapping{{IP: net.ParseIP("10.0.0.1"), Hostname: "static.example.com"}}),
+				FileLoaderOption(loader.FileLoader(file)),
+				HTTPLoaderOption(loader.HTTPLoader(srv.URL)),
+			},
+			states: []state{
+				{
+					file: "192.168.1.1 static.example.com\n",
+					http: "192.168.2.1 static.example.com\n",
+					want: map[string][]net.IP{"static.example.com": ips("10.0.0.1", "192.168.1.1", "192.168.2.1")},
+				},
+				{
+					want: map[string][]net.IP{"static.example.com": ips("10.0.0.1")},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append(tt.opts, LoggerOption(xlogger.Nop()))
+
+			var h *hosts
+			for i, s := range tt.states {
+				os.WriteFile(file, []byte(s.file), 0644)
+				data.Store(s.http)
+				rs.SetHash("hosts", s.redis)
+
+				if h == nil {
+					h = NewHostMapper(opts...).(*hosts)
+					defer h.Close()
+				} else if err := h.reload(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+
+				for host, want := range s.want {
+					v, _ := h.Lookup("ip", host)
+					if !sameIPs(v, want) {
+						t.Errorf("#%d %s: got %v, want %v", i, host, v, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// sameIPs compares the addresses regardless of the order of the redis data.
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x.Equal(y) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultHTTPTimeout = 30 * time.Second
+)
+
+type httpLoaderOptions struct {
+	timeout time.Duration
+}
+
+type HTTPLoaderOption func(opts *httpLoaderOptions)
+
+func TimeoutHTTPLoaderOption(timeout time.Duration) HTTPLoaderOption {
+	return func(opts *httpLoaderOptions) {
+		opts.timeout = timeout
+	}
+}
+
+type httpLoader struct {
+	url    string
+	client *http.Client
+}
+
+// HTTPLoader loads data from HTTP URL.
+func HTTPLoader(url string, opts ...HTTPLoaderOption) Loader {
+	var options httpLoaderOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	timeout := options.timeout
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+
+	return &httpLoader{
+		url: url,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (l *httpLoader) Load(ctx context.Context) (io.Reader, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", l.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (l *httpLoader) Close() error {
+	l.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,144 @@
+package loader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hxdcloud/gost-x/internal/loader/loadertest"
+)
+
+func load(t *testing.T, l Loader) (string, error) {
+	t.Helper()
+
+	r, err := l.Load(context.Background())
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), nil
+}
+
+// sortLines sorts the lines of the unordered redis data.
+func sortLines(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestFileLoader(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hosts")
+	l := FileLoader(file)
+	defer l.Close()
+
+	if _, err := load(t, l); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+
+	for _, data := range []string{"127.0.0.1 a\n", "127.0.0.1 b\n"} {
+		os.WriteFile(file, []byte(data), 0644)
+		if s, err := load(t, l); err != nil || s != data {
+			t.Errorf("got %q, %v, want %q", s, err, data)
+		}
+	}
+}
+
+func TestHTTPLoader(t *testing.T) {
+	var data atomic.Value
+	data.Store("127.0.0.1 a\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, data.Load().(string))
+	}))
+	defer srv.Close()
+
+	t.Run("reload", func(t *testing.T) {
+		l := HTTPLoader(srv.URL + "/hosts")
+		defer l.Close()
+
+		for _, v := range []string{"127.0.0.1 a\n", "127.0.0.1 b\n"} {
+			data.Store(v)
+			if s, err := load(t, l); err != nil || s != v {
+				t.Errorf("got %q, %v, want %q", s, err, v)
+			}
+		}
+	})
+
+	tests := []struct {
+		name string
+		path string
+		opts []HTTPLoaderOption
+	}{
+		{name: "status", path: "/missing"},
+		{name: "timeout", path: "/slow", opts: []HTTPLoaderOption{TimeoutHTTPLoaderOption(50 * time.Millisecond)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := HTTPLoader(srv.URL+tt.path, tt.opts...)
+			defer l.Close()
+
+			if s, err := load(t, l); err == nil {
+				t.Errorf("got %q, want error", s)
+			}
+		})
+	}
+}
+
+func TestRedisLoader(t *testing.T) {
+	srv := loadertest.NewRedisServer(t)
+
+	t.Run("set", func(t *testing.T) {
+		l := RedisSetLoader(srv.Addr, KeyRedisLoaderOption("bypass"))
+		defer l.Close()
+
+		srv.SetMembers("bypass", "example.com", "*.example.org")
+		if s, err := load(t, l); err != nil || sortLines(s) != "*.example.org\nexample.com" {
+			t.Errorf("got %q, %v", s, err)
+		}
+		srv.SetMembers("bypass", "example.net")
+		if s, err := load(t, l); err != nil || s != "example.net" {
+			t.Errorf("got %q, %v", s, err)
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		l := RedisHashLoader(srv.Addr)
+		defer l.Close()
+
+		srv.SetHash(DefaultRedisKey, map[string]string{"127.0.0.1": "a", "::1": "a"})
+		if s, err := load(t, l); err != nil || sortLines(s) != "127.0.0.1 a\n::1 a" {
+			t.Errorf("got %q, %v", s, err)
+		}
+		srv.SetHash(DefaultRedisKey, nil)
+		if s, err := load(t, l); err != nil || s != "" {
+			t.Errorf("got %q, %v", s, err)
+		}
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		l := RedisSetLoader("127.0.0.1:1")
+		defer l.Close()
+
+		if _, err := load(t, l); err == nil {
+			t.Error("want error")
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Package loadertest provides the servers for the loader tests.
+package loadertest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RedisServer is a minimal redis server which serves the SMEMBERS and HGETALL commands
+// from the in-memory sets and hashes.
+type RedisServer struct {
+	Addr   string
+	sets   map[string][]string
+	hashes map[string]map[string]string
+	mu     sync.Mutex
+}
+
+// NewRedisServer starts a redis server, it is closed when the test finishes.
+func NewRedisServer(t testing.TB) *RedisServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &RedisServer{
+		Addr:   ln.Addr().String(),
+		sets:   make(map[string][]string),
+		hashes: make(map[string]map[string]string),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// SetMembers replaces the members of the set.
+func (s *RedisServer) SetMembers(key string, members ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sets[key] = members
+}
+
+// SetHash replaces the fields of the hash.
+func (s *RedisServer) SetHash(key string, fields map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hashes[key] = fields
+}
+
+func (s *RedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(s.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *RedisServer) exec(args []string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		b.WriteString("+PONG\r\n")
+	case "SMEMBERS":
+		if len(args) != 2 {
+			return []byte("-ERR wrong number of arguments\r\n")
+		}
+		members := s.sets[args[1]]
+		fmt.Fprintf(&b, "*%d\r\n", len(members))
+		for _, v := range members {
+			writeBulk(&b, v)
+		}
+	case "HGETALL":
+		if len(args) != 2 {
+			return []byte("-ERR wrong number of arguments\r\n")
+		}
+		fields := s.hashes[args[1]]
+		fmt.Fprintf(&b, "*%d\r\n", 2*len(fields))
+		for k, v := range fields {
+			writeBulk(&b, k)
+			writeBulk(&b, v)
+		}
+	default:
+		fmt.Fprintf(&b, "-ERR unknown command '%s'\r\n", args[0])
+	}
+	return []byte(b.String())
+}
+
+func writeBulk(b *strings.Builder, s string) {
+	fmt.Fprintf(b, "$%d\r\n%s\r\n", len(s), s)
+}
+
+// readCommand reads a command in the RESP array of bulk strings.
+func readCommand(br *bufio.Reader) ([]string, error) {
+	n, err := readLength(br, '*')
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid command length %d", n)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		size, err := readLength(br, '$')
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func readLength(br *bufio.Reader, prefix byte) (int, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) < 2 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected line %q", line)
+	}
+	return strconv.Atoi(line[1:])
+}
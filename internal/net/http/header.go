@@ -0,0 +1,235 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+const (
+	// DefaultVia is the pseudonym used in the Via header if the via option is set to true.
+	DefaultVia = "gost"
+)
+
+// Hop-by-hop headers, these are removed when forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// HeaderRules modifies the headers of a message,
+// the headers are removed first, then set and added.
+type HeaderRules struct {
+	Add    map[string]string
+	Set    map[string]string
+	Remove []string
+}
+
+func (r *HeaderRules) Apply(header http.Header) {
+	if r == nil || header == nil {
+		return
+	}
+	for _, k := range r.Remove {
+		header.Del(k)
+	}
+	for k, v := range r.Set {
+		header.Set(k, v)
+	}
+	for k, v := range r.Add {
+		header.Add(k, v)
+	}
+}
+
+// HeaderOptions is the header manipulation of the forwarded (non-CONNECT) requests and responses.
+type HeaderOptions struct {
+	// Request is the rules of the requests toward the upstreams.
+	Request *HeaderRules
+	// Response is the rules of the responses toward the clients.
+	Response *HeaderRules
+	// Via is the pseudonym of the proxy in the Via header, the header is not added if empty.
+	Via string
+	// ForwardedFor adds the client IP to the X-Forwarded-For header.
+	ForwardedFor bool
+	// Forwarded adds the Forwarded header defined in RFC 7239.
+	Forwarded bool
+}
+
+// HeaderOptionsFromMetadata parses the header options from the handler metadata,
+// the requestHeader, responseHeader, via, forwardedFor and forwarded keys are used.
+// The requestHeader and responseHeader are maps with the optional add, set and remove keys.
+// It returns nil if none of them is set.
+func HeaderOptionsFromMetadata(md mdata.Metadata) *HeaderOptions {
+	const (
+		requestHeader  = "requestHeader"
+		responseHeader = "responseHeader"
+		via            = "via"
+		forwardedFor   = "forwardedFor"
+		forwarded      = "forwarded"
+	)
+
+	opts := &HeaderOptions{
+		Request:      parseHeaderRules(md, requestHeader),
+		Response:     parseHeaderRules(md, responseHeader),
+		ForwardedFor: mdx.GetBool(md, forwardedFor),
+		Forwarded:    mdx.GetBool(md, forwarded),
+	}
+	if mdx.GetBool(md, via) {
+		opts.Via = DefaultVia
+	} else if v := mdx.GetString(md, via); v != "" && !strings.EqualFold(v, "false") {
+		opts.Via = v
+	}
+
+	if opts.Request == nil && opts.Response == nil &&
+		opts.Via == "" && !opts.ForwardedFor && !opts.Forwarded {
+		return nil
+	}
+	return opts
+}
+
+func parseHeaderRules(md mdata.Metadata, key string) *HeaderRules {
+	const (
+		add    = "add"
+		set    = "set"
+		remove = "remove"
+	)
+
+	m := mdx.GetStringMap(md, key)
+	if len(m) == 0 {
+		return nil
+	}
+	rmd := mdx.NewMetadata(m)
+	return &HeaderRules{
+		Add:    mdx.GetStringMapString(rmd, add),
+		Set:    mdx.GetStringMapString(rmd, set),
+		Remove: mdx.GetStrings(rmd, remove),
+	}
+}
+
+// ApplyRequest adds the forwarding headers for the client and applies the request rules.
+// The hop-by-hop headers should be removed before.
+func (o *HeaderOptions) ApplyRequest(req *http.Request, clientAddr string) {
+	if o == nil {
+		return
+	}
+
+	ip := clientAddr
+	if host, _, err := net.SplitHostPort(clientAddr); err == nil {
+		ip = host
+	}
+
+	if o.Via != "" {
+		addVia(req.Header, req.ProtoMajor, req.ProtoMinor, o.Via)
+	}
+	if o.ForwardedFor && ip != "" {
+		if prior := req.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			req.Header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+ip)
+		} else {
+			req.Header.Set("X-Forwarded-For", ip)
+		}
+	}
+	if o.Forwarded {
+		req.Header.Add("Forwarded", forwardedElement(req, ip))
+	}
+
+	o.Request.Apply(req.Header)
+}
+
+// ApplyResponse applies the response rules.
+// The hop-by-hop headers should be removed before.
+func (o *HeaderOptions) ApplyResponse(resp *http.Response) {
+	if o == nil {
+		return
+	}
+
+	if o.Via != "" {
+		addVia(resp.Header, resp.ProtoMajor, resp.ProtoMinor, o.Via)
+	}
+	o.Response.Apply(resp.Header)
+}
+
+// addVia adds the Via header, the protocol name is omitted for HTTP.
+func addVia(header http.Header, major, minor int, pseudonym string) {
+	var proto string
+	switch {
+	case major <= 0:
+		proto = "1.1"
+	case major == 1:
+		proto = "1." + strconv.Itoa(minor)
+	default:
+		proto = strconv.Itoa(major)
+	}
+	header.Add("Via", proto+" "+pseudonym)
+}
+
+func forwardedElement(req *http.Request, ip string) string {
+	var pairs []string
+	if ip != "" {
+		if strings.Contains(ip, ":") {
+			ip = `"[` + ip + `]"`
+		}
+		pairs = append(pairs, "for="+ip)
+	}
+	if req.Host != "" {
+		pairs = append(pairs, "host="+quote(req.Host))
+	}
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	pairs = append(pairs, "proto="+proto)
+	return strings.Join(pairs, ";")
+}
+
+func quote(s string) string {
+	if strings.ContainsAny(s, ":[]\" ;,") {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	return s
+}
+
+// KeepUserAgent keeps the User-Agent of the forwarded request as sent by the client.
+// Request.Write adds the Go default User-Agent if the header is missing,
+// an empty value makes it write the request without one.
+func KeepUserAgent(header http.Header) {
+	if _, ok := header["User-Agent"]; !ok {
+		header.Set("User-Agent", "")
+	}
+}
+
+// RemoveHopHeaders removes the hop-by-hop headers and the headers listed in the Connection header.
+func RemoveHopHeaders(header http.Header) {
+	for _, f := range header.Values("Connection") {
+		for _, sf := range strings.Split(f, ",") {
+			if sf = textproto.TrimString(sf); sf != "" {
+				header.Del(sf)
+			}
+		}
+	}
+	for _, k := range hopHeaders {
+		header.Del(k)
+	}
+}
+
+// UpgradeType returns the protocol of the Upgrade header if the Connection header contains the upgrade option.
+func UpgradeType(header http.Header) string {
+	for _, f := range header.Values("Connection") {
+		for _, sf := range strings.Split(f, ",") {
+			if strings.EqualFold(textproto.TrimString(sf), "upgrade") {
+				return header.Get("Upgrade")
+			}
+		}
+	}
+	return ""
+}
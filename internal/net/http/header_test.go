@@ -0,0 +1,277 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+func TestHeaderRulesApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  *HeaderRules
+		header http.Header
+		want   http.Header
+	}{
+		{
+			name:   "nil",
+			header: http.Header{"A": {"1"}},
+			want:   http.Header{"A": {"1"}},
+		},
+		{
+			name: "remove set add",
+			rules: &HeaderRules{
+				Remove: []string{"a", "B"},
+				Set:    map[string]string{"b": "2", "c": "3"},
+				Add:    map[string]string{"c": "4", "d": "5"},
+			},
+			header: http.Header{"A": {"1"}, "B": {"1", "2"}, "C": {"1"}},
+			want:   http.Header{"B": {"2"}, "C": {"3", "4"}, "D": {"5"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rules.Apply(tt.header)
+			if !reflect.DeepEqual(tt.header, tt.want) {
+				t.Errorf("got %v, want %v", tt.header, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveHopHeaders(t *testing.T) {
+	header := http.Header{
+		"Connection":          {"keep-alive, X-Custom", " x-other "},
+		"Keep-Alive":          {"timeout=5"},
+		"Proxy-Connection":    {"keep-alive"},
+		"Proxy-Authorization": {"Basic dXNlcjpwYXNz"},
+		"Te":                  {"trailers"},
+		"Transfer-Encoding":   {"chunked"},
+		"Upgrade":             {"websocket"},
+		"X-Custom":            {"1"},
+		"X-Other":             {"1"},
+		"X-Kept":              {"1"},
+		"Content-Type":        {"text/plain"},
+	}
+	RemoveHopHeaders(header)
+
+	want := http.Header{
+		"X-Kept":       {"1"},
+		"Content-Type": {"text/plain"},
+	}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("got %v, want %v", header, want)
+	}
+}
+
+func TestUpgradeType(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{name: "upgrade", header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}, want: "websocket"},
+		{name: "token list", header: http.Header{"Connection": {"keep-alive, upgrade"}, "Upgrade": {"h2c"}}, want: "h2c"},
+		{name: "multiple fields", header: http.Header{"Connection": {"keep-alive", " UPGRADE "}, "Upgrade": {"websocket"}}, want: "websocket"},
+		{name: "no connection option", header: http.Header{"Connection": {"keep-alive"}, "Upgrade": {"websocket"}}},
+		{name: "no connection", header: http.Header{"Upgrade": {"websocket"}}},
+		{name: "none", header: http.Header{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := UpgradeType(tt.header); v != tt.want {
+				t.Errorf("got %q, want %q", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderOptionsApplyRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   *HeaderOptions
+		req    *http.Request
+		client string
+		want   http.Header
+	}{
+		{
+			name:   "nil",
+			req:    &http.Request{ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{"A": {"1"}}},
+			client: "192.168.1.1:1234",
+			want:   http.Header{"A": {"1"}},
+		},
+		{
+			name:   "via http/1.0",
+			opts:   &HeaderOptions{Via: DefaultVia},
+			req:    &http.Request{ProtoMajor: 1, ProtoMinor: 0, Header: http.Header{"Via": {"1.1 other"}}},
+			client: "192.168.1.1:1234",
+			want:   http.Header{"Via": {"1.1 other", "1.0 gost"}},
+		},
+		{
+			name:   "via http/2",
+			opts:   &HeaderOptions{Via: "proxy"},
+			req:    &http.Request{ProtoMajor: 2, Header: http.Header{}},
+			client: "192.168.1.1:1234",
+			want:   http.Header{"Via": {"2 proxy"}},
+		},
+		{
+			name:   "via unknown version",
+			opts:   &HeaderOptions{Via: "proxy"},
+			req:    &http.Request{Header: http.Header{}},
+			client: "192.168.1.1:1234",
+			want:   http.Header{"Via": {"1.1 proxy"}},
+		},
+		{
+			name:   "forwarded for",
+			opts:   &HeaderOptions{ForwardedFor: true},
+			req:    &http.Request{ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{}},
+			client: "192.168.1.1:1234",
+			want:   http.Header{"X-Forwarded-For": {"192.168.1.1"}},
+		},
+		{
+			name:   "forwarded for appended",
+			opts:   &HeaderOptions{ForwardedFor: true},
+			req:    &http.Request{ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{"X-Forwarded-For": {"10.0.0.1", "10.0.0.2"}}},
+			client: "[2001:db8::1]:1234",
+			want:   http.Header{"X-Forwarded-For": {"10.0.0.1, 10.0.0.2, 2001:db8::1"}},
+		},
+		{
+			name: "forwarded for without client",
+			opts: &HeaderOptions{ForwardedFor: true},
+			req:  &http.Request{ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{}},
+			want: http.Header{},
+		},
+		{
+			name:   "forwarded",
+			opts:   &HeaderOptions{Forwarded: true},
+			req:    &http.Request{ProtoMajor: 1, ProtoMinor: 1, Host: "example.com", Header: http.Header{}},
+			client: "192.168.1.1:1234",
+			want:   http.Header{"Forwarded": {"for=192.168.1.1;host=example.com;proto=http"}},
+		},
+		{
+			name:   "forwarded ipv6 and host port over tls",
+			opts:   &HeaderOptions{Forwarded: true},
+			req:    &http.Request{ProtoMajor: 1, ProtoMinor: 1, Host: "example.com:8443", TLS: &tls.ConnectionState{}, Header: http.Header{"Forwarded": {"for=10.0.0.1"}}},
+			client: "[2001:db8::1]:1234",
+			want:   http.Header{"Forwarded": {"for=10.0.0.1", `for="[2001:db8::1]";host="example.com:8443";proto=https`}},
+		},
+		{
+			name: "rules after forwarding headers",
+			opts: &HeaderOptions{
+				ForwardedFor: true,
+				Request:      &HeaderRules{Remove: []string{"X-Forwarded-For"}, Set: map[string]string{"X-Real-IP": "10.0.0.1"}},
+			},
+			req:    &http.Request{ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{}},
+			client: "192.168.1.1:1234",
+			want:   http.Header{"X-Real-Ip": {"10.0.0.1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.ApplyRequest(tt.req, tt.client)
+			if !reflect.DeepEqual(tt.req.Header, tt.want) {
+				t.Errorf("got %v, want %v", tt.req.Header, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderOptionsApplyResponse(t *testing.T) {
+	opts := &HeaderOptions{
+		Via:          "proxy",
+		ForwardedFor: true,
+		Response:     &HeaderRules{Set: map[string]string{"Server": "proxy"}},
+	}
+	resp := &http.Response{ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{"Server": {"nginx"}}}
+	opts.ApplyResponse(resp)
+
+	want := http.Header{"Via": {"1.1 proxy"}, "Server": {"proxy"}}
+	if !reflect.DeepEqual(resp.Header, want) {
+		t.Errorf("got %v, want %v", resp.Header, want)
+	}
+}
+
+func TestHeaderOptionsFromMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		md   map[string]any
+		want *HeaderOptions
+	}{
+		{name: "none", md: map[string]any{}},
+		{name: "via false", md: map[string]any{"via": "false"}},
+		{name: "via true", md: map[string]any{"via": true}, want: &HeaderOptions{Via: DefaultVia}},
+		{name: "via pseudonym", md: map[string]any{"via": "proxy"}, want: &HeaderOptions{Via: "proxy"}},
+		{
+			name: "forwarded",
+			md:   map[string]any{"forwardedFor": true, "forwarded": "true"},
+			want: &HeaderOptions{ForwardedFor: true, Forwarded: true},
+		},
+		{
+			name: "rules",
+			md: map[string]any{
+				"requestHeader": map[string]any{
+					"set":    map[string]any{"X-A": "1"},
+					"remove": []any{"X-B", "X-C"},
+				},
+				"responseHeader": map[string]any{
+					"add": map[string]any{"X-D": "2"},
+				},
+			},
+			want: &HeaderOptions{
+				Request:  &HeaderRules{Set: map[string]string{"X-A": "1"}, Remove: []string{"X-B", "X-C"}},
+				Response: &HeaderRules{Add: map[string]string{"X-D": "2"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := HeaderOptionsFromMetadata(mdx.NewMetadata(tt.md))
+			if !reflect.DeepEqual(opts, tt.want) {
+				t.Errorf("got %+v, want %+v", opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeepUserAgent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{name: "missing", header: http.Header{}},
+		{name: "client", header: http.Header{"User-Agent": {"curl/8.0"}}, want: "User-Agent: curl/8.0\r\n"},
+		{name: "empty", header: http.Header{"User-Agent": {""}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.Header = tt.header
+			KeepUserAgent(req.Header)
+
+			var buf bytes.Buffer
+			if err := req.Write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			var ua string
+			for _, line := range strings.SplitAfter(buf.String(), "\r\n") {
+				if strings.HasPrefix(line, "User-Agent:") {
+					ua = line
+				}
+			}
+			if ua != tt.want {
+				t.Errorf("got %q, want %q", ua, tt.want)
+			}
+		})
+	}
+}
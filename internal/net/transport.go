@@ -2,35 +2,321 @@ package net
 
 import (
 	"bufio"
+	"errors"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/go-gost/core/common/bufpool"
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/hxdcloud/gost-x/metrics/wrapper"
 )
 
-func Transport(rw1, rw2 io.ReadWriter) error {
-	errc := make(chan error, 1)
+const (
+	// DefaultBufferSize is the buffer size of io.Copy, which relays about 1.6x the data of 4KiB over loopback TCP,
+	// while 64KiB makes no notable gain (see BenchmarkRelay).
+	DefaultBufferSize = 32 * 1024
+)
+
+var (
+	// the pools of the copy buffers by size,
+	// bufpool of the core is not used as its Put reads the buffer after it is put back.
+	bufPools sync.Map
+)
+
+var (
+	ErrIdleTimeout = errors.New("transport: idle timeout")
+	ErrMaxLifetime = errors.New("transport: max lifetime exceeded")
+)
+
+type TransportOptions struct {
+	// IdleTimeout closes the connections if no data is transferred in either direction for the duration.
+	IdleTimeout time.Duration
+	// MaxLifetime closes the connections after the duration regardless of activity.
+	MaxLifetime time.Duration
+	// BufferSize is the size of the copy buffer, DefaultBufferSize is used if not set.
+	BufferSize int
+}
+
+type TransportOption func(opts *TransportOptions)
+
+func IdleTimeoutTransportOption(timeout time.Duration) TransportOption {
+	return func(opts *TransportOptions) {
+		opts.IdleTimeout = timeout
+	}
+}
+
+func MaxLifetimeTransportOption(lifetime time.Duration) TransportOption {
+	return func(opts *TransportOptions) {
+		opts.MaxLifetime = lifetime
+	}
+}
+
+func BufferSizeTransportOption(size int) TransportOption {
+	return func(opts *TransportOptions) {
+		opts.BufferSize = size
+	}
+}
+
+// TransportOptionsFromMetadata parses the transport options from the handler metadata,
+// the idleTimeout, maxLifetime and relayBufferSize keys are used.
+func TransportOptionsFromMetadata(md mdata.Metadata) []TransportOption {
+	const (
+		idleTimeout     = "idleTimeout"
+		maxLifetime     = "maxLifetime"
+		relayBufferSize = "relayBufferSize"
+	)
+
+	return []TransportOption{
+		IdleTimeoutTransportOption(mdx.GetDuration(md, idleTimeout)),
+		MaxLifetimeTransportOption(mdx.GetDuration(md, maxLifetime)),
+		BufferSizeTransportOption(mdx.GetInt(md, relayBufferSize)),
+	}
+}
+
+// TransportStats is the number of bytes copied in each direction.
+type TransportStats struct {
+	// Up is the number of bytes copied from rw1 to rw2.
+	Up int64
+	// Down is the number of bytes copied from rw2 to rw1.
+	Down int64
+}
+
+func Transport(rw1, rw2 io.ReadWriter, opts ...TransportOption) error {
+	_, err := Relay(rw1, rw2, opts...)
+	return err
+}
+
+// Relay copies data between rw1 and rw2 in both directions.
+// The conns wrapped by the service metrics or a drained buffered reader are unwrapped for the half-close and zero-copy.
+// If both rw1 and rw2 are raw TCP or Unix sockets and the idle timeout is not set,
+// the data is copied by the ReaderFrom of the standard library, which uses splice(2) on Linux,
+// and the transferred bytes are added to the service metrics when Relay returns,
+// otherwise a copy buffer of the configured size is used.
+// If both rw1 and rw2 support half-close (CloseWrite), the EOF from one side is propagated to the other side,
+// and Relay returns when both directions are done, otherwise it returns as soon as one direction ends.
+func Relay(rw1, rw2 io.ReadWriter, opts ...TransportOption) (stats TransportStats, err error) {
+	var options TransportOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultBufferSize
+	}
+
+	c1, service1 := unwrap(rw1)
+	c2, service2 := unwrap(rw2)
+
+	cw1, ok1 := c1.(closeWriter)
+	cw2, ok2 := c2.(closeWriter)
+	halfClose := ok1 && ok2
+
+	t := &transport{
+		lastActive: time.Now().UnixNano(),
+		bufferSize: options.BufferSize,
+		// the activity can not be tracked for zero-copy.
+		zeroCopy: options.IdleTimeout <= 0 && isRawConn(c1) && isRawConn(c2),
+	}
+	if t.zeroCopy {
+		// the data bypasses the metrics wrappers.
+		rw1, rw2 = c1, c2
+		defer func() {
+			if service1 != "" {
+				wrapper.AddTransferBytes(service1, stats.Up, stats.Down)
+			}
+			if service2 != "" {
+				wrapper.AddTransferBytes(service2, stats.Down, stats.Up)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	if options.IdleTimeout > 0 || options.MaxLifetime > 0 {
+		go t.watch(rw1, rw2, &options, done)
+	}
+
+	var up, down int64
+	errc := make(chan error, 2)
 	go func() {
-		errc <- copyBuffer(rw1, rw2)
+		err := t.copyBuffer(rw1, rw2, &down)
+		if halfClose {
+			cw1.CloseWrite()
+		}
+		errc <- err
 	}()
 
 	go func() {
-		errc <- copyBuffer(rw2, rw1)
+		err := t.copyBuffer(rw2, rw1, &up)
+		if halfClose {
+			cw2.CloseWrite()
+		}
+		errc <- err
 	}()
 
-	err := <-errc
+	err = <-errc
+	if halfClose && err == nil {
+		err = <-errc
+	}
 	if err != nil && err == io.EOF {
 		err = nil
 	}
-	return err
+	if e, _ := t.err.Load().(error); e != nil {
+		err = e
+	}
+
+	stats.Up = atomic.LoadInt64(&up)
+	stats.Down = atomic.LoadInt64(&down)
+	return
 }
 
-func copyBuffer(dst io.Writer, src io.Reader) error {
-	buf := bufpool.Get(4 * 1024)
-	defer bufpool.Put(buf)
+// unwrap returns the conn wrapped by the service metrics and the service,
+// the buffered reader conn with no data buffered is also unwrapped.
+func unwrap(rw io.ReadWriter) (io.ReadWriter, string) {
+	var service string
+	for {
+		switch c := rw.(type) {
+		case *bufferReaderConn:
+			if c.br.Buffered() > 0 {
+				return rw, service
+			}
+			rw = c.Conn
+		case net.Conn:
+			cc, s, ok := wrapper.Unwrap(c)
+			if !ok {
+				return rw, service
+			}
+			rw, service = cc, s
+		default:
+			return rw, service
+		}
+	}
+}
 
-	_, err := io.CopyBuffer(dst, src, *buf)
-	return err
+// isRawConn reports whether the rw is an unwrapped TCP or Unix socket.
+func isRawConn(rw io.ReadWriter) bool {
+	switch rw.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		return true
+	default:
+		return false
+	}
+}
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+type transport struct {
+	lastActive int64
+	bufferSize int
+	zeroCopy   bool
+	err        atomic.Value
+}
+
+func (t *transport) copyBuffer(dst io.Writer, src io.Reader, n *int64) error {
+	if t.zeroCopy {
+		nw, err := io.Copy(dst, src)
+		atomic.AddInt64(n, nw)
+		return err
+	}
+
+	buf := getBuffer(t.bufferSize)
+	defer putBuffer(buf)
+
+	for {
+		nr, er := src.Read(*buf)
+		if nr > 0 {
+			atomic.StoreInt64(&t.lastActive, time.Now().UnixNano())
+			nw, ew := dst.Write((*buf)[:nr])
+			atomic.AddInt64(n, int64(nw))
+			if ew != nil {
+				return ew
+			}
+			if nw != nr {
+				return io.ErrShortWrite
+			}
+		}
+		if er != nil {
+			if er == io.EOF {
+				return nil
+			}
+			return er
+		}
+	}
+}
+
+func getBuffer(size int) *[]byte {
+	if p, ok := bufPools.Load(size); ok {
+		return p.(*sync.Pool).Get().(*[]byte)
+	}
+	p, _ := bufPools.LoadOrStore(size, &sync.Pool{
+		New: func() any {
+			b := make([]byte, size)
+			return &b
+		},
+	})
+	return p.(*sync.Pool).Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	if p, ok := bufPools.Load(len(*b)); ok {
+		p.(*sync.Pool).Put(b)
+	}
+}
+
+// watch closes the connections when the idle timeout or max lifetime is reached.
+func (t *transport) watch(rw1, rw2 io.ReadWriter, options *TransportOptions, done <-chan struct{}) {
+	start := time.Now()
+
+	period := options.IdleTimeout
+	if period <= 0 || (options.MaxLifetime > 0 && options.MaxLifetime < period) {
+		period = options.MaxLifetime
+	}
+	period /= 2
+	if period < 100*time.Millisecond {
+		period = 100 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			var err error
+			if options.MaxLifetime > 0 && now.Sub(start) >= options.MaxLifetime {
+				err = ErrMaxLifetime
+			}
+			if options.IdleTimeout > 0 &&
+				now.Sub(time.Unix(0, atomic.LoadInt64(&t.lastActive))) >= options.IdleTimeout {
+				err = ErrIdleTimeout
+			}
+			if err != nil {
+				t.err.Store(err)
+				abort(rw1)
+				abort(rw2)
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// abort interrupts the blocking operations on the rw.
+func abort(rw io.ReadWriter) {
+	if c, ok := rw.(interface{ SetDeadline(time.Time) error }); ok {
+		if c.SetDeadline(time.Now()) == nil {
+			return
+		}
+	}
+	if c, ok := rw.(io.Closer); ok {
+		c.Close()
+	}
 }
 
 type bufferReaderConn struct {
@@ -0,0 +1,273 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/metrics"
+	"github.com/hxdcloud/gost-x/metrics/wrapper"
+)
+
+// tcpPair returns the two ends of a loopback TCP connection.
+func tcpPair(tb testing.TB) (net.Conn, net.Conn) {
+	tb.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer ln.Close()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		c, err := ln.Accept()
+		ch <- result{c, err}
+	}()
+
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	r := <-ch
+	if r.err != nil {
+		tb.Fatal(r.err)
+	}
+	return c1, r.conn
+}
+
+type counterMetrics struct {
+	counters map[string]float64
+	mu       sync.Mutex
+}
+
+func (m *counterMetrics) Counter(name metrics.MetricName, labels metrics.Labels) metrics.Counter {
+	return &counter{m: m, key: string(name) + "/" + labels["service"]}
+}
+
+func (m *counterMetrics) Gauge(name metrics.MetricName, labels metrics.Labels) metrics.Gauge {
+	return nil
+}
+
+func (m *counterMetrics) Observer(name metrics.MetricName, labels metrics.Labels) metrics.Observer {
+	return nil
+}
+
+func (m *counterMetrics) get(name metrics.MetricName, service string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[string(name)+"/"+service]
+}
+
+type counter struct {
+	m   *counterMetrics
+	key string
+}
+
+func (c *counter) Inc() { c.Add(1) }
+
+func (c *counter) Add(v float64) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.counters[c.key] += v
+}
+
+func TestRelayHalfClose(t *testing.T) {
+	m := &counterMetrics{counters: make(map[string]float64)}
+	metrics.SetGlobal(m)
+	defer metrics.SetGlobal(nil)
+
+	tests := []struct {
+		name string
+		wrap bool
+		opts []TransportOption
+	}{
+		{name: "raw"},
+		{name: "metrics", wrap: true},
+		// the idle timeout disables the zero-copy.
+		{name: "buffer", wrap: true, opts: []TransportOption{IdleTimeoutTransportOption(time.Minute)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// client <-> server ... upstream <-> target
+			client, server := tcpPair(t)
+			defer client.Close()
+			upstream, target := tcpPair(t)
+			defer target.Close()
+
+			var rw1 net.Conn = server
+			if tt.wrap {
+				rw1 = wrapper.WrapConn(tt.name, server)
+			}
+			if c1, _ := unwrap(rw1); !isRawConn(c1) {
+				t.Fatalf("%T is not unwrapped", rw1)
+			}
+
+			type result struct {
+				stats TransportStats
+				err   error
+			}
+			done := make(chan result, 1)
+			go func() {
+				stats, err := Relay(rw1, upstream, tt.opts...)
+				rw1.Close()
+				upstream.Close()
+				done <- result{stats, err}
+			}()
+
+			request := []byte("request")
+			if _, err := client.Write(request); err != nil {
+				t.Fatal(err)
+			}
+			client.(*net.TCPConn).CloseWrite()
+
+			// the EOF of the client is passed through to the target.
+			target.SetReadDeadline(time.Now().Add(5 * time.Second))
+			b, err := io.ReadAll(target)
+			if err != nil {
+				t.Fatalf("target read: %v", err)
+			}
+			if !bytes.Equal(b, request) {
+				t.Fatalf("target read: got %q, want %q", b, request)
+			}
+
+			// the target can still reply after the client half-closed.
+			response := []byte("response")
+			if _, err := target.Write(response); err != nil {
+				t.Fatal(err)
+			}
+			target.(*net.TCPConn).CloseWrite()
+
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			b, err = io.ReadAll(client)
+			if err != nil {
+				t.Fatalf("client read: %v", err)
+			}
+			if !bytes.Equal(b, response) {
+				t.Fatalf("client read: got %q, want %q", b, response)
+			}
+
+			r := <-done
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			if r.stats.Up != int64(len(request)) || r.stats.Down != int64(len(response)) {
+				t.Fatalf("stats: got %+v", r.stats)
+			}
+
+			if tt.wrap {
+				if v := m.get(metrics.MetricServiceTransferInputBytesCounter, tt.name); v != float64(len(request)) {
+					t.Errorf("input bytes: got %v, want %v", v, len(request))
+				}
+				if v := m.get(metrics.MetricServiceTransferOutputBytesCounter, tt.name); v != float64(len(response)) {
+					t.Errorf("output bytes: got %v, want %v", v, len(response))
+				}
+			}
+		})
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	client, server := tcpPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	if _, err := client.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	buffered := bufio.NewReader(server)
+	if _, err := buffered.Peek(1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		conn    net.Conn
+		raw     bool
+		service string
+	}{
+		{name: "raw", conn: server, raw: true},
+		{name: "metrics", conn: wrapper.WrapConn("metrics", server), raw: true, service: "metrics"},
+		{name: "drained", conn: NewBufferReaderConn(wrapper.WrapConn("drained", server), bufio.NewReader(server)), raw: true, service: "drained"},
+		{name: "buffered", conn: NewBufferReaderConn(server, buffered)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, service := unwrap(tt.conn)
+			if isRawConn(c) != tt.raw {
+				t.Errorf("raw: got %T", c)
+			}
+			if service != tt.service {
+				t.Errorf("service: got %q, want %q", service, tt.service)
+			}
+		})
+	}
+}
+
+// BenchmarkRelay measures the throughput of the relay over loopback TCP,
+// by the zero-copy and by the copy buffer of different sizes.
+func BenchmarkRelay(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		opts []TransportOption
+	}{
+		{name: "splice"},
+	}
+	for _, size := range []int{4 * 1024, 16 * 1024, 32 * 1024, 64 * 1024} {
+		benchmarks = append(benchmarks, struct {
+			name string
+			opts []TransportOption
+		}{
+			name: fmt.Sprintf("buffer-%dK", size/1024),
+			// the idle timeout disables the zero-copy.
+			opts: []TransportOption{
+				IdleTimeoutTransportOption(time.Minute),
+				BufferSizeTransportOption(size),
+			},
+		})
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			client, server := tcpPair(b)
+			defer client.Close()
+			upstream, target := tcpPair(b)
+			defer target.Close()
+
+			go func() {
+				Relay(server, upstream, bm.opts...)
+				server.Close()
+				upstream.Close()
+			}()
+
+			done := make(chan struct{})
+			go func() {
+				io.Copy(io.Discard, target)
+				close(done)
+			}()
+
+			buf := make([]byte, 128*1024)
+			b.SetBytes(int64(len(buf)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := client.Write(buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			client.(*net.TCPConn).CloseWrite()
+			<-done
+		})
+	}
+}
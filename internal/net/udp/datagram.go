@@ -0,0 +1,180 @@
+package udp
+
+import (
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	datagramBacklog = 128
+)
+
+// Datagrammer is implemented by the connections over a QUIC stream.
+type Datagrammer interface {
+	// Datagrams returns the packet conn sending the UDP datagrams in the QUIC DATAGRAM frames of the stream,
+	// it returns nil if the DATAGRAM frames are not supported by the session.
+	Datagrams() net.PacketConn
+}
+
+// UDPServerConn relays the UDP datagrams in the QUIC DATAGRAM frames along with the UDP tunnel over the stream conn,
+// the replies are sent in the DATAGRAM frames once a datagram is received from them.
+// The tun is returned if the conn is not over a QUIC stream supporting the DATAGRAM frames.
+func UDPServerConn(conn net.Conn, tun net.PacketConn) net.PacketConn {
+	if c := newUDPConn(conn, tun, nil, false); c != nil {
+		return c
+	}
+	return tun
+}
+
+// UDPClientConn relays the UDP datagrams in the QUIC DATAGRAM frames instead of the UDP tunnel over the stream conn,
+// the datagrams too large for a DATAGRAM frame are sent over the tunnel.
+// The tun is returned if the conn is not over a QUIC stream supporting the DATAGRAM frames.
+func UDPClientConn(conn net.Conn, tun net.Conn, taddr net.Addr) net.Conn {
+	pc, ok := tun.(net.PacketConn)
+	if !ok {
+		return tun
+	}
+	if c := newUDPConn(conn, pc, taddr, true); c != nil {
+		return c
+	}
+	return tun
+}
+
+func newUDPConn(conn net.Conn, tun net.PacketConn, taddr net.Addr, client bool) *udpConn {
+	dc, ok := conn.(Datagrammer)
+	if !ok {
+		return nil
+	}
+	dgram := dc.Datagrams()
+	if dgram == nil {
+		return nil
+	}
+
+	c := &udpConn{
+		Conn:   conn,
+		tun:    tun,
+		dgram:  dgram,
+		taddr:  taddr,
+		rc:     make(chan udpPacket, datagramBacklog),
+		errc:   make(chan error, 2),
+		closed: make(chan struct{}),
+	}
+	if client {
+		c.datagram = 1
+	}
+	go c.read(tun, false)
+	go c.read(dgram, true)
+
+	return c
+}
+
+type udpPacket struct {
+	b    []byte
+	addr net.Addr
+}
+
+// udpConn merges the datagrams from the UDP tunnel over the stream and the DATAGRAM frames.
+type udpConn struct {
+	net.Conn
+	tun   net.PacketConn
+	dgram net.PacketConn
+	taddr net.Addr
+	rc    chan udpPacket
+	errc  chan error
+	// datagram is set if the datagrams are sent in the DATAGRAM frames.
+	datagram uint32
+	closed   chan struct{}
+	once     sync.Once
+	deadline time.Time
+	mu       sync.Mutex
+}
+
+func (c *udpConn) read(pc net.PacketConn, datagram bool) {
+	b := make([]byte, 65535)
+	for {
+		n, addr, err := pc.ReadFrom(b)
+		if err != nil {
+			select {
+			case c.errc <- err:
+			default:
+			}
+			return
+		}
+		if datagram {
+			atomic.StoreUint32(&c.datagram, 1)
+		}
+		select {
+		case c.rc <- udpPacket{b: append([]byte(nil), b[:n]...), addr: addr}:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *udpConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p := <-c.rc:
+		return copy(b, p.b), p.addr, nil
+	case err = <-c.errc:
+		return
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *udpConn) Read(b []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(b)
+	return
+}
+
+func (c *udpConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	if atomic.LoadUint32(&c.datagram) == 1 {
+		// the datagram too large for a DATAGRAM frame is sent over the tunnel.
+		if n, err = c.dgram.WriteTo(b, addr); err == nil {
+			return
+		}
+	}
+	return c.tun.WriteTo(b, addr)
+}
+
+func (c *udpConn) Write(b []byte) (n int, err error) {
+	return c.WriteTo(b, c.taddr)
+}
+
+func (c *udpConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of ReadFrom only, the reads of the tunnel and the DATAGRAM frames are not affected.
+func (c *udpConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *udpConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	c.dgram.Close()
+	return c.tun.Close()
+}
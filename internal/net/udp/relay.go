@@ -13,6 +13,7 @@ type Relay struct {
 	pc2 net.PacketConn
 
 	bypass     bypass.Bypass
+	session    *Session
 	bufferSize int
 	logger     logger.Logger
 }
@@ -29,6 +30,13 @@ func (r *Relay) WithBypass(bp bypass.Bypass) *Relay {
 	return r
 }
 
+// WithSession tracks the flows of pc1 in the session,
+// the datagrams are dropped by the max flows and the NAT behavior of the session table.
+func (r *Relay) WithSession(s *Session) *Relay {
+	r.session = s
+	return r
+}
+
 func (r *Relay) WithLogger(logger logger.Logger) *Relay {
 	r.logger = logger
 	return r
@@ -64,6 +72,13 @@ func (r *Relay) Run() (err error) {
 					return nil
 				}
 
+				if r.session != nil && !r.session.Outbound(raddr.String(), n) {
+					if r.logger != nil {
+						r.logger.Debugf("%s: max flows exceeded, drop datagram to %s", r.pc1.LocalAddr(), raddr)
+					}
+					return nil
+				}
+
 				if _, err := r.pc2.WriteTo((*b)[:n], raddr); err != nil {
 					return err
 				}
@@ -102,6 +117,13 @@ func (r *Relay) Run() (err error) {
 					return nil
 				}
 
+				if r.session != nil && !r.session.Inbound(raddr.String(), n) {
+					if r.logger != nil {
+						r.logger.Debugf("%s: port restricted NAT, drop datagram from %s", r.pc2.LocalAddr(), raddr)
+					}
+					return nil
+				}
+
 				if _, err := r.pc1.WriteTo((*b)[:n], raddr); err != nil {
 					return err
 				}
@@ -0,0 +1,449 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/metrics"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	xmetrics "github.com/hxdcloud/gost-x/metrics"
+)
+
+const (
+	// NATFullCone accepts the datagrams from any remote address to the association of the client.
+	NATFullCone = "fullcone"
+	// NATPortRestricted only accepts the datagrams from the remote addresses (IP and port) which the client has sent to,
+	// the flow must not be idle. The mapping is not per destination, all the flows of the client share the association.
+	NATPortRestricted = "portrestricted"
+)
+
+const (
+	DefaultIdleTimeout = 60 * time.Second
+)
+
+var (
+	ErrMaxFlows = errors.New("udp: max flows exceeded")
+)
+
+var (
+	tables   = make(map[string]*SessionTable)
+	tablesMu sync.RWMutex
+)
+
+type SessionOptions struct {
+	// IdleTimeout removes the flows if no datagram is seen in either direction for the duration.
+	IdleTimeout time.Duration
+	// MaxFlows is the maximum number of the flows of a client (by IP), zero means no limit.
+	MaxFlows int
+	// NAT is the filtering behavior, NATFullCone or NATPortRestricted.
+	NAT string
+}
+
+// SessionOptionsFromMetadata parses the UDP session options from the handler metadata,
+// the udpIdleTimeout, udpMaxFlows and natType keys are used.
+func SessionOptionsFromMetadata(md mdata.Metadata) SessionOptions {
+	const (
+		udpIdleTimeout = "udpIdleTimeout"
+		udpMaxFlows    = "udpMaxFlows"
+		natType        = "natType"
+	)
+
+	opts := SessionOptions{
+		IdleTimeout: mdx.GetDuration(md, udpIdleTimeout),
+		MaxFlows:    mdx.GetInt(md, udpMaxFlows),
+		NAT:         mdx.GetString(md, natType),
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.NAT != NATPortRestricted {
+		opts.NAT = NATFullCone
+	}
+	return opts
+}
+
+// FlowInfo is the snapshot of a UDP flow.
+type FlowInfo struct {
+	Client      string    `json:"client"`
+	Dst         string    `json:"dst"`
+	Created     time.Time `json:"created"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Expires     time.Time `json:"expires"`
+	BytesUp     int64     `json:"bytesUp"`
+	BytesDown   int64     `json:"bytesDown"`
+	PacketsUp   int64     `json:"packetsUp"`
+	PacketsDown int64     `json:"packetsDown"`
+}
+
+type flow struct {
+	dst         string
+	created     time.Time
+	seen        int64
+	bytesUp     int64
+	bytesDown   int64
+	packetsUp   int64
+	packetsDown int64
+}
+
+func (f *flow) touch(now time.Time) {
+	atomic.StoreInt64(&f.seen, now.UnixNano())
+}
+
+func (f *flow) lastSeen() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&f.seen))
+}
+
+// SessionTable is the NAT table of the UDP flows of a service,
+// a flow is the datagrams between a client association and a remote address.
+type SessionTable struct {
+	service  string
+	options  SessionOptions
+	sessions map[*Session]struct{}
+	// the number of the flows of each client IP.
+	clients map[string]int
+	mu      sync.Mutex
+	// the number of the handlers sharing the registered table.
+	refs int
+}
+
+// NewSessionTable creates and registers the session table of the service.
+// The handlers of a service with the same options share the table, such as the handlers of the auto handler,
+// each of them must close the table.
+func NewSessionTable(service string, opts SessionOptions) *SessionTable {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.NAT != NATPortRestricted {
+		opts.NAT = NATFullCone
+	}
+
+	if service != "" {
+		tablesMu.Lock()
+		defer tablesMu.Unlock()
+
+		if t := tables[service]; t != nil && t.options == opts {
+			t.refs++
+			return t
+		}
+	}
+
+	t := &SessionTable{
+		service:  service,
+		options:  opts,
+		sessions: make(map[*Session]struct{}),
+		clients:  make(map[string]int),
+		refs:     1,
+	}
+	if service != "" {
+		tables[service] = t
+	}
+
+	return t
+}
+
+// GetSessionTable returns the session table of the service.
+func GetSessionTable(service string) *SessionTable {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	return tables[service]
+}
+
+func (t *SessionTable) Options() SessionOptions {
+	return t.options
+}
+
+// NewSession creates the session of a client association, the session must be closed after use.
+func (t *SessionTable) NewSession(client net.Addr) *Session {
+	s := &Session{
+		table:  t,
+		client: addrString(client),
+		host:   hostString(client),
+		flows:  make(map[string]*flow),
+	}
+
+	t.mu.Lock()
+	t.sessions[s] = struct{}{}
+	t.mu.Unlock()
+
+	return s
+}
+
+// Flows returns the active flows ordered by the client and destination address.
+func (t *SessionTable) Flows() []FlowInfo {
+	now := time.Now()
+
+	t.mu.Lock()
+	sessions := make([]*Session, 0, len(t.sessions))
+	for s := range t.sessions {
+		sessions = append(sessions, s)
+	}
+	t.mu.Unlock()
+
+	var infos []FlowInfo
+	for _, s := range sessions {
+		s.mu.Lock()
+		s.prune(now)
+		for _, f := range s.flows {
+			seen := f.lastSeen()
+			infos = append(infos, FlowInfo{
+				Client:      s.client,
+				Dst:         f.dst,
+				Created:     f.created,
+				LastSeen:    seen,
+				Expires:     seen.Add(t.options.IdleTimeout),
+				BytesUp:     atomic.LoadInt64(&f.bytesUp),
+				BytesDown:   atomic.LoadInt64(&f.bytesDown),
+				PacketsUp:   atomic.LoadInt64(&f.packetsUp),
+				PacketsDown: atomic.LoadInt64(&f.packetsDown),
+			})
+		}
+		s.mu.Unlock()
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Client != infos[j].Client {
+			return infos[i].Client < infos[j].Client
+		}
+		return infos[i].Dst < infos[j].Dst
+	})
+	return infos
+}
+
+// Close unregisters the session table when it is closed by all the handlers sharing it.
+func (t *SessionTable) Close() error {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	if t.refs > 0 {
+		t.refs--
+	}
+	if t.refs == 0 && tables[t.service] == t {
+		delete(tables, t.service)
+	}
+	return nil
+}
+
+// acquire reserves a flow for the client IP, it reports false if the client has reached the max flows.
+func (t *SessionTable) acquire(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.options.MaxFlows > 0 && t.clients[host] >= t.options.MaxFlows {
+		return false
+	}
+	t.clients[host]++
+	t.flowsChanged(1)
+	return true
+}
+
+func (t *SessionTable) release(host string, n int) {
+	if n <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.clients[host] -= n; t.clients[host] <= 0 {
+		delete(t.clients, host)
+	}
+	t.flowsChanged(-n)
+}
+
+func (t *SessionTable) flowsChanged(n int) {
+	if t.service == "" {
+		return
+	}
+	if v := metrics.GetGauge(xmetrics.MetricServiceUDPFlowsGauge,
+		metrics.Labels{"service": t.service}); v != nil {
+		v.Add(float64(n))
+	}
+	if n > 0 {
+		if v := metrics.GetCounter(xmetrics.MetricServiceUDPFlowsCounter,
+			metrics.Labels{"service": t.service}); v != nil {
+			v.Inc()
+		}
+	}
+}
+
+func (t *SessionTable) dropped(reason string) {
+	if t.service == "" {
+		return
+	}
+	if v := metrics.GetCounter(xmetrics.MetricServiceUDPDroppedCounter,
+		metrics.Labels{"service": t.service, "reason": reason}); v != nil {
+		v.Inc()
+	}
+}
+
+// Session is the flows of a client association.
+type Session struct {
+	table  *SessionTable
+	client string
+	host   string
+	flows  map[string]*flow
+	pruned time.Time
+	closed bool
+	mu     sync.Mutex
+}
+
+// Outbound records the datagram of n bytes from the client to the dst,
+// it reports false if the datagram must be dropped as the client has reached the max flows.
+func (s *Session) Outbound(dst string, n int) bool {
+	now := time.Now()
+
+	s.mu.Lock()
+	f := s.flows[dst]
+	if f == nil {
+		if s.closed {
+			s.mu.Unlock()
+			return false
+		}
+		if now.Sub(s.pruned) > s.table.options.IdleTimeout/2 {
+			s.prune(now)
+		}
+		if !s.table.acquire(s.host) {
+			s.mu.Unlock()
+			s.table.dropped("maxFlows")
+			return false
+		}
+		f = &flow{
+			dst:     dst,
+			created: now,
+		}
+		s.flows[dst] = f
+	}
+	s.mu.Unlock()
+
+	f.touch(now)
+	atomic.AddInt64(&f.bytesUp, int64(n))
+	atomic.AddInt64(&f.packetsUp, 1)
+	return true
+}
+
+// Inbound records the datagram of n bytes from the src to the client,
+// it reports false if the datagram must be dropped by the port restricted NAT.
+func (s *Session) Inbound(src string, n int) bool {
+	now := time.Now()
+	idle := s.table.options.IdleTimeout
+
+	s.mu.Lock()
+	f := s.flows[src]
+	s.mu.Unlock()
+
+	if f == nil || now.Sub(f.lastSeen()) > idle {
+		if s.table.options.NAT == NATPortRestricted {
+			s.table.dropped("filtered")
+			return false
+		}
+		return true
+	}
+
+	f.touch(now)
+	atomic.AddInt64(&f.bytesDown, int64(n))
+	atomic.AddInt64(&f.packetsDown, 1)
+	return true
+}
+
+// Close removes the flows of the session.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	n := len(s.flows)
+	s.flows = make(map[string]*flow)
+	s.mu.Unlock()
+
+	s.table.release(s.host, n)
+
+	s.table.mu.Lock()
+	delete(s.table.sessions, s)
+	s.table.mu.Unlock()
+
+	return nil
+}
+
+// prune removes the idle flows, the caller must hold the lock.
+func (s *Session) prune(now time.Time) {
+	s.pruned = now
+	n := 0
+	for k, f := range s.flows {
+		if now.Sub(f.lastSeen()) > s.table.options.IdleTimeout {
+			delete(s.flows, k)
+			n++
+		}
+	}
+	s.table.release(s.host, n)
+}
+
+// FlowConn tracks the connection of a single flow from the client to the dst in the session table,
+// such as the UDP connection of the redirect listener. The session is closed with the connection.
+func (t *SessionTable) FlowConn(conn net.Conn, client net.Addr, dst string) (net.Conn, error) {
+	s := t.NewSession(client)
+	if !s.Outbound(dst, 0) {
+		s.Close()
+		return nil, ErrMaxFlows
+	}
+	return s.Conn(conn, dst), nil
+}
+
+// Conn tracks the datagrams read from the conn as outbound and written to the conn as inbound of the flow to the dst,
+// the session is closed with the connection.
+func (s *Session) Conn(conn net.Conn, dst string) net.Conn {
+	return &flowConn{
+		Conn:    conn,
+		session: s,
+		dst:     dst,
+	}
+}
+
+type flowConn struct {
+	net.Conn
+	session *Session
+	dst     string
+}
+
+func (c *flowConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	if n > 0 {
+		c.session.Outbound(c.dst, n)
+	}
+	return
+}
+
+func (c *flowConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	if n > 0 {
+		c.session.Inbound(c.dst, n)
+	}
+	return
+}
+
+func (c *flowConn) Close() error {
+	c.session.Close()
+	return c.Conn.Close()
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func hostString(addr net.Addr) string {
+	s := addrString(addr)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return host
+	}
+	return s
+}
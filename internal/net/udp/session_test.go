@@ -0,0 +1,159 @@
+package udp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSessionNAT(t *testing.T) {
+	client := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 5000}
+
+	tests := []struct {
+		name    string
+		nat     string
+		out     []string
+		idle    bool
+		src     string
+		allowed bool
+	}{
+		{name: "fullcone sent", nat: NATFullCone, out: []string{"1.1.1.1:53"}, src: "1.1.1.1:53", allowed: true},
+		{name: "fullcone not sent", nat: NATFullCone, out: []string{"1.1.1.1:53"}, src: "2.2.2.2:53", allowed: true},
+		{name: "fullcone idle", nat: NATFullCone, out: []string{"1.1.1.1:53"}, idle: true, src: "1.1.1.1:53", allowed: true},
+		{name: "portrestricted sent", nat: NATPortRestricted, out: []string{"1.1.1.1:53"}, src: "1.1.1.1:53", allowed: true},
+		{name: "portrestricted other port", nat: NATPortRestricted, out: []string{"1.1.1.1:53"}, src: "1.1.1.1:54", allowed: false},
+		{name: "portrestricted other host", nat: NATPortRestricted, out: []string{"1.1.1.1:53"}, src: "2.2.2.2:53", allowed: false},
+		{name: "portrestricted second flow", nat: NATPortRestricted, out: []string{"1.1.1.1:53", "2.2.2.2:53"}, src: "2.2.2.2:53", allowed: true},
+		{name: "portrestricted idle", nat: NATPortRestricted, out: []string{"1.1.1.1:53"}, idle: true, src: "1.1.1.1:53", allowed: false},
+		{name: "unknown is fullcone", nat: "symmetric", out: []string{"1.1.1.1:53"}, src: "2.2.2.2:53", allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := NewSessionTable("", SessionOptions{
+				IdleTimeout: time.Hour,
+				NAT:         tt.nat,
+			})
+			defer table.Close()
+
+			s := table.NewSession(client)
+			defer s.Close()
+
+			for _, dst := range tt.out {
+				if !s.Outbound(dst, 1) {
+					t.Fatalf("outbound %s dropped", dst)
+				}
+			}
+			if tt.idle {
+				for _, f := range s.flows {
+					f.touch(time.Now().Add(-2 * time.Hour))
+				}
+			}
+
+			if v := s.Inbound(tt.src, 1); v != tt.allowed {
+				t.Errorf("inbound %s: got %v, want %v", tt.src, v, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestSessionMaxFlows(t *testing.T) {
+	table := NewSessionTable("", SessionOptions{MaxFlows: 2})
+	defer table.Close()
+
+	s1 := table.NewSession(&net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 5000})
+	s2 := table.NewSession(&net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 5001})
+	s3 := table.NewSession(&net.UDPAddr{IP: net.IPv4(192, 168, 1, 3), Port: 5000})
+
+	tests := []struct {
+		name    string
+		s       *Session
+		close   bool
+		dst     string
+		allowed bool
+	}{
+		{name: "first flow", s: s1, dst: "1.1.1.1:53", allowed: true},
+		{name: "same flow", s: s1, dst: "1.1.1.1:53", allowed: true},
+		{name: "second flow of the IP", s: s2, dst: "1.1.1.1:53", allowed: true},
+		{name: "third flow of the IP", s: s1, dst: "2.2.2.2:53", allowed: false},
+		{name: "other IP", s: s3, dst: "2.2.2.2:53", allowed: true},
+		{name: "released by close", s: s2, close: true},
+		{name: "after release", s: s1, dst: "2.2.2.2:53", allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.close {
+				tt.s.Close()
+				return
+			}
+			if v := tt.s.Outbound(tt.dst, 1); v != tt.allowed {
+				t.Errorf("outbound %s: got %v, want %v", tt.dst, v, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestSessionTableShared(t *testing.T) {
+	const service = "test-session-table"
+	opts := SessionOptions{IdleTimeout: time.Minute, NAT: NATFullCone}
+
+	var t1, t2 *SessionTable
+	tests := []struct {
+		name string
+		fn   func()
+		want func() *SessionTable
+	}{
+		{
+			name: "same options",
+			fn: func() {
+				t1 = NewSessionTable(service, opts)
+				t2 = NewSessionTable(service, opts)
+				if t1 != t2 {
+					t.Error("not shared")
+				}
+			},
+			want: func() *SessionTable { return t1 },
+		},
+		{
+			name: "closed by one",
+			fn:   func() { t2.Close() },
+			want: func() *SessionTable { return t1 },
+		},
+		{
+			name: "closed by all",
+			fn:   func() { t1.Close() },
+			want: func() *SessionTable { return nil },
+		},
+		{
+			name: "other options",
+			fn: func() {
+				t1 = NewSessionTable(service, opts)
+				t2 = NewSessionTable(service, SessionOptions{NAT: NATPortRestricted})
+				if t1 == t2 {
+					t.Error("shared")
+				}
+			},
+			want: func() *SessionTable { return t2 },
+		},
+		{
+			name: "replaced table closed",
+			fn:   func() { t1.Close() },
+			want: func() *SessionTable { return t2 },
+		},
+		{
+			name: "replacing table closed",
+			fn:   func() { t2.Close() },
+			want: func() *SessionTable { return nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn()
+			if v, want := GetSessionTable(service), tt.want(); v != want {
+				t.Errorf("got %p, want %p", v, want)
+			}
+		})
+	}
+}
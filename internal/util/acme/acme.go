@@ -0,0 +1,668 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"golang.org/x/crypto/acme"
+)
+
+const (
+	// LetsEncryptURL is the directory URL of the Let's Encrypt production CA.
+	LetsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+	ChallengeHTTP01    = "http-01"
+	ChallengeTLSALPN01 = "tls-alpn-01"
+	ChallengeDNS01     = "dns-01"
+
+	// ALPNProto is the ALPN protocol of the TLS-ALPN-01 challenge,
+	// it should be in the NextProtos of the TLS config.
+	ALPNProto = acme.ALPNProto
+
+	defaultStoreDir    = "acme"
+	defaultRenewBefore = 30 * 24 * time.Hour
+	defaultTimeout     = 5 * time.Minute
+	minRetryInterval   = time.Minute
+	maxRetryInterval   = 6 * time.Hour
+
+	accountKeyFile = "account.key"
+)
+
+var (
+	ErrNoCertificate = errors.New("acme: no certificate")
+	ErrNoChallenge   = errors.New("acme: no supported challenge")
+)
+
+var (
+	managers   = make(map[string]*Manager)
+	managersMu sync.Mutex
+
+	// tokens are the key authorizations of the pending HTTP-01 challenges of all managers.
+	tokens sync.Map
+)
+
+type options struct {
+	domains     []string
+	email       string
+	directory   string
+	storeDir    string
+	challenge   string
+	dnsProvider DNSProvider
+	dnsWait     time.Duration
+	renewBefore time.Duration
+	httpClient  *http.Client
+	logger      logger.Logger
+}
+
+type Option func(opts *options)
+
+func DomainsOption(domains ...string) Option {
+	return func(opts *options) {
+		opts.domains = domains
+	}
+}
+
+func EmailOption(email string) Option {
+	return func(opts *options) {
+		opts.email = email
+	}
+}
+
+// DirectoryOption sets the ACME directory URL of the CA, default is Let's Encrypt.
+func DirectoryOption(url string) Option {
+	return func(opts *options) {
+		opts.directory = url
+	}
+}
+
+// StoreDirOption sets the directory to store the account key and certificates.
+func StoreDirOption(dir string) Option {
+	return func(opts *options) {
+		opts.storeDir = dir
+	}
+}
+
+// ChallengeOption sets the challenge type, one of http-01, tls-alpn-01 and dns-01.
+func ChallengeOption(challenge string) Option {
+	return func(opts *options) {
+		opts.challenge = challenge
+	}
+}
+
+// DNSProviderOption sets the provider of the DNS-01 challenge records,
+// wait is the time to wait for the records to propagate.
+func DNSProviderOption(provider DNSProvider, wait time.Duration) Option {
+	return func(opts *options) {
+		opts.dnsProvider = provider
+		opts.dnsWait = wait
+	}
+}
+
+// RenewBeforeOption sets the time before the expiration to renew the certificate.
+func RenewBeforeOption(d time.Duration) Option {
+	return func(opts *options) {
+		opts.renewBefore = d
+	}
+}
+
+// HTTPClientOption sets the HTTP client to access the CA.
+func HTTPClientOption(client *http.Client) Option {
+	return func(opts *options) {
+		opts.httpClient = client
+	}
+}
+
+func LoggerOption(logger logger.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// Get returns the shared manager of the domains, email and CA,
+// creates and starts it if it does not exist.
+// Each call to Get must be paired with a call to Release.
+func Get(opts ...Option) (*Manager, error) {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	key := managerKey(&options)
+
+	managersMu.Lock()
+	defer managersMu.Unlock()
+
+	if m := managers[key]; m != nil {
+		m.refs++
+		return m, nil
+	}
+
+	m, err := NewManager(opts...)
+	if err != nil {
+		return nil, err
+	}
+	m.Start()
+	m.key = key
+	m.refs = 1
+	managers[key] = m
+	return m, nil
+}
+
+// Release releases the shared manager obtained by Get.
+// The manager is stopped and removed when the last user releases it.
+func (m *Manager) Release() error {
+	if m == nil {
+		return nil
+	}
+
+	managersMu.Lock()
+	m.refs--
+	if m.refs > 0 || managers[m.key] != m {
+		managersMu.Unlock()
+		return nil
+	}
+	delete(managers, m.key)
+	managersMu.Unlock()
+
+	return m.Close()
+}
+
+func managerKey(opts *options) string {
+	v := make([]string, 0, len(opts.domains))
+	for _, d := range opts.domains {
+		v = append(v, strings.ToLower(d))
+	}
+	sort.Strings(v)
+
+	directory := opts.directory
+	if directory == "" {
+		directory = LetsEncryptURL
+	}
+	return strings.Join(v, ",") + "|" + strings.ToLower(opts.email) + "|" + directory
+}
+
+// HTTPChallengeResponse returns the key authorization of the HTTP-01 challenge of the request path.
+func HTTPChallengeResponse(path string) (string, bool) {
+	v, ok := tokens.Load(path)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+// Manager obtains the certificate of the domains from an ACME CA,
+// stores it on disk and renews it before it expires.
+type Manager struct {
+	cert       *tls.Certificate
+	alpnCerts  map[string]*tls.Certificate
+	client     *acme.Client
+	mu         sync.RWMutex
+	cancelFunc context.CancelFunc
+	options    options
+	// key and refs are guarded by managersMu.
+	key  string
+	refs int
+}
+
+func NewManager(opts ...Option) (*Manager, error) {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if len(options.domains) == 0 {
+		return nil, errors.New("acme: no domain")
+	}
+	if options.directory == "" {
+		options.directory = LetsEncryptURL
+	}
+	if options.storeDir == "" {
+		options.storeDir = defaultStoreDir
+	}
+	if options.challenge == "" {
+		options.challenge = ChallengeTLSALPN01
+	}
+	switch options.challenge {
+	case ChallengeHTTP01, ChallengeTLSALPN01:
+		for _, d := range options.domains {
+			if strings.HasPrefix(d, "*.") {
+				return nil, fmt.Errorf("acme: wildcard domain %s requires %s challenge", d, ChallengeDNS01)
+			}
+		}
+	case ChallengeDNS01:
+		if options.dnsProvider == nil {
+			return nil, errors.New("acme: no DNS provider")
+		}
+	default:
+		return nil, fmt.Errorf("acme: unknown challenge %s", options.challenge)
+	}
+	if options.renewBefore <= 0 {
+		options.renewBefore = defaultRenewBefore
+	}
+	if options.logger == nil {
+		options.logger = logger.Default()
+	}
+
+	if err := os.MkdirAll(options.storeDir, 0700); err != nil {
+		return nil, err
+	}
+
+	key, err := loadAccountKey(filepath.Join(options.storeDir, accountKeyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		alpnCerts: make(map[string]*tls.Certificate),
+		client: &acme.Client{
+			Key:          key,
+			DirectoryURL: options.directory,
+			HTTPClient:   options.httpClient,
+			UserAgent:    "gost",
+		},
+		options: options,
+	}
+
+	if cert, err := m.loadCert(); err == nil {
+		m.cert = cert
+	} else if !os.IsNotExist(err) {
+		options.logger.Warnf("acme: load certificate: %v", err)
+	}
+
+	return m, nil
+}
+
+// Start starts obtaining and renewing the certificate in background.
+func (m *Manager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	if m.cancelFunc != nil {
+		m.mu.Unlock()
+		cancel()
+		return
+	}
+	m.cancelFunc = cancel
+	m.mu.Unlock()
+
+	go m.renewLoop(ctx)
+}
+
+// Close stops the renewal.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancelFunc != nil {
+		m.cancelFunc()
+	}
+	return nil
+}
+
+// Certificate returns the current certificate, it is nil if the certificate is not obtained yet.
+func (m *Manager) Certificate() *tls.Certificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cert
+}
+
+// GetCertificate implements the tls.Config.GetCertificate,
+// it answers the TLS-ALPN-01 challenges and returns the current certificate.
+// It returns nil if the certificate is not obtained yet, so that the certificates of the tls.Config are used.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == ALPNProto {
+		m.mu.RLock()
+		cert := m.alpnCerts[strings.ToLower(hello.ServerName)]
+		m.mu.RUnlock()
+		if cert == nil {
+			return nil, fmt.Errorf("acme: no challenge for %s", hello.ServerName)
+		}
+		return cert, nil
+	}
+
+	return m.Certificate(), nil
+}
+
+// ServerConfig returns a copy of the cfg which uses the certificate of the manager,
+// the certificates of the cfg are used until the certificate is obtained.
+func (m *Manager) ServerConfig(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+
+	fallback := cfg.GetCertificate
+	cfg.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := m.GetCertificate(hello)
+		if cert == nil && err == nil && fallback != nil {
+			return fallback(hello)
+		}
+		return cert, err
+	}
+	if m.options.challenge == ChallengeTLSALPN01 {
+		cfg.NextProtos = append(cfg.NextProtos, ALPNProto)
+	}
+	return cfg
+}
+
+func (m *Manager) renewLoop(ctx context.Context) {
+	retry := minRetryInterval
+	for {
+		d := m.renewIn()
+		if d <= 0 {
+			if err := m.obtain(ctx); err != nil {
+				m.options.logger.Errorf("acme: obtain certificate for %v: %v", m.options.domains, err)
+				d = retry
+				if retry *= 2; retry > maxRetryInterval {
+					retry = maxRetryInterval
+				}
+			} else {
+				retry = minRetryInterval
+				continue
+			}
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// renewIn returns the duration until the certificate should be renewed.
+func (m *Manager) renewIn() time.Duration {
+	cert := m.Certificate()
+	if cert == nil || cert.Leaf == nil || !m.covers(cert.Leaf) {
+		return 0
+	}
+	d := time.Until(cert.Leaf.NotAfter.Add(-m.options.renewBefore))
+	if d > maxRetryInterval {
+		d = maxRetryInterval
+	}
+	return d
+}
+
+// covers reports whether the certificate contains all the domains.
+func (m *Manager) covers(leaf *x509.Certificate) bool {
+	for _, d := range m.options.domains {
+		found := false
+		for _, name := range leaf.DNSNames {
+			if strings.EqualFold(name, d) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Manager) obtain(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	log := m.options.logger
+	log.Infof("acme: obtaining certificate for %v from %s", m.options.domains, m.options.directory)
+
+	acct := &acme.Account{}
+	if m.options.email != "" {
+		acct.Contact = []string{"mailto:" + m.options.email}
+	}
+	if _, err := m.client.Register(ctx, acct, acme.AcceptTOS); err != nil &&
+		!errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return err
+	}
+
+	order, err := m.client.AuthorizeOrder(ctx, acme.DomainIDs(m.options.domains...))
+	if err != nil {
+		return err
+	}
+
+	for i, u := range order.AuthzURLs {
+		var domain string
+		if len(order.AuthzURLs) == len(m.options.domains) {
+			domain = m.options.domains[i]
+		}
+		if err := m.authorize(ctx, u, domain); err != nil {
+			return err
+		}
+	}
+
+	order, err = m.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: m.options.domains[0]},
+		DNSNames: m.options.domains,
+	}, key)
+	if err != nil {
+		return err
+	}
+	der, _, err := m.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return err
+	}
+
+	cert, err := m.saveCert(der, key)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.cert = cert
+	m.mu.Unlock()
+
+	log.Infof("acme: certificate for %v obtained, expires at %s", m.options.domains, cert.Leaf.NotAfter)
+	return nil
+}
+
+// authorize completes the authorization of the URL u,
+// the domain is used if the identifier is absent in the authorization.
+func (m *Manager) authorize(ctx context.Context, u string, domain string) error {
+	z, err := m.client.GetAuthorization(ctx, u)
+	if err != nil {
+		return err
+	}
+	if z.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == m.options.challenge {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("%w: %s for %s", ErrNoChallenge, m.options.challenge, z.Identifier.Value)
+	}
+
+	if z.Identifier.Value != "" {
+		domain = z.Identifier.Value
+	}
+	switch chal.Type {
+	case ChallengeHTTP01:
+		v, err := m.client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return err
+		}
+		path := m.client.HTTP01ChallengePath(chal.Token)
+		tokens.Store(path, v)
+		defer tokens.Delete(path)
+
+	case ChallengeTLSALPN01:
+		cert, err := m.client.TLSALPN01ChallengeCert(chal.Token, domain)
+		if err != nil {
+			return err
+		}
+		name := strings.ToLower(domain)
+		m.mu.Lock()
+		m.alpnCerts[name] = &cert
+		m.mu.Unlock()
+		defer func() {
+			m.mu.Lock()
+			delete(m.alpnCerts, name)
+			m.mu.Unlock()
+		}()
+
+	case ChallengeDNS01:
+		v, err := m.client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return err
+		}
+		// the wildcard prefix is not in the identifier.
+		fqdn := "_acme-challenge." + strings.TrimPrefix(domain, "*.") + "."
+		if err := m.options.dnsProvider.Present(ctx, domain, fqdn, v); err != nil {
+			return err
+		}
+		defer func() {
+			if err := m.options.dnsProvider.CleanUp(context.Background(), domain, fqdn, v); err != nil {
+				m.options.logger.Warnf("acme: clean up %s: %v", fqdn, err)
+			}
+		}()
+
+		if m.options.dnsWait > 0 {
+			select {
+			case <-time.After(m.options.dnsWait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	if _, err := m.client.Accept(ctx, chal); err != nil {
+		return err
+	}
+	_, err = m.client.WaitAuthorization(ctx, z.URI)
+	return err
+}
+
+// certFiles returns the files of the certificate and key, the name has the hash of the domains, email and CA,
+// so the managers sharing the store directory do not overwrite the certificates of each other.
+func (m *Manager) certFiles() (certFile, keyFile string) {
+	key := managerKey(&m.options)
+	sum := sha256.Sum256([]byte(key))
+	name := strings.ReplaceAll(key[:strings.IndexAny(key, ",|")], "*", "_") +
+		"-" + hex.EncodeToString(sum[:8])
+	return filepath.Join(m.options.storeDir, name+".crt"),
+		filepath.Join(m.options.storeDir, name+".key")
+}
+
+func (m *Manager) loadCert() (*tls.Certificate, error) {
+	certFile, keyFile := m.certFiles()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (m *Manager) saveCert(der [][]byte, key crypto.Signer) (*tls.Certificate, error) {
+	if len(der) == 0 {
+		return nil, ErrNoCertificate
+	}
+
+	var certPEM []byte
+	for _, b := range der {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b})...)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+
+	// both files are written before either is replaced,
+	// so a failed write does not leave a certificate with a mismatched key.
+	certFile, keyFile := m.certFiles()
+	if err := os.WriteFile(certFile+".tmp", certPEM, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile+".tmp", keyPEM, 0600); err != nil {
+		os.Remove(certFile + ".tmp")
+		return nil, err
+	}
+	if err := os.Rename(certFile+".tmp", certFile); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(keyFile+".tmp", keyFile); err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+func loadAccountKey(file string) (crypto.Signer, error) {
+	if b, err := os.ReadFile(file); err == nil {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, fmt.Errorf("acme: invalid account key %s", file)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeFile writes the file through a temporary file, so the file is replaced atomically.
+func writeFile(file string, data []byte, perm os.FileMode) error {
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
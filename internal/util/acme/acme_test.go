@@ -0,0 +1,554 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+)
+
+func newCert(t *testing.T, parent *x509.Certificate, parentKey crypto.Signer, pub crypto.PublicKey, names ...string) *x509.Certificate {
+	t.Helper()
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test"},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// fakeCA is a minimal ACME (RFC 8555) server, which validates a challenge
+// by the verify func and issues the certificates by a self-signed CA.
+type fakeCA struct {
+	*httptest.Server
+	t      *testing.T
+	ca     *x509.Certificate
+	caKey  crypto.Signer
+	verify func(chal, token, domain string) error
+
+	mu      sync.Mutex
+	domains []string
+	valid   map[int]bool
+	cert    []byte
+}
+
+func newFakeCA(t *testing.T, verify func(chal, token, domain string) error) *fakeCA {
+	key := newKey(t)
+	ca := &fakeCA{
+		t:      t,
+		ca:     newCert(t, nil, key, key.Public()),
+		caKey:  key,
+		verify: verify,
+		valid:  make(map[int]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", ca.directory)
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/account", ca.account)
+	mux.HandleFunc("/order", ca.newOrder)
+	mux.HandleFunc("/order/1", ca.order)
+	mux.HandleFunc("/authz/", ca.authz)
+	mux.HandleFunc("/chal/", ca.challenge)
+	mux.HandleFunc("/finalize/1", ca.finalize)
+	mux.HandleFunc("/cert/1", ca.certificate)
+	ca.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", strconv.FormatInt(time.Now().UnixNano(), 36))
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ca.Close)
+
+	return ca
+}
+
+func (ca *fakeCA) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// payload decodes the payload of the JWS request, the signature is not verified.
+func (ca *fakeCA) payload(r *http.Request, v any) error {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (ca *fakeCA) directory(w http.ResponseWriter, r *http.Request) {
+	ca.writeJSON(w, http.StatusOK, map[string]any{
+		"newNonce":   ca.URL + "/nonce",
+		"newAccount": ca.URL + "/account",
+		"newOrder":   ca.URL + "/order",
+	})
+}
+
+func (ca *fakeCA) account(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Location", ca.URL+"/account/1")
+	ca.writeJSON(w, http.StatusCreated, map[string]any{"status": "valid"})
+}
+
+func (ca *fakeCA) newOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Identifiers []struct {
+			Value string `json:"value"`
+		} `json:"identifiers"`
+	}
+	if err := ca.payload(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ca.mu.Lock()
+	ca.domains = nil
+	for _, id := range req.Identifiers {
+		ca.domains = append(ca.domains, id.Value)
+	}
+	ca.mu.Unlock()
+
+	w.Header().Set("Location", ca.URL+"/order/1")
+	ca.writeJSON(w, http.StatusCreated, ca.orderObject())
+}
+
+func (ca *fakeCA) orderObject() map[string]any {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	status := "ready"
+	var authzs []string
+	for i := range ca.domains {
+		authzs = append(authzs, fmt.Sprintf("%s/authz/%d", ca.URL, i))
+		if !ca.valid[i] {
+			status = "pending"
+		}
+	}
+	o := map[string]any{
+		"authorizations": authzs,
+		"finalize":       ca.URL + "/finalize/1",
+	}
+	if ca.cert != nil {
+		status = "valid"
+		o["certificate"] = ca.URL + "/cert/1"
+	}
+	o["status"] = status
+	return o
+}
+
+func (ca *fakeCA) order(w http.ResponseWriter, r *http.Request) {
+	ca.writeJSON(w, http.StatusOK, ca.orderObject())
+}
+
+func (ca *fakeCA) index(r *http.Request) int {
+	i, _ := strconv.Atoi(r.URL.Path[strings.LastIndexByte(r.URL.Path, '/')+1:])
+	return i
+}
+
+func (ca *fakeCA) authzObject(i int) map[string]any {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	status := "pending"
+	if ca.valid[i] {
+		status = "valid"
+	}
+	var chals []map[string]any
+	for _, typ := range []string{ChallengeHTTP01, ChallengeTLSALPN01, ChallengeDNS01} {
+		chals = append(chals, map[string]any{
+			"type":   typ,
+			"url":    fmt.Sprintf("%s/chal/%d?type=%s", ca.URL, i, typ),
+			"token":  fmt.Sprintf("token-%d", i),
+			"status": status,
+		})
+	}
+	return map[string]any{
+		"status":     status,
+		"identifier": map[string]any{"type": "dns", "value": ca.domains[i]},
+		"challenges": chals,
+	}
+}
+
+func (ca *fakeCA) authz(w http.ResponseWriter, r *http.Request) {
+	ca.writeJSON(w, http.StatusOK, ca.authzObject(ca.index(r)))
+}
+
+func (ca *fakeCA) challenge(w http.ResponseWriter, r *http.Request) {
+	i := ca.index(r)
+	typ := r.URL.Query().Get("type")
+
+	ca.mu.Lock()
+	domain := ca.domains[i]
+	ca.mu.Unlock()
+
+	if err := ca.verify(typ, fmt.Sprintf("token-%d", i), domain); err != nil {
+		ca.t.Errorf("challenge %s for %s: %v", typ, domain, err)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{
+			"type":   "urn:ietf:params:acme:error:unauthorized",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	ca.mu.Lock()
+	ca.valid[i] = true
+	ca.mu.Unlock()
+
+	ca.writeJSON(w, http.StatusOK, map[string]any{
+		"type":   typ,
+		"url":    ca.URL + r.URL.RequestURI(),
+		"token":  fmt.Sprintf("token-%d", i),
+		"status": "valid",
+	})
+}
+
+func (ca *fakeCA) finalize(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CSR string `json:"csr"`
+	}
+	if err := ca.payload(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := base64.RawURLEncoding.DecodeString(req.CSR)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cert := newCert(ca.t, ca.ca, ca.caKey, csr.PublicKey, csr.DNSNames...)
+	ca.mu.Lock()
+	ca.cert = cert.Raw
+	ca.mu.Unlock()
+
+	ca.writeJSON(w, http.StatusOK, ca.orderObject())
+}
+
+func (ca *fakeCA) certificate(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.cert})
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.ca.Raw})
+}
+
+type dnsRecords struct {
+	records sync.Map
+}
+
+func (p *dnsRecords) Present(ctx context.Context, domain, fqdn, value string) error {
+	p.records.Store(fqdn, value)
+	return nil
+}
+
+func (p *dnsRecords) CleanUp(ctx context.Context, domain, fqdn, value string) error {
+	p.records.Delete(fqdn)
+	return nil
+}
+
+func TestManagerObtain(t *testing.T) {
+	domains := []string{"example.com", "www.example.com"}
+
+	tests := []struct {
+		challenge string
+	}{
+		{challenge: ChallengeHTTP01},
+		{challenge: ChallengeTLSALPN01},
+		{challenge: ChallengeDNS01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.challenge, func(t *testing.T) {
+			var m *Manager
+			dns := &dnsRecords{}
+			ca := newFakeCA(t, func(chal, token, domain string) error {
+				if chal != tt.challenge {
+					return fmt.Errorf("unexpected challenge %s", chal)
+				}
+				var ok bool
+				switch chal {
+				case ChallengeHTTP01:
+					_, ok = HTTPChallengeResponse("/.well-known/acme-challenge/" + token)
+				case ChallengeTLSALPN01:
+					cert, err := m.GetCertificate(&tls.ClientHelloInfo{
+						ServerName:      domain,
+						SupportedProtos: []string{ALPNProto},
+					})
+					ok = err == nil && cert != nil
+				case ChallengeDNS01:
+					_, ok = dns.records.Load("_acme-challenge." + domain + ".")
+				}
+				if !ok {
+					return fmt.Errorf("token %s not presented", token)
+				}
+				return nil
+			})
+
+			opts := []Option{
+				DomainsOption(domains...),
+				DirectoryOption(ca.URL + "/directory"),
+				StoreDirOption(t.TempDir()),
+				ChallengeOption(tt.challenge),
+				DNSProviderOption(dns, 0),
+				HTTPClientOption(ca.Client()),
+				LoggerOption(xlogger.Nop()),
+			}
+			var err error
+			if m, err = NewManager(opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			// the certificates of the config are used until the certificate is obtained.
+			key := newKey(t)
+			fallback := tls.Certificate{
+				Certificate: [][]byte{newCert(t, nil, key, key.Public(), "fallback").Raw},
+				PrivateKey:  key,
+			}
+			cfg := tls_util.NewCertificates().ServerConfig(
+				m.ServerConfig(&tls.Config{Certificates: []tls.Certificate{fallback}}))
+			hello := &tls.ClientHelloInfo{ServerName: domains[0]}
+
+			cert, err := cfg.GetCertificate(hello)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(cert.Certificate[0], fallback.Certificate[0]) {
+				t.Fatal("fallback certificate is not used before obtained")
+			}
+
+			if err := m.obtain(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			cert, err = cfg.GetCertificate(hello)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cert.Leaf == nil || !m.covers(cert.Leaf) || len(cert.Certificate) != 2 {
+				t.Fatal("obtained certificate is not used")
+			}
+
+			// the stored certificate is loaded by a new manager.
+			m2, err := NewManager(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c := m2.Certificate(); c == nil || !bytes.Equal(c.Certificate[0], cert.Certificate[0]) {
+				t.Fatal("stored certificate is not loaded")
+			}
+			if m2.renewIn() <= 0 {
+				t.Fatal("stored certificate is renewed")
+			}
+		})
+	}
+}
+
+func TestManagerKey(t *testing.T) {
+	base := options{
+		domains: []string{"example.com", "www.example.com"},
+		email:   "admin@example.com",
+	}
+
+	tests := []struct {
+		name string
+		opts options
+		same bool
+	}{
+		{
+			name: "domain order and case",
+			opts: options{domains: []string{"WWW.example.com", "example.com"}, email: "admin@example.com"},
+			same: true,
+		},
+		{
+			name: "default CA",
+			opts: options{domains: base.domains, email: base.email, directory: LetsEncryptURL},
+			same: true,
+		},
+		{
+			name: "email",
+			opts: options{domains: base.domains, email: "other@example.com"},
+		},
+		{
+			name: "CA",
+			opts: options{domains: base.domains, email: base.email, directory: "https://ca.example.com/directory"},
+		},
+		{
+			name: "domains",
+			opts: options{domains: base.domains[:1], email: base.email},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := managerKey(&tt.opts) == managerKey(&base); same != tt.same {
+				t.Errorf("got %v, want %v", same, tt.same)
+			}
+			// the managers of different keys do not share the certificate files.
+			certFile, keyFile := (&Manager{options: tt.opts}).certFiles()
+			baseCertFile, baseKeyFile := (&Manager{options: base}).certFiles()
+			if same := certFile == baseCertFile && keyFile == baseKeyFile; same != tt.same {
+				t.Errorf("files: got %s, want %s", certFile, baseCertFile)
+			}
+		})
+	}
+}
+
+// TestSaveCertFailure checks that a failed write keeps the stored certificate and key matched.
+func TestSaveCertFailure(t *testing.T) {
+	m, err := NewManager(
+		DomainsOption("example.com"),
+		StoreDirOption(t.TempDir()),
+		LoggerOption(xlogger.Nop()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func() ([][]byte, crypto.Signer) {
+		key := newKey(t)
+		return [][]byte{newCert(t, nil, key, key.Public(), "example.com").Raw}, key
+	}
+
+	der, key := issue()
+	if _, err := m.saveCert(der, key); err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := m.certFiles()
+	for _, file := range []string{certFile, keyFile} {
+		t.Run(file[strings.LastIndexByte(file, '.')+1:], func(t *testing.T) {
+			// the temporary file can not be created.
+			if err := os.Mkdir(file+".tmp", 0700); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(file + ".tmp")
+
+			der2, key2 := issue()
+			if _, err := m.saveCert(der2, key2); err == nil {
+				t.Fatal("saveCert succeeded")
+			}
+
+			cert, err := m.loadCert()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(cert.Certificate[0], der[0]) {
+				t.Fatal("stored certificate is replaced")
+			}
+		})
+	}
+}
+
+func TestGetRelease(t *testing.T) {
+	ca := httptest.NewServer(http.NotFoundHandler())
+	defer ca.Close()
+
+	opts := []Option{
+		DomainsOption("example.com"),
+		DirectoryOption(ca.URL),
+		StoreDirOption(t.TempDir()),
+		LoggerOption(xlogger.Nop()),
+	}
+	m1, err := Get(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := Get(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1 != m2 {
+		t.Fatal("manager is not shared")
+	}
+
+	if err := m1.Release(); err != nil {
+		t.Fatal(err)
+	}
+	managersMu.Lock()
+	shared := managers[m1.key] == m1
+	managersMu.Unlock()
+	if !shared {
+		t.Fatal("released before the last release")
+	}
+
+	if err := m2.Release(); err != nil {
+		t.Fatal(err)
+	}
+	managersMu.Lock()
+	_, ok := managers[m1.key]
+	managersMu.Unlock()
+	if ok {
+		t.Error("manager is not removed")
+	}
+
+	// the released manager is removed, a new manager is created.
+	m3, err := Get(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m3.Release()
+	if m3 == m1 {
+		t.Error("released manager is reused")
+	}
+}
@@ -0,0 +1,91 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout = 30 * time.Second
+)
+
+// DNSProvider creates and removes the TXT records of the DNS-01 challenges.
+type DNSProvider interface {
+	// Present creates the TXT record fqdn with the value for the domain.
+	Present(ctx context.Context, domain, fqdn, value string) error
+	// CleanUp removes the TXT record created by Present.
+	CleanUp(ctx context.Context, domain, fqdn, value string) error
+}
+
+// WebhookRequest is the request body sent to the DNS webhook.
+type WebhookRequest struct {
+	// Action is present or cleanup.
+	Action string `json:"action"`
+	Domain string `json:"domain"`
+	FQDN   string `json:"fqdn"`
+	Value  string `json:"value"`
+}
+
+type webhookProvider struct {
+	url    string
+	client *http.Client
+}
+
+// WebhookDNSProvider returns a DNSProvider which POSTs a WebhookRequest in JSON to the url,
+// any 2xx status code is treated as success.
+func WebhookDNSProvider(url string, timeout time.Duration) DNSProvider {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &webhookProvider{
+		url: url,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (p *webhookProvider) Present(ctx context.Context, domain, fqdn, value string) error {
+	return p.call(ctx, &WebhookRequest{
+		Action: "present",
+		Domain: domain,
+		FQDN:   fqdn,
+		Value:  value,
+	})
+}
+
+func (p *webhookProvider) CleanUp(ctx context.Context, domain, fqdn, value string) error {
+	return p.call(ctx, &WebhookRequest{
+		Action: "cleanup",
+		Domain: domain,
+		FQDN:   fqdn,
+		Value:  value,
+	})
+}
+
+func (p *webhookProvider) call(ctx context.Context, r *WebhookRequest) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("acme: webhook %s %s: %s", r.Action, r.FQDN, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package bind
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultBackoffMin = time.Second
+	defaultBackoffMax = 30 * time.Second
+)
+
+// Backoff is the exponential backoff with jitter of the reconnection.
+type Backoff struct {
+	Min      time.Duration
+	Max      time.Duration
+	attempts int
+}
+
+// Next returns the delay of the next attempt,
+// it is a random value between the half and the full of the exponential delay.
+func (b *Backoff) Next() time.Duration {
+	min, max := b.Min, b.Max
+	if min <= 0 {
+		min = defaultBackoffMin
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	if max < min {
+		max = min
+	}
+
+	d := min
+	for i := 0; i < b.attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	b.attempts++
+
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// Reset resets the delay to the minimum after the successful attempt.
+func (b *Backoff) Reset() {
+	b.attempts = 0
+}
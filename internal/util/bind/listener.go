@@ -0,0 +1,230 @@
+package bind
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+// BindFunc establishes the BIND session and returns the listener of it.
+type BindFunc func() (net.Listener, error)
+
+const (
+	drainInterval = time.Second
+	// stableTime is the minimum time the session stays up for the backoff to be reset,
+	// if no stream is accepted from it.
+	stableTime = 10 * time.Second
+)
+
+type ListenConfig struct {
+	Service string
+	Network string
+	Addr    net.Addr
+	Tunnel  string
+	// BackoffMin and BackoffMax are the range of the delay between the reconnections.
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+	Logger     logger.Logger
+}
+
+// listener is a listener on the BIND session, the session is re-established with backoff when it is broken.
+type listener struct {
+	bind    BindFunc
+	ln      net.Listener
+	addr    net.Addr
+	state   *State
+	backoff Backoff
+	// since is the time the current session is established,
+	// accepted reports whether a stream is accepted from it.
+	since    time.Time
+	accepted bool
+	closed   chan struct{}
+	mu       sync.Mutex
+	logger   logger.Logger
+}
+
+func NewListener(bind BindFunc, cfg *ListenConfig) net.Listener {
+	if cfg == nil {
+		cfg = &ListenConfig{}
+	}
+
+	var addr string
+	if cfg.Addr != nil {
+		addr = cfg.Addr.String()
+	}
+	return &listener{
+		bind:  bind,
+		addr:  cfg.Addr,
+		state: NewState(cfg.Service, cfg.Network, addr, cfg.Tunnel),
+		backoff: Backoff{
+			Min: cfg.BackoffMin,
+			Max: cfg.BackoffMax,
+		},
+		closed: make(chan struct{}),
+		logger: cfg.Logger,
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		ln, err := l.getListener()
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := ln.Accept()
+		if err == nil {
+			l.mu.Lock()
+			reset := l.ln == ln && !l.accepted
+			if reset {
+				l.accepted = true
+			}
+			l.mu.Unlock()
+
+			if reset {
+				l.backoff.Reset()
+			}
+			return conn, nil
+		}
+
+		select {
+		case <-l.closed:
+			return nil, net.ErrClosed
+		default:
+		}
+
+		l.logger.Warnf("bind session on %s is broken: %v", l.addr, err)
+		l.state.Reconnecting(err)
+
+		l.mu.Lock()
+		stable := true
+		if l.ln == ln {
+			stable = l.accepted || time.Since(l.since) >= stableTime
+			l.ln = nil
+		}
+		l.mu.Unlock()
+		go l.drain(ln)
+
+		if stable {
+			l.backoff.Reset()
+			continue
+		}
+
+		// the session is broken before it is stable, such as it is closed by the server right after the BIND,
+		// so the re-bind is delayed as a failed one.
+		d := l.backoff.Next()
+		l.logger.Warnf("bind session on %s is unstable, retrying in %v", l.addr, d)
+		if err := l.wait(d); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// drain closes the listener of the broken session after the accepted streams of it are closed,
+// the new session is established meanwhile, so the in-flight streams are not dropped by the re-bind.
+// The listener is closed immediately if it does not report the number of the streams.
+func (l *listener) drain(ln net.Listener) {
+	defer ln.Close()
+
+	sc, ok := ln.(interface {
+		NumStreams() int
+	})
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for sc.NumStreams() > 0 {
+		select {
+		case <-ticker.C:
+		case <-l.closed:
+			return
+		}
+	}
+}
+
+// getListener returns the listener of the current BIND session,
+// the session is established if there is none, and retried until the listener is closed.
+func (l *listener) getListener() (net.Listener, error) {
+	for {
+		select {
+		case <-l.closed:
+			return nil, net.ErrClosed
+		default:
+		}
+
+		l.mu.Lock()
+		ln := l.ln
+		l.mu.Unlock()
+		if ln != nil {
+			return ln, nil
+		}
+
+		ln, err := l.bind()
+		if err == nil {
+			l.mu.Lock()
+			select {
+			case <-l.closed:
+				l.mu.Unlock()
+				ln.Close()
+				return nil, net.ErrClosed
+			default:
+			}
+			l.ln = ln
+			l.since = time.Now()
+			l.accepted = false
+			l.mu.Unlock()
+
+			l.state.Connected()
+			l.logger.Debugf("bind session on %s is established", l.addr)
+			return ln, nil
+		}
+
+		l.state.Reconnecting(err)
+		d := l.backoff.Next()
+		l.logger.Warnf("bind on %s: %v, retrying in %v", l.addr, err, d)
+		if err := l.wait(d); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait waits for the delay, net.ErrClosed is returned if the listener is closed meanwhile.
+func (l *listener) wait(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-l.closed:
+		return net.ErrClosed
+	}
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *listener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.closed:
+		return nil
+	default:
+		close(l.closed)
+	}
+
+	l.state.Close()
+	if l.ln != nil {
+		l.ln.Close()
+		l.ln = nil
+	}
+	return nil
+}
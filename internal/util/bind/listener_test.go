@@ -0,0 +1,196 @@
+package bind
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	xlogger "github.com/hxdcloud/gost-x/logger"
+)
+
+// fakeListener is the listener of a BIND session, Accept fails once the session is broken.
+type fakeListener struct {
+	broken  chan struct{}
+	streams atomic.Int32
+	closed  atomic.Bool
+}
+
+func newFakeListener() *fakeListener {
+	return &fakeListener{broken: make(chan struct{})}
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	<-l.broken
+	return nil, errors.New("session broken")
+}
+
+func (l *fakeListener) Close() error {
+	l.closed.Store(true)
+	return nil
+}
+
+func (l *fakeListener) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+// countListener reports the number of the streams of the session.
+type countListener struct {
+	*fakeListener
+}
+
+func (l countListener) NumStreams() int {
+	return int(l.streams.Load())
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestListenerDrain(t *testing.T) {
+	tests := []struct {
+		name    string
+		counter bool
+		streams int32
+		// drained is whether the broken listener is closed before the streams are closed.
+		drained bool
+	}{
+		{name: "no stream counter", counter: false, streams: 1, drained: true},
+		{name: "no streams", counter: true, streams: 0, drained: true},
+		{name: "in-flight streams", counter: true, streams: 1, drained: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lns := make(chan *fakeListener, 2)
+			ln := NewListener(func() (net.Listener, error) {
+				fl := newFakeListener()
+				lns <- fl
+				if tt.counter {
+					return countListener{fl}, nil
+				}
+				return fl, nil
+			}, &ListenConfig{BackoffMin: 10 * time.Millisecond, Logger: xlogger.Nop()})
+
+			go ln.Accept()
+
+			old := <-lns
+			old.streams.Store(tt.streams)
+			close(old.broken)
+
+			select {
+			case <-lns:
+			case <-time.After(3 * time.Second):
+				t.Fatal("not re-bound")
+			}
+
+			if v := waitFor(t, 100*time.Millisecond, old.closed.Load); v != tt.drained {
+				t.Errorf("closed: got %v, want %v", v, tt.drained)
+			}
+
+			old.streams.Store(0)
+			if !waitFor(t, 3*drainInterval, old.closed.Load) {
+				t.Error("not closed after the streams are closed")
+			}
+
+			ln.Close()
+		})
+	}
+}
+
+// streamListener accepts the streams of the session before it is broken.
+type streamListener struct {
+	*fakeListener
+	streams chan net.Conn
+}
+
+func (l streamListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.streams:
+		return conn, nil
+	default:
+	}
+	return l.fakeListener.Accept()
+}
+
+func TestListenerFlapping(t *testing.T) {
+	const backoffMin = 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		streams int
+		// delayed is whether the re-binds are delayed by the backoff.
+		delayed bool
+	}{
+		{name: "broken after bind", streams: 0, delayed: true},
+		{name: "broken after streams", streams: 1, delayed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binds := make(chan time.Time, 16)
+			ln := NewListener(func() (net.Listener, error) {
+				fl := newFakeListener()
+				close(fl.broken)
+				sl := streamListener{fakeListener: fl, streams: make(chan net.Conn, tt.streams)}
+				for i := 0; i < tt.streams; i++ {
+					c1, c2 := net.Pipe()
+					c2.Close()
+					sl.streams <- c1
+				}
+				binds <- time.Now()
+				return sl, nil
+			}, &ListenConfig{
+				BackoffMin: backoffMin,
+				BackoffMax: 4 * backoffMin,
+				Logger:     xlogger.Nop(),
+			})
+			defer ln.Close()
+
+			go func() {
+				for {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					conn.Close()
+				}
+			}()
+
+			var times []time.Time
+			for len(times) < 5 {
+				select {
+				case v := <-binds:
+					times = append(times, v)
+				case <-time.After(3 * time.Second):
+					t.Fatalf("got %d binds, want 5", len(times))
+				}
+			}
+
+			for i := 1; i < len(times); i++ {
+				d := times[i].Sub(times[i-1])
+				// the delay is at least the half of the exponential delay.
+				want := backoffMin / 2 << (i - 1)
+				if want > 2*backoffMin {
+					want = 2 * backoffMin
+				}
+				if tt.delayed && d < want {
+					t.Errorf("bind %d: got delay %v, want at least %v", i, d, want)
+				}
+				if !tt.delayed && d >= backoffMin/2 {
+					t.Errorf("bind %d: got delay %v, want no delay", i, d)
+				}
+			}
+		})
+	}
+}
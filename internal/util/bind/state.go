@@ -0,0 +1,132 @@
+package bind
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/metrics"
+	xmetrics "github.com/hxdcloud/gost-x/metrics"
+)
+
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+)
+
+var (
+	states   = make(map[string]*State)
+	statesMu sync.RWMutex
+)
+
+// Info is the snapshot of the State.
+// swagger:model BindInfo
+type Info struct {
+	Service    string    `json:"service"`
+	Network    string    `json:"network"`
+	Addr       string    `json:"addr"`
+	Tunnel     string    `json:"tunnel,omitempty"`
+	State      string    `json:"state"`
+	LastError  string    `json:"lastError,omitempty"`
+	Reconnects int       `json:"reconnects"`
+	Since      time.Time `json:"since"`
+}
+
+// State is the state of the BIND session of a reverse listener (rtcp, rudp).
+type State struct {
+	info Info
+	mu   sync.RWMutex
+}
+
+// NewState creates and registers the state of the service.
+func NewState(service, network, addr, tunnel string) *State {
+	s := &State{
+		info: Info{
+			Service: service,
+			Network: network,
+			Addr:    addr,
+			Tunnel:  tunnel,
+			State:   StateConnecting,
+			Since:   time.Now(),
+		},
+	}
+
+	statesMu.Lock()
+	states[service] = s
+	statesMu.Unlock()
+
+	return s
+}
+
+// Connected marks the session as established.
+func (s *State) Connected() {
+	s.mu.Lock()
+	s.info.State = StateConnected
+	s.info.Since = time.Now()
+	s.mu.Unlock()
+
+	if v := metrics.GetGauge(xmetrics.MetricServiceBindConnectedGauge,
+		metrics.Labels{"service": s.info.Service}); v != nil {
+		v.Set(1)
+	}
+}
+
+// Reconnecting marks the session as broken by the err.
+func (s *State) Reconnecting(err error) {
+	s.mu.Lock()
+	if s.info.State == StateConnected {
+		s.info.Since = time.Now()
+		s.info.Reconnects++
+
+		if v := metrics.GetCounter(xmetrics.MetricServiceBindReconnectsCounter,
+			metrics.Labels{"service": s.info.Service}); v != nil {
+			v.Inc()
+		}
+	}
+	if s.info.State != StateConnecting {
+		s.info.State = StateReconnecting
+	}
+	if err != nil {
+		s.info.LastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	if v := metrics.GetGauge(xmetrics.MetricServiceBindConnectedGauge,
+		metrics.Labels{"service": s.info.Service}); v != nil {
+		v.Set(0)
+	}
+}
+
+func (s *State) Info() Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.info
+}
+
+// Close unregisters the state.
+func (s *State) Close() error {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+
+	if states[s.info.Service] == s {
+		delete(states, s.info.Service)
+	}
+	return nil
+}
+
+// States returns the states of all the reverse listeners ordered by the service name.
+func States() []Info {
+	statesMu.RLock()
+	infos := make([]Info, 0, len(states))
+	for _, s := range states {
+		infos = append(infos, s.Info())
+	}
+	statesMu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Service < infos[j].Service
+	})
+	return infos
+}
@@ -0,0 +1,391 @@
+package fakeip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	// maxPoolSize limits the number of addresses for large (e.g. IPv6) pools.
+	maxPoolSize  = 1 << 24
+	savePeriod   = 10 * time.Second
+	defaultCIDR  = "198.18.0.0/15"
+	reservedSize = 2 // network address and gateway
+)
+
+var (
+	ErrInvalidPool = errors.New("fakeip: invalid pool")
+	ErrUnknownAddr = errors.New("fakeip: unknown address")
+)
+
+var (
+	pools   = make(map[string]*Pool)
+	poolsMu sync.Mutex
+)
+
+// Get returns the shared pool of the CIDR, creates it if it does not exist.
+// The same pool is used by the dns handler to allocate the fake IPs and
+// by the redirect handlers to translate the fake IPs back to the domain names.
+// Each call to Get must be paired with a call to Release.
+func Get(cidr string, opts ...Option) (*Pool, error) {
+	if cidr == "" {
+		cidr = defaultCIDR
+	}
+	_, inet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	key := inet.String()
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	if p := pools[key]; p != nil {
+		for _, opt := range opts {
+			opt(&p.options)
+		}
+		if p.options.file != "" && p.saveDone == nil {
+			p.load()
+			p.saveDone = make(chan struct{})
+			go p.periodSave()
+		}
+		p.refs++
+		return p, nil
+	}
+
+	p, err := NewPool(inet, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.key = key
+	p.refs = 1
+	pools[key] = p
+	return p, nil
+}
+
+// Release releases the shared pool obtained by Get.
+// The pool is closed and removed when the last user releases it.
+func (p *Pool) Release() error {
+	if p == nil {
+		return nil
+	}
+
+	poolsMu.Lock()
+	p.refs--
+	if p.refs > 0 || pools[p.key] != p {
+		poolsMu.Unlock()
+		return nil
+	}
+	delete(pools, p.key)
+	poolsMu.Unlock()
+
+	return p.Close()
+}
+
+type options struct {
+	file   string
+	logger logger.Logger
+}
+
+type Option func(opts *options)
+
+// FileOption sets the file to persist the name to IP table.
+func FileOption(file string) Option {
+	return func(opts *options) {
+		if file != "" {
+			opts.file = file
+		}
+	}
+}
+
+func LoggerOption(logger logger.Logger) Option {
+	return func(opts *options) {
+		if logger != nil {
+			opts.logger = logger
+		}
+	}
+}
+
+// Pool allocates fake IP addresses from a network and
+// keeps a bidirectional name to IP table.
+// When the pool is exhausted, the addresses are recycled in allocation order.
+type Pool struct {
+	inet     *net.IPNet
+	size     uint32
+	cursor   uint32
+	names    map[string]uint32
+	ips      map[uint32]string
+	dirty    bool
+	mu       sync.Mutex
+	saveDone chan struct{}
+	options  options
+	// key and refs are guarded by poolsMu.
+	key  string
+	refs int
+}
+
+func NewPool(inet *net.IPNet, opts ...Option) (*Pool, error) {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.logger == nil {
+		options.logger = logger.Default()
+	}
+
+	ones, bits := inet.Mask.Size()
+	size := uint64(1) << uint(bits-ones)
+	if bits-ones >= 32 || size > maxPoolSize {
+		size = maxPoolSize
+	}
+	if size <= reservedSize+1 {
+		return nil, ErrInvalidPool
+	}
+	if inet.IP.To4() != nil {
+		size-- // broadcast address
+	}
+
+	p := &Pool{
+		inet:    inet,
+		size:    uint32(size),
+		cursor:  reservedSize,
+		names:   make(map[string]uint32),
+		ips:     make(map[uint32]string),
+		options: options,
+	}
+
+	if p.options.file != "" {
+		p.load()
+		p.saveDone = make(chan struct{})
+		go p.periodSave()
+	}
+
+	return p, nil
+}
+
+// Network returns the network of the pool.
+func (p *Pool) Network() *net.IPNet {
+	return p.inet
+}
+
+// IsIPv4 reports whether the pool is an IPv4 network.
+func (p *Pool) IsIPv4() bool {
+	return p.inet.IP.To4() != nil
+}
+
+// Contains reports whether the ip is allocated from the pool network.
+func (p *Pool) Contains(ip net.IP) bool {
+	return ip != nil && p.inet.Contains(ip)
+}
+
+// Allocate returns the fake IP of the name, allocates a new one if not exists.
+func (p *Pool) Allocate(name string) net.IP {
+	name = normalize(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n, ok := p.names[name]; ok {
+		return p.ip(n)
+	}
+
+	n := p.cursor
+	p.cursor++
+	if p.cursor >= p.size {
+		p.cursor = reservedSize
+	}
+
+	// recycle the address
+	if old, ok := p.ips[n]; ok {
+		delete(p.names, old)
+		p.options.logger.Debugf("fakeip: recycle %s from %s", p.ip(n), old)
+	}
+	p.names[name] = n
+	p.ips[n] = name
+	p.dirty = true
+
+	return p.ip(n)
+}
+
+// Lookup returns the name mapped to the fake IP.
+func (p *Pool) Lookup(ip net.IP) (string, bool) {
+	n, ok := p.offset(ip)
+	if !ok {
+		return "", false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name, ok := p.ips[n]
+	return name, ok
+}
+
+// ReverseAddr translates the fake IP in addr back to the domain name, the port is kept.
+// The addr is returned unchanged if it is not in the pool network,
+// ErrUnknownAddr is returned if it is in the pool network but not allocated.
+func (p *Pool) ReverseAddr(addr net.Addr) (net.Addr, error) {
+	if p == nil || addr == nil {
+		return addr, nil
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr, nil
+	}
+	ip := net.ParseIP(host)
+	if !p.Contains(ip) {
+		return addr, nil
+	}
+	name, ok := p.Lookup(ip)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAddr, addr)
+	}
+	return &domainAddr{
+		network: addr.Network(),
+		addr:    net.JoinHostPort(name, port),
+	}, nil
+}
+
+func (p *Pool) ip(n uint32) net.IP {
+	base := p.inet.IP.To16()
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, base)
+	v := binary.BigEndian.Uint32(ip[12:]) + n
+	binary.BigEndian.PutUint32(ip[12:], v)
+	if p.IsIPv4() {
+		return ip.To4()
+	}
+	return ip
+}
+
+func (p *Pool) offset(ip net.IP) (uint32, bool) {
+	if !p.Contains(ip) {
+		return 0, false
+	}
+	ip = ip.To16()
+	base := p.inet.IP.To16()
+	n := binary.BigEndian.Uint32(ip[12:]) - binary.BigEndian.Uint32(base[12:])
+	if n < reservedSize || n >= p.size {
+		return 0, false
+	}
+	return n, true
+}
+
+// Close saves the table and stops the persistence.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	done := p.saveDone
+	p.saveDone = nil
+	p.mu.Unlock()
+
+	if done != nil {
+		close(done)
+	}
+	return p.save()
+}
+
+func (p *Pool) periodSave() {
+	p.mu.Lock()
+	done := p.saveDone
+	p.mu.Unlock()
+
+	ticker := time.NewTicker(savePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.save(); err != nil {
+				p.options.logger.Warnf("fakeip: save: %v", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// load loads the table from file, one mapping per line in the format: IP name
+func (p *Pool) load() {
+	f, err := os.Open(p.options.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			p.options.logger.Warnf("fakeip: load: %v", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		n, ok := p.offset(net.ParseIP(fields[0]))
+		if !ok {
+			continue
+		}
+		name := normalize(fields[1])
+		p.names[name] = n
+		p.ips[n] = name
+		if n >= p.cursor {
+			p.cursor = n + 1
+			if p.cursor >= p.size {
+				p.cursor = reservedSize
+			}
+		}
+	}
+}
+
+func (p *Pool) save() error {
+	if p.options.file == "" {
+		return nil
+	}
+
+	p.mu.Lock()
+	if !p.dirty {
+		p.mu.Unlock()
+		return nil
+	}
+	var b strings.Builder
+	for n, name := range p.ips {
+		fmt.Fprintf(&b, "%s %s\n", p.ip(n), name)
+	}
+	p.dirty = false
+	p.mu.Unlock()
+
+	tmp := p.options.file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.options.file)
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+type domainAddr struct {
+	network string
+	addr    string
+}
+
+func (a *domainAddr) Network() string {
+	return a.network
+}
+
+func (a *domainAddr) String() string {
+	return a.addr
+}
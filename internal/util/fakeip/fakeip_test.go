@@ -0,0 +1,311 @@
+package fakeip
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	xlogger "github.com/hxdcloud/gost-x/logger"
+)
+
+func newPool(t *testing.T, cidr string, opts ...Option) *Pool {
+	t.Helper()
+
+	_, inet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPool(inet, append([]Option{LoggerOption(xlogger.Nop())}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestNewPool(t *testing.T) {
+	tests := []struct {
+		cidr string
+		size uint32
+		err  error
+	}{
+		{cidr: "198.18.0.0/15", size: 1<<17 - 1},
+		{cidr: "10.0.0.0/29", size: 7},
+		{cidr: "10.0.0.0/30", size: 3},
+		{cidr: "10.0.0.0/31", err: ErrInvalidPool},
+		{cidr: "10.0.0.0/32", err: ErrInvalidPool},
+		{cidr: "fd00::/120", size: 256},
+		{cidr: "fd00::/64", size: maxPoolSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			_, inet, _ := net.ParseCIDR(tt.cidr)
+			p, err := NewPool(inet, LoggerOption(xlogger.Nop()))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err == nil && p.size != tt.size {
+				t.Errorf("got size %d, want %d", p.size, tt.size)
+			}
+		})
+	}
+}
+
+func TestPoolAllocate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidr  string
+		names []string
+		// ips are the addresses allocated to the names.
+		ips []string
+		// lookup is the expected name of each address after all the allocations, empty if recycled.
+		lookup []string
+	}{
+		{
+			name:   "ipv4",
+			cidr:   "198.18.0.0/15",
+			names:  []string{"a.example.com", "b.example.com"},
+			ips:    []string{"198.18.0.2", "198.18.0.3"},
+			lookup: []string{"a.example.com", "b.example.com"},
+		},
+		{
+			name:   "normalize",
+			cidr:   "198.18.0.0/15",
+			names:  []string{"A.Example.com.", "a.example.com"},
+			ips:    []string{"198.18.0.2", "198.18.0.2"},
+			lookup: []string{"a.example.com", "a.example.com"},
+		},
+		{
+			name:   "ipv6",
+			cidr:   "fd00::/120",
+			names:  []string{"a.example.com", "b.example.com"},
+			ips:    []string{"fd00::2", "fd00::3"},
+			lookup: []string{"a.example.com", "b.example.com"},
+		},
+		{
+			// 10.0.0.2-10.0.0.6 are usable, 10.0.0.7 is the broadcast address.
+			name:   "wraparound",
+			cidr:   "10.0.0.0/29",
+			names:  []string{"a", "b", "c", "d", "e", "f"},
+			ips:    []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.0.2"},
+			lookup: []string{"f", "b", "c", "d", "e", "f"},
+		},
+		{
+			// the addresses are recycled in allocation order, looking up a name does not refresh it.
+			name:   "recycle order",
+			cidr:   "10.0.0.0/30",
+			names:  []string{"a", "a", "b", "c", "a"},
+			ips:    []string{"10.0.0.2", "10.0.0.2", "10.0.0.2", "10.0.0.2", "10.0.0.2"},
+			lookup: []string{"a", "a", "a", "a", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPool(t, tt.cidr)
+
+			for i, name := range tt.names {
+				if ip := p.Allocate(name); ip.String() != tt.ips[i] {
+					t.Errorf("%s: got %s, want %s", name, ip, tt.ips[i])
+				}
+			}
+			for i, ip := range tt.ips {
+				name, ok := p.Lookup(net.ParseIP(ip))
+				if name != tt.lookup[i] || ok != (tt.lookup[i] != "") {
+					t.Errorf("%s: got %q %v, want %q", ip, name, ok, tt.lookup[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPoolRecycle(t *testing.T) {
+	p := newPool(t, "10.0.0.0/29")
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		p.Allocate(name)
+	}
+
+	// a is recycled by f, allocating a again takes the next oldest address from b.
+	if ip := p.Allocate("a"); ip.String() != "10.0.0.3" {
+		t.Errorf("got %s, want 10.0.0.3", ip)
+	}
+	if _, ok := p.Lookup(net.ParseIP("10.0.0.3")); !ok {
+		t.Error("10.0.0.3 not found")
+	}
+	if ip := p.Allocate("b"); ip.String() != "10.0.0.4" {
+		t.Errorf("got %s, want 10.0.0.4", ip)
+	}
+	if name, _ := p.Lookup(net.ParseIP("10.0.0.2")); name != "f" {
+		t.Errorf("got %q, want f", name)
+	}
+}
+
+func TestPoolLookup(t *testing.T) {
+	p := newPool(t, "198.18.0.0/15")
+	p.Allocate("example.com")
+
+	tests := []struct {
+		ip   string
+		name string
+	}{
+		{ip: "198.18.0.2", name: "example.com"},
+		{ip: "198.18.0.3"},
+		{ip: "198.18.0.0"},
+		{ip: "198.18.0.1"},
+		{ip: "198.19.255.255"},
+		{ip: "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			name, ok := p.Lookup(net.ParseIP(tt.ip))
+			if name != tt.name || ok != (tt.name != "") {
+				t.Errorf("got %q %v, want %q", name, ok, tt.name)
+			}
+		})
+	}
+}
+
+func TestPoolReverseAddr(t *testing.T) {
+	p := newPool(t, "198.18.0.0/15")
+	p.Allocate("example.com")
+
+	tests := []struct {
+		name string
+		addr net.Addr
+		want string
+		err  error
+	}{
+		{
+			name: "tcp",
+			addr: &net.TCPAddr{IP: net.ParseIP("198.18.0.2"), Port: 443},
+			want: "example.com:443",
+		},
+		{
+			name: "udp",
+			addr: &net.UDPAddr{IP: net.ParseIP("198.18.0.2"), Port: 53},
+			want: "example.com:53",
+		},
+		{
+			name: "not in pool",
+			addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 80},
+			want: "10.0.0.1:80",
+		},
+		{
+			name: "unknown",
+			addr: &net.TCPAddr{IP: net.ParseIP("198.18.0.3"), Port: 80},
+			err:  ErrUnknownAddr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := p.ReverseAddr(tt.addr)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if addr.String() != tt.want || addr.Network() != tt.addr.Network() {
+				t.Errorf("got %s/%s, want %s/%s", addr.Network(), addr, tt.addr.Network(), tt.want)
+			}
+		})
+	}
+
+	var np *Pool
+	addr := &net.TCPAddr{IP: net.ParseIP("198.18.0.2"), Port: 443}
+	if got, err := np.ReverseAddr(addr); got != addr || err != nil {
+		t.Errorf("nil pool: got %v, %v", got, err)
+	}
+}
+
+func TestPoolPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fakeip.txt")
+
+	p := newPool(t, "10.0.0.0/29", FileOption(file))
+	p.Allocate("a")
+	p.Allocate("b")
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 2 {
+		t.Errorf("got %d lines, want 2:\n%s", n, b)
+	}
+
+	// append an invalid and an out of pool entry, both are skipped.
+	os.WriteFile(file, append(b, "invalid\n10.0.1.2 c\n10.0.0.1 d\n"...), 0644)
+
+	p = newPool(t, "10.0.0.0/29", FileOption(file))
+	for ip, want := range map[string]string{"10.0.0.2": "a", "10.0.0.3": "b", "10.0.0.1": ""} {
+		if name, _ := p.Lookup(net.ParseIP(ip)); name != want {
+			t.Errorf("%s: got %q, want %q", ip, name, want)
+		}
+	}
+	// the allocation continues after the loaded entries.
+	if ip := p.Allocate("c"); ip.String() != "10.0.0.4" {
+		t.Errorf("got %s, want 10.0.0.4", ip)
+	}
+	if ip := p.Allocate("a"); ip.String() != "10.0.0.2" {
+		t.Errorf("got %s, want 10.0.0.2", ip)
+	}
+}
+
+func TestGetRelease(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fakeip.txt")
+
+	p1, err := Get("10.1.0.0/24", LoggerOption(xlogger.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file option of a later user enables the persistence of the shared pool.
+	p2, err := Get("10.1.0.1/24", FileOption(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1 != p2 {
+		t.Fatal("pool is not shared")
+	}
+	p1.Allocate("example.com")
+
+	if err := p1.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("saved before the last release: %v", err)
+	}
+
+	if err := p2.Release(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "10.1.0.2 example.com\n" {
+		t.Errorf("got %q", b)
+	}
+
+	// the released pool is removed, a new pool is loaded from the file.
+	p3, err := Get("10.1.0.0/24", LoggerOption(xlogger.Nop()), FileOption(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p3.Release()
+	if p3 == p1 {
+		t.Error("released pool is reused")
+	}
+	if name, _ := p3.Lookup(net.ParseIP("10.1.0.2")); name != "example.com" {
+		t.Errorf("got %q, want example.com", name)
+	}
+}
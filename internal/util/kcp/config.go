@@ -2,11 +2,24 @@ package kcp
 
 import (
 	"crypto/sha1"
+	"encoding/json"
+	"fmt"
 
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
 	"github.com/xtaci/kcp-go/v5"
 	"golang.org/x/crypto/pbkdf2"
 )
 
+const (
+	PresetNormal = "normal"
+	PresetFast   = "fast"
+	PresetFast2  = "fast2"
+	// PresetLowBandwidth trades the latency for the bandwidth,
+	// with the congestion control on, small windows and no FEC.
+	PresetLowBandwidth = "low-bandwidth"
+)
+
 var (
 	// DefaultSalt is the default salt for KCP cipher.
 	DefaultSalt = "kcp-go"
@@ -62,6 +75,77 @@ type Config struct {
 	SnmpPeriod   int    `json:"snmpperiod"`
 	Signal       bool   `json:"signal"` // Signal enables the signal SIGUSR1 feature.
 	TCP          bool   `json:"tcp"`
+	// Adaptive adjusts the send window and interval of the sessions based on the observed loss.
+	// kcp-go only measures the loss per process, so it takes effect only while
+	// the sessions of this service are the only KCP sessions in the process.
+	Adaptive bool `json:"adaptive"`
+}
+
+// PresetConfig returns a copy of the named preset config.
+func PresetConfig(name string) (*Config, error) {
+	c := *DefaultConfig
+
+	switch name {
+	case PresetNormal:
+		c.Mode = "normal"
+	case PresetFast:
+		c.Mode = "fast"
+	case PresetFast2:
+		c.Mode = "fast2"
+		c.AckNodelay = true
+	case PresetLowBandwidth:
+		c.Mode = "manual"
+		c.NoDelay, c.Interval, c.Resend, c.NoCongestion = 0, 40, 0, 0
+		c.SndWnd, c.RcvWnd = 128, 128
+		c.DataShard, c.ParityShard = 0, 0
+		c.SockBuf = 1048576
+	default:
+		return nil, fmt.Errorf("kcp: unknown preset %s", name)
+	}
+	return &c, nil
+}
+
+// ConfigFromMetadata parses the KCP config from the listener or dialer metadata.
+// The config is based on the preset if the preset key is set, then overridden by the config key,
+// the adaptive key enables the adaptive mode.
+func ConfigFromMetadata(md mdata.Metadata) (*Config, error) {
+	const (
+		preset   = "preset"
+		config   = "config"
+		adaptive = "adaptive"
+	)
+
+	var cfg *Config
+	if name := mdx.GetString(md, preset); name != "" {
+		c, err := PresetConfig(name)
+		if err != nil {
+			return nil, err
+		}
+		cfg = c
+	}
+
+	if m := mdx.GetStringMap(md, config); len(m) > 0 {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		if cfg == nil {
+			cfg = &Config{}
+		}
+		if err := json.Unmarshal(b, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg == nil {
+		c := *DefaultConfig
+		cfg = &c
+	}
+	if mdx.GetBool(md, adaptive) {
+		cfg.Adaptive = true
+	}
+
+	return cfg, nil
 }
 
 // Init initializes the KCP config.
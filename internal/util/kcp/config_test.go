@@ -0,0 +1,58 @@
+package kcp
+
+import (
+	"testing"
+
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+func TestConfigFromMetadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		md        map[string]any
+		mode      string
+		sndWnd    int
+		dataShard int
+		crypt     string
+		adaptive  bool
+		err       bool
+	}{
+		{name: "default", md: map[string]any{}, mode: "fast", sndWnd: 1024, dataShard: 10, crypt: "aes"},
+		{name: "preset fast2", md: map[string]any{"preset": "fast2"}, mode: "fast2", sndWnd: 1024, dataShard: 10, crypt: "aes"},
+		{name: "preset low bandwidth", md: map[string]any{"preset": "low-bandwidth"}, mode: "manual", sndWnd: 128, dataShard: 0, crypt: "aes"},
+		{
+			name:      "preset overridden",
+			md:        map[string]any{"preset": "low-bandwidth", "config": map[string]any{"sndwnd": 256, "crypt": "none"}},
+			mode:      "manual",
+			sndWnd:    256,
+			dataShard: 0,
+			crypt:     "none",
+		},
+		{name: "config only", md: map[string]any{"config": map[string]any{"mode": "normal"}}, mode: "normal"},
+		{name: "adaptive", md: map[string]any{"preset": "fast", "adaptive": true}, mode: "fast", sndWnd: 1024, dataShard: 10, crypt: "aes", adaptive: true},
+		{name: "unknown preset", md: map[string]any{"preset": "turbo"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ConfigFromMetadata(mdx.NewMetadata(tt.md))
+			if (err != nil) != tt.err {
+				t.Fatalf("err: got %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if cfg == DefaultConfig {
+				t.Fatal("got the default config, want a copy")
+			}
+			if cfg.Mode != tt.mode || cfg.SndWnd != tt.sndWnd || cfg.DataShard != tt.dataShard ||
+				cfg.Crypt != tt.crypt || cfg.Adaptive != tt.adaptive {
+				t.Errorf("got %+v", cfg)
+			}
+		})
+	}
+
+	if DefaultConfig.Mode != "fast" || DefaultConfig.SndWnd != 1024 || DefaultConfig.AckNodelay {
+		t.Errorf("the default config is changed: %+v", DefaultConfig)
+	}
+}
@@ -0,0 +1,225 @@
+package kcp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/metrics"
+	xmetrics "github.com/hxdcloud/gost-x/metrics"
+	"github.com/xtaci/kcp-go/v5"
+)
+
+const (
+	// the interval of sampling the SNMP counters and the sessions.
+	sampleInterval = 5 * time.Second
+	// the segments sent in a sample below which the loss is not evaluated.
+	minSampleSegs = 100
+	// the ratio of the retransmitted segments above which the adaptive sessions back off.
+	lossHigh = 0.1
+	// the ratio of the retransmitted segments below which the adaptive sessions recover.
+	lossLow = 0.02
+	// the bounds of the send window and interval (in milliseconds) of the adaptive sessions.
+	minWindow    = 32
+	maxInterval  = 100
+	intervalStep = 10
+)
+
+var (
+	monitors    = make(map[*Monitor]struct{})
+	monitorsMu  sync.Mutex
+	samplerOnce sync.Once
+)
+
+// Monitor tracks the KCP sessions of a service for the RTT statistics and the adaptive mode,
+// it is sampled only while it has sessions, so an idle monitor is not leaked if it is never closed.
+type Monitor struct {
+	service  string
+	config   *Config
+	sessions map[*kcp.UDPSession]*tuning
+	mu       sync.Mutex
+}
+
+// NewMonitor creates the monitor of the service, the config must be initialized.
+func NewMonitor(service string, config *Config) *Monitor {
+	samplerOnce.Do(func() {
+		go sample()
+	})
+
+	return &Monitor{
+		service:  service,
+		config:   config,
+		sessions: make(map[*kcp.UDPSession]*tuning),
+	}
+}
+
+// Add tracks the session, the session must be removed when it is closed.
+func (m *Monitor) Add(sess *kcp.UDPSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[sess] = &tuning{
+		wnd:      m.config.SndWnd,
+		interval: m.config.Interval,
+	}
+	if len(m.sessions) == 1 {
+		monitorsMu.Lock()
+		monitors[m] = struct{}{}
+		monitorsMu.Unlock()
+	}
+}
+
+func (m *Monitor) Remove(sess *kcp.UDPSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[sess]; !ok {
+		return
+	}
+	delete(m.sessions, sess)
+	if len(m.sessions) == 0 {
+		monitorsMu.Lock()
+		delete(monitors, m)
+		monitorsMu.Unlock()
+	}
+}
+
+func (m *Monitor) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions = make(map[*kcp.UDPSession]*tuning)
+
+	monitorsMu.Lock()
+	delete(monitors, m)
+	monitorsMu.Unlock()
+
+	return nil
+}
+
+// sample observes the RTT of the sessions and adjusts the adaptive sessions by the loss,
+// a negative loss means the loss is unknown.
+// The loss is measured per process, it can only be attributed to the sessions
+// if they are the only ones in the process, so the adaptive sessions are restored
+// to the configured values when the sessions of other monitors are active (shared).
+func (m *Monitor) sample(loss float64, shared bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for sess, t := range m.sessions {
+		if m.service != "" {
+			if v := metrics.GetObserver(xmetrics.MetricKCPRTTObserver,
+				metrics.Labels{"service": m.service}); v != nil {
+				v.Observe(float64(sess.GetSRTT()) / 1000)
+			}
+		}
+		if !m.config.Adaptive {
+			continue
+		}
+		if shared {
+			t.reset(sess, m.config)
+		} else if loss >= 0 {
+			t.adapt(sess, m.config, loss)
+		}
+	}
+}
+
+// tuning is the current send window and interval of an adaptive session,
+// they vary between the bounds and the configured values.
+type tuning struct {
+	wnd      int
+	interval int
+}
+
+func (t *tuning) adapt(sess *kcp.UDPSession, c *Config, loss float64) {
+	wnd, interval := t.wnd, t.interval
+
+	switch {
+	case loss > lossHigh:
+		floor := minWindow
+		if c.SndWnd < floor {
+			floor = c.SndWnd
+		}
+		if wnd /= 2; wnd < floor {
+			wnd = floor
+		}
+		ceil := maxInterval
+		if c.Interval > ceil {
+			ceil = c.Interval
+		}
+		if interval += intervalStep; interval > ceil {
+			interval = ceil
+		}
+	case loss < lossLow:
+		if wnd += wnd/4 + 1; wnd > c.SndWnd {
+			wnd = c.SndWnd
+		}
+		if interval -= intervalStep; interval < c.Interval {
+			interval = c.Interval
+		}
+	}
+
+	t.set(sess, c, wnd, interval)
+}
+
+// reset restores the configured send window and interval.
+func (t *tuning) reset(sess *kcp.UDPSession, c *Config) {
+	t.set(sess, c, c.SndWnd, c.Interval)
+}
+
+func (t *tuning) set(sess *kcp.UDPSession, c *Config, wnd, interval int) {
+	if wnd != t.wnd {
+		sess.SetWindowSize(wnd, c.RcvWnd)
+		t.wnd = wnd
+	}
+	if interval != t.interval {
+		sess.SetNoDelay(c.NoDelay, interval, c.Resend, c.NoCongestion)
+		t.interval = interval
+	}
+}
+
+// sample exports the SNMP counters of kcp-go and samples the monitors periodically.
+// kcp-go keeps the counters per process and has no counters per session,
+// so the counters are exported without the service label, and so is the loss measured.
+func sample() {
+	last := kcp.DefaultSnmp.Copy()
+
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		snmp := kcp.DefaultSnmp.Copy()
+
+		addCounter(xmetrics.MetricKCPOutSegsCounter, snmp.OutSegs, last.OutSegs)
+		addCounter(xmetrics.MetricKCPRetransSegsCounter, snmp.RetransSegs, last.RetransSegs)
+		addCounter(xmetrics.MetricKCPLostSegsCounter, snmp.LostSegs, last.LostSegs)
+		addCounter(xmetrics.MetricKCPFECRecoveredCounter, snmp.FECRecovered, last.FECRecovered)
+		addCounter(xmetrics.MetricKCPFECErrorsCounter, snmp.FECErrs, last.FECErrs)
+
+		loss := -1.0
+		if n := snmp.OutSegs - last.OutSegs; snmp.OutSegs > last.OutSegs && n >= minSampleSegs {
+			loss = float64(snmp.RetransSegs-last.RetransSegs) / float64(n)
+		}
+		last = snmp
+
+		monitorsMu.Lock()
+		ms := make([]*Monitor, 0, len(monitors))
+		for m := range monitors {
+			ms = append(ms, m)
+		}
+		monitorsMu.Unlock()
+
+		for _, m := range ms {
+			m.sample(loss, len(ms) > 1)
+		}
+	}
+}
+
+// addCounter adds the increment of the SNMP counter, which is lost if the counters are reset.
+func addCounter(name metrics.MetricName, n, last uint64) {
+	if n <= last {
+		return
+	}
+	if v := metrics.GetCounter(name, nil); v != nil {
+		v.Add(float64(n - last))
+	}
+}
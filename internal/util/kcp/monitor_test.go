@@ -0,0 +1,100 @@
+package kcp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/xtaci/kcp-go/v5"
+)
+
+func newSession(t *testing.T) *kcp.UDPSession {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := kcp.NewConn("127.0.0.1:9", nil, 0, 0, pc)
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sess.Close()
+		pc.Close()
+	})
+	return sess
+}
+
+func registered(m *Monitor) bool {
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+
+	_, ok := monitors[m]
+	return ok
+}
+
+func TestMonitorRegister(t *testing.T) {
+	m := NewMonitor("", &Config{SndWnd: 128, Interval: 20})
+	s1, s2 := newSession(t), newSession(t)
+
+	tests := []struct {
+		name       string
+		fn         func()
+		registered bool
+	}{
+		{name: "new", fn: func() {}, registered: false},
+		{name: "first session", fn: func() { m.Add(s1) }, registered: true},
+		{name: "second session", fn: func() { m.Add(s2) }, registered: true},
+		{name: "one removed", fn: func() { m.Remove(s1) }, registered: true},
+		{name: "removed twice", fn: func() { m.Remove(s1) }, registered: true},
+		{name: "all removed", fn: func() { m.Remove(s2) }, registered: false},
+		{name: "added again", fn: func() { m.Add(s1) }, registered: true},
+		{name: "closed", fn: func() { m.Close() }, registered: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn()
+			if v := registered(m); v != tt.registered {
+				t.Errorf("registered: got %v, want %v", v, tt.registered)
+			}
+		})
+	}
+}
+
+func TestMonitorAdaptive(t *testing.T) {
+	config := &Config{SndWnd: 128, RcvWnd: 128, Interval: 20, Adaptive: true}
+	m := NewMonitor("", config)
+	defer m.Close()
+
+	sess := newSession(t)
+	m.Add(sess)
+	tn := m.sessions[sess]
+
+	tests := []struct {
+		name     string
+		loss     float64
+		shared   bool
+		wnd      int
+		interval int
+	}{
+		{name: "unknown loss", loss: -1, wnd: 128, interval: 20},
+		{name: "high loss", loss: 0.5, wnd: 64, interval: 30},
+		{name: "high loss again", loss: 0.5, wnd: 32, interval: 40},
+		{name: "window floor", loss: 0.5, wnd: 32, interval: 50},
+		{name: "moderate loss", loss: 0.05, wnd: 32, interval: 50},
+		{name: "low loss", loss: 0, wnd: 41, interval: 40},
+		{name: "shared with high loss", loss: 0.5, shared: true, wnd: 128, interval: 20},
+		{name: "low loss at the configured values", loss: 0, wnd: 128, interval: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.sample(tt.loss, tt.shared)
+			if tn.wnd != tt.wnd || tn.interval != tt.interval {
+				t.Errorf("got %d/%d, want %d/%d", tn.wnd, tn.interval, tt.wnd, tt.interval)
+			}
+		})
+	}
+}
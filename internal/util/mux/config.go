@@ -0,0 +1,139 @@
+package mux
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	"github.com/hashicorp/yamux"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	smux "github.com/xtaci/smux"
+)
+
+const (
+	ProtocolSMux  = "smux"
+	ProtocolYamux = "yamux"
+)
+
+// Config is the configuration of the mux session, the zero value uses the defaults.
+type Config struct {
+	// Protocol is the multiplexing protocol, smux (default) or yamux.
+	Protocol string
+	// Version is the version of the smux protocol, 1 (default) or 2.
+	Version int
+	// KeepAliveDisabled disables the keepalive pings.
+	KeepAliveDisabled bool
+	// KeepAliveInterval is how often to send the keepalive ping.
+	KeepAliveInterval time.Duration
+	// KeepAliveTimeout is how long the session will be closed if no data has arrived,
+	// for yamux it is the timeout of the ping.
+	KeepAliveTimeout time.Duration
+	// MaxFrameSize is the max size of the smux frame.
+	MaxFrameSize int
+	// MaxReceiveBuffer is the receive buffer size of the smux session.
+	MaxReceiveBuffer int
+	// MaxStreamBuffer is the receive buffer size of each stream,
+	// for yamux it is the stream window size, which is at least 256KB.
+	MaxStreamBuffer int
+}
+
+// ConfigFromMetadata parses the mux settings of the listener or dialer,
+// the keys are muxProtocol, muxVersion, muxKeepAliveDisabled, muxKeepAliveInterval,
+// muxKeepAliveTimeout, muxMaxFrameSize, muxMaxReceiveBuffer and muxMaxStreamBuffer.
+func ConfigFromMetadata(md mdata.Metadata) *Config {
+	const (
+		muxProtocol          = "muxProtocol"
+		muxVersion           = "muxVersion"
+		muxKeepAliveDisabled = "muxKeepAliveDisabled"
+		muxKeepAliveInterval = "muxKeepAliveInterval"
+		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
+		muxMaxFrameSize      = "muxMaxFrameSize"
+		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
+		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
+	)
+
+	return &Config{
+		Protocol:          strings.ToLower(mdx.GetString(md, muxProtocol)),
+		Version:           mdx.GetInt(md, muxVersion),
+		KeepAliveDisabled: mdx.GetBool(md, muxKeepAliveDisabled),
+		KeepAliveInterval: mdx.GetDuration(md, muxKeepAliveInterval),
+		KeepAliveTimeout:  mdx.GetDuration(md, muxKeepAliveTimeout),
+		MaxFrameSize:      mdx.GetInt(md, muxMaxFrameSize),
+		MaxReceiveBuffer:  mdx.GetInt(md, muxMaxReceiveBuffer),
+		MaxStreamBuffer:   mdx.GetInt(md, muxMaxStreamBuffer),
+	}
+}
+
+func (c *Config) protocol() string {
+	if c == nil || c.Protocol == "" {
+		return ProtocolSMux
+	}
+	return c.Protocol
+}
+
+func (c *Config) smuxConfig() *smux.Config {
+	cfg := smux.DefaultConfig()
+	if c == nil {
+		return cfg
+	}
+
+	if c.Version > 0 {
+		cfg.Version = c.Version
+	}
+	cfg.KeepAliveDisabled = c.KeepAliveDisabled
+	if c.KeepAliveInterval > 0 {
+		cfg.KeepAliveInterval = c.KeepAliveInterval
+	}
+	if c.KeepAliveTimeout > 0 {
+		cfg.KeepAliveTimeout = c.KeepAliveTimeout
+	}
+	if cfg.KeepAliveTimeout < cfg.KeepAliveInterval {
+		cfg.KeepAliveTimeout = 3 * cfg.KeepAliveInterval
+	}
+	if c.MaxFrameSize > 0 {
+		cfg.MaxFrameSize = c.MaxFrameSize
+	}
+	if c.MaxReceiveBuffer > 0 {
+		cfg.MaxReceiveBuffer = c.MaxReceiveBuffer
+	}
+	if c.MaxStreamBuffer > 0 {
+		cfg.MaxStreamBuffer = c.MaxStreamBuffer
+	}
+	return cfg
+}
+
+func (c *Config) yamuxConfig() *yamux.Config {
+	cfg := yamux.DefaultConfig()
+	cfg.LogOutput = io.Discard
+	if c == nil {
+		return cfg
+	}
+
+	cfg.EnableKeepAlive = !c.KeepAliveDisabled
+	if c.KeepAliveInterval > 0 {
+		cfg.KeepAliveInterval = c.KeepAliveInterval
+	}
+	if c.KeepAliveTimeout > 0 {
+		cfg.ConnectionWriteTimeout = c.KeepAliveTimeout
+	}
+	if c.MaxStreamBuffer > int(cfg.MaxStreamWindowSize) {
+		cfg.MaxStreamWindowSize = uint32(c.MaxStreamBuffer)
+	}
+	return cfg
+}
+
+type configKey struct{}
+
+// ContextWithConfig returns a copy of ctx carrying the mux config,
+// it is used by the connectors to set up the session of the BIND request.
+func ContextWithConfig(ctx context.Context, cfg *Config) context.Context {
+	return context.WithValue(ctx, configKey{}, cfg)
+}
+
+// ConfigFromContext returns the mux config carried by ctx, or nil if not set.
+func ConfigFromContext(ctx context.Context) *Config {
+	v, _ := ctx.Value(configKey{}).(*Config)
+	return v
+}
@@ -0,0 +1,82 @@
+package mux
+
+import (
+	"net"
+
+	"github.com/go-gost/core/listener"
+	"github.com/go-gost/core/logger"
+)
+
+// muxListener accepts the streams of the mux sessions established on the connections of the listener.
+type muxListener struct {
+	net.Listener
+	cfg     *Config
+	cqueue  chan net.Conn
+	errChan chan error
+	logger  logger.Logger
+}
+
+// NewListener returns a listener accepting the streams of the mux sessions on the connections of ln,
+// at most backlog streams are queued.
+func NewListener(ln net.Listener, cfg *Config, backlog int, logger logger.Logger) net.Listener {
+	l := &muxListener{
+		Listener: ln,
+		cfg:      cfg,
+		cqueue:   make(chan net.Conn, backlog),
+		errChan:  make(chan error, 1),
+		logger:   logger,
+	}
+	go l.listenLoop()
+
+	return l
+}
+
+func (l *muxListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *muxListener) listenLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		go l.mux(conn)
+	}
+}
+
+func (l *muxListener) mux(conn net.Conn) {
+	defer conn.Close()
+
+	session, err := ServerSession(conn, l.cfg)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+	defer session.Close()
+
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			l.logger.Debug("accept stream: ", err)
+			return
+		}
+
+		select {
+		case l.cqueue <- stream:
+		default:
+			stream.Close()
+			l.logger.Warnf("connection queue is full, client %s discarded", stream.RemoteAddr())
+		}
+	}
+}
@@ -1,31 +1,110 @@
 package mux
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net"
+	"time"
 
+	"github.com/hashicorp/yamux"
 	smux "github.com/xtaci/smux"
 )
 
-type Session struct {
-	conn    net.Conn
-	session *smux.Session
+// session is the common interface of the smux and yamux sessions.
+type session interface {
+	OpenStream() (net.Conn, error)
+	AcceptStream() (net.Conn, error)
+	Close() error
+	IsClosed() bool
+	NumStreams() int
+}
+
+type smuxSession struct {
+	*smux.Session
+}
+
+func (s smuxSession) OpenStream() (net.Conn, error) {
+	stream, err := s.Session.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s smuxSession) AcceptStream() (net.Conn, error) {
+	stream, err := s.Session.AcceptStream()
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+type yamuxSession struct {
+	*yamux.Session
+}
+
+func (s yamuxSession) OpenStream() (net.Conn, error) {
+	stream, err := s.Session.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
 }
 
-func ClientSession(conn net.Conn) (*Session, error) {
-	s, err := smux.Client(conn, smux.DefaultConfig())
+func (s yamuxSession) AcceptStream() (net.Conn, error) {
+	stream, err := s.Session.AcceptStream()
 	if err != nil {
 		return nil, err
 	}
+	return stream, nil
+}
+
+type Session struct {
+	conn    net.Conn
+	session session
+}
+
+func ClientSession(conn net.Conn, cfg *Config) (*Session, error) {
+	var s session
+	switch p := cfg.protocol(); p {
+	case ProtocolSMux:
+		ss, err := smux.Client(conn, cfg.smuxConfig())
+		if err != nil {
+			return nil, err
+		}
+		s = smuxSession{ss}
+	case ProtocolYamux:
+		ys, err := yamux.Client(conn, cfg.yamuxConfig())
+		if err != nil {
+			return nil, err
+		}
+		s = yamuxSession{ys}
+	default:
+		return nil, fmt.Errorf("mux: unknown protocol %s", p)
+	}
 	return &Session{
 		conn:    conn,
 		session: s,
 	}, nil
 }
 
-func ServerSession(conn net.Conn) (*Session, error) {
-	s, err := smux.Server(conn, smux.DefaultConfig())
-	if err != nil {
-		return nil, err
+func ServerSession(conn net.Conn, cfg *Config) (*Session, error) {
+	var s session
+	switch p := cfg.protocol(); p {
+	case ProtocolSMux:
+		ss, err := smux.Server(conn, cfg.smuxConfig())
+		if err != nil {
+			return nil, err
+		}
+		s = smuxSession{ss}
+	case ProtocolYamux:
+		ys, err := yamux.Server(conn, cfg.yamuxConfig())
+		if err != nil {
+			return nil, err
+		}
+		s = yamuxSession{ys}
+	default:
+		return nil, fmt.Errorf("mux: unknown protocol %s", p)
 	}
 	return &Session{
 		conn:    conn,
@@ -64,12 +143,17 @@ func (session *Session) IsClosed() bool {
 }
 
 func (session *Session) NumStreams() int {
+	if session.session == nil {
+		return 0
+	}
 	return session.session.NumStreams()
 }
 
+// streamConn is a stream with the addresses of the underlying connection,
+// the deadlines are set on the stream, not on the shared connection.
 type streamConn struct {
 	net.Conn
-	stream *smux.Stream
+	stream net.Conn
 }
 
 func (c *streamConn) Read(b []byte) (n int, err error) {
@@ -83,3 +167,27 @@ func (c *streamConn) Write(b []byte) (n int, err error) {
 func (c *streamConn) Close() error {
 	return c.stream.Close()
 }
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	return c.stream.SetDeadline(t)
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	return c.stream.SetReadDeadline(t)
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	return c.stream.SetWriteDeadline(t)
+}
+
+// ConnectionState returns the TLS state of the underlying connection,
+// so the client certificate of the session is available on the streams.
+// The zero value is returned if the connection is not a TLS connection.
+func (c *streamConn) ConnectionState() tls.ConnectionState {
+	if tc, ok := c.Conn.(interface {
+		ConnectionState() tls.ConnectionState
+	}); ok {
+		return tc.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
@@ -0,0 +1,105 @@
+package mux
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+)
+
+func newCertificate(t *testing.T, cn string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestStreamIdentity(t *testing.T) {
+	serverCert := newCertificate(t, "server")
+	clientCert := newCertificate(t, "client")
+
+	tests := []struct {
+		name     string
+		tls      bool
+		certs    []tls.Certificate
+		identity string
+	}{
+		{name: "tcp"},
+		{name: "tls without client certificate", tls: true},
+		{name: "tls with client certificate", tls: true, certs: []tls.Certificate{clientCert}, identity: "client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c1, c2 := net.Pipe()
+			defer c1.Close()
+			defer c2.Close()
+
+			if tt.tls {
+				c1 = tls.Client(c1, &tls.Config{
+					InsecureSkipVerify: true,
+					Certificates:       tt.certs,
+				})
+				c2 = tls.Server(c2, &tls.Config{
+					Certificates: []tls.Certificate{serverCert},
+					ClientAuth:   tls.RequestClientCert,
+				})
+			}
+
+			client, err := ClientSession(c1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			server, err := ServerSession(c2, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			cc, err := client.GetConn()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cc.Close()
+			if _, err := cc.Write([]byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+
+			sc, err := server.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sc.Close()
+
+			var name string
+			if id := tls_util.IdentityFromConn(sc); id != nil {
+				name = id.CommonName
+			}
+			if name != tt.identity {
+				t.Errorf("identity: got %q, want %q", name, tt.identity)
+			}
+		})
+	}
+}
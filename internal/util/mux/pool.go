@@ -0,0 +1,164 @@
+package mux
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+var (
+	ErrPoolFull = errors.New("mux: all the sessions are full")
+)
+
+// PoolSession is the session kept by the Pool.
+type PoolSession interface {
+	GetConn() (net.Conn, error)
+	Close() error
+	IsClosed() bool
+	NumStreams() int
+}
+
+// PoolEntry is the connection of the session,
+// the Session is nil until the handshake on the Conn is completed.
+type PoolEntry struct {
+	Conn    net.Conn
+	Session PoolSession
+}
+
+// streams must be called with the lock of the pool held.
+func (e *PoolEntry) streams() int {
+	if e.Session == nil {
+		return 0
+	}
+	return e.Session.NumStreams()
+}
+
+// Pool keeps up to MaxSessions sessions for each address.
+// If MaxStreams is set, a session is filled up to MaxStreams streams before a new session is created,
+// otherwise the streams are spread over the sessions.
+// When the pool is full the least loaded session is used.
+type Pool struct {
+	MaxSessions int
+	MaxStreams  int
+	sessions    map[string][]*PoolEntry
+	mu          sync.Mutex
+}
+
+func NewPool(maxSessions, maxStreams int) *Pool {
+	if maxSessions <= 0 {
+		maxSessions = 1
+	}
+	return &Pool{
+		MaxSessions: maxSessions,
+		MaxStreams:  maxStreams,
+		sessions:    make(map[string][]*PoolEntry),
+	}
+}
+
+// Get returns the entry to open the stream on, or nil if a new session should be created.
+// The pending entries, of which the handshake is not completed, count to MaxSessions.
+// When the pool is full and no established session can take more streams, a pending entry is returned,
+// the caller waits for its handshake in Handshake.
+// ErrPoolFull is returned if the pool is full and all the sessions are established with MaxStreams streams.
+func (p *Pool) Get(addr string) (*PoolEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var entries []*PoolEntry
+	for _, e := range p.sessions[addr] {
+		if e.Session != nil && e.Session.IsClosed() {
+			continue // session is dead
+		}
+		entries = append(entries, e)
+	}
+	p.sessions[addr] = entries
+
+	var best, pending *PoolEntry
+	for _, e := range entries {
+		if e.Session == nil {
+			if pending == nil {
+				pending = e
+			}
+			continue
+		}
+		if best == nil || e.Session.NumStreams() < best.Session.NumStreams() {
+			best = e
+		}
+	}
+
+	full := len(entries) >= p.MaxSessions
+	if best != nil && p.MaxStreams > 0 && best.Session.NumStreams() >= p.MaxStreams {
+		best = nil // all the established sessions are full
+	}
+
+	if best == nil {
+		if !full {
+			return nil, nil
+		}
+		if pending != nil {
+			return pending, nil
+		}
+		return nil, ErrPoolFull
+	}
+
+	if !full && p.MaxStreams <= 0 && best.Session.NumStreams() > 0 {
+		return nil, nil
+	}
+	return best, nil
+}
+
+// Add adds the connection of the new session.
+func (p *Pool) Add(addr string, conn net.Conn) *PoolEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := &PoolEntry{Conn: conn}
+	p.sessions[addr] = append(p.sessions[addr], e)
+	return e
+}
+
+// Find returns the entry of the connection.
+func (p *Pool) Find(addr string, conn net.Conn) *PoolEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.sessions[addr] {
+		if e.Conn == conn {
+			return e
+		}
+	}
+	return nil
+}
+
+// SetSession sets the session of the entry after the handshake.
+func (p *Pool) SetSession(e *PoolEntry, session PoolSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.Session = session
+}
+
+// Len returns the number of the sessions of the address.
+func (p *Pool) Len(addr string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.sessions[addr])
+}
+
+// Remove removes the entry of the broken session.
+func (p *Pool) Remove(addr string, e *PoolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries := p.sessions[addr]
+	for i := range entries {
+		if entries[i] == e {
+			p.sessions[addr] = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(p.sessions[addr]) == 0 {
+		delete(p.sessions, addr)
+	}
+}
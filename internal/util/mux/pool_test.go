@@ -0,0 +1,95 @@
+package mux
+
+import (
+	"net"
+	"testing"
+)
+
+// testSession is a PoolSession with the given number of streams.
+type testSession struct {
+	streams int
+	closed  bool
+}
+
+func (s *testSession) GetConn() (net.Conn, error) {
+	s.streams++
+	return nil, nil
+}
+
+func (s *testSession) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *testSession) IsClosed() bool {
+	return s.closed
+}
+
+func (s *testSession) NumStreams() int {
+	return s.streams
+}
+
+func TestPoolGet(t *testing.T) {
+	const addr = "127.0.0.1:8080"
+
+	tests := []struct {
+		name        string
+		maxSessions int
+		maxStreams  int
+		// sessions are the streams of the sessions, -1 is a pending session and -2 is a closed session.
+		sessions []int
+		// want is the index of the returned session, -1 means a new session.
+		want int
+		err  error
+	}{
+		{name: "empty", maxSessions: 2, sessions: nil, want: -1},
+		{name: "spread idle", maxSessions: 2, sessions: []int{0}, want: 0},
+		{name: "spread busy", maxSessions: 2, sessions: []int{1}, want: -1},
+		{name: "spread full", maxSessions: 2, sessions: []int{3, 1}, want: 1},
+		{name: "fill", maxSessions: 2, maxStreams: 2, sessions: []int{1}, want: 0},
+		{name: "fill new", maxSessions: 2, maxStreams: 2, sessions: []int{2}, want: -1},
+		{name: "fill least loaded", maxSessions: 2, maxStreams: 2, sessions: []int{2, 1}, want: 1},
+		{name: "fill full", maxSessions: 2, maxStreams: 2, sessions: []int{2, 2}, err: ErrPoolFull},
+		{name: "pending skipped", maxSessions: 2, sessions: []int{-1}, want: -1},
+		{name: "pending skipped for busy", maxSessions: 2, sessions: []int{-1, 1}, want: 1},
+		{name: "pending full", maxSessions: 1, sessions: []int{-1}, want: 0},
+		{name: "pending for full", maxSessions: 2, maxStreams: 2, sessions: []int{2, -1}, want: 1},
+		{name: "established before pending", maxSessions: 2, maxStreams: 2, sessions: []int{-1, 1}, want: 1},
+		{name: "closed removed", maxSessions: 1, sessions: []int{-2}, want: -1},
+		{name: "closed removed for full", maxSessions: 2, maxStreams: 2, sessions: []int{-2, 2}, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPool(tt.maxSessions, tt.maxStreams)
+
+			var entries []*PoolEntry
+			for _, n := range tt.sessions {
+				e := p.Add(addr, nil)
+				switch {
+				case n == -2:
+					p.SetSession(e, &testSession{closed: true})
+				case n >= 0:
+					p.SetSession(e, &testSession{streams: n})
+				}
+				entries = append(entries, e)
+			}
+
+			e, err := p.Get(addr)
+			if err != tt.err {
+				t.Fatalf("err: got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			var want *PoolEntry
+			if tt.want >= 0 {
+				want = entries[tt.want]
+			}
+			if e != want {
+				t.Errorf("got %p, want %p", e, want)
+			}
+		})
+	}
+}
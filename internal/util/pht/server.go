@@ -16,8 +16,8 @@ import (
 
 	"github.com/go-gost/core/common/bufpool"
 	"github.com/go-gost/core/logger"
-	"github.com/lucas-clemente/quic-go"
-	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/xid"
 )
 
@@ -120,12 +120,9 @@ func NewHTTP3Server(addr string, quicConfig *quic.Config, opts ...ServerOption)
 
 	s := &Server{
 		http3Server: &http3.Server{
-			Server: &http.Server{
-				Addr:              addr,
-				TLSConfig:         options.tlsConfig,
-				ReadHeaderTimeout: 30 * time.Second,
-			},
-			QuicConfig: quicConfig,
+			Addr:       addr,
+			TLSConfig:  options.tlsConfig,
+			QUICConfig: quicConfig,
 		},
 		cqueue:  make(chan net.Conn, options.backlog),
 		closed:  make(chan struct{}),
@@ -0,0 +1,58 @@
+package quic
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	// the keepalive period if keepAlive is enabled without a period.
+	defaultKeepAlivePeriod = 15 * time.Second
+)
+
+// ConfigFromMetadata parses the QUIC config from the listener or dialer metadata,
+// the keepAlive, keepAlivePeriod, handshakeTimeout, maxIdleTimeout, maxStreams, the flow control windows,
+// initialPacketSize, disablePathMTUDiscovery and datagram keys are used.
+// The congestion controller of quic-go is built in and can not be configured.
+func ConfigFromMetadata(md mdata.Metadata) *quic.Config {
+	const (
+		keepAlive               = "keepAlive"
+		keepAlivePeriod         = "keepAlivePeriod"
+		handshakeTimeout        = "handshakeTimeout"
+		maxIdleTimeout          = "maxIdleTimeout"
+		maxStreams              = "maxStreams"
+		initialStreamWindow     = "initialStreamWindow"
+		maxStreamWindow         = "maxStreamWindow"
+		initialConnWindow       = "initialConnWindow"
+		maxConnWindow           = "maxConnWindow"
+		initialPacketSize       = "initialPacketSize"
+		disablePathMTUDiscovery = "disablePathMTUDiscovery"
+		datagram                = "datagram"
+	)
+
+	period := mdx.GetDuration(md, keepAlivePeriod)
+	if period <= 0 && mdx.GetBool(md, keepAlive) {
+		period = defaultKeepAlivePeriod
+	}
+
+	return &quic.Config{
+		KeepAlivePeriod:                period,
+		HandshakeIdleTimeout:           mdx.GetDuration(md, handshakeTimeout),
+		MaxIdleTimeout:                 mdx.GetDuration(md, maxIdleTimeout),
+		MaxIncomingStreams:             int64(mdx.GetInt(md, maxStreams)),
+		InitialStreamReceiveWindow:     uint64(mdx.GetInt(md, initialStreamWindow)),
+		MaxStreamReceiveWindow:         uint64(mdx.GetInt(md, maxStreamWindow)),
+		InitialConnectionReceiveWindow: uint64(mdx.GetInt(md, initialConnWindow)),
+		MaxConnectionReceiveWindow:     uint64(mdx.GetInt(md, maxConnWindow)),
+		InitialPacketSize:              uint16(mdx.GetInt(md, initialPacketSize)),
+		DisablePathMTUDiscovery:        mdx.GetBool(md, disablePathMTUDiscovery),
+		EnableDatagrams:                mdx.GetBool(md, datagram),
+		Versions: []quic.Version{
+			quic.Version1,
+			quic.Version2,
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package quic
+
+import (
+	"testing"
+	"time"
+
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+func TestConfigFromMetadata(t *testing.T) {
+	tests := []struct {
+		name              string
+		md                map[string]any
+		keepAlivePeriod   time.Duration
+		initialPacketSize uint16
+		datagrams         bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:            "keepAlive",
+			md:              map[string]any{"keepAlive": true},
+			keepAlivePeriod: defaultKeepAlivePeriod,
+		},
+		{
+			name:            "keepAlivePeriod",
+			md:              map[string]any{"keepAlivePeriod": "5s"},
+			keepAlivePeriod: 5 * time.Second,
+		},
+		{
+			name:            "keepAlive with period",
+			md:              map[string]any{"keepAlive": true, "keepAlivePeriod": "30s"},
+			keepAlivePeriod: 30 * time.Second,
+		},
+		{
+			name:              "initialPacketSize",
+			md:                map[string]any{"initialPacketSize": 1350},
+			initialPacketSize: 1350,
+		},
+		{
+			name:      "datagram",
+			md:        map[string]any{"datagram": true},
+			datagrams: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ConfigFromMetadata(mdx.NewMetadata(tt.md))
+			if cfg.KeepAlivePeriod != tt.keepAlivePeriod {
+				t.Errorf("keepAlivePeriod: got %v, want %v", cfg.KeepAlivePeriod, tt.keepAlivePeriod)
+			}
+			if cfg.InitialPacketSize != tt.initialPacketSize {
+				t.Errorf("initialPacketSize: got %d, want %d", cfg.InitialPacketSize, tt.initialPacketSize)
+			}
+			if cfg.EnableDatagrams != tt.datagrams {
+				t.Errorf("datagrams: got %v, want %v", cfg.EnableDatagrams, tt.datagrams)
+			}
+			if len(cfg.Versions) == 0 {
+				t.Error("no versions")
+			}
+		})
+	}
+}
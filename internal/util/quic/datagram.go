@@ -0,0 +1,239 @@
+package quic
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-gost/gosocks5"
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	datagramBacklog = 128
+	// the max number of the datagrams received before the conns of their streams are created.
+	pendingBacklog = 64
+)
+
+// DatagramSession demultiplexes the QUIC DATAGRAM frames of a session to the streams,
+// each datagram is prefixed with the ID of the stream.
+type DatagramSession struct {
+	session *quic.Conn
+	laddr   net.Addr
+	conns   map[quic.StreamID]*datagramConn
+	// the datagrams arrived ahead of the conns of their streams.
+	pending []pendingDatagram
+	once    sync.Once
+	mu      sync.Mutex
+}
+
+type pendingDatagram struct {
+	id quic.StreamID
+	b  []byte
+}
+
+func NewDatagramSession(session *quic.Conn) *DatagramSession {
+	return &DatagramSession{
+		session: session,
+		// quic.Conn.LocalAddr is not safe to call while switching the path.
+		laddr: session.LocalAddr(),
+		conns: make(map[quic.StreamID]*datagramConn),
+	}
+}
+
+// Conn returns the packet conn of the stream, the conn must be closed after use.
+// It returns nil if the DATAGRAM frames are not supported by the session.
+func (s *DatagramSession) Conn(stream *quic.Stream) net.PacketConn {
+	if s == nil || !s.session.ConnectionState().SupportsDatagrams {
+		return nil
+	}
+	s.once.Do(func() {
+		go s.receive()
+	})
+
+	c := &datagramConn{
+		s:      s,
+		id:     stream.StreamID(),
+		laddr:  s.laddr,
+		rc:     make(chan []byte, datagramBacklog),
+		closed: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if old := s.conns[c.id]; old != nil {
+		old.closeOnce()
+	}
+	s.conns[c.id] = c
+	pending := s.pending[:0]
+	for _, p := range s.pending {
+		if p.id == c.id {
+			c.push(p.b)
+		} else {
+			pending = append(pending, p)
+		}
+	}
+	s.pending = pending
+	s.mu.Unlock()
+
+	return c
+}
+
+func (s *DatagramSession) receive() {
+	for {
+		b, err := s.session.ReceiveDatagram(context.Background())
+		if err != nil {
+			s.mu.Lock()
+			for _, c := range s.conns {
+				c.closeOnce()
+			}
+			s.mu.Unlock()
+			return
+		}
+
+		id, n := binary.Uvarint(b)
+		if n <= 0 {
+			continue
+		}
+
+		s.mu.Lock()
+		c := s.conns[quic.StreamID(id)]
+		if c == nil {
+			if len(s.pending) >= pendingBacklog {
+				s.pending = s.pending[1:]
+			}
+			s.pending = append(s.pending, pendingDatagram{id: quic.StreamID(id), b: b[n:]})
+		}
+		s.mu.Unlock()
+		if c != nil {
+			c.push(b[n:])
+		}
+	}
+}
+
+func (s *DatagramSession) del(c *datagramConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conns[c.id] == c {
+		delete(s.conns, c.id)
+	}
+}
+
+// datagramConn sends the UDP datagrams of a stream in the DATAGRAM frames,
+// the target address is encoded as the socks5 address.
+type datagramConn struct {
+	s        *DatagramSession
+	id       quic.StreamID
+	laddr    net.Addr
+	rc       chan []byte
+	closed   chan struct{}
+	once     sync.Once
+	deadline time.Time
+	mu       sync.Mutex
+}
+
+func (c *datagramConn) push(b []byte) {
+	select {
+	case c.rc <- b:
+	case <-c.closed:
+	default:
+	}
+}
+
+func (c *datagramConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		var p []byte
+		select {
+		case p = <-c.rc:
+		case <-c.closed:
+			return 0, nil, io.EOF
+		case <-timeout:
+			return 0, nil, os.ErrDeadlineExceeded
+		}
+
+		socksAddr := gosocks5.Addr{}
+		r := bytes.NewReader(p)
+		if _, err := socksAddr.ReadFrom(r); err != nil {
+			continue
+		}
+		if addr, err = net.ResolveUDPAddr("udp", socksAddr.String()); err != nil {
+			continue
+		}
+		n = copy(b, p[len(p)-r.Len():])
+		return
+	}
+}
+
+func (c *datagramConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	socksAddr := gosocks5.Addr{}
+	if err = socksAddr.ParseFrom(addr.String()); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	var vb [binary.MaxVarintLen64]byte
+	buf.Write(vb[:binary.PutUvarint(vb[:], uint64(c.id))])
+	if _, err = socksAddr.WriteTo(&buf); err != nil {
+		return
+	}
+	buf.Write(b)
+
+	if err = c.s.session.SendDatagram(buf.Bytes()); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+func (c *datagramConn) closeOnce() {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+}
+
+func (c *datagramConn) Close() error {
+	c.closeOnce()
+	c.s.del(c)
+	return nil
+}
+
+func (c *datagramConn) LocalAddr() net.Addr {
+	return c.laddr
+}
+
+func (c *datagramConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *datagramConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *datagramConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,239 @@
+package quic
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/metrics"
+	xmetrics "github.com/hxdcloud/gost-x/metrics"
+	"github.com/quic-go/quic-go/logging"
+)
+
+const (
+	// the interval of observing the RTT and congestion window of a connection.
+	observeInterval = time.Second
+)
+
+var (
+	stats   = make(map[string]*Stats)
+	statsMu sync.RWMutex
+)
+
+// ConnStats is the snapshot of the statistics of a QUIC connection.
+type ConnStats struct {
+	Local  string    `json:"local"`
+	Remote string    `json:"remote"`
+	Since  time.Time `json:"since"`
+	// smoothed RTT, in nanoseconds.
+	RTT time.Duration `json:"rtt"`
+	// minimum RTT, in nanoseconds.
+	MinRTT time.Duration `json:"minRTT"`
+	// congestion window, in bytes.
+	CongestionWindow int64 `json:"cwnd"`
+	BytesInFlight    int64 `json:"bytesInFlight"`
+	BytesSent        int64 `json:"bytesSent"`
+	BytesReceived    int64 `json:"bytesReceived"`
+	PacketsSent      int64 `json:"packetsSent"`
+	PacketsReceived  int64 `json:"packetsReceived"`
+	PacketsLost      int64 `json:"packetsLost"`
+}
+
+// Stats collects the statistics of the QUIC connections of a service by tracing the connections.
+type Stats struct {
+	service string
+	conns   map[*connTracer]struct{}
+	mu      sync.Mutex
+}
+
+// NewStats creates and registers the statistics of the service.
+func NewStats(service string) *Stats {
+	s := &Stats{
+		service: service,
+		conns:   make(map[*connTracer]struct{}),
+	}
+
+	if service != "" {
+		statsMu.Lock()
+		stats[service] = s
+		statsMu.Unlock()
+	}
+
+	return s
+}
+
+// GetStats returns the statistics of the service.
+func GetStats(service string) *Stats {
+	statsMu.RLock()
+	defer statsMu.RUnlock()
+
+	return stats[service]
+}
+
+// Tracer traces the connections, it is used as the Tracer of the quic.Config.
+func (s *Stats) Tracer(ctx context.Context, p logging.Perspective, odcid logging.ConnectionID) *logging.ConnectionTracer {
+	c := &connTracer{
+		stats: s,
+		info: ConnStats{
+			Since: time.Now(),
+		},
+	}
+	return &logging.ConnectionTracer{
+		StartedConnection: c.startedConnection,
+		SentLongHeaderPacket: func(hdr *logging.ExtendedHeader, size logging.ByteCount, ecn logging.ECN, ack *logging.AckFrame, frames []logging.Frame) {
+			c.sentPacket(size)
+		},
+		SentShortHeaderPacket: func(hdr *logging.ShortHeader, size logging.ByteCount, ecn logging.ECN, ack *logging.AckFrame, frames []logging.Frame) {
+			c.sentPacket(size)
+		},
+		ReceivedLongHeaderPacket: func(hdr *logging.ExtendedHeader, size logging.ByteCount, ecn logging.ECN, frames []logging.Frame) {
+			c.receivedPacket(size)
+		},
+		ReceivedShortHeaderPacket: func(hdr *logging.ShortHeader, size logging.ByteCount, ecn logging.ECN, frames []logging.Frame) {
+			c.receivedPacket(size)
+		},
+		UpdatedMetrics: c.updatedMetrics,
+		LostPacket: func(logging.EncryptionLevel, logging.PacketNumber, logging.PacketLossReason) {
+			c.lostPacket()
+		},
+		Close: c.close,
+	}
+}
+
+// Conns returns the statistics of the active connections ordered by the remote address.
+func (s *Stats) Conns() []ConnStats {
+	s.mu.Lock()
+	conns := make([]ConnStats, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c.snapshot())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].Remote < conns[j].Remote
+	})
+	return conns
+}
+
+// Close unregisters the statistics.
+func (s *Stats) Close() error {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	if stats[s.service] == s {
+		delete(stats, s.service)
+	}
+	return nil
+}
+
+func (s *Stats) add(c *connTracer) {
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	if v := metrics.GetGauge(xmetrics.MetricQUICConnectionsGauge,
+		metrics.Labels{"service": s.service}); v != nil {
+		v.Inc()
+	}
+}
+
+func (s *Stats) remove(c *connTracer) {
+	s.mu.Lock()
+	_, ok := s.conns[c]
+	delete(s.conns, c)
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	if v := metrics.GetGauge(xmetrics.MetricQUICConnectionsGauge,
+		metrics.Labels{"service": s.service}); v != nil {
+		v.Dec()
+	}
+}
+
+// connTracer traces a QUIC connection, the RTT and congestion window are observed at most once per observeInterval.
+type connTracer struct {
+	stats    *Stats
+	info     ConnStats
+	observed time.Time
+	mu       sync.Mutex
+}
+
+func (c *connTracer) snapshot() ConnStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.info
+}
+
+func (c *connTracer) startedConnection(local, remote net.Addr, srcConnID, destConnID logging.ConnectionID) {
+	c.mu.Lock()
+	c.info.Local = local.String()
+	c.info.Remote = remote.String()
+	c.mu.Unlock()
+
+	c.stats.add(c)
+}
+
+func (c *connTracer) sentPacket(size logging.ByteCount) {
+	c.mu.Lock()
+	c.info.PacketsSent++
+	c.info.BytesSent += int64(size)
+	c.mu.Unlock()
+
+	if v := metrics.GetCounter(xmetrics.MetricQUICPacketsSentCounter,
+		metrics.Labels{"service": c.stats.service}); v != nil {
+		v.Inc()
+	}
+}
+
+func (c *connTracer) receivedPacket(size logging.ByteCount) {
+	c.mu.Lock()
+	c.info.PacketsReceived++
+	c.info.BytesReceived += int64(size)
+	c.mu.Unlock()
+}
+
+func (c *connTracer) updatedMetrics(rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount, packetsInFlight int) {
+	now := time.Now()
+
+	c.mu.Lock()
+	c.info.RTT = rttStats.SmoothedRTT()
+	c.info.MinRTT = rttStats.MinRTT()
+	c.info.CongestionWindow = int64(cwnd)
+	c.info.BytesInFlight = int64(bytesInFlight)
+	observe := now.Sub(c.observed) >= observeInterval
+	if observe {
+		c.observed = now
+	}
+	c.mu.Unlock()
+
+	if !observe {
+		return
+	}
+	labels := metrics.Labels{"service": c.stats.service}
+	if v := metrics.GetObserver(xmetrics.MetricQUICRTTObserver, labels); v != nil {
+		v.Observe(rttStats.SmoothedRTT().Seconds())
+	}
+	if v := metrics.GetObserver(xmetrics.MetricQUICCongestionWindowObserver, labels); v != nil {
+		v.Observe(float64(cwnd))
+	}
+}
+
+func (c *connTracer) lostPacket() {
+	c.mu.Lock()
+	c.info.PacketsLost++
+	c.mu.Unlock()
+
+	if v := metrics.GetCounter(xmetrics.MetricQUICPacketsLostCounter,
+		metrics.Labels{"service": c.stats.service}); v != nil {
+		v.Inc()
+	}
+}
+
+func (c *connTracer) close() {
+	c.stats.remove(c)
+}
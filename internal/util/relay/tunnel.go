@@ -0,0 +1,10 @@
+package relay
+
+const (
+	// FTunnel is a flag of the BIND request indicating that the client registers a tunnel,
+	// the tunnel ID is carried by the address feature as the domain name.
+	FTunnel uint8 = 0x40
+	// FMux is a flag of the CONNECT request indicating that the connection is upgraded to a mux session,
+	// each stream of the session carries a relay request.
+	FMux uint8 = 0x20
+)
@@ -20,6 +20,15 @@ func NewCacheKey(q *dns.Question) CacheKey {
 	return CacheKey(key)
 }
 
+// WithSubnet returns the cache key for the answer scoped to the EDNS0 client subnet,
+// so that answers for different subnets are not mixed.
+func (k CacheKey) WithSubnet(e *dns.EDNS0_SUBNET) CacheKey {
+	if k == "" || e == nil {
+		return k
+	}
+	return CacheKey(fmt.Sprintf("%s/%s/%d", k, e.Address, e.SourceNetmask))
+}
+
 type cacheItem struct {
 	msg *dns.Msg
 	ts  time.Time
@@ -0,0 +1,71 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	"github.com/miekg/dns"
+)
+
+func TestCacheKeyWithSubnet(t *testing.T) {
+	q := &dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	key := NewCacheKey(q)
+
+	subnet := func(ip string, prefix int) *dns.EDNS0_SUBNET {
+		m := &dns.Msg{}
+		SetSubnetOpt(m, net.ParseIP(ip), prefix)
+		return GetSubnetOpt(m)
+	}
+
+	tests := []struct {
+		name string
+		a, b CacheKey
+		same bool
+	}{
+		{name: "no subnet", a: key, b: key.WithSubnet(nil), same: true},
+		{name: "empty key", a: "", b: CacheKey("").WithSubnet(subnet("1.2.3.4", 24)), same: true},
+		{name: "same subnet", a: key.WithSubnet(subnet("1.2.3.4", 24)), b: key.WithSubnet(subnet("1.2.3.5", 24)), same: true},
+		{name: "subnet and none", a: key, b: key.WithSubnet(subnet("1.2.3.4", 24))},
+		{name: "other subnet", a: key.WithSubnet(subnet("1.2.3.4", 24)), b: key.WithSubnet(subnet("1.2.4.4", 24))},
+		{name: "other prefix", a: key.WithSubnet(subnet("1.2.3.4", 24)), b: key.WithSubnet(subnet("1.2.3.4", 16))},
+		{name: "ipv6", a: key.WithSubnet(subnet("2001:db8::1", 56)), b: key.WithSubnet(subnet("2001:db8:0:100::1", 56))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.a == tt.b; same != tt.same {
+				t.Errorf("%q, %q: got same %v, want %v", tt.a, tt.b, same, tt.same)
+			}
+		})
+	}
+}
+
+func TestCacheSubnet(t *testing.T) {
+	c := NewCache().WithLogger(xlogger.Nop())
+	q := &dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+
+	keys := map[string]CacheKey{}
+	for _, ip := range []string{"1.2.3.4", "5.6.7.8"} {
+		m := &dns.Msg{}
+		m.SetQuestion(q.Name, q.Qtype)
+		AddSubnetOpt(m, net.ParseIP(ip))
+		keys[ip] = NewCacheKey(q).WithSubnet(GetSubnetOpt(m))
+
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP(ip),
+		})
+		c.Store(keys[ip], m, 0)
+	}
+
+	for ip, key := range keys {
+		m := c.Load(key)
+		if m == nil || m.Answer[0].(*dns.A).A.String() != ip {
+			t.Errorf("%s: got %v", ip, m)
+		}
+	}
+	if m := c.Load(NewCacheKey(q)); m != nil {
+		t.Errorf("got %v without subnet", m)
+	}
+}
@@ -6,25 +6,75 @@ import (
 	"github.com/miekg/dns"
 )
 
+const (
+	DefaultSubnetPrefix4 = 24
+	DefaultSubnetPrefix6 = 128
+)
+
+// AddSubnetOpt sets the EDNS0 client subnet option of the message to the ip,
+// with the default source prefix length.
 func AddSubnetOpt(m *dns.Msg, ip net.IP) {
+	prefix := DefaultSubnetPrefix4
+	if ip.To4() == nil {
+		prefix = DefaultSubnetPrefix6
+	}
+	SetSubnetOpt(m, ip, prefix)
+}
+
+// SetSubnetOpt sets the EDNS0 client subnet option of the message to the ip truncated to the prefix length,
+// the existing client subnet option is replaced.
+func SetSubnetOpt(m *dns.Msg, ip net.IP, prefix int) {
 	if m == nil || ip == nil {
 		return
 	}
 
-	opt := new(dns.OPT)
-	opt.Hdr.Name = "."
-	opt.Hdr.Rrtype = dns.TypeOPT
 	e := new(dns.EDNS0_SUBNET)
 	e.Code = dns.EDNS0SUBNET
-	if ip := ip.To4(); ip != nil {
+	if ip4 := ip.To4(); ip4 != nil {
+		if prefix <= 0 || prefix > net.IPv4len*8 {
+			prefix = DefaultSubnetPrefix4
+		}
 		e.Family = 1
-		e.SourceNetmask = 24
-		e.Address = ip
+		e.SourceNetmask = uint8(prefix)
+		e.Address = ip4.Mask(net.CIDRMask(prefix, net.IPv4len*8))
 	} else {
+		if prefix <= 0 || prefix > net.IPv6len*8 {
+			prefix = DefaultSubnetPrefix6
+		}
 		e.Family = 2
-		e.SourceNetmask = 128
-		e.Address = ip.To16()
+		e.SourceNetmask = uint8(prefix)
+		e.Address = ip.To16().Mask(net.CIDRMask(prefix, net.IPv6len*8))
+	}
+
+	opt := m.IsEdns0()
+	if opt == nil {
+		opt = new(dns.OPT)
+		opt.Hdr.Name = "."
+		opt.Hdr.Rrtype = dns.TypeOPT
+		m.Extra = append(m.Extra, opt)
+	}
+	options := opt.Option[:0]
+	for _, o := range opt.Option {
+		if o.Option() != dns.EDNS0SUBNET {
+			options = append(options, o)
+		}
+	}
+	opt.Option = append(options, e)
+}
+
+// GetSubnetOpt returns the EDNS0 client subnet option of the message, or nil if not exists.
+func GetSubnetOpt(m *dns.Msg) *dns.EDNS0_SUBNET {
+	if m == nil {
+		return nil
+	}
+	opt := m.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if e, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return e
+		}
 	}
-	opt.Option = append(opt.Option, e)
-	m.Extra = append(m.Extra, opt)
+	return nil
 }
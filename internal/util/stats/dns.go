@@ -0,0 +1,207 @@
+package stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DNSBucketPeriod is the time span of each bucket of the sliding window.
+	DNSBucketPeriod = time.Minute
+	// DNSMaxWindow is the max time span of the sliding window.
+	DNSMaxWindow = time.Hour
+)
+
+// DNSQuery is the record of a DNS query handled by the dns handler.
+type DNSQuery struct {
+	Service  string    `json:"service"`
+	Client   string    `json:"client"`
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Upstream string    `json:"upstream,omitempty"`
+	Rcode    string    `json:"rcode"`
+	Answers  []string  `json:"answers,omitempty"`
+	Duration float64   `json:"duration"` // in seconds
+	Cached   bool      `json:"cached"`
+	Blocked  bool      `json:"blocked,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// DNSTopItem is the number of queries of a name or client.
+type DNSTopItem struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// DNSStats is the summary of the DNS queries over a time window.
+type DNSStats struct {
+	Window       float64      `json:"window"` // in seconds
+	Queries      int          `json:"queries"`
+	CacheHits    int          `json:"cacheHits"`
+	TopNames     []DNSTopItem `json:"topNames"`
+	TopClients   []DNSTopItem `json:"topClients"`
+	TopBlocked   []DNSTopItem `json:"topBlocked"`
+	TopUpstreams []DNSTopItem `json:"topUpstreams"`
+}
+
+type dnsBucket struct {
+	start     time.Time
+	queries   int
+	cacheHits int
+	names     map[string]int
+	clients   map[string]int
+	blocked   map[string]int
+	upstreams map[string]int
+}
+
+func newDNSBucket(start time.Time) *dnsBucket {
+	return &dnsBucket{
+		start:     start,
+		names:     make(map[string]int),
+		clients:   make(map[string]int),
+		blocked:   make(map[string]int),
+		upstreams: make(map[string]int),
+	}
+}
+
+// dnsCollector keeps the counters of the DNS queries in a sliding window of per-minute buckets.
+type dnsCollector struct {
+	buckets []*dnsBucket
+	mu      sync.Mutex
+}
+
+var (
+	defaultDNSCollector = &dnsCollector{}
+)
+
+// AddDNSQuery adds the query to the DNS statistics.
+func AddDNSQuery(q *DNSQuery) {
+	defaultDNSCollector.Add(q)
+}
+
+// GetDNSStats returns the DNS statistics over the window, with the top n items for each list.
+func GetDNSStats(window time.Duration, n int) *DNSStats {
+	return defaultDNSCollector.Stats(window, n)
+}
+
+func (c *dnsCollector) Add(q *DNSQuery) {
+	if q == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.bucket(q.Time)
+
+	b.queries++
+	if q.Cached {
+		b.cacheHits++
+	}
+	b.names[q.Name]++
+	if q.Client != "" {
+		b.clients[q.Client]++
+	}
+	if q.Blocked {
+		b.blocked[q.Name]++
+	}
+	if q.Upstream != "" {
+		b.upstreams[q.Upstream]++
+	}
+}
+
+// bucket returns the bucket of the time t, the bucket is created if not exists.
+// The buckets are kept in time order, as the queries may be added out of order.
+func (c *dnsCollector) bucket(t time.Time) *dnsBucket {
+	start := t.Truncate(DNSBucketPeriod)
+
+	i := len(c.buckets)
+	for i > 0 && c.buckets[i-1].start.After(start) {
+		i--
+	}
+	if i > 0 && c.buckets[i-1].start.Equal(start) {
+		return c.buckets[i-1]
+	}
+
+	b := newDNSBucket(start)
+	c.buckets = append(c.buckets, nil)
+	copy(c.buckets[i+1:], c.buckets[i:])
+	c.buckets[i] = b
+	if i == len(c.buckets)-1 {
+		c.expire(t)
+	}
+	return b
+}
+
+func (c *dnsCollector) expire(now time.Time) {
+	i := 0
+	for ; i < len(c.buckets); i++ {
+		if now.Sub(c.buckets[i].start) <= DNSMaxWindow {
+			break
+		}
+	}
+	if i > 0 {
+		c.buckets = append(c.buckets[:0], c.buckets[i:]...)
+	}
+}
+
+func (c *dnsCollector) Stats(window time.Duration, n int) *DNSStats {
+	if window <= 0 || window > DNSMaxWindow {
+		window = DNSMaxWindow
+	}
+
+	names := make(map[string]int)
+	clients := make(map[string]int)
+	blocked := make(map[string]int)
+	upstreams := make(map[string]int)
+	stats := &DNSStats{
+		Window: window.Seconds(),
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	for _, b := range c.buckets {
+		if now.Sub(b.start) > window {
+			continue
+		}
+		stats.Queries += b.queries
+		stats.CacheHits += b.cacheHits
+		merge(names, b.names)
+		merge(clients, b.clients)
+		merge(blocked, b.blocked)
+		merge(upstreams, b.upstreams)
+	}
+	c.mu.Unlock()
+
+	stats.TopNames = top(names, n)
+	stats.TopClients = top(clients, n)
+	stats.TopBlocked = top(blocked, n)
+	stats.TopUpstreams = top(upstreams, n)
+
+	return stats
+}
+
+func merge(dst, src map[string]int) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+func top(m map[string]int, n int) []DNSTopItem {
+	items := make([]DNSTopItem, 0, len(m))
+	for k, v := range m {
+		items = append(items, DNSTopItem{Key: k, Count: v})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count == items[j].Count {
+			return items[i].Key < items[j].Key
+		}
+		return items[i].Count > items[j].Count
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
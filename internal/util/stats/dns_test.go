@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDNSStats(t *testing.T) {
+	now := time.Now()
+	c := &dnsCollector{}
+	c.Add(&DNSQuery{Name: "a.example.com.", Client: "10.0.0.1", Time: now})
+	c.Add(&DNSQuery{Name: "a.example.com.", Client: "10.0.0.2", Cached: true, Time: now})
+	c.Add(&DNSQuery{Name: "b.example.com.", Client: "10.0.0.1", Blocked: true, Time: now.Add(-30 * time.Minute)})
+
+	tests := []struct {
+		name    string
+		window  time.Duration
+		json    string
+		queries int
+		names   int
+	}{
+		{name: "default", window: 0, json: `"window":3600`, queries: 3, names: 2},
+		{name: "over max", window: 2 * time.Hour, json: `"window":3600`, queries: 3, names: 2},
+		{name: "recent", window: 10 * time.Minute, json: `"window":600`, queries: 2, names: 1},
+		{name: "fraction", window: 90500 * time.Millisecond, json: `"window":90.5`, queries: 2, names: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := c.Stats(tt.window, 0)
+			if stats.Queries != tt.queries || len(stats.TopNames) != tt.names {
+				t.Errorf("got %d queries of %d names, want %d of %d", stats.Queries, len(stats.TopNames), tt.queries, tt.names)
+			}
+
+			b, err := json.Marshal(stats)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(b), tt.json) {
+				t.Errorf("got %s, want %s", b, tt.json)
+			}
+		})
+	}
+}
+
+func TestDNSCollectorBuckets(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// times are the offsets of the query times to the base.
+		times []time.Duration
+		// starts are the offsets of the bucket starts, and counts are the number of queries of the buckets.
+		starts []time.Duration
+		counts []int
+	}{
+		{
+			name:   "in order",
+			times:  []time.Duration{0, 10 * time.Second, time.Minute, 90 * time.Second},
+			starts: []time.Duration{0, time.Minute},
+			counts: []int{2, 2},
+		},
+		{
+			name:   "late query",
+			times:  []time.Duration{59 * time.Second, 61 * time.Second, 58 * time.Second, 62 * time.Second},
+			starts: []time.Duration{0, time.Minute},
+			counts: []int{2, 2},
+		},
+		{
+			name:   "late query of a new bucket",
+			times:  []time.Duration{0, 2 * time.Minute, time.Minute, 2 * time.Minute, 30 * time.Second},
+			starts: []time.Duration{0, time.Minute, 2 * time.Minute},
+			counts: []int{2, 1, 2},
+		},
+		{
+			name:   "expired",
+			times:  []time.Duration{0, 2 * time.Minute, 61*time.Minute + 30*time.Second},
+			starts: []time.Duration{2 * time.Minute, 61 * time.Minute},
+			counts: []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &dnsCollector{}
+			for _, d := range tt.times {
+				c.Add(&DNSQuery{Name: "example.com.", Time: base.Add(d)})
+			}
+
+			var starts []time.Duration
+			var counts []int
+			for _, b := range c.buckets {
+				starts = append(starts, b.start.Sub(base))
+				counts = append(counts, b.queries)
+			}
+			if !reflect.DeepEqual(starts, tt.starts) || !reflect.DeepEqual(counts, tt.counts) {
+				t.Errorf("got %v %v, want %v %v", starts, counts, tt.starts, tt.counts)
+			}
+		})
+	}
+}
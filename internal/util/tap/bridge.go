@@ -0,0 +1,292 @@
+package tap
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	etherTypeVLAN = 0x8100
+	macHeaderLen  = 14
+	vlanTagLen    = 4
+)
+
+var (
+	tables   = make(map[string]*MACTable)
+	tablesMu sync.RWMutex
+)
+
+// VLAN returns the VLAN ID of the 802.1Q tagged frame, or zero if the frame is untagged.
+func VLAN(frame []byte) uint16 {
+	if len(frame) < macHeaderLen+vlanTagLen ||
+		binary.BigEndian.Uint16(frame[12:]) != etherTypeVLAN {
+		return 0
+	}
+	return binary.BigEndian.Uint16(frame[14:]) & 0x0fff
+}
+
+// TagVLAN inserts the 802.1Q tag of the VLAN into the untagged frame, the tagged frame is returned unchanged.
+func TagVLAN(frame []byte, vlan uint16) []byte {
+	if len(frame) < macHeaderLen || VLAN(frame) != 0 {
+		return frame
+	}
+	b := make([]byte, len(frame)+vlanTagLen)
+	copy(b, frame[:12])
+	binary.BigEndian.PutUint16(b[12:], etherTypeVLAN)
+	binary.BigEndian.PutUint16(b[14:], vlan&0x0fff)
+	copy(b[16:], frame[12:])
+	return b
+}
+
+// UntagVLAN removes the 802.1Q tag from the frame in place.
+func UntagVLAN(frame []byte) []byte {
+	if len(frame) < macHeaderLen+vlanTagLen ||
+		binary.BigEndian.Uint16(frame[12:]) != etherTypeVLAN {
+		return frame
+	}
+	copy(frame[12:], frame[16:])
+	return frame[:len(frame)-vlanTagLen]
+}
+
+// IsMulticast reports whether the MAC address is a broadcast or multicast address.
+func IsMulticast(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x01 == 1
+}
+
+// MACEntry is the snapshot of a learned MAC address.
+type MACEntry struct {
+	MAC  string `json:"mac"`
+	VLAN uint16 `json:"vlan,omitempty"`
+	// Peer is the address of the peer, it is empty for the MAC addresses of the local tap device.
+	Peer    string    `json:"peer,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+// PeerMACs is the MAC addresses learned from a peer.
+type PeerMACs struct {
+	Peer string     `json:"peer"`
+	MACs []MACEntry `json:"macs"`
+}
+
+type macKey struct {
+	mac  [6]byte
+	vlan uint16
+}
+
+type macEntry struct {
+	peer net.Addr
+	seen time.Time
+}
+
+// MACTable is the MAC address table of the learning bridge,
+// the MAC addresses are learned per VLAN and removed if they are not seen for the aging time.
+type MACTable struct {
+	service string
+	aging   time.Duration
+	entries map[macKey]*macEntry
+	pruned  time.Time
+	mu      sync.RWMutex
+}
+
+// NewMACTable creates and registers the MAC table of the service.
+func NewMACTable(service string, aging time.Duration) *MACTable {
+	t := &MACTable{
+		service: service,
+		aging:   aging,
+		entries: make(map[macKey]*macEntry),
+	}
+
+	if service != "" {
+		tablesMu.Lock()
+		tables[service] = t
+		tablesMu.Unlock()
+	}
+
+	return t
+}
+
+// GetMACTable returns the MAC table of the service.
+func GetMACTable(service string) *MACTable {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	return tables[service]
+}
+
+// Learn records the source MAC address of the frame from the peer, nil peer is the local tap device.
+// It reports whether the MAC address is new or moved from another port.
+func (t *MACTable) Learn(mac net.HardwareAddr, vlan uint16, peer net.Addr) (moved bool) {
+	if len(mac) != 6 || IsMulticast(mac) {
+		return false
+	}
+	key := newMACKey(mac, vlan)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.entries[key]
+	if e != nil && addrString(e.peer) == addrString(peer) {
+		e.seen = now
+		return false
+	}
+	if e == nil && now.Sub(t.pruned) > t.aging/2 {
+		t.prune(now)
+	}
+	t.entries[key] = &macEntry{
+		peer: peer,
+		seen: now,
+	}
+	return true
+}
+
+// Lookup returns the port of the MAC address, the local tap device is returned as nil peer.
+func (t *MACTable) Lookup(mac net.HardwareAddr, vlan uint16) (peer net.Addr, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	e := t.entries[newMACKey(mac, vlan)]
+	if e == nil || time.Since(e.seen) > t.aging {
+		return nil, false
+	}
+	return e.peer, true
+}
+
+// DelPeer removes the MAC addresses of the peer.
+func (t *MACTable) DelPeer(peer net.Addr) {
+	s := addrString(peer)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k, e := range t.entries {
+		if e.peer != nil && addrString(e.peer) == s {
+			delete(t.entries, k)
+		}
+	}
+}
+
+// Peers returns the learned MAC addresses grouped by the peer, the local tap device is the peer with empty address.
+func (t *MACTable) Peers() []PeerMACs {
+	now := time.Now()
+
+	t.mu.Lock()
+	t.prune(now)
+	m := make(map[string][]MACEntry)
+	for k, e := range t.entries {
+		peer := addrString(e.peer)
+		m[peer] = append(m[peer], MACEntry{
+			MAC:     net.HardwareAddr(k.mac[:]).String(),
+			VLAN:    k.vlan,
+			Peer:    peer,
+			Expires: e.seen.Add(t.aging),
+		})
+	}
+	t.mu.Unlock()
+
+	peers := make([]PeerMACs, 0, len(m))
+	for peer, macs := range m {
+		sort.Slice(macs, func(i, j int) bool {
+			if macs[i].VLAN != macs[j].VLAN {
+				return macs[i].VLAN < macs[j].VLAN
+			}
+			return macs[i].MAC < macs[j].MAC
+		})
+		peers = append(peers, PeerMACs{
+			Peer: peer,
+			MACs: macs,
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Peer < peers[j].Peer
+	})
+	return peers
+}
+
+// prune removes the aged entries, the caller must hold the write lock.
+func (t *MACTable) prune(now time.Time) {
+	t.pruned = now
+	for k, e := range t.entries {
+		if now.Sub(e.seen) > t.aging {
+			delete(t.entries, k)
+		}
+	}
+}
+
+// Close unregisters the MAC table.
+func (t *MACTable) Close() error {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	if tables[t.service] == t {
+		delete(tables, t.service)
+	}
+	return nil
+}
+
+func newMACKey(mac net.HardwareAddr, vlan uint16) (key macKey) {
+	copy(key.mac[:], mac)
+	key.vlan = vlan
+	return
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// Limiter is a token bucket limiting the rate of the broadcast and multicast frames.
+type Limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// NewLimiter creates a limiter allowing rate frames per second with the burst,
+// it returns nil if the rate is not positive.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(rate)
+		if burst <= 0 {
+			burst = 1
+		}
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow reports whether a frame can be sent now, the nil limiter allows all frames.
+func (l *Limiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,127 @@
+package tls
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	camouflageSessionIDLen = 32
+	defaultMaxTimeDiff     = time.Minute
+)
+
+var (
+	ErrBadClientHello = errors.New("tls: bad client hello")
+)
+
+// CamouflageKey derives the authentication key from the pre-shared secret.
+func CamouflageKey(secret string) []byte {
+	sum := sha256.Sum256([]byte("gost camouflage " + secret))
+	return sum[:]
+}
+
+// CamouflageSessionID returns the session ID of the ClientHello which carries the authentication token.
+// The session ID is the time masked by the key and the client random, followed by the HMAC of them,
+// so it can not be distinguished from a random session ID without the key.
+func CamouflageSessionID(key, random []byte, t time.Time) []byte {
+	sid := make([]byte, camouflageSessionIDLen)
+	binary.BigEndian.PutUint64(sid, uint64(t.Unix()))
+
+	mask := camouflageMAC(key, []byte("mask"), random)
+	for i := 0; i < 8; i++ {
+		sid[i] ^= mask[i]
+	}
+	copy(sid[8:], camouflageMAC(key, random, sid[:8]))
+
+	return sid
+}
+
+func camouflageMAC(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, b := range data {
+		mac.Write(b)
+	}
+	return mac.Sum(nil)
+}
+
+// CamouflageVerifier verifies the authentication token of the ClientHello,
+// each client random can only be used once to prevent the replay attack.
+type CamouflageVerifier struct {
+	key         []byte
+	maxTimeDiff time.Duration
+	seen        map[[32]byte]time.Time
+	mu          sync.Mutex
+}
+
+func NewCamouflageVerifier(key []byte, maxTimeDiff time.Duration) *CamouflageVerifier {
+	if maxTimeDiff <= 0 {
+		maxTimeDiff = defaultMaxTimeDiff
+	}
+	return &CamouflageVerifier{
+		key:         key,
+		maxTimeDiff: maxTimeDiff,
+		seen:        make(map[[32]byte]time.Time),
+	}
+}
+
+// Verify reports whether the session ID is a valid token of the client random.
+func (v *CamouflageVerifier) Verify(random, sessionID []byte) bool {
+	if len(random) != 32 || len(sessionID) != camouflageSessionIDLen {
+		return false
+	}
+
+	ts := make([]byte, 8)
+	copy(ts, sessionID[:8])
+	if !hmac.Equal(sessionID[8:], camouflageMAC(v.key, random, ts)[:camouflageSessionIDLen-8]) {
+		return false
+	}
+
+	mask := camouflageMAC(v.key, []byte("mask"), random)
+	for i := range ts {
+		ts[i] ^= mask[i]
+	}
+	now := time.Now()
+	t := time.Unix(int64(binary.BigEndian.Uint64(ts)), 0)
+	if d := now.Sub(t); d > v.maxTimeDiff || d < -v.maxTimeDiff {
+		return false
+	}
+
+	var k [32]byte
+	copy(k[:], random)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.seen[k]; ok {
+		return false
+	}
+	// the tokens out of the time window are rejected anyway.
+	for r, t := range v.seen {
+		if now.Sub(t) > 2*v.maxTimeDiff {
+			delete(v.seen, r)
+		}
+	}
+	v.seen[k] = now
+
+	return true
+}
+
+// ParseClientHello returns the client random and the session ID of the ClientHello in the TLS record.
+func ParseClientHello(record []byte) (random, sessionID []byte, err error) {
+	// record header (5) + handshake header (4) + version (2) + random (32) + session ID length (1)
+	const n = 5 + 4 + 2 + 32 + 1
+	if len(record) < n || record[0] != 0x16 || record[5] != 0x01 {
+		return nil, nil, ErrBadClientHello
+	}
+	random = record[11:43]
+	sidLen := int(record[43])
+	if sidLen > 32 || len(record) < n+sidLen {
+		return nil, nil, ErrBadClientHello
+	}
+	sessionID = record[n : n+sidLen]
+	return
+}
@@ -0,0 +1,205 @@
+package tls
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func randomBytes(t *testing.T) []byte {
+	t.Helper()
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// clientHello returns the first TLS record sent by the client.
+func clientHello(t *testing.T, h *ClientHello) []byte {
+	t.Helper()
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	go h.Client(context.Background(), c1, &tls.Config{ServerName: "example.com"})
+
+	hdr := make([]byte, 5)
+	if _, err := io.ReadFull(c2, hdr); err != nil {
+		t.Fatal(err)
+	}
+	record := make([]byte, 5+int(binary.BigEndian.Uint16(hdr[3:])))
+	copy(record, hdr)
+	if _, err := io.ReadFull(c2, record[5:]); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestCamouflageVerify(t *testing.T) {
+	key := CamouflageKey("secret")
+	random := randomBytes(t)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		random    []byte
+		sessionID func() []byte
+		ok        bool
+	}{
+		{
+			name:      "valid",
+			random:    random,
+			sessionID: func() []byte { return CamouflageSessionID(key, random, now) },
+			ok:        true,
+		},
+		{
+			name:      "within time window",
+			random:    random,
+			sessionID: func() []byte { return CamouflageSessionID(key, random, now.Add(-50*time.Second)) },
+			ok:        true,
+		},
+		{
+			name:      "expired",
+			random:    random,
+			sessionID: func() []byte { return CamouflageSessionID(key, random, now.Add(-2*time.Minute)) },
+		},
+		{
+			name:      "future",
+			random:    random,
+			sessionID: func() []byte { return CamouflageSessionID(key, random, now.Add(2*time.Minute)) },
+		},
+		{
+			name:      "wrong key",
+			random:    random,
+			sessionID: func() []byte { return CamouflageSessionID(CamouflageKey("other"), random, now) },
+		},
+		{
+			name:      "other random",
+			random:    randomBytes(t),
+			sessionID: func() []byte { return CamouflageSessionID(key, random, now) },
+		},
+		{
+			name:   "tampered time",
+			random: random,
+			sessionID: func() []byte {
+				sid := CamouflageSessionID(key, random, now)
+				sid[7] ^= 1
+				return sid
+			},
+		},
+		{
+			name:      "random session ID",
+			random:    random,
+			sessionID: func() []byte { return randomBytes(t) },
+		},
+		{
+			name:      "short session ID",
+			random:    random,
+			sessionID: func() []byte { return CamouflageSessionID(key, random, now)[:16] },
+		},
+		{
+			name:      "short random",
+			random:    random[:16],
+			sessionID: func() []byte { return CamouflageSessionID(key, random, now) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewCamouflageVerifier(key, 0)
+			if ok := v.Verify(tt.random, tt.sessionID()); ok != tt.ok {
+				t.Errorf("got %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCamouflageReplay(t *testing.T) {
+	key := CamouflageKey("secret")
+	v := NewCamouflageVerifier(key, time.Minute)
+
+	r1 := randomBytes(t)
+	if !v.Verify(r1, CamouflageSessionID(key, r1, time.Now())) {
+		t.Fatal("valid token rejected")
+	}
+	// the same client random is rejected even with a new token.
+	if v.Verify(r1, CamouflageSessionID(key, r1, time.Now())) {
+		t.Error("replayed token accepted")
+	}
+
+	r2 := randomBytes(t)
+	if !v.Verify(r2, CamouflageSessionID(key, r2, time.Now())) {
+		t.Error("other token rejected")
+	}
+
+	// the randoms out of the time window are forgotten.
+	var k [32]byte
+	copy(k[:], r1)
+	v.mu.Lock()
+	v.seen[k] = time.Now().Add(-3 * time.Minute)
+	v.mu.Unlock()
+
+	r3 := randomBytes(t)
+	v.Verify(r3, CamouflageSessionID(key, r3, time.Now()))
+	v.mu.Lock()
+	_, ok := v.seen[k]
+	n := len(v.seen)
+	v.mu.Unlock()
+	if ok || n != 2 {
+		t.Errorf("got %d randoms, expired kept: %v", n, ok)
+	}
+}
+
+func TestParseClientHello(t *testing.T) {
+	key := CamouflageKey("secret")
+
+	h, err := NewClientHello("chrome", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.CamouflageKey = key
+	record := clientHello(t, h)
+
+	random, sessionID, err := ParseClientHello(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !NewCamouflageVerifier(key, 0).Verify(random, sessionID) {
+		t.Error("token of the ClientHello rejected")
+	}
+
+	// the Go client sends a random session ID.
+	random, sessionID, err = ParseClientHello(clientHello(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NewCamouflageVerifier(key, 0).Verify(random, sessionID) {
+		t.Error("plain ClientHello accepted")
+	}
+
+	tests := []struct {
+		name   string
+		record []byte
+	}{
+		{name: "short", record: record[:40]},
+		{name: "not handshake", record: append([]byte{0x17}, record[1:]...)},
+		{name: "not client hello", record: append(append([]byte{}, record[:5]...), append([]byte{0x02}, record[6:]...)...)},
+		{name: "long session ID", record: append(append([]byte{}, record[:43]...), append([]byte{33}, record[44:]...)...)},
+		{name: "truncated session ID", record: record[:44+16]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseClientHello(tt.record); !errors.Is(err, ErrBadClientHello) {
+				t.Errorf("got %v, want %v", err, ErrBadClientHello)
+			}
+		})
+	}
+}
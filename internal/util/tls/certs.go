@@ -0,0 +1,219 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+var (
+	ErrNoCertificate    = errors.New("tls: no certificate")
+	ErrClientNotAllowed = errors.New("tls: client certificate is not allowed")
+)
+
+// Certificates is a set of server certificates indexed by the server names.
+type Certificates struct {
+	certs map[string]*tls.Certificate
+	mu    sync.RWMutex
+}
+
+func NewCertificates() *Certificates {
+	return &Certificates{
+		certs: make(map[string]*tls.Certificate),
+	}
+}
+
+// CertificatesFromMetadata loads the certificates from the metadata,
+// the certDir and certs keys are used.
+// The certDir is a directory of the certificate and key file pairs,
+// the certs is a map of server name to the certFile and keyFile.
+// It returns nil if none of them is set.
+func CertificatesFromMetadata(md mdata.Metadata) (*Certificates, error) {
+	const (
+		certDir  = "certDir"
+		certs    = "certs"
+		certFile = "certFile"
+		keyFile  = "keyFile"
+	)
+
+	dir := mdx.GetString(md, certDir)
+	m := mdx.GetStringMap(md, certs)
+	if dir == "" && len(m) == 0 {
+		return nil, nil
+	}
+
+	c := NewCertificates()
+	if dir != "" {
+		if err := c.LoadDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	for name, v := range m {
+		vm, ok := v.(map[string]any)
+		if !ok {
+			if vv, ok := v.(map[any]any); ok {
+				vm = make(map[string]any)
+				for k, v := range vv {
+					if s, ok := k.(string); ok {
+						vm[s] = v
+					}
+				}
+			}
+		}
+		cmd := mdx.NewMetadata(vm)
+		if err := c.LoadFile(mdx.GetString(cmd, certFile), mdx.GetString(cmd, keyFile), name); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// LoadFile loads the certificate from the cert and key files,
+// the names in the certificate are used if no name is specified.
+func (c *Certificates) LoadFile(certFile, keyFile string, names ...string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	return c.Add(&cert, names...)
+}
+
+// LoadDir loads all the certificate and key file pairs in the directory,
+// the key file of a certificate file name.crt (or name.pem, name.cer) is name.key,
+// and the certificate is indexed by the names in it.
+func (c *Certificates) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		switch ext {
+		case ".crt", ".pem", ".cer":
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), ext)
+		keyFile := filepath.Join(dir, base+".key")
+		if _, err := os.Stat(keyFile); err != nil {
+			continue
+		}
+		if err := c.LoadFile(filepath.Join(dir, entry.Name()), keyFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add adds the certificate with the server names,
+// the DNS names and the common name of the certificate are used if no name is specified.
+func (c *Certificates) Add(cert *tls.Certificate, names ...string) error {
+	if cert == nil || len(cert.Certificate) == 0 {
+		return ErrNoCertificate
+	}
+	if cert.Leaf == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+		cert.Leaf = leaf
+	}
+	if len(names) == 0 {
+		names = append(names, cert.Leaf.DNSNames...)
+		if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+			names = append(names, cert.Leaf.Subject.CommonName)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range names {
+		if name = normalizeName(name); name != "" {
+			c.certs[name] = cert
+		}
+	}
+	return nil
+}
+
+// Get returns the certificate of the server name,
+// the wildcard certificate (*.example.com) is used if no exact match.
+func (c *Certificates) Get(name string) *tls.Certificate {
+	if c == nil {
+		return nil
+	}
+
+	name = normalizeName(name)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if cert := c.certs[name]; cert != nil {
+		return cert
+	}
+	if n := strings.IndexByte(name, '.'); n > 0 {
+		if cert := c.certs["*"+name[n:]]; cert != nil {
+			return cert
+		}
+	}
+	return nil
+}
+
+// Len returns the number of the server names.
+func (c *Certificates) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.certs)
+}
+
+// ServerConfig returns a copy of the cfg which selects the certificate by the server name,
+// the certificates of the cfg are used if no certificate is found.
+func (c *Certificates) ServerConfig(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+
+	fallback := cfg.GetCertificate
+	certs := cfg.Certificates
+	cfg.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if cert := c.Get(hello.ServerName); cert != nil {
+			return cert, nil
+		}
+		if fallback != nil {
+			// the fallback may have no certificate yet, e.g. the ACME certificate is not obtained.
+			if cert, err := fallback(hello); cert != nil || err != nil {
+				return cert, err
+			}
+		}
+		if len(certs) > 0 {
+			return &certs[0], nil
+		}
+		return nil, ErrNoCertificate
+	}
+	// GetCertificate is only called if Certificates is empty.
+	cfg.Certificates = nil
+
+	return cfg
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
@@ -0,0 +1,173 @@
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/go-gost/core/auth"
+	"github.com/gobwas/glob"
+)
+
+// Identity is the identity of the client certificate.
+type Identity struct {
+	CommonName string
+	DNSNames   []string
+	Emails     []string
+	IPs        []string
+	URIs       []string
+	// Fingerprint is the hex encoded SHA-256 digest of the certificate.
+	Fingerprint string
+}
+
+// IdentityFromConn returns the identity of the client certificate of the TLS connection,
+// the handshake is performed if it is not completed.
+// It returns nil if conn is not a TLS connection or the client sends no certificate.
+func IdentityFromConn(conn net.Conn) *Identity {
+	tc, ok := conn.(interface {
+		ConnectionState() tls.ConnectionState
+	})
+	if !ok {
+		return nil
+	}
+	if !tc.ConnectionState().HandshakeComplete {
+		// the streams of a multiplexed TLS connection have no handshake.
+		hs, ok := conn.(interface {
+			Handshake() error
+		})
+		if !ok || hs.Handshake() != nil {
+			return nil
+		}
+	}
+	state := tc.ConnectionState()
+	return IdentityFromState(&state)
+}
+
+// IdentityFromState returns the identity of the peer certificate.
+// The server only requests the client certificate when the client CA is set,
+// and the certificate is always verified, so the peer certificate can be trusted.
+func IdentityFromState(state *tls.ConnectionState) *Identity {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return NewIdentity(state.PeerCertificates[0])
+}
+
+func NewIdentity(cert *x509.Certificate) *Identity {
+	sum := sha256.Sum256(cert.Raw)
+	id := &Identity{
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		Emails:      cert.EmailAddresses,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		id.IPs = append(id.IPs, ip.String())
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id
+}
+
+// Names returns the common name, the subject alternative names and the fingerprint.
+func (id *Identity) Names() []string {
+	if id == nil {
+		return nil
+	}
+
+	var names []string
+	if id.CommonName != "" {
+		names = append(names, id.CommonName)
+	}
+	names = append(names, id.DNSNames...)
+	names = append(names, id.Emails...)
+	names = append(names, id.IPs...)
+	names = append(names, id.URIs...)
+	return append(names, id.Fingerprint)
+}
+
+// IdentityAuthenticator looks up the client by the name only, the certificate replaces the password.
+type IdentityAuthenticator interface {
+	AuthenticateIdentity(name string) bool
+}
+
+// Authenticate authenticates the identity by the names of it, it returns the first accepted name.
+// The names are looked up without the password if the auther is an IdentityAuthenticator,
+// otherwise they are authenticated with an empty password.
+func (id *Identity) Authenticate(auther auth.Authenticator) (string, bool) {
+	if id == nil || auther == nil {
+		return "", false
+	}
+	for _, name := range id.Names() {
+		if ia, ok := auther.(IdentityAuthenticator); ok {
+			if ia.AuthenticateIdentity(name) {
+				return name, true
+			}
+			continue
+		}
+		if auther.Authenticate(name, "") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Match reports whether any name of the identity matches any of the patterns,
+// the pattern can contain wildcards, such as *.example.com.
+func (id *Identity) Match(patterns []string) bool {
+	if id == nil {
+		return false
+	}
+	for _, pattern := range patterns {
+		g, err := glob.Compile(strings.ToLower(pattern))
+		if err != nil {
+			continue
+		}
+		for _, name := range id.Names() {
+			if g.Match(strings.ToLower(name)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (id *Identity) String() string {
+	if id == nil {
+		return ""
+	}
+	if id.CommonName != "" {
+		return id.CommonName
+	}
+	if names := id.Names(); len(names) > 1 {
+		return names[0]
+	}
+	return id.Fingerprint
+}
+
+// AllowClients returns a copy of the cfg which only accepts the client certificates matching the patterns.
+func AllowClients(cfg *tls.Config, patterns []string) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+
+	verify := cfg.VerifyConnection
+	cfg.VerifyConnection = func(state tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(state); err != nil {
+				return err
+			}
+		}
+		if id := IdentityFromState(&state); !id.Match(patterns) {
+			return ErrClientNotAllowed
+		}
+		return nil
+	}
+
+	return cfg
+}
@@ -0,0 +1,58 @@
+package tls
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/go-gost/core/auth"
+	auth_impl "github.com/hxdcloud/gost-x/auth"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+)
+
+// passwordAuther only authenticates the user-password pairs.
+type passwordAuther map[string]string
+
+func (a passwordAuther) Authenticate(user, password string) bool {
+	v, ok := a[user]
+	return ok && v == password
+}
+
+func TestIdentityAuthenticate(t *testing.T) {
+	id := NewIdentity(&x509.Certificate{
+		Raw:      []byte("cert"),
+		Subject:  pkix.Name{CommonName: "client"},
+		DNSNames: []string{"client.example.com"},
+	})
+
+	newAuther := func(auths map[string]string) auth.Authenticator {
+		return auth_impl.NewAuthenticator(
+			auth_impl.AuthsPeriodOption(auths),
+			auth_impl.LoggerOption(xlogger.Nop()),
+		)
+	}
+
+	tests := []struct {
+		name   string
+		auther auth.Authenticator
+		want   string
+		ok     bool
+	}{
+		{name: "no auther", auther: nil},
+		{name: "no password", auther: newAuther(map[string]string{"client": ""}), want: "client", ok: true},
+		{name: "with password", auther: newAuther(map[string]string{"client": "pass"}), want: "client", ok: true},
+		{name: "subject alternative name", auther: newAuther(map[string]string{"client.example.com": "pass"}), want: "client.example.com", ok: true},
+		{name: "unknown", auther: newAuther(map[string]string{"other": "pass"})},
+		{name: "password only auther", auther: passwordAuther{"client": "pass"}},
+		{name: "password only auther empty password", auther: passwordAuther{"client": ""}, want: "client", ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, ok := id.Authenticate(tt.auther)
+			if name != tt.want || ok != tt.ok {
+				t.Errorf("got %q %v, want %q %v", name, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+var (
+	reloaders   = make(map[string]*Reloader)
+	reloadersMu sync.Mutex
+)
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reloader reloads the certificate, key and CA files when they are changed,
+// the files are checked periodically.
+type Reloader struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	cert       *tls.Certificate
+	pool       *x509.CertPool
+	stats      map[string]fileStat
+	mu         sync.RWMutex
+	reloadMu   sync.Mutex
+	cancelFunc context.CancelFunc
+	logger     logger.Logger
+}
+
+// GetReloader returns the shared Reloader of the files, it is created if not exists.
+func GetReloader(certFile, keyFile, caFile string, period time.Duration, logger logger.Logger) (*Reloader, error) {
+	key := strings.Join([]string{certFile, keyFile, caFile, period.String()}, "|")
+
+	reloadersMu.Lock()
+	defer reloadersMu.Unlock()
+
+	if r := reloaders[key]; r != nil {
+		return r, nil
+	}
+	r, err := NewReloader(certFile, keyFile, caFile, period, logger)
+	if err != nil {
+		return nil, err
+	}
+	reloaders[key] = r
+	return r, nil
+}
+
+// NewReloader loads the files and checks them for changes every period.
+func NewReloader(certFile, keyFile, caFile string, period time.Duration, logger logger.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		stats:    make(map[string]fileStat),
+		logger:   logger,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	if period > 0 {
+		if period < time.Second {
+			period = time.Second
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		r.cancelFunc = cancel
+		go r.periodReload(ctx, period)
+	}
+
+	return r, nil
+}
+
+// Reload loads the changed files, the previous certificate and CA are kept if the loading fails.
+func (r *Reloader) Reload() (changed bool, err error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	if r.certFile != "" || r.keyFile != "" {
+		if r.changed(r.certFile) || r.changed(r.keyFile) {
+			cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+			if err != nil {
+				return changed, err
+			}
+			if cert.Leaf == nil {
+				cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+			}
+
+			r.mu.Lock()
+			r.cert = &cert
+			r.mu.Unlock()
+
+			r.update(r.certFile)
+			r.update(r.keyFile)
+			changed = true
+		}
+	}
+
+	if r.caFile != "" && r.changed(r.caFile) {
+		pool, err := loadCA(r.caFile)
+		if err != nil {
+			return changed, err
+		}
+
+		r.mu.Lock()
+		r.pool = pool
+		r.mu.Unlock()
+
+		r.update(r.caFile)
+		changed = true
+	}
+
+	return
+}
+
+// Certificate returns the current certificate.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert
+}
+
+// CertPool returns the current CA certificates.
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.pool
+}
+
+// ServerConfig returns a copy of the cfg which uses the current certificate and client CA.
+func (r *Reloader) ServerConfig(cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+
+	if r.Certificate() != nil {
+		cfg.Certificates = nil
+		cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		}
+	}
+
+	if r.caFile != "" {
+		// the client CA can not be changed after the handshake starts, so we verify the certificate manually.
+		cfg.ClientCAs = r.CertPool()
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			return r.verify(state, "", x509.ExtKeyUsageClientAuth)
+		}
+	}
+
+	return cfg
+}
+
+// ClientConfig returns a copy of the cfg which uses the current client certificate and root CA.
+// The server certificate is verified with the current root CA,
+// and the server name is also verified if verify is true.
+func (r *Reloader) ClientConfig(cfg *tls.Config, verify bool) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+
+	if r.Certificate() != nil {
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		}
+	}
+
+	if r.caFile != "" {
+		// the root CA can not be changed after the handshake starts, so we verify the certificate manually.
+		cfg.RootCAs = nil
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			var dnsName string
+			if verify {
+				dnsName = state.ServerName
+			}
+			return r.verify(state, dnsName, x509.ExtKeyUsageServerAuth)
+		}
+	}
+
+	return cfg
+}
+
+func (r *Reloader) verify(state tls.ConnectionState, dnsName string, usage x509.ExtKeyUsage) error {
+	certs := state.PeerCertificates
+	if len(certs) == 0 {
+		return ErrNoCertificate
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         r.CertPool(),
+		CurrentTime:   time.Now(),
+		DNSName:       dnsName,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// Close stops the periodic reloading.
+func (r *Reloader) Close() error {
+	if r.cancelFunc != nil {
+		r.cancelFunc()
+	}
+	return nil
+}
+
+func (r *Reloader) periodReload(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := r.Reload()
+			if err != nil {
+				r.logger.Warnf("reload %s: %v", r.certFile, err)
+				continue
+			}
+			if changed {
+				r.logger.Infof("reload %s, %s, %s done", r.certFile, r.keyFile, r.caFile)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Reloader) changed(name string) bool {
+	fi, err := os.Stat(name)
+	if err != nil {
+		// let the loading report the error.
+		return true
+	}
+	st, ok := r.stats[name]
+	return !ok || !st.modTime.Equal(fi.ModTime()) || st.size != fi.Size()
+}
+
+func (r *Reloader) update(name string) {
+	if fi, err := os.Stat(name); err == nil {
+		r.stats[name] = fileStat{
+			modTime: fi.ModTime(),
+			size:    fi.Size(),
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	utls "github.com/refraction-networking/utls"
+)
+
+var fingerprints = map[string]utls.ClientHelloID{
+	"chrome":            utls.HelloChrome_Auto,
+	"chrome_83":         utls.HelloChrome_83,
+	"chrome_87":         utls.HelloChrome_87,
+	"chrome_96":         utls.HelloChrome_96,
+	"chrome_100":        utls.HelloChrome_100,
+	"chrome_102":        utls.HelloChrome_102,
+	"firefox":           utls.HelloFirefox_Auto,
+	"firefox_99":        utls.HelloFirefox_99,
+	"firefox_102":       utls.HelloFirefox_102,
+	"firefox_105":       utls.HelloFirefox_105,
+	"safari":            utls.HelloSafari_Auto,
+	"ios":               utls.HelloIOS_Auto,
+	"edge":              utls.HelloEdge_Auto,
+	"android":           utls.HelloAndroid_11_OkHttp,
+	"360":               utls.Hello360_Auto,
+	"qq":                utls.HelloQQ_Auto,
+	"random":            utls.HelloRandomized,
+	"randomized":        utls.HelloRandomizedALPN,
+	"randomized-noalpn": utls.HelloRandomizedNoALPN,
+}
+
+// ClientHello controls the ClientHello message of the TLS client connection.
+type ClientHello struct {
+	// Fingerprint is the name of the mimicked client, such as chrome, firefox, safari and random.
+	// The Go ClientHello is used if it is empty.
+	Fingerprint string
+	// ALPN is the application protocols of the ALPN extension.
+	ALPN []string
+	// CamouflageKey authenticates the client to the camouflage TLS server.
+	CamouflageKey []byte
+	id            utls.ClientHelloID
+}
+
+// NewClientHello returns a ClientHello with the fingerprint and ALPN protocols.
+func NewClientHello(fingerprint string, alpn []string) (*ClientHello, error) {
+	h := &ClientHello{
+		Fingerprint: strings.ToLower(strings.TrimSpace(fingerprint)),
+		ALPN:        alpn,
+	}
+	if h.Fingerprint != "" {
+		id, ok := fingerprints[h.Fingerprint]
+		if !ok {
+			return nil, fmt.Errorf("tls: unknown fingerprint %s", fingerprint)
+		}
+		h.id = id
+	}
+	return h, nil
+}
+
+// ClientHelloFromMetadata parses the fingerprint, alpn and camouflageKey keys of the metadata,
+// it returns nil if none of them is set.
+func ClientHelloFromMetadata(md mdata.Metadata) (*ClientHello, error) {
+	const (
+		fingerprint   = "fingerprint"
+		alpn          = "alpn"
+		camouflageKey = "camouflageKey"
+	)
+
+	fp := mdx.GetString(md, fingerprint)
+	protos := mdx.GetStrings(md, alpn)
+	if len(protos) == 0 {
+		if v := mdx.GetString(md, alpn); v != "" {
+			protos = strings.Split(v, ",")
+		}
+	}
+	key := mdx.GetString(md, camouflageKey)
+	if fp == "" && len(protos) == 0 && key == "" {
+		return nil, nil
+	}
+	if key != "" && fp == "" {
+		// the token is carried by the mimicked ClientHello.
+		fp = "chrome"
+	}
+
+	h, err := NewClientHello(fp, protos)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		h.CamouflageKey = CamouflageKey(key)
+	}
+	return h, nil
+}
+
+// Client performs the client handshake on conn.
+// The ALPN protocols are selected in order from the ClientHello and the NextProtos of cfg,
+// and for the mimicked ClientHello, the protos are used instead of the ones of the mimicked client.
+func (h *ClientHello) Client(ctx context.Context, conn net.Conn, cfg *tls.Config, protos ...string) (net.Conn, error) {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+
+	if h == nil || h.Fingerprint == "" {
+		if h != nil && len(h.ALPN) > 0 {
+			cfg = cfg.Clone()
+			cfg.NextProtos = h.ALPN
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+
+	alpn := protos
+	if len(h.ALPN) > 0 {
+		alpn = h.ALPN
+	} else if len(cfg.NextProtos) > 0 {
+		alpn = cfg.NextProtos
+	}
+
+	uconn := utls.UClient(conn, uConfig(cfg, alpn), h.id)
+	if len(alpn) > 0 || h.CamouflageKey != nil {
+		if err := uconn.BuildHandshakeState(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		for _, ext := range uconn.Extensions {
+			if v, ok := ext.(*utls.ALPNExtension); ok && len(alpn) > 0 {
+				v.AlpnProtocols = alpn
+			}
+		}
+		if h.CamouflageKey != nil {
+			hello := uconn.HandshakeState.Hello
+			hello.SessionId = CamouflageSessionID(h.CamouflageKey, hello.Random, time.Now())
+		}
+		// apply the changed extensions and session ID.
+		if err := uconn.BuildHandshakeState(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return uconn, nil
+}
+
+// uConfig converts the cfg to the uTLS config, the certificate verification is kept unchanged.
+func uConfig(cfg *tls.Config, alpn []string) *utls.Config {
+	c := &utls.Config{
+		Rand:                   cfg.Rand,
+		Time:                   cfg.Time,
+		RootCAs:                cfg.RootCAs,
+		NextProtos:             alpn,
+		ServerName:             cfg.ServerName,
+		InsecureSkipVerify:     cfg.InsecureSkipVerify,
+		VerifyPeerCertificate:  cfg.VerifyPeerCertificate,
+		MinVersion:             cfg.MinVersion,
+		MaxVersion:             cfg.MaxVersion,
+		SessionTicketsDisabled: cfg.SessionTicketsDisabled,
+		KeyLogWriter:           cfg.KeyLogWriter,
+	}
+	for i := range cfg.Certificates {
+		c.Certificates = append(c.Certificates, uCertificate(&cfg.Certificates[i]))
+	}
+	if getCert := cfg.GetClientCertificate; getCert != nil {
+		c.GetClientCertificate = func(info *utls.CertificateRequestInfo) (*utls.Certificate, error) {
+			cri := &tls.CertificateRequestInfo{
+				AcceptableCAs: info.AcceptableCAs,
+				Version:       info.Version,
+			}
+			for _, v := range info.SignatureSchemes {
+				cri.SignatureSchemes = append(cri.SignatureSchemes, tls.SignatureScheme(v))
+			}
+			cert, err := getCert(cri)
+			if err != nil || cert == nil {
+				return nil, err
+			}
+			uc := uCertificate(cert)
+			return &uc, nil
+		}
+	}
+	if verify := cfg.VerifyConnection; verify != nil {
+		c.VerifyConnection = func(state utls.ConnectionState) error {
+			return verify(tls.ConnectionState{
+				Version:                     state.Version,
+				HandshakeComplete:           state.HandshakeComplete,
+				DidResume:                   state.DidResume,
+				CipherSuite:                 state.CipherSuite,
+				NegotiatedProtocol:          state.NegotiatedProtocol,
+				ServerName:                  state.ServerName,
+				PeerCertificates:            state.PeerCertificates,
+				VerifiedChains:              state.VerifiedChains,
+				SignedCertificateTimestamps: state.SignedCertificateTimestamps,
+				OCSPResponse:                state.OCSPResponse,
+				TLSUnique:                   state.TLSUnique,
+			})
+		}
+	}
+
+	return c
+}
+
+func uCertificate(cert *tls.Certificate) utls.Certificate {
+	c := utls.Certificate{
+		Certificate:                 cert.Certificate,
+		PrivateKey:                  cert.PrivateKey,
+		OCSPStaple:                  cert.OCSPStaple,
+		SignedCertificateTimestamps: cert.SignedCertificateTimestamps,
+		Leaf:                        cert.Leaf,
+	}
+	for _, v := range cert.SupportedSignatureAlgorithms {
+		c.SupportedSignatureAlgorithms = append(c.SupportedSignatureAlgorithms, utls.SignatureScheme(v))
+	}
+	return c
+}
+
+// ServerNameConfig returns a copy of the cfg with the host of the addr as the server name if it is not set.
+func ServerNameConfig(cfg *tls.Config, addr string) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName != "" {
+		return cfg
+	}
+
+	cfg = cfg.Clone()
+	cfg.ServerName = addr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		cfg.ServerName = host
+	}
+	return cfg
+}
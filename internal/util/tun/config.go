@@ -11,9 +11,17 @@ type Route struct {
 type Config struct {
 	Name string
 	Net  string
+	// Net6 is the IPv6 address of the tun device in CIDR notation.
+	Net6 string
 	// peer addr of point-to-point on MacOS
 	Peer    string
 	MTU     int
 	Gateway string
 	Routes  []Route
 }
+
+// Device is the tun device created by the tun listener.
+type Device interface {
+	// AddAddr adds the address assigned by the server to the device.
+	AddAddr(ip net.IP) error
+}
@@ -0,0 +1,236 @@
+package tun
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// The control packets are exchanged between the tun client and server over the same packet connection as the IP packets,
+// they start with a zero version nibble so that they can not be confused with the IP packets.
+const (
+	ctrlAuth         byte = 0x01
+	ctrlAuthResponse byte = 0x02
+)
+
+const (
+	AuthOK     byte = 0x00
+	AuthFailed byte = 0x01
+)
+
+const (
+	authMACLen = 32
+	// PacketMACLen is the length of the MAC appended to the data packets of the authenticated peers.
+	PacketMACLen       = 16
+	defaultMaxTimeDiff = time.Minute
+	maxPeerUserLen     = 255
+	maxAuthResponseIPs = 16
+	authRequestMinLen  = 2 + 8 + authMACLen
+	authResponseMinLen = 3
+)
+
+var (
+	ErrBadControlPacket = errors.New("tun: bad control packet")
+)
+
+// IsControlPacket reports whether the packet is a control packet.
+func IsControlPacket(b []byte) bool {
+	return len(b) > 0 && b[0]>>4 == 0
+}
+
+// IsAuthRequest reports whether the control packet is an authentication request.
+func IsAuthRequest(b []byte) bool {
+	return len(b) > 0 && b[0] == ctrlAuth
+}
+
+// IsAuthResponse reports whether the control packet is an authentication response.
+func IsAuthResponse(b []byte) bool {
+	return len(b) > 0 && b[0] == ctrlAuthResponse
+}
+
+// Peer is a client of the tun server authenticated by the key.
+type Peer struct {
+	User string
+	Key  string
+	// IPs are the static IPs of the tun device of the peer.
+	IPs []net.IP
+	// Routes are the networks behind the peer.
+	Routes []*net.IPNet
+}
+
+// Allowed reports whether the peer can send the packets from the source ip,
+// any source is allowed if neither the IPs nor the routes are set.
+func (p *Peer) Allowed(ip net.IP) bool {
+	if len(p.IPs) == 0 && len(p.Routes) == 0 {
+		return true
+	}
+	for _, v := range p.IPs {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+	for _, v := range p.Routes {
+		if v.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthRequest is the authentication request of the peer,
+// it is sent periodically by the client to keep the peer session alive.
+type AuthRequest struct {
+	User string
+	Time time.Time
+	mac  []byte
+}
+
+// NewAuthRequest encodes the authentication request of the user signed by the key.
+func NewAuthRequest(user, key string, t time.Time) []byte {
+	if len(user) > maxPeerUserLen {
+		user = user[:maxPeerUserLen]
+	}
+	b := authData(user, t)
+	return append(b, authMAC(key, b)...)
+}
+
+// ParseAuthRequest decodes the authentication request.
+func ParseAuthRequest(b []byte) (*AuthRequest, error) {
+	if len(b) < authRequestMinLen || b[0] != ctrlAuth {
+		return nil, ErrBadControlPacket
+	}
+	n := int(b[1])
+	if len(b) != authRequestMinLen+n {
+		return nil, ErrBadControlPacket
+	}
+	return &AuthRequest{
+		User: string(b[2 : 2+n]),
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(b[2+n:]))),
+		mac:  append([]byte(nil), b[len(b)-authMACLen:]...),
+	}, nil
+}
+
+func (r *AuthRequest) verify(key string) bool {
+	return hmac.Equal(r.mac, authMAC(key, authData(r.User, r.Time)))
+}
+
+// authData returns the signed part of the authentication request.
+func authData(user string, t time.Time) []byte {
+	b := make([]byte, 2+len(user)+8, authRequestMinLen+len(user))
+	b[0] = ctrlAuth
+	b[1] = byte(len(user))
+	copy(b[2:], user)
+	binary.BigEndian.PutUint64(b[2+len(user):], uint64(t.UnixNano()))
+	return b
+}
+
+func authMAC(key string, data []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// SignPacket appends the MAC of the data packet signed by the key of the peer,
+// so the packets of the peer can not be forged by spoofing the source address of it.
+func SignPacket(b []byte, key string) []byte {
+	return append(b, authMAC(key, b)[:PacketMACLen]...)
+}
+
+// VerifyPacket verifies the MAC of the data packet signed by the key, and returns the packet without the MAC.
+func VerifyPacket(b []byte, key string) ([]byte, bool) {
+	n := len(b) - PacketMACLen
+	if n <= 0 {
+		return nil, false
+	}
+	return b[:n], hmac.Equal(b[n:], authMAC(key, b[:n])[:PacketMACLen])
+}
+
+// NewAuthResponse encodes the authentication response with the static IPs assigned to the peer.
+func NewAuthResponse(status byte, ips ...net.IP) []byte {
+	if len(ips) > maxAuthResponseIPs {
+		ips = ips[:maxAuthResponseIPs]
+	}
+	b := []byte{ctrlAuthResponse, status, byte(len(ips))}
+	for _, ip := range ips {
+		if v := ip.To4(); v != nil {
+			ip = v
+		}
+		b = append(b, byte(len(ip)))
+		b = append(b, ip...)
+	}
+	return b
+}
+
+// ParseAuthResponse decodes the authentication response.
+func ParseAuthResponse(b []byte) (status byte, ips []net.IP, err error) {
+	if len(b) < authResponseMinLen || b[0] != ctrlAuthResponse {
+		return 0, nil, ErrBadControlPacket
+	}
+	status = b[1]
+	n := int(b[2])
+	b = b[authResponseMinLen:]
+	for i := 0; i < n; i++ {
+		if len(b) == 0 {
+			return 0, nil, ErrBadControlPacket
+		}
+		l := int(b[0])
+		if (l != net.IPv4len && l != net.IPv6len) || len(b) < 1+l {
+			return 0, nil, ErrBadControlPacket
+		}
+		ips = append(ips, net.IP(append([]byte(nil), b[1:1+l]...)))
+		b = b[1+l:]
+	}
+	return
+}
+
+// PeerAuthenticator authenticates the authentication requests of the peers.
+// The time of the request must be within the time window and newer than the last one of the user,
+// so the captured requests can not be replayed.
+type PeerAuthenticator struct {
+	peers       map[string]*Peer
+	maxTimeDiff time.Duration
+	last        map[string]time.Time
+	mu          sync.Mutex
+}
+
+func NewPeerAuthenticator(peers []*Peer, maxTimeDiff time.Duration) *PeerAuthenticator {
+	if maxTimeDiff <= 0 {
+		maxTimeDiff = defaultMaxTimeDiff
+	}
+	m := make(map[string]*Peer)
+	for _, p := range peers {
+		m[p.User] = p
+	}
+	return &PeerAuthenticator{
+		peers:       m,
+		maxTimeDiff: maxTimeDiff,
+		last:        make(map[string]time.Time),
+	}
+}
+
+// Authenticate returns the peer of the request, or nil if the request is not valid.
+func (a *PeerAuthenticator) Authenticate(r *AuthRequest) *Peer {
+	if r == nil {
+		return nil
+	}
+	p := a.peers[r.User]
+	if p == nil || !r.verify(p.Key) {
+		return nil
+	}
+	if d := time.Since(r.Time); d > a.maxTimeDiff || d < -a.maxTimeDiff {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !r.Time.After(a.last[r.User]) {
+		return nil
+	}
+	a.last[r.User] = r.Time
+	return p
+}
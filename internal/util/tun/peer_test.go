@@ -0,0 +1,39 @@
+package tun
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPacketMAC(t *testing.T) {
+	pkt := []byte{0x45, 0x00, 0x00, 0x14, 0x00, 0x00, 0x00, 0x00, 0x40, 0x11}
+	signed := SignPacket(append([]byte(nil), pkt...), "key")
+
+	tampered := append([]byte(nil), signed...)
+	tampered[1] ^= 0xff
+
+	tests := []struct {
+		name string
+		b    []byte
+		key  string
+		ok   bool
+	}{
+		{name: "valid", b: signed, key: "key", ok: true},
+		{name: "other key", b: signed, key: "other", ok: false},
+		{name: "tampered", b: tampered, key: "key", ok: false},
+		{name: "unsigned", b: pkt, key: "key", ok: false},
+		{name: "too short", b: signed[:PacketMACLen], key: "key", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, ok := VerifyPacket(tt.b, tt.key)
+			if ok != tt.ok {
+				t.Fatalf("ok: got %v, want %v", ok, tt.ok)
+			}
+			if ok && !bytes.Equal(b, pkt) {
+				t.Errorf("packet: got %x, want %x", b, pkt)
+			}
+		})
+	}
+}
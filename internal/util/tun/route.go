@@ -0,0 +1,357 @@
+package tun
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrInvalidRoute = errors.New("tun: invalid route")
+	ErrRouteExists  = errors.New("tun: route exists")
+)
+
+var (
+	tables   = make(map[string]*RouteTable)
+	tablesMu sync.RWMutex
+)
+
+// RouteEntry is the snapshot of a route of the RouteTable.
+type RouteEntry struct {
+	// Net is the destination network in CIDR notation.
+	Net string `json:"net"`
+	// Gateway is the IP of the tun device which the traffic to the network is sent to.
+	Gateway string `json:"gateway,omitempty"`
+	// Peer is the address of the peer which the traffic to the network is sent to.
+	Peer string `json:"peer,omitempty"`
+	// User is the authenticated user of the peer.
+	User string `json:"user,omitempty"`
+	// Static reports whether the route is configured or added by the API instead of being learned from the traffic.
+	Static  bool       `json:"static"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+type route struct {
+	net     *net.IPNet
+	gateway net.IP
+	peer    net.Addr
+	user    string
+	static  bool
+	// expires is the fixed expiration time of the static route.
+	expires time.Time
+	// seen is the last time in unix nanoseconds the learned route is used by the peer.
+	seen int64
+}
+
+func (r *route) expiresAt(ttl time.Duration) time.Time {
+	if r.static {
+		return r.expires
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, atomic.LoadInt64(&r.seen)).Add(ttl)
+}
+
+func (r *route) expired(now time.Time, ttl time.Duration) bool {
+	t := r.expiresAt(ttl)
+	return !t.IsZero() && now.After(t)
+}
+
+// RouteTable is the routing table of the tun server.
+// The host routes map the IPs of the tun devices to the peer addresses,
+// and the network routes map the networks to the gateways or the peers, the longest prefix wins.
+type RouteTable struct {
+	service string
+	ttl     time.Duration
+	hosts   map[tunRouteKey]*route
+	nets    []*route
+	mu      sync.RWMutex
+}
+
+// NewRouteTable creates the routing table of the service,
+// the learned routes are removed if they are not used by the peers for ttl, zero means never.
+func NewRouteTable(service string, ttl time.Duration) *RouteTable {
+	t := &RouteTable{
+		service: service,
+		ttl:     ttl,
+		hosts:   make(map[tunRouteKey]*route),
+	}
+
+	if service != "" {
+		tablesMu.Lock()
+		tables[service] = t
+		tablesMu.Unlock()
+	}
+
+	return t
+}
+
+// GetRouteTable returns the routing table of the service.
+func GetRouteTable(service string) *RouteTable {
+	tablesMu.RLock()
+	defer tablesMu.RUnlock()
+
+	return tables[service]
+}
+
+// Learn updates the host route of the ip to the peer, it returns the old peer address if the route is changed.
+// The static host routes are not overridden.
+func (t *RouteTable) Learn(ip net.IP, peer net.Addr, user string) (old net.Addr, changed bool) {
+	key := ipToTunRouteKey(ip)
+	now := time.Now()
+
+	t.mu.RLock()
+	r := t.hosts[key]
+	if r != nil && (r.static || (r.peer.String() == peer.String() && r.user == user)) {
+		if !r.static {
+			atomic.StoreInt64(&r.seen, now.UnixNano())
+		}
+		t.mu.RUnlock()
+		return nil, false
+	}
+	t.mu.RUnlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r = t.hosts[key]; r != nil {
+		if r.static {
+			return nil, false
+		}
+		if !r.expired(now, t.ttl) {
+			old = r.peer
+		}
+	} else {
+		t.prune(now)
+	}
+
+	t.hosts[key] = &route{
+		net:  hostNet(ip),
+		peer: peer,
+		user: user,
+		seen: now.UnixNano(),
+	}
+	return old, true
+}
+
+// AddRoute adds a static route of the network to the gateway or the peer, the route expires after ttl if it is positive.
+// The network of a single IP with the peer is added as a host route.
+func (t *RouteTable) AddRoute(ipNet *net.IPNet, gateway net.IP, peer net.Addr, user string, ttl time.Duration) error {
+	if ipNet == nil || (gateway == nil && peer == nil) {
+		return ErrInvalidRoute
+	}
+
+	r := &route{
+		net:     &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask},
+		gateway: gateway,
+		peer:    peer,
+		user:    user,
+		static:  true,
+	}
+	if ttl > 0 {
+		r.expires = time.Now().Add(ttl)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.prune(now)
+
+	if isHostNet(r.net) && peer != nil {
+		key := ipToTunRouteKey(r.net.IP)
+		if v := t.hosts[key]; v != nil && v.static {
+			return ErrRouteExists
+		}
+		t.hosts[key] = r
+		return nil
+	}
+
+	for _, v := range t.nets {
+		if v.net.String() == r.net.String() {
+			return ErrRouteExists
+		}
+	}
+	t.nets = append(t.nets, r)
+	sort.SliceStable(t.nets, func(i, j int) bool {
+		ni, _ := t.nets[i].net.Mask.Size()
+		nj, _ := t.nets[j].net.Mask.Size()
+		return ni > nj
+	})
+	return nil
+}
+
+// DelRoute deletes the route of the network, it reports whether the route is found.
+func (t *RouteTable) DelRoute(ipNet *net.IPNet) bool {
+	if ipNet == nil {
+		return false
+	}
+	s := (&net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}).String()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, v := range t.nets {
+		if v.net.String() == s {
+			t.nets = append(t.nets[:i], t.nets[i+1:]...)
+			return true
+		}
+	}
+	if isHostNet(ipNet) {
+		key := ipToTunRouteKey(ipNet.IP)
+		if _, ok := t.hosts[key]; ok {
+			delete(t.hosts, key)
+			return true
+		}
+	}
+	return false
+}
+
+// DelPeer deletes the routes to the peer.
+func (t *RouteTable) DelPeer(peer net.Addr) {
+	s := peer.String()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k, v := range t.hosts {
+		if v.peer != nil && v.peer.String() == s {
+			delete(t.hosts, k)
+		}
+	}
+	nets := t.nets[:0]
+	for _, v := range t.nets {
+		if v.peer != nil && v.peer.String() == s {
+			continue
+		}
+		nets = append(nets, v)
+	}
+	t.nets = nets
+}
+
+// Find returns the peer address of the destination IP.
+func (t *RouteTable) Find(dst net.IP) net.Addr {
+	now := time.Now()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if r := t.hosts[ipToTunRouteKey(dst)]; r != nil && !r.expired(now, t.ttl) {
+		return r.peer
+	}
+
+	for _, r := range t.nets {
+		if !r.net.Contains(dst) || r.expired(now, t.ttl) {
+			continue
+		}
+		if r.peer != nil {
+			return r.peer
+		}
+		if v := t.hosts[ipToTunRouteKey(r.gateway)]; v != nil && !v.expired(now, t.ttl) {
+			return v.peer
+		}
+	}
+	return nil
+}
+
+// Routes returns the unexpired routes, the network routes are ordered by the prefix length.
+func (t *RouteTable) Routes() []RouteEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(time.Now())
+
+	var hosts []RouteEntry
+	for _, r := range t.hosts {
+		hosts = append(hosts, t.entry(r))
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Net < hosts[j].Net
+	})
+
+	entries := make([]RouteEntry, 0, len(hosts)+len(t.nets))
+	entries = append(entries, hosts...)
+	for _, r := range t.nets {
+		entries = append(entries, t.entry(r))
+	}
+	return entries
+}
+
+func (t *RouteTable) entry(r *route) RouteEntry {
+	e := RouteEntry{
+		Net:    r.net.String(),
+		User:   r.user,
+		Static: r.static,
+	}
+	if r.gateway != nil {
+		e.Gateway = r.gateway.String()
+	}
+	if r.peer != nil {
+		e.Peer = r.peer.String()
+	}
+	if v := r.expiresAt(t.ttl); !v.IsZero() {
+		e.Expires = &v
+	}
+	return e
+}
+
+// prune removes the expired routes, the caller must hold the write lock.
+func (t *RouteTable) prune(now time.Time) {
+	for k, r := range t.hosts {
+		if r.expired(now, t.ttl) {
+			delete(t.hosts, k)
+		}
+	}
+	nets := t.nets[:0]
+	for _, r := range t.nets {
+		if !r.expired(now, t.ttl) {
+			nets = append(nets, r)
+		}
+	}
+	t.nets = nets
+}
+
+// Close unregisters the routing table.
+func (t *RouteTable) Close() error {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	if tables[t.service] == t {
+		delete(tables, t.service)
+	}
+	return nil
+}
+
+// ParseNet parses the network in CIDR notation or a single IP address.
+func ParseNet(s string) (*net.IPNet, error) {
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		return ipNet, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return hostNet(ip), nil
+	}
+	return nil, ErrInvalidRoute
+}
+
+func hostNet(ip net.IP) *net.IPNet {
+	if v := ip.To4(); v != nil {
+		return &net.IPNet{IP: v, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(128, 128)}
+}
+
+func isHostNet(ipNet *net.IPNet) bool {
+	ones, bits := ipNet.Mask.Size()
+	return bits > 0 && ones == bits
+}
+
+type tunRouteKey [16]byte
+
+func ipToTunRouteKey(ip net.IP) (key tunRouteKey) {
+	copy(key[:], ip.To16())
+	return
+}
@@ -0,0 +1,153 @@
+package tun
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func mustParseNet(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+
+	ipNet, err := ParseNet(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ipNet
+}
+
+func TestRouteTableFind(t *testing.T) {
+	peer1 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 8421}
+	peer2 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 8421}
+	peer3 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 3), Port: 8421}
+
+	rt := NewRouteTable("", time.Minute)
+	rt.Learn(net.ParseIP("10.0.0.2"), peer1, "")
+	rt.Learn(net.ParseIP("10.0.0.3"), peer2, "")
+	rt.Learn(net.ParseIP("fd00::2"), peer1, "")
+	for _, r := range []struct {
+		net     string
+		gateway string
+		peer    net.Addr
+	}{
+		{net: "172.16.0.0/16", gateway: "10.0.0.2"},
+		{net: "172.16.1.0/24", gateway: "10.0.0.3"},
+		{net: "172.17.0.0/16", peer: peer3},
+		{net: "172.18.0.0/16", gateway: "10.0.0.9"},
+		{net: "fd01::/64", gateway: "fd00::2"},
+	} {
+		if err := rt.AddRoute(mustParseNet(t, r.net), net.ParseIP(r.gateway), r.peer, "", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		dst  string
+		want net.Addr
+	}{
+		{name: "host", dst: "10.0.0.2", want: peer1},
+		{name: "host v6", dst: "fd00::2", want: peer1},
+		{name: "gateway", dst: "172.16.2.1", want: peer1},
+		{name: "longest prefix", dst: "172.16.1.1", want: peer2},
+		{name: "peer", dst: "172.17.0.1", want: peer3},
+		{name: "unknown gateway", dst: "172.18.0.1", want: nil},
+		{name: "gateway v6", dst: "fd01::1", want: peer1},
+		{name: "no route", dst: "8.8.8.8", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := rt.Find(net.ParseIP(tt.dst)); v != tt.want {
+				t.Errorf("got %v, want %v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteTableLearn(t *testing.T) {
+	peer1 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 8421}
+	peer2 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 8421}
+	ip := net.ParseIP("10.0.0.2")
+
+	rt := NewRouteTable("", time.Minute)
+	// expire marks the learned route of the ip as unused for longer than the ttl.
+	expire := func() {
+		rt.mu.Lock()
+		atomic.StoreInt64(&rt.hosts[ipToTunRouteKey(ip)].seen, time.Now().Add(-2*time.Minute).UnixNano())
+		rt.mu.Unlock()
+	}
+
+	tests := []struct {
+		name    string
+		fn      func()
+		peer    net.Addr
+		old     net.Addr
+		changed bool
+		want    net.Addr
+	}{
+		{name: "new", peer: peer1, changed: true, want: peer1},
+		{name: "same", peer: peer1, want: peer1},
+		{name: "moved", peer: peer2, old: peer1, changed: true, want: peer2},
+		{name: "expired", fn: expire, peer: peer1, changed: true, want: peer1},
+		{name: "peer deleted", fn: func() { rt.DelPeer(peer1) }, peer: peer2, changed: true, want: peer2},
+		{
+			name: "static",
+			fn: func() {
+				rt.DelRoute(mustParseNet(t, "10.0.0.2"))
+				rt.AddRoute(mustParseNet(t, "10.0.0.2"), nil, peer1, "", 0)
+			},
+			peer: peer2,
+			want: peer1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fn != nil {
+				tt.fn()
+			}
+			old, changed := rt.Learn(ip, tt.peer, "")
+			if old != tt.old || changed != tt.changed {
+				t.Errorf("got %v/%v, want %v/%v", old, changed, tt.old, tt.changed)
+			}
+			if v := rt.Find(ip); v != tt.want {
+				t.Errorf("find: got %v, want %v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteTablePrune(t *testing.T) {
+	peer := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 8421}
+
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		seen   time.Duration
+		static time.Duration
+		routes int
+	}{
+		{name: "used", ttl: time.Minute, seen: -time.Second, routes: 2},
+		{name: "unused", ttl: time.Minute, seen: -2 * time.Minute, routes: 1},
+		{name: "no ttl", ttl: 0, seen: -time.Hour, routes: 2},
+		{name: "static expired", ttl: 0, seen: -time.Hour, static: time.Nanosecond, routes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewRouteTable("", tt.ttl)
+			rt.Learn(net.ParseIP("10.0.0.2"), peer, "")
+			rt.hosts[ipToTunRouteKey(net.ParseIP("10.0.0.2"))].seen = time.Now().Add(tt.seen).UnixNano()
+			if err := rt.AddRoute(mustParseNet(t, "172.16.0.0/16"), net.ParseIP("10.0.0.2"), nil, "", tt.static); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Millisecond)
+
+			if n := len(rt.Routes()); n != tt.routes {
+				t.Errorf("got %d routes, want %d", n, tt.routes)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package stack
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	natPortMin = 10000
+	natPortMax = 65535
+)
+
+var (
+	errNATExhausted = errors.New("stack: NAT ports exhausted")
+)
+
+// natEntry maps a TCP connection from the tun device to the port of the NAT address.
+type natEntry struct {
+	src  *net.TCPAddr
+	dst  *net.TCPAddr
+	port uint16
+	// active is set while the connection is accepted and not closed.
+	active bool
+	seen   time.Time
+}
+
+// natTable is the table of the TCP connections redirected to the local listener.
+type natTable struct {
+	entries map[string]*natEntry
+	ports   map[uint16]*natEntry
+	next    uint16
+	timeout time.Duration
+	mu      sync.Mutex
+}
+
+func newNATTable(timeout time.Duration) *natTable {
+	return &natTable{
+		entries: make(map[string]*natEntry),
+		ports:   make(map[uint16]*natEntry),
+		next:    natPortMin,
+		timeout: timeout,
+	}
+}
+
+func natKey(src net.IP, srcPort uint16, dst net.IP, dstPort uint16) string {
+	return net.JoinHostPort(src.String(), strconv.Itoa(int(srcPort))) + "-" +
+		net.JoinHostPort(dst.String(), strconv.Itoa(int(dstPort)))
+}
+
+// get returns the entry of the connection, a new port is allocated for the new connection.
+func (t *natTable) get(src net.IP, srcPort uint16, dst net.IP, dstPort uint16) (*natEntry, error) {
+	key := natKey(src, srcPort, dst, dstPort)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e := t.entries[key]; e != nil {
+		e.seen = now
+		return e, nil
+	}
+
+	port, ok := t.alloc(now)
+	if !ok {
+		return nil, errNATExhausted
+	}
+
+	e := &natEntry{
+		src:  &net.TCPAddr{IP: append(net.IP(nil), src...), Port: int(srcPort)},
+		dst:  &net.TCPAddr{IP: append(net.IP(nil), dst...), Port: int(dstPort)},
+		port: port,
+		seen: now,
+	}
+	t.entries[key] = e
+	t.ports[port] = e
+	return e, nil
+}
+
+// alloc returns the next unused port, the inactive entries out of the timeout are recycled.
+func (t *natTable) alloc(now time.Time) (uint16, bool) {
+	for i := 0; i <= natPortMax-natPortMin; i++ {
+		port := t.next
+		if t.next == natPortMax {
+			t.next = natPortMin
+		} else {
+			t.next++
+		}
+
+		e := t.ports[port]
+		if e == nil {
+			return port, true
+		}
+		if !e.active && now.Sub(e.seen) > t.timeout {
+			t.remove(e)
+			return port, true
+		}
+	}
+	return 0, false
+}
+
+// lookup returns the entry of the NAT port.
+func (t *natTable) lookup(port uint16) *natEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.ports[port]
+	if e != nil {
+		e.seen = time.Now()
+	}
+	return e
+}
+
+func (t *natTable) setActive(e *natEntry, active bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e.active = active
+	e.seen = time.Now()
+}
+
+func (t *natTable) remove(e *natEntry) {
+	delete(t.entries, natKey(e.src.IP, uint16(e.src.Port), e.dst.IP, uint16(e.dst.Port)))
+	if t.ports[e.port] == e {
+		delete(t.ports, e.port)
+	}
+}
+
+// prune removes the inactive entries out of the timeout.
+func (t *natTable) prune() {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, e := range t.ports {
+		if !e.active && now.Sub(e.seen) > t.timeout {
+			t.remove(e)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package stack
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNATAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		cidr string
+		want string
+	}{
+		{name: "next", cidr: "10.0.0.1/24", want: "10.0.0.2"},
+		{name: "before broadcast", cidr: "10.0.0.254/24", want: "10.0.0.253"},
+		{name: "slash 30", cidr: "10.0.0.1/30", want: "10.0.0.2"},
+		{name: "slash 30 before broadcast", cidr: "10.0.0.2/30", want: "10.0.0.1"},
+		{name: "slash 31", cidr: "10.0.0.0/31", want: ""},
+		{name: "slash 32", cidr: "10.0.0.1/32", want: ""},
+		{name: "ipv6 next", cidr: "fd00::1/64", want: "fd00::2"},
+		{name: "ipv6 last", cidr: "fd00::ffff:ffff:ffff:ffff/64", want: "fd00::ffff:ffff:ffff:fffe"},
+		{name: "ipv6 slash 127", cidr: "fd00::1/127", want: ""},
+		{name: "ipv6 slash 126", cidr: "fd00::1/126", want: "fd00::2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, ipNet, err := net.ParseCIDR(tt.cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := ip.To4(); v != nil {
+				ip = v
+			}
+
+			got := natAddr(ip, ipNet)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("got %s, want none", got)
+				}
+				return
+			}
+			if !got.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNATTable(t *testing.T) {
+	src, dst := net.ParseIP("10.0.0.2"), net.ParseIP("1.1.1.1")
+	nt := newNATTable(time.Minute)
+
+	e1, err := nt.get(src, 40000, dst, 443)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stale marks the entry as unused for longer than the timeout.
+	stale := func(e *natEntry) func() {
+		return func() { e.seen = time.Now().Add(-2 * time.Minute) }
+	}
+
+	tests := []struct {
+		name string
+		fn   func()
+		// port is looked up after fn, zero means the entry of the port is removed.
+		port  uint16
+		entry *natEntry
+	}{
+		{name: "lookup", port: e1.port, entry: e1},
+		{name: "get again", fn: func() {
+			if e, _ := nt.get(src, 40000, dst, 443); e != e1 {
+				t.Errorf("got another entry %v", e)
+			}
+		}, port: e1.port, entry: e1},
+		{name: "active not pruned", fn: func() { nt.setActive(e1, true); stale(e1)(); nt.prune() }, port: e1.port, entry: e1},
+		{name: "recently closed not pruned", fn: func() { nt.setActive(e1, false); nt.prune() }, port: e1.port, entry: e1},
+		{name: "expired pruned", fn: func() { stale(e1)(); nt.prune() }, port: e1.port, entry: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fn != nil {
+				tt.fn()
+			}
+			if e := nt.lookup(tt.port); e != tt.entry {
+				t.Errorf("got %v, want %v", e, tt.entry)
+			}
+		})
+	}
+
+	if e, _ := nt.get(src, 40000, dst, 443); e == e1 {
+		t.Error("got the pruned entry")
+	}
+}
+
+func TestNATTableAlloc(t *testing.T) {
+	src, dst := net.ParseIP("10.0.0.2"), net.ParseIP("1.1.1.1")
+
+	tests := []struct {
+		name string
+		// setup fills the table before the allocation.
+		setup func(nt *natTable)
+		port  uint16
+		err   error
+	}{
+		{name: "first", port: natPortMin},
+		{name: "wraparound", setup: func(nt *natTable) { nt.next = natPortMax }, port: natPortMax},
+		{
+			name: "wraparound after max",
+			setup: func(nt *natTable) {
+				nt.next = natPortMax
+				nt.get(src, 1, dst, 443)
+			},
+			port: natPortMin,
+		},
+		{
+			name: "used port skipped",
+			setup: func(nt *natTable) {
+				nt.get(src, 1, dst, 443)
+				nt.next = natPortMin
+			},
+			port: natPortMin + 1,
+		},
+		{
+			name: "stale port recycled",
+			setup: func(nt *natTable) {
+				e, _ := nt.get(src, 1, dst, 443)
+				e.seen = time.Now().Add(-2 * time.Minute)
+				nt.next = natPortMin
+			},
+			port: natPortMin,
+		},
+		{
+			name: "exhausted",
+			setup: func(nt *natTable) {
+				for i := 0; i <= natPortMax-natPortMin; i++ {
+					e, _ := nt.get(src, uint16(i), dst, 80)
+					e.active = true
+				}
+			},
+			err: errNATExhausted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nt := newNATTable(time.Minute)
+			if tt.setup != nil {
+				tt.setup(nt)
+			}
+
+			e, err := nt.get(src, 40000, dst, 443)
+			if err != tt.err {
+				t.Fatalf("err: got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if e.port != tt.port {
+				t.Errorf("got port %d, want %d", e.port, tt.port)
+			}
+			if nt.lookup(e.port) != e {
+				t.Error("the port is not mapped to the entry")
+			}
+		})
+	}
+}
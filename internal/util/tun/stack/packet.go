@@ -0,0 +1,208 @@
+package stack
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const (
+	protoTCP = 6
+	protoUDP = 17
+
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	udpHeaderLen  = 8
+	tcpHeaderLen  = 20
+)
+
+var (
+	errBadPacket         = errors.New("stack: bad packet")
+	errUnsupportedPacket = errors.New("stack: unsupported packet")
+)
+
+// packet is an IPv4 or IPv6 packet carrying a TCP segment or a UDP datagram,
+// the addresses and ports can be rewritten in place.
+type packet struct {
+	b         []byte
+	ipv6      bool
+	proto     uint8
+	headerLen int
+}
+
+func parsePacket(b []byte) (*packet, error) {
+	if len(b) == 0 {
+		return nil, errBadPacket
+	}
+
+	p := &packet{b: b}
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < ipv4HeaderLen {
+			return nil, errBadPacket
+		}
+		p.headerLen = int(b[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(b[2:]))
+		if p.headerLen < ipv4HeaderLen || totalLen < p.headerLen || len(b) < totalLen {
+			return nil, errBadPacket
+		}
+		p.b = b[:totalLen]
+		// fragments are not reassembled.
+		if binary.BigEndian.Uint16(b[6:])&0x3fff != 0 {
+			return nil, errUnsupportedPacket
+		}
+		p.proto = b[9]
+	case 6:
+		if len(b) < ipv6HeaderLen {
+			return nil, errBadPacket
+		}
+		p.ipv6 = true
+		p.headerLen = ipv6HeaderLen
+		totalLen := ipv6HeaderLen + int(binary.BigEndian.Uint16(b[4:]))
+		if len(b) < totalLen {
+			return nil, errBadPacket
+		}
+		p.b = b[:totalLen]
+		// extension headers are not supported.
+		p.proto = b[6]
+	default:
+		return nil, errBadPacket
+	}
+
+	switch p.proto {
+	case protoTCP:
+		if len(p.b) < p.headerLen+tcpHeaderLen {
+			return nil, errBadPacket
+		}
+	case protoUDP:
+		if len(p.b) < p.headerLen+udpHeaderLen {
+			return nil, errBadPacket
+		}
+	default:
+		return nil, errUnsupportedPacket
+	}
+	return p, nil
+}
+
+func (p *packet) srcIP() net.IP {
+	if p.ipv6 {
+		return net.IP(p.b[8:24])
+	}
+	return net.IP(p.b[12:16])
+}
+
+func (p *packet) dstIP() net.IP {
+	if p.ipv6 {
+		return net.IP(p.b[24:40])
+	}
+	return net.IP(p.b[16:20])
+}
+
+func (p *packet) srcPort() uint16 {
+	return binary.BigEndian.Uint16(p.b[p.headerLen:])
+}
+
+func (p *packet) dstPort() uint16 {
+	return binary.BigEndian.Uint16(p.b[p.headerLen+2:])
+}
+
+func (p *packet) payload() []byte {
+	if p.proto == protoUDP {
+		return p.b[p.headerLen+udpHeaderLen:]
+	}
+	return nil
+}
+
+// rewrite sets the addresses and ports of the packet and updates the checksums.
+func (p *packet) rewrite(src net.IP, srcPort uint16, dst net.IP, dstPort uint16) {
+	if p.ipv6 {
+		copy(p.b[8:24], src.To16())
+		copy(p.b[24:40], dst.To16())
+	} else {
+		copy(p.b[12:16], src.To4())
+		copy(p.b[16:20], dst.To4())
+	}
+	binary.BigEndian.PutUint16(p.b[p.headerLen:], srcPort)
+	binary.BigEndian.PutUint16(p.b[p.headerLen+2:], dstPort)
+	p.checksum()
+}
+
+// checksum computes the checksums of the IP header and the transport header.
+func (p *packet) checksum() {
+	if !p.ipv6 {
+		p.b[10], p.b[11] = 0, 0
+		binary.BigEndian.PutUint16(p.b[10:], ^fold(sum(p.b[:p.headerLen], 0)))
+	}
+
+	seg := p.b[p.headerLen:]
+	off := 16 // TCP checksum offset
+	if p.proto == protoUDP {
+		off = 6
+	}
+	seg[off], seg[off+1] = 0, 0
+
+	var s uint32
+	if p.ipv6 {
+		s = sum(p.b[8:40], 0)
+	} else {
+		s = sum(p.b[12:20], 0)
+	}
+	s += uint32(p.proto) + uint32(len(seg))
+	cs := ^fold(sum(seg, s))
+	if cs == 0 && p.proto == protoUDP {
+		cs = 0xffff
+	}
+	binary.BigEndian.PutUint16(seg[off:], cs)
+}
+
+// newUDPPacket builds the IP packet of the UDP datagram.
+func newUDPPacket(src *net.UDPAddr, dst *net.UDPAddr, payload []byte, id uint16) []byte {
+	ipv6 := src.IP.To4() == nil
+	headerLen := ipv4HeaderLen
+	if ipv6 {
+		headerLen = ipv6HeaderLen
+	}
+
+	b := make([]byte, headerLen+udpHeaderLen+len(payload))
+	if ipv6 {
+		b[0] = 0x60
+		binary.BigEndian.PutUint16(b[4:], uint16(udpHeaderLen+len(payload)))
+		b[6] = protoUDP
+		b[7] = 64
+	} else {
+		b[0] = 0x45
+		binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+		binary.BigEndian.PutUint16(b[4:], id)
+		b[8] = 64
+		b[9] = protoUDP
+	}
+	binary.BigEndian.PutUint16(b[headerLen+4:], uint16(udpHeaderLen+len(payload)))
+	copy(b[headerLen+udpHeaderLen:], payload)
+
+	p := &packet{
+		b:         b,
+		ipv6:      ipv6,
+		proto:     protoUDP,
+		headerLen: headerLen,
+	}
+	p.rewrite(src.IP, uint16(src.Port), dst.IP, uint16(dst.Port))
+	return b
+}
+
+func sum(b []byte, s uint32) uint32 {
+	n := len(b)
+	for i := 0; i+1 < n; i += 2 {
+		s += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if n%2 == 1 {
+		s += uint32(b[n-1]) << 8
+	}
+	return s
+}
+
+func fold(s uint32) uint16 {
+	for s>>16 != 0 {
+		s = s&0xffff + s>>16
+	}
+	return uint16(s)
+}
@@ -0,0 +1,174 @@
+package stack
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// buildPacket serializes the TCP or UDP packet by gopacket with the checksums computed, as the reference.
+func buildPacket(t *testing.T, proto uint8, src, dst *net.UDPAddr, payload []byte, id uint16) []byte {
+	t.Helper()
+
+	var network interface {
+		gopacket.SerializableLayer
+		gopacket.NetworkLayer
+	}
+	if src.IP.To4() != nil {
+		network = &layers.IPv4{
+			Version:  4,
+			Id:       id,
+			TTL:      64,
+			Protocol: layers.IPProtocol(proto),
+			SrcIP:    src.IP.To4(),
+			DstIP:    dst.IP.To4(),
+		}
+	} else {
+		network = &layers.IPv6{
+			Version:    6,
+			HopLimit:   64,
+			NextHeader: layers.IPProtocol(proto),
+			SrcIP:      src.IP,
+			DstIP:      dst.IP,
+		}
+	}
+
+	var transport interface {
+		gopacket.SerializableLayer
+		SetNetworkLayerForChecksum(gopacket.NetworkLayer) error
+	}
+	if proto == protoTCP {
+		transport = &layers.TCP{
+			SrcPort: layers.TCPPort(src.Port),
+			DstPort: layers.TCPPort(dst.Port),
+			Seq:     1000,
+			Ack:     2000,
+			ACK:     true,
+			PSH:     true,
+			Window:  65535,
+		}
+	} else {
+		transport = &layers.UDP{
+			SrcPort: layers.UDPPort(src.Port),
+			DstPort: layers.UDPPort(dst.Port),
+		}
+	}
+	transport.SetNetworkLayerForChecksum(network)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, network, transport, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func udpAddr(s string) *net.UDPAddr {
+	addr, _ := net.ResolveUDPAddr("udp", s)
+	return addr
+}
+
+func TestPacketRewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		proto   uint8
+		src     string
+		dst     string
+		newSrc  string
+		newDst  string
+		payload []byte
+	}{
+		{name: "tcp4", proto: protoTCP, src: "10.0.0.2:40000", dst: "1.1.1.1:443", newSrc: "10.0.0.3:10000", newDst: "10.0.0.1:8080", payload: []byte("hello")},
+		{name: "tcp4 empty", proto: protoTCP, src: "10.0.0.2:40000", dst: "1.1.1.1:443", newSrc: "10.0.0.3:10000", newDst: "10.0.0.1:8080"},
+		{name: "udp4", proto: protoUDP, src: "10.0.0.2:5353", dst: "8.8.8.8:53", newSrc: "8.8.8.8:53", newDst: "10.0.0.2:5353", payload: []byte("query")},
+		{name: "udp4 even", proto: protoUDP, src: "10.0.0.2:5353", dst: "8.8.8.8:53", newSrc: "192.168.1.1:1", newDst: "172.16.0.1:65535", payload: []byte("even")},
+		{name: "tcp6", proto: protoTCP, src: "[fd00::2]:40000", dst: "[2001:db8::1]:443", newSrc: "[fd00::3]:10000", newDst: "[fd00::1]:8080", payload: []byte("hello")},
+		{name: "udp6", proto: protoUDP, src: "[fd00::2]:5353", dst: "[2001:db8::53]:53", newSrc: "[2001:db8::53]:53", newDst: "[fd00::2]:5353", payload: []byte("query")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := udpAddr(tt.src), udpAddr(tt.dst)
+			newSrc, newDst := udpAddr(tt.newSrc), udpAddr(tt.newDst)
+
+			p, err := parsePacket(buildPacket(t, tt.proto, src, dst, tt.payload, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.proto != tt.proto || !p.srcIP().Equal(src.IP) || int(p.srcPort()) != src.Port ||
+				!p.dstIP().Equal(dst.IP) || int(p.dstPort()) != dst.Port {
+				t.Fatalf("parse: got %s:%d -> %s:%d", p.srcIP(), p.srcPort(), p.dstIP(), p.dstPort())
+			}
+
+			p.rewrite(newSrc.IP, uint16(newSrc.Port), newDst.IP, uint16(newDst.Port))
+			if want := buildPacket(t, tt.proto, newSrc, newDst, tt.payload, 1); !bytes.Equal(p.b, want) {
+				t.Errorf("rewrite:\ngot  %x\nwant %x", p.b, want)
+			}
+
+			// rewriting back restores the original packet.
+			p.rewrite(src.IP, uint16(src.Port), dst.IP, uint16(dst.Port))
+			if want := buildPacket(t, tt.proto, src, dst, tt.payload, 1); !bytes.Equal(p.b, want) {
+				t.Errorf("rewrite back:\ngot  %x\nwant %x", p.b, want)
+			}
+		})
+	}
+}
+
+func TestNewUDPPacket(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		dst     string
+		payload []byte
+		id      uint16
+	}{
+		{name: "ipv4", src: "8.8.8.8:53", dst: "10.0.0.2:5353", payload: []byte("answer"), id: 7},
+		{name: "ipv4 odd", src: "8.8.8.8:53", dst: "10.0.0.2:5353", payload: []byte("odd"), id: 0xffff},
+		{name: "ipv4 empty", src: "8.8.8.8:53", dst: "10.0.0.2:5353", id: 1},
+		{name: "ipv6", src: "[2001:db8::53]:53", dst: "[fd00::2]:5353", payload: []byte("answer")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := udpAddr(tt.src), udpAddr(tt.dst)
+			b := newUDPPacket(src, dst, tt.payload, tt.id)
+			if want := buildPacket(t, protoUDP, src, dst, tt.payload, tt.id); !bytes.Equal(b, want) {
+				t.Errorf("got  %x\nwant %x", b, want)
+			}
+		})
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	tcp4 := buildPacket(t, protoTCP, udpAddr("10.0.0.2:1"), udpAddr("10.0.0.1:2"), nil, 1)
+	fragment := append([]byte(nil), tcp4...)
+	fragment[6] |= 0x20 // more fragments
+
+	tests := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{name: "tcp4", b: tcp4},
+		{name: "trailing bytes", b: append(append([]byte(nil), tcp4...), 0, 0)},
+		{name: "empty", b: nil, err: errBadPacket},
+		{name: "short", b: tcp4[:ipv4HeaderLen+tcpHeaderLen-1], err: errBadPacket},
+		{name: "fragment", b: fragment, err: errUnsupportedPacket},
+		{name: "version", b: append([]byte{0x50}, tcp4[1:]...), err: errBadPacket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePacket(tt.b)
+			if err != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err == nil && len(p.b) != len(tcp4) {
+				t.Errorf("got %d bytes, want %d", len(p.b), len(tcp4))
+			}
+		})
+	}
+}
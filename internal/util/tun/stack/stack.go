@@ -0,0 +1,389 @@
+// Package stack is the TCP/IP stack of the tun device which turns the IP packets into TCP connections and UDP flows.
+//
+// The UDP datagrams are handled in user space. The TCP segments are translated to the address of the tun device
+// and delivered back to the tun device, then the connections are accepted by a local listener,
+// the segments of the listener are translated back to the original addresses of the connection,
+// so the reassembly, retransmission and flow control are done by the TCP stack of the system.
+package stack
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	defaultMTU        = 1500
+	defaultTCPTimeout = time.Minute
+	udpFlowBacklog    = 64
+)
+
+var (
+	ErrInvalidNet = errors.New("stack: invalid network of the tun device")
+)
+
+// Handler handles the TCP connections and the UDP flows of the stack.
+// The LocalAddr of the connection is the original destination and the RemoteAddr is the source on the tun device.
+type Handler interface {
+	HandleTCP(conn net.Conn)
+	HandleUDP(conn net.Conn)
+}
+
+type Options struct {
+	// Net is the IPv4 address of the tun device in CIDR notation.
+	Net string
+	// Net6 is the IPv6 address of the tun device in CIDR notation.
+	Net6 string
+	MTU  int
+	// TCPTimeout is the time the closed TCP connections are kept in the NAT table.
+	TCPTimeout time.Duration
+	Logger     logger.Logger
+}
+
+// family is the NAT of an IP version.
+type family struct {
+	// ip is the address of the tun device which the local listener listens on.
+	ip net.IP
+	// natIP is the address the redirected connections come from,
+	// it is another address in the network of the tun device so that the replies are routed back to the tun device.
+	natIP net.IP
+	port  uint16
+	ln    *net.TCPListener
+}
+
+type Stack struct {
+	dev     io.ReadWriter
+	handler Handler
+	v4      *family
+	v6      *family
+	nat     *natTable
+	flows   map[string]*udpFlow
+	flowsMu sync.Mutex
+	ipID    uint32
+	writeMu sync.Mutex
+	options Options
+}
+
+// New creates the stack on the tun device, the local listeners are created on the addresses of the tun device.
+func New(dev io.ReadWriter, handler Handler, opts Options) (*Stack, error) {
+	if opts.MTU <= 0 {
+		opts.MTU = defaultMTU
+	}
+	if opts.TCPTimeout <= 0 {
+		opts.TCPTimeout = defaultTCPTimeout
+	}
+
+	s := &Stack{
+		dev:     dev,
+		handler: handler,
+		nat:     newNATTable(opts.TCPTimeout),
+		flows:   make(map[string]*udpFlow),
+		options: opts,
+	}
+
+	var err error
+	if opts.Net != "" {
+		if s.v4, err = newFamily("tcp4", opts.Net); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Net6 != "" {
+		if s.v6, err = newFamily("tcp6", opts.Net6); err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+	if s.v4 == nil && s.v6 == nil {
+		return nil, ErrInvalidNet
+	}
+	return s, nil
+}
+
+func newFamily(network, cidr string) (*family, error) {
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if v := ip.To4(); v != nil {
+		ip = v
+	}
+	natIP := natAddr(ip, ipNet)
+	if natIP == nil {
+		return nil, ErrInvalidNet
+	}
+
+	ln, err := net.ListenTCP(network, &net.TCPAddr{IP: ip})
+	if err != nil {
+		return nil, err
+	}
+	return &family{
+		ip:    ip,
+		natIP: natIP,
+		port:  uint16(ln.Addr().(*net.TCPAddr).Port),
+		ln:    ln,
+	}, nil
+}
+
+// natAddr returns the next address of the ip in the network, or the previous one if it is the last one.
+func natAddr(ip net.IP, ipNet *net.IPNet) net.IP {
+	ones, bits := ipNet.Mask.Size()
+	// the network of the point-to-point link has no room for the NAT address.
+	if bits-ones < 2 {
+		return nil
+	}
+
+	n := new(big.Int).SetBytes(ip)
+	for _, d := range []int64{1, -1} {
+		v := new(big.Int).Add(n, big.NewInt(d)).Bytes()
+		addr := make(net.IP, len(ip))
+		copy(addr[len(addr)-len(v):], v)
+		if !ipNet.Contains(addr) || addr.Equal(ipNet.IP) {
+			continue
+		}
+		// the broadcast address of IPv4.
+		if len(addr) == net.IPv4len && addr.Equal(broadcast(ipNet)) {
+			continue
+		}
+		return addr
+	}
+	return nil
+}
+
+func broadcast(ipNet *net.IPNet) net.IP {
+	ip := ipNet.IP.To4()
+	b := make(net.IP, len(ip))
+	for i := range ip {
+		b[i] = ip[i] | ^ipNet.Mask[len(ipNet.Mask)-len(ip)+i]
+	}
+	return b
+}
+
+// Run reads the packets from the tun device and dispatches the connections to the handler until ctx is done
+// or the tun device is closed.
+func (s *Stack) Run(ctx context.Context) error {
+	defer s.close()
+
+	for _, f := range []*family{s.v4, s.v6} {
+		if f != nil {
+			go s.accept(f)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.prune(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.close()
+		case <-done:
+		}
+	}()
+
+	for {
+		err := func() error {
+			b := bufpool.Get(s.options.MTU)
+			defer bufpool.Put(b)
+
+			n, err := s.dev.Read(*b)
+			if err != nil {
+				return err
+			}
+
+			p, err := parsePacket((*b)[:n])
+			if err != nil {
+				if err != errUnsupportedPacket {
+					s.options.Logger.Debug(err)
+				}
+				return nil
+			}
+
+			switch p.proto {
+			case protoTCP:
+				s.handleTCP(p)
+			case protoUDP:
+				s.handleUDP(p)
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Stack) family(p *packet) *family {
+	if p.ipv6 {
+		return s.v6
+	}
+	return s.v4
+}
+
+func (s *Stack) handleTCP(p *packet) {
+	f := s.family(p)
+	if f == nil {
+		return
+	}
+
+	// the segments of the local listener are sent back to the source with the original destination.
+	if p.srcIP().Equal(f.ip) && p.srcPort() == f.port {
+		e := s.nat.lookup(p.dstPort())
+		if e == nil {
+			return
+		}
+		p.rewrite(e.dst.IP, uint16(e.dst.Port), e.src.IP, uint16(e.src.Port))
+		s.write(p.b)
+		return
+	}
+
+	e, err := s.nat.get(p.srcIP(), p.srcPort(), p.dstIP(), p.dstPort())
+	if err != nil {
+		s.options.Logger.Warn(err)
+		return
+	}
+	p.rewrite(f.natIP, e.port, f.ip, f.port)
+	s.write(p.b)
+}
+
+func (s *Stack) accept(f *family) {
+	for {
+		conn, err := f.ln.AcceptTCP()
+		if err != nil {
+			return
+		}
+
+		raddr, _ := conn.RemoteAddr().(*net.TCPAddr)
+		var e *natEntry
+		if raddr != nil && raddr.IP.Equal(f.natIP) {
+			e = s.nat.lookup(uint16(raddr.Port))
+		}
+		if e == nil {
+			conn.Close()
+			continue
+		}
+
+		s.nat.setActive(e, true)
+		go s.handler.HandleTCP(&tcpConn{
+			TCPConn: conn,
+			entry:   e,
+			nat:     s.nat,
+		})
+	}
+}
+
+func (s *Stack) handleUDP(p *packet) {
+	src := &net.UDPAddr{IP: append(net.IP(nil), p.srcIP()...), Port: int(p.srcPort())}
+	dst := &net.UDPAddr{IP: append(net.IP(nil), p.dstIP()...), Port: int(p.dstPort())}
+	key := src.String() + "-" + dst.String()
+
+	s.flowsMu.Lock()
+	flow := s.flows[key]
+	if flow == nil {
+		flow = newUDPFlow(s, key, src, dst)
+		s.flows[key] = flow
+		go s.handler.HandleUDP(flow)
+	}
+	s.flowsMu.Unlock()
+
+	flow.push(append([]byte(nil), p.payload()...))
+}
+
+func (s *Stack) delFlow(flow *udpFlow) {
+	s.flowsMu.Lock()
+	defer s.flowsMu.Unlock()
+
+	if s.flows[flow.key] == flow {
+		delete(s.flows, flow.key)
+	}
+}
+
+// writeUDP sends the datagram to the tun device.
+func (s *Stack) writeUDP(src, dst *net.UDPAddr, b []byte) (int, error) {
+	headerLen := ipv4HeaderLen
+	if dst.IP.To4() == nil {
+		headerLen = ipv6HeaderLen
+	}
+	if headerLen+udpHeaderLen+len(b) > s.options.MTU {
+		return 0, errors.New("stack: datagram too large")
+	}
+
+	pkt := newUDPPacket(src, dst, b, uint16(atomic.AddUint32(&s.ipID, 1)))
+	if err := s.write(pkt); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (s *Stack) write(b []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_, err := s.dev.Write(b)
+	if err != nil {
+		s.options.Logger.Debug(err)
+	}
+	return err
+}
+
+func (s *Stack) prune(done chan struct{}) {
+	ticker := time.NewTicker(s.options.TCPTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.nat.prune()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *Stack) close() {
+	for _, f := range []*family{s.v4, s.v6} {
+		if f != nil {
+			f.ln.Close()
+		}
+	}
+
+	s.flowsMu.Lock()
+	flows := s.flows
+	s.flows = make(map[string]*udpFlow)
+	s.flowsMu.Unlock()
+
+	for _, flow := range flows {
+		flow.Close()
+	}
+}
+
+// tcpConn is the connection accepted by the local listener with the original addresses.
+type tcpConn struct {
+	*net.TCPConn
+	entry *natEntry
+	nat   *natTable
+	once  sync.Once
+}
+
+func (c *tcpConn) LocalAddr() net.Addr {
+	return c.entry.dst
+}
+
+func (c *tcpConn) RemoteAddr() net.Addr {
+	return c.entry.src
+}
+
+func (c *tcpConn) Close() error {
+	c.once.Do(func() {
+		c.nat.setActive(c.entry, false)
+	})
+	return c.TCPConn.Close()
+}
@@ -0,0 +1,106 @@
+package stack
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// udpFlow is the UDP datagrams between a source and a destination on the tun device.
+// Each Read returns a datagram from the source and each Write sends a datagram to the source.
+type udpFlow struct {
+	stack    *Stack
+	key      string
+	src      *net.UDPAddr
+	dst      *net.UDPAddr
+	rc       chan []byte
+	closed   chan struct{}
+	once     sync.Once
+	deadline time.Time
+	mu       sync.Mutex
+}
+
+func newUDPFlow(s *Stack, key string, src, dst *net.UDPAddr) *udpFlow {
+	return &udpFlow{
+		stack:  s,
+		key:    key,
+		src:    src,
+		dst:    dst,
+		rc:     make(chan []byte, udpFlowBacklog),
+		closed: make(chan struct{}),
+	}
+}
+
+// push queues the datagram from the source, it is discarded if the queue is full.
+func (c *udpFlow) push(b []byte) {
+	select {
+	case c.rc <- b:
+	case <-c.closed:
+	default:
+	}
+}
+
+func (c *udpFlow) Read(b []byte) (n int, err error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p := <-c.rc:
+		return copy(b, p), nil
+	case <-c.closed:
+		return 0, io.EOF
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *udpFlow) Write(b []byte) (n int, err error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	return c.stack.writeUDP(c.dst, c.src, b)
+}
+
+func (c *udpFlow) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		c.stack.delFlow(c)
+	})
+	return nil
+}
+
+func (c *udpFlow) LocalAddr() net.Addr {
+	return c.dst
+}
+
+func (c *udpFlow) RemoteAddr() net.Addr {
+	return c.src
+}
+
+func (c *udpFlow) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *udpFlow) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *udpFlow) SetWriteDeadline(t time.Time) error {
+	return nil
+}
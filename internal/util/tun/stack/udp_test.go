@@ -0,0 +1,119 @@
+package stack
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testDevice records the packets written to the tun device.
+type testDevice struct {
+	packets [][]byte
+}
+
+func (d *testDevice) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (d *testDevice) Write(b []byte) (int, error) {
+	d.packets = append(d.packets, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+type testHandler struct {
+	flows chan net.Conn
+}
+
+func (h *testHandler) HandleTCP(conn net.Conn) {}
+
+func (h *testHandler) HandleUDP(conn net.Conn) {
+	h.flows <- conn
+}
+
+func TestStackUDP(t *testing.T) {
+	client, server := udpAddr("10.0.0.2:5353"), udpAddr("8.8.8.8:53")
+	other := udpAddr("10.0.0.2:5354")
+
+	dev := &testDevice{}
+	h := &testHandler{flows: make(chan net.Conn, 4)}
+	s := &Stack{
+		dev:     dev,
+		handler: h,
+		flows:   make(map[string]*udpFlow),
+		options: Options{MTU: 64},
+	}
+
+	var flows []net.Conn
+	// push sends the datagram from the tun device, the new flow is appended to flows.
+	push := func(src, dst *net.UDPAddr, payload string) {
+		p, err := parsePacket(buildPacket(t, protoUDP, src, dst, []byte(payload), 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.handleUDP(p)
+		select {
+		case flow := <-h.flows:
+			flows = append(flows, flow)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	tests := []struct {
+		name    string
+		src     *net.UDPAddr
+		payload string
+		flows   int
+	}{
+		{name: "new flow", src: client, payload: "q1", flows: 1},
+		{name: "same flow", src: client, payload: "q2", flows: 1},
+		{name: "other source", src: other, payload: "q3", flows: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			push(tt.src, server, tt.payload)
+			if len(flows) != tt.flows {
+				t.Fatalf("got %d flows, want %d", len(flows), tt.flows)
+			}
+			flow := flows[tt.flows-1]
+			if flow.RemoteAddr().String() != tt.src.String() || flow.LocalAddr().String() != server.String() {
+				t.Fatalf("got flow %s -> %s", flow.RemoteAddr(), flow.LocalAddr())
+			}
+
+			b := make([]byte, 64)
+			flow.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := flow.Read(b)
+			if err != nil || string(b[:n]) != tt.payload {
+				t.Fatalf("read: got %q, %v", b[:n], err)
+			}
+		})
+	}
+
+	t.Run("reply", func(t *testing.T) {
+		if _, err := flows[0].Write([]byte("answer")); err != nil {
+			t.Fatal(err)
+		}
+		if want := buildPacket(t, protoUDP, server, client, []byte("answer"), 1); len(dev.packets) != 1 || !bytes.Equal(dev.packets[0], want) {
+			t.Errorf("got %x, want %x", dev.packets, want)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		if _, err := flows[0].Write(make([]byte, 64)); err == nil {
+			t.Error("got no error")
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		flows[0].Close()
+		if _, err := flows[0].Write([]byte("answer")); err != net.ErrClosed {
+			t.Errorf("write: got %v, want %v", err, net.ErrClosed)
+		}
+		push(client, server, "q4")
+		if len(flows) != 3 {
+			t.Errorf("got %d flows, want a new flow", len(flows))
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+)
+
+var (
+	ErrTunnelNotFound = errors.New("tunnel: not found")
+	ErrNoConnector    = errors.New("tunnel: no available connector")
+	ErrInvalidID      = errors.New("tunnel: invalid ID")
+	ErrForbidden      = errors.New("tunnel: owned by another user")
+)
+
+var (
+	tunnels   = make(map[string]*Tunnel)
+	tunnelsMu sync.RWMutex
+)
+
+type idKey struct{}
+
+// ContextWithID returns a copy of ctx carrying the tunnel ID,
+// the relay connector binds the tunnel instead of a port if the ID is set.
+func ContextWithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// IDFromContext returns the tunnel ID carried by ctx.
+func IDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(idKey{}).(string)
+	return v
+}
+
+// ValidID reports whether the id can be used as a tunnel ID.
+func ValidID(id string) bool {
+	return id != "" && len(id) <= 255
+}
+
+// Connector is a client connection of the tunnel,
+// each connection to the tunnel is a stream of the mux session.
+type Connector struct {
+	addr    net.Addr
+	session *mux.Session
+}
+
+func NewConnector(addr net.Addr, session *mux.Session) *Connector {
+	return &Connector{
+		addr:    addr,
+		session: session,
+	}
+}
+
+// Addr returns the address of the client.
+func (c *Connector) Addr() net.Addr {
+	return c.addr
+}
+
+func (c *Connector) Dial() (net.Conn, error) {
+	return c.session.GetConn()
+}
+
+func (c *Connector) IsClosed() bool {
+	return c.session.IsClosed()
+}
+
+// NumStreams returns the number of the connections opened on the connector.
+func (c *Connector) NumStreams() int {
+	return c.session.NumStreams()
+}
+
+func (c *Connector) Close() error {
+	return c.session.Close()
+}
+
+// Info is the snapshot of the Tunnel.
+// swagger:model TunnelInfo
+type Info struct {
+	ID         string          `json:"id"`
+	Owner      string          `json:"owner,omitempty"`
+	Connectors []ConnectorInfo `json:"connectors"`
+}
+
+type ConnectorInfo struct {
+	Addr    string `json:"addr"`
+	Streams int    `json:"streams"`
+}
+
+// Tunnel is a named tunnel served by one or more connectors,
+// the connections are balanced over the connectors in round-robin order.
+type Tunnel struct {
+	id         string
+	owner      string
+	connectors []*Connector
+	n          uint32
+	mu         sync.RWMutex
+}
+
+func (t *Tunnel) ID() string {
+	return t.id
+}
+
+// Owner returns the user who registered the tunnel.
+func (t *Tunnel) Owner() string {
+	return t.owner
+}
+
+// Connectors returns the current connectors of the tunnel.
+func (t *Tunnel) Connectors() []*Connector {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return append([]*Connector(nil), t.connectors...)
+}
+
+func (t *Tunnel) Info() Info {
+	info := Info{
+		ID:         t.id,
+		Owner:      t.owner,
+		Connectors: []ConnectorInfo{},
+	}
+	for _, c := range t.Connectors() {
+		info.Connectors = append(info.Connectors, ConnectorInfo{
+			Addr:    c.Addr().String(),
+			Streams: c.NumStreams(),
+		})
+	}
+	return info
+}
+
+// Dial opens a connection to one of the connectors,
+// the connectors failed to open the connection are closed and removed, and the next one is tried.
+func (t *Tunnel) Dial() (net.Conn, error) {
+	connectors := t.Connectors()
+	if len(connectors) == 0 {
+		return nil, ErrNoConnector
+	}
+
+	// the index is computed in uint32, int is 32 bits on 32-bit platforms.
+	start := atomic.AddUint32(&t.n, 1)
+	for i := range connectors {
+		c := connectors[(start+uint32(i))%uint32(len(connectors))]
+		if !c.IsClosed() {
+			conn, err := c.Dial()
+			if err == nil {
+				return conn, nil
+			}
+			c.Close()
+		}
+		Del(t.id, c)
+	}
+
+	return nil, ErrNoConnector
+}
+
+// Add adds the connector to the tunnel of the id, the tunnel is created if not exists.
+// The tunnel is owned by the user of the first connector until all the connectors are gone.
+func Add(id, owner string, c *Connector) error {
+	if !ValidID(id) {
+		return ErrInvalidID
+	}
+
+	tunnelsMu.Lock()
+	defer tunnelsMu.Unlock()
+
+	t := tunnels[id]
+	if t == nil {
+		t = &Tunnel{
+			id:    id,
+			owner: owner,
+		}
+		tunnels[id] = t
+	}
+	if t.owner != owner {
+		return ErrForbidden
+	}
+
+	t.mu.Lock()
+	t.connectors = append(t.connectors, c)
+	t.mu.Unlock()
+
+	return nil
+}
+
+// Del removes the connector from the tunnel of the id, the tunnel is deleted with its last connector.
+func Del(id string, c *Connector) {
+	tunnelsMu.Lock()
+	defer tunnelsMu.Unlock()
+
+	t := tunnels[id]
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.connectors {
+		if t.connectors[i] == c {
+			t.connectors = append(t.connectors[:i], t.connectors[i+1:]...)
+			break
+		}
+	}
+	if len(t.connectors) == 0 {
+		delete(tunnels, id)
+	}
+}
+
+// Allowed reports whether the owner can add connectors to the tunnel of the id.
+func Allowed(id, owner string) bool {
+	t := Get(id)
+	return t == nil || t.owner == owner
+}
+
+func Get(id string) *Tunnel {
+	tunnelsMu.RLock()
+	defer tunnelsMu.RUnlock()
+
+	return tunnels[id]
+}
+
+// Dial opens a connection to the tunnel of the id.
+func Dial(id string) (net.Conn, error) {
+	t := Get(id)
+	if t == nil {
+		return nil, ErrTunnelNotFound
+	}
+	return t.Dial()
+}
+
+// Tunnels returns the snapshots of the tunnels sorted by the ID.
+func Tunnels() []Info {
+	tunnelsMu.RLock()
+	ts := make([]*Tunnel, 0, len(tunnels))
+	for _, t := range tunnels {
+		ts = append(ts, t)
+	}
+	tunnelsMu.RUnlock()
+
+	infos := make([]Info, 0, len(ts))
+	for _, t := range ts {
+		infos = append(infos, t.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
@@ -0,0 +1,186 @@
+package tunnel
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+)
+
+// newConnector returns a connector whose connections read its name.
+func newConnector(t *testing.T, name string) *Connector {
+	t.Helper()
+
+	// smux may deadlock on the unbuffered net.Pipe, a TCP connection is used instead.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := mux.ServerSession(c1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := mux.ClientSession(c2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := client.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, name)
+			conn.Close()
+		}
+	}()
+
+	return NewConnector(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000 + len(name)}, server)
+}
+
+// dial returns the name of the connector serving the connection.
+func dial(t *testing.T, id string) (string, error) {
+	t.Helper()
+
+	conn, err := Dial(id)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), nil
+}
+
+func TestAddDel(t *testing.T) {
+	const id = "add-del"
+	c1 := newConnector(t, "a")
+	c2 := newConnector(t, "bb")
+
+	if err := Add("", "alice", c1); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("got %v, want %v", err, ErrInvalidID)
+	}
+	if err := Add(strings.Repeat("x", 256), "alice", c1); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("got %v, want %v", err, ErrInvalidID)
+	}
+
+	if err := Add(id, "alice", c1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add(id, "bob", c2); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got %v, want %v", err, ErrForbidden)
+	}
+	if Allowed(id, "bob") || !Allowed(id, "alice") || !Allowed("other", "bob") {
+		t.Error("owner not enforced")
+	}
+	if err := Add(id, "alice", c2); err != nil {
+		t.Fatal(err)
+	}
+
+	tun := Get(id)
+	if tun == nil || tun.Owner() != "alice" || len(tun.Connectors()) != 2 {
+		t.Fatalf("got %+v", tun)
+	}
+	var info *Info
+	for _, v := range Tunnels() {
+		if v.ID == id {
+			info = &v
+		}
+	}
+	if info == nil || len(info.Connectors) != 2 || info.Connectors[0].Addr != "127.0.0.1:10001" {
+		t.Errorf("got info %+v", info)
+	}
+
+	Del(id, c1)
+	// removing a connector twice or from an unknown tunnel is ignored.
+	Del(id, c1)
+	Del("other", c2)
+	if tun := Get(id); tun == nil || len(tun.Connectors()) != 1 {
+		t.Fatalf("got %+v", tun)
+	}
+
+	// the tunnel and its owner are gone with the last connector.
+	Del(id, c2)
+	if Get(id) != nil {
+		t.Error("tunnel not deleted")
+	}
+	if !Allowed(id, "bob") {
+		t.Error("owner kept")
+	}
+	if _, err := Dial(id); !errors.Is(err, ErrTunnelNotFound) {
+		t.Errorf("got %v, want %v", err, ErrTunnelNotFound)
+	}
+}
+
+func TestTunnelDial(t *testing.T) {
+	const id = "dial"
+	connectors := map[string]*Connector{}
+	for _, name := range []string{"a", "b", "c"} {
+		connectors[name] = newConnector(t, name)
+		if err := Add(id, "", connectors[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next := func(names ...string) {
+		t.Helper()
+
+		var got []string
+		for range names {
+			name, err := dial(t, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, name)
+		}
+		if strings.Join(got, ",") != strings.Join(names, ",") {
+			t.Errorf("got %v, want %v", got, names)
+		}
+	}
+
+	// round-robin
+	next("b", "c", "a", "b", "c", "a")
+
+	// the counter wraps around without going out of range.
+	Get(id).n = math.MaxUint32 - 1
+	next("a", "a", "b", "c")
+
+	// failover to the next connector, the closed connector is removed.
+	connectors["c"].Close()
+	next("a", "b", "a", "a", "b")
+	if n := len(Get(id).Connectors()); n != 2 {
+		t.Errorf("got %d connectors, want 2", n)
+	}
+
+	// the tunnel is removed with the last connector.
+	connectors["a"].Close()
+	connectors["b"].Close()
+	if _, err := dial(t, id); !errors.Is(err, ErrNoConnector) {
+		t.Errorf("got %v, want %v", err, ErrNoConnector)
+	}
+	if Get(id) != nil {
+		t.Error("tunnel not deleted")
+	}
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 	"github.com/miekg/dns"
 )
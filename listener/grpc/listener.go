@@ -7,8 +7,8 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
 	pb "github.com/hxdcloud/gost-x/internal/util/grpc/proto"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
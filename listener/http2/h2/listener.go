@@ -11,7 +11,7 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
@@ -9,8 +9,8 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
 	mdx "github.com/hxdcloud/gost-x/metadata"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 	"golang.org/x/net/http2"
 )
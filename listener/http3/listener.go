@@ -6,10 +6,10 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
 	pht_util "github.com/hxdcloud/gost-x/internal/util/pht"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/lucas-clemente/quic-go"
 )
 
 func init() {
@@ -20,6 +20,7 @@ func init() {
 type http3Listener struct {
 	addr    net.Addr
 	server  *pht_util.Server
+	stats   *quic_util.Stats
 	logger  logger.Logger
 	md      metadata
 	options listener.Options
@@ -46,9 +47,13 @@ func (l *http3Listener) Init(md md.Metadata) (err error) {
 		return
 	}
 
+	l.stats = quic_util.NewStats(l.options.Service)
+	config := l.md.config
+	config.Tracer = l.stats.Tracer
+
 	l.server = pht_util.NewHTTP3Server(
 		l.options.Addr,
-		&quic.Config{},
+		config,
 		pht_util.TLSConfigServerOption(l.options.TLSConfig),
 		pht_util.BacklogServerOption(l.md.backlog),
 		pht_util.PathServerOption(l.md.authorizePath, l.md.pushPath, l.md.pullPath),
@@ -77,5 +82,6 @@ func (l *http3Listener) Addr() net.Addr {
 }
 
 func (l *http3Listener) Close() (err error) {
+	l.stats.Close()
 	return l.server.Close()
 }
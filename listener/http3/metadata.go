@@ -4,7 +4,9 @@ import (
 	"strings"
 
 	mdata "github.com/go-gost/core/metadata"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
 	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/quic-go/quic-go"
 )
 
 const (
@@ -19,9 +21,12 @@ type metadata struct {
 	pushPath      string
 	pullPath      string
 	backlog       int
+	config        *quic.Config
 }
 
 func (l *http3Listener) parseMetadata(md mdata.Metadata) (err error) {
+	l.md.config = quic_util.ConfigFromMetadata(md)
+
 	const (
 		authorizePath = "authorizePath"
 		pushPath      = "pushPath"
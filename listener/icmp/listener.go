@@ -11,7 +11,7 @@ import (
 	metrics "github.com/go-gost/core/metrics/wrapper"
 	icmp_pkg "github.com/hxdcloud/gost-x/internal/util/icmp"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/lucas-clemente/quic-go"
+	"github.com/quic-go/quic-go"
 	"golang.org/x/net/icmp"
 )
 
@@ -20,7 +20,7 @@ func init() {
 }
 
 type icmpListener struct {
-	ln      quic.Listener
+	ln      *quic.Listener
 	cqueue  chan net.Conn
 	errChan chan error
 	logger  logger.Logger
@@ -58,20 +58,10 @@ func (l *icmpListener) Init(md md.Metadata) (err error) {
 	conn = metrics.WrapPacketConn(l.options.Service, conn)
 	conn = admission.WrapPacketConn(l.options.Admission, conn)
 
-	config := &quic.Config{
-		KeepAlive:            l.md.keepAlive,
-		HandshakeIdleTimeout: l.md.handshakeTimeout,
-		MaxIdleTimeout:       l.md.maxIdleTimeout,
-		Versions: []quic.VersionNumber{
-			quic.Version1,
-			quic.VersionDraft29,
-		},
-	}
-
 	tlsCfg := l.options.TLSConfig
 	tlsCfg.NextProtos = []string{"http/3", "quic/v1"}
 
-	ln, err := quic.Listen(conn, tlsCfg, config)
+	ln, err := quic.Listen(conn, tlsCfg, l.md.config)
 	if err != nil {
 		return
 	}
@@ -120,7 +110,7 @@ func (l *icmpListener) listenLoop() {
 	}
 }
 
-func (l *icmpListener) mux(ctx context.Context, session quic.Session) {
+func (l *icmpListener) mux(ctx context.Context, session *quic.Conn) {
 	defer session.CloseWithError(0, "closed")
 
 	for {
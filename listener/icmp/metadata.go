@@ -1,10 +1,10 @@
 package quic
 
 import (
-	"time"
-
 	mdata "github.com/go-gost/core/metadata"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
 	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/quic-go/quic-go"
 )
 
 const (
@@ -12,20 +12,16 @@ const (
 )
 
 type metadata struct {
-	keepAlive        bool
-	handshakeTimeout time.Duration
-	maxIdleTimeout   time.Duration
+	config *quic.Config
 
 	cipherKey []byte
 	backlog   int
 }
 
 func (l *icmpListener) parseMetadata(md mdata.Metadata) (err error) {
-	const (
-		keepAlive        = "keepAlive"
-		handshakeTimeout = "handshakeTimeout"
-		maxIdleTimeout   = "maxIdleTimeout"
+	l.md.config = quic_util.ConfigFromMetadata(md)
 
+	const (
 		backlog = "backlog"
 	)
 
@@ -34,9 +30,5 @@ func (l *icmpListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.backlog = defaultBacklog
 	}
 
-	l.md.keepAlive = mdx.GetBool(md, keepAlive)
-	l.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
-	l.md.maxIdleTimeout = mdx.GetDuration(md, maxIdleTimeout)
-
 	return
 }
@@ -23,6 +23,7 @@ func init() {
 type kcpListener struct {
 	conn    net.PacketConn
 	ln      *kcp.Listener
+	monitor *kcp_util.Monitor
 	cqueue  chan net.Conn
 	errChan chan error
 	logger  logger.Logger
@@ -88,6 +89,7 @@ func (l *kcpListener) Init(md md.Metadata) (err error) {
 
 	l.ln = ln
 	l.conn = conn
+	l.monitor = kcp_util.NewMonitor(l.options.Service, config)
 	l.cqueue = make(chan net.Conn, l.md.backlog)
 	l.errChan = make(chan error, 1)
 
@@ -109,6 +111,7 @@ func (l *kcpListener) Accept() (conn net.Conn, err error) {
 }
 
 func (l *kcpListener) Close() error {
+	l.monitor.Close()
 	l.conn.Close()
 	return l.ln.Close()
 }
@@ -138,12 +141,16 @@ func (l *kcpListener) listenLoop() {
 		conn.SetMtu(l.md.config.MTU)
 		conn.SetWindowSize(l.md.config.SndWnd, l.md.config.RcvWnd)
 		conn.SetACKNoDelay(l.md.config.AckNodelay)
+		l.monitor.Add(conn)
 		go l.mux(conn)
 	}
 }
 
-func (l *kcpListener) mux(conn net.Conn) {
-	defer conn.Close()
+func (l *kcpListener) mux(sess *kcp.UDPSession) {
+	defer sess.Close()
+	defer l.monitor.Remove(sess)
+
+	var conn net.Conn = sess
 
 	smuxConfig := smux.DefaultConfig()
 	smuxConfig.MaxReceiveBuffer = l.md.config.SockBuf
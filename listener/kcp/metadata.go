@@ -1,8 +1,6 @@
 package kcp
 
 import (
-	"encoding/json"
-
 	mdata "github.com/go-gost/core/metadata"
 	kcp_util "github.com/hxdcloud/gost-x/internal/util/kcp"
 	mdx "github.com/hxdcloud/gost-x/metadata"
@@ -20,23 +18,10 @@ type metadata struct {
 func (l *kcpListener) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		backlog = "backlog"
-		config  = "config"
 	)
 
-	if m := mdx.GetStringMap(md, config); len(m) > 0 {
-		b, err := json.Marshal(m)
-		if err != nil {
-			return err
-		}
-		cfg := &kcp_util.Config{}
-		if err := json.Unmarshal(b, cfg); err != nil {
-			return err
-		}
-		l.md.config = cfg
-	}
-
-	if l.md.config == nil {
-		l.md.config = kcp_util.DefaultConfig
+	if l.md.config, err = kcp_util.ConfigFromMetadata(md); err != nil {
+		return
 	}
 
 	l.md.backlog = mdx.GetInt(md, backlog)
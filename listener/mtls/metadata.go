@@ -1,9 +1,8 @@
 package mtls
 
 import (
-	"time"
-
 	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
@@ -12,12 +11,7 @@ const (
 )
 
 type metadata struct {
-	muxKeepAliveDisabled bool
-	muxKeepAliveInterval time.Duration
-	muxKeepAliveTimeout  time.Duration
-	muxMaxFrameSize      int
-	muxMaxReceiveBuffer  int
-	muxMaxStreamBuffer   int
+	muxCfg *mux.Config
 
 	backlog int
 }
@@ -25,13 +19,6 @@ type metadata struct {
 func (l *mtlsListener) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		backlog = "backlog"
-
-		muxKeepAliveDisabled = "muxKeepAliveDisabled"
-		muxKeepAliveInterval = "muxKeepAliveInterval"
-		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
-		muxMaxFrameSize      = "muxMaxFrameSize"
-		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
-		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
 	)
 
 	l.md.backlog = mdx.GetInt(md, backlog)
@@ -39,12 +26,7 @@ func (l *mtlsListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.backlog = defaultBacklog
 	}
 
-	l.md.muxKeepAliveDisabled = mdx.GetBool(md, muxKeepAliveDisabled)
-	l.md.muxKeepAliveInterval = mdx.GetDuration(md, muxKeepAliveInterval)
-	l.md.muxKeepAliveTimeout = mdx.GetDuration(md, muxKeepAliveTimeout)
-	l.md.muxMaxFrameSize = mdx.GetInt(md, muxMaxFrameSize)
-	l.md.muxMaxReceiveBuffer = mdx.GetInt(md, muxMaxReceiveBuffer)
-	l.md.muxMaxStreamBuffer = mdx.GetInt(md, muxMaxStreamBuffer)
+	l.md.muxCfg = mux.ConfigFromMetadata(md)
 
 	return
 }
@@ -10,11 +10,11 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
 	"github.com/gorilla/websocket"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	ws_util "github.com/hxdcloud/gost-x/internal/util/ws"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/xtaci/smux"
 )
 
 func init() {
@@ -151,24 +151,7 @@ func (l *mwsListener) upgrade(w http.ResponseWriter, r *http.Request) {
 func (l *mwsListener) mux(conn net.Conn) {
 	defer conn.Close()
 
-	smuxConfig := smux.DefaultConfig()
-	smuxConfig.KeepAliveDisabled = l.md.muxKeepAliveDisabled
-	if l.md.muxKeepAliveInterval > 0 {
-		smuxConfig.KeepAliveInterval = l.md.muxKeepAliveInterval
-	}
-	if l.md.muxKeepAliveTimeout > 0 {
-		smuxConfig.KeepAliveTimeout = l.md.muxKeepAliveTimeout
-	}
-	if l.md.muxMaxFrameSize > 0 {
-		smuxConfig.MaxFrameSize = l.md.muxMaxFrameSize
-	}
-	if l.md.muxMaxReceiveBuffer > 0 {
-		smuxConfig.MaxReceiveBuffer = l.md.muxMaxReceiveBuffer
-	}
-	if l.md.muxMaxStreamBuffer > 0 {
-		smuxConfig.MaxStreamBuffer = l.md.muxMaxStreamBuffer
-	}
-	session, err := smux.Server(conn, smuxConfig)
+	session, err := mux.ServerSession(conn, l.md.muxCfg)
 	if err != nil {
 		l.logger.Error(err)
 		return
@@ -176,7 +159,7 @@ func (l *mwsListener) mux(conn net.Conn) {
 	defer session.Close()
 
 	for {
-		stream, err := session.AcceptStream()
+		stream, err := session.Accept()
 		if err != nil {
 			l.logger.Error("accept stream: ", err)
 			return
@@ -184,8 +167,6 @@ func (l *mwsListener) mux(conn net.Conn) {
 
 		select {
 		case l.cqueue <- stream:
-		case <-stream.GetDieCh():
-			stream.Close()
 		default:
 			stream.Close()
 			l.logger.Warnf("connection queue is full, client %s discarded", stream.RemoteAddr())
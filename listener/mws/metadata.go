@@ -5,6 +5,7 @@ import (
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
 	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
@@ -24,12 +25,7 @@ type metadata struct {
 	writeBufferSize   int
 	enableCompression bool
 
-	muxKeepAliveDisabled bool
-	muxKeepAliveInterval time.Duration
-	muxKeepAliveTimeout  time.Duration
-	muxMaxFrameSize      int
-	muxMaxReceiveBuffer  int
-	muxMaxStreamBuffer   int
+	muxCfg *mux.Config
 }
 
 func (l *mwsListener) parseMetadata(md mdata.Metadata) (err error) {
@@ -43,13 +39,6 @@ func (l *mwsListener) parseMetadata(md mdata.Metadata) (err error) {
 		readBufferSize    = "readBufferSize"
 		writeBufferSize   = "writeBufferSize"
 		enableCompression = "enableCompression"
-
-		muxKeepAliveDisabled = "muxKeepAliveDisabled"
-		muxKeepAliveInterval = "muxKeepAliveInterval"
-		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
-		muxMaxFrameSize      = "muxMaxFrameSize"
-		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
-		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
 	)
 
 	l.md.path = mdx.GetString(md, path)
@@ -68,12 +57,7 @@ func (l *mwsListener) parseMetadata(md mdata.Metadata) (err error) {
 	l.md.writeBufferSize = mdx.GetInt(md, writeBufferSize)
 	l.md.enableCompression = mdx.GetBool(md, enableCompression)
 
-	l.md.muxKeepAliveDisabled = mdx.GetBool(md, muxKeepAliveDisabled)
-	l.md.muxKeepAliveInterval = mdx.GetDuration(md, muxKeepAliveInterval)
-	l.md.muxKeepAliveTimeout = mdx.GetDuration(md, muxKeepAliveTimeout)
-	l.md.muxMaxFrameSize = mdx.GetInt(md, muxMaxFrameSize)
-	l.md.muxMaxReceiveBuffer = mdx.GetInt(md, muxMaxReceiveBuffer)
-	l.md.muxMaxStreamBuffer = mdx.GetInt(md, muxMaxStreamBuffer)
+	l.md.muxCfg = mux.ConfigFromMetadata(md)
 
 	if mm := mdx.GetStringMapString(md, header); len(mm) > 0 {
 		hd := http.Header{}
@@ -3,13 +3,16 @@ package quic
 import (
 	"net"
 
-	"github.com/lucas-clemente/quic-go"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
+	"github.com/quic-go/quic-go"
 )
 
 type quicConn struct {
-	quic.Stream
+	*quic.Stream
 	laddr net.Addr
 	raddr net.Addr
+	dgram *quic_util.DatagramSession
 }
 
 func (c *quicConn) LocalAddr() net.Addr {
@@ -19,3 +22,26 @@ func (c *quicConn) LocalAddr() net.Addr {
 func (c *quicConn) RemoteAddr() net.Addr {
 	return c.raddr
 }
+
+// Datagrams implements udp.Datagrammer interface.
+func (c *quicConn) Datagrams() net.PacketConn {
+	return c.dgram.Conn(c.Stream)
+}
+
+type datagramConn struct {
+	net.Conn
+	dc udp.Datagrammer
+}
+
+// Datagrams implements udp.Datagrammer interface.
+func (c *datagramConn) Datagrams() net.PacketConn {
+	return c.dc.Datagrams()
+}
+
+// withDatagrams keeps the DATAGRAM frames of the stream reachable from the wrapped conn.
+func withDatagrams(c net.Conn, dc udp.Datagrammer) net.Conn {
+	return &datagramConn{
+		Conn: c,
+		dc:   dc,
+	}
+}
@@ -7,18 +7,27 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
+	"github.com/hxdcloud/gost-x/internal/net/udp"
 	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
-	"github.com/lucas-clemente/quic-go"
+	"github.com/quic-go/quic-go"
 )
 
 func init() {
 	registry.ListenerRegistry().Register("quic", NewListener)
 }
 
+// sessionListener is the common interface of quic.Listener and quic.EarlyListener.
+type sessionListener interface {
+	Accept(ctx context.Context) (*quic.Conn, error)
+	Addr() net.Addr
+	Close() error
+}
+
 type quicListener struct {
-	ln      quic.Listener
+	ln      sessionListener
+	stats   *quic_util.Stats
 	cqueue  chan net.Conn
 	errChan chan error
 	logger  logger.Logger
@@ -62,25 +71,24 @@ func (l *quicListener) Init(md md.Metadata) (err error) {
 		conn = quic_util.CipherPacketConn(conn, l.md.cipherKey)
 	}
 
-	config := &quic.Config{
-		KeepAlive:            l.md.keepAlive,
-		HandshakeIdleTimeout: l.md.handshakeTimeout,
-		MaxIdleTimeout:       l.md.maxIdleTimeout,
-		Versions: []quic.VersionNumber{
-			quic.Version1,
-			quic.VersionDraft29,
-		},
-	}
+	l.stats = quic_util.NewStats(l.options.Service)
+	config := l.md.config
+	config.Tracer = l.stats.Tracer
+	config.Allow0RTT = l.md.zeroRTT
 
 	tlsCfg := l.options.TLSConfig
 	tlsCfg.NextProtos = []string{"http/3", "quic/v1"}
 
-	ln, err := quic.Listen(conn, tlsCfg, config)
-	if err != nil {
-		return
+	if l.md.zeroRTT {
+		if l.ln, err = quic.ListenEarly(conn, tlsCfg, config); err != nil {
+			return
+		}
+	} else {
+		if l.ln, err = quic.Listen(conn, tlsCfg, config); err != nil {
+			return
+		}
 	}
 
-	l.ln = ln
 	l.cqueue = make(chan net.Conn, l.md.backlog)
 	l.errChan = make(chan error, 1)
 
@@ -93,7 +101,11 @@ func (l *quicListener) Accept() (conn net.Conn, err error) {
 	var ok bool
 	select {
 	case conn = <-l.cqueue:
-		conn = metrics.WrapConn(l.options.Service, conn)
+		if dc, ok := conn.(udp.Datagrammer); ok {
+			conn = withDatagrams(metrics.WrapConn(l.options.Service, conn), dc)
+		} else {
+			conn = metrics.WrapConn(l.options.Service, conn)
+		}
 	case err, ok = <-l.errChan:
 		if !ok {
 			err = listener.ErrClosed
@@ -103,6 +115,7 @@ func (l *quicListener) Accept() (conn net.Conn, err error) {
 }
 
 func (l *quicListener) Close() error {
+	l.stats.Close()
 	return l.ln.Close()
 }
 
@@ -124,9 +137,11 @@ func (l *quicListener) listenLoop() {
 	}
 }
 
-func (l *quicListener) mux(ctx context.Context, session quic.Session) {
+func (l *quicListener) mux(ctx context.Context, session *quic.Conn) {
 	defer session.CloseWithError(0, "closed")
 
+	dgram := quic_util.NewDatagramSession(session)
+
 	for {
 		stream, err := session.AcceptStream(ctx)
 		if err != nil {
@@ -138,6 +153,7 @@ func (l *quicListener) mux(ctx context.Context, session quic.Session) {
 			Stream: stream,
 			laddr:  session.LocalAddr(),
 			raddr:  session.RemoteAddr(),
+			dgram:  dgram,
 		}
 		select {
 		case l.cqueue <- conn:
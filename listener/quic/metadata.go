@@ -1,10 +1,10 @@
 package quic
 
 import (
-	"time"
-
 	mdata "github.com/go-gost/core/metadata"
+	quic_util "github.com/hxdcloud/gost-x/internal/util/quic"
 	mdx "github.com/hxdcloud/gost-x/metadata"
+	"github.com/quic-go/quic-go"
 )
 
 const (
@@ -12,19 +12,18 @@ const (
 )
 
 type metadata struct {
-	keepAlive        bool
-	handshakeTimeout time.Duration
-	maxIdleTimeout   time.Duration
+	config  *quic.Config
+	zeroRTT bool
 
 	cipherKey []byte
 	backlog   int
 }
 
 func (l *quicListener) parseMetadata(md mdata.Metadata) (err error) {
+	l.md.config = quic_util.ConfigFromMetadata(md)
+
 	const (
-		keepAlive        = "keepAlive"
-		handshakeTimeout = "handshakeTimeout"
-		maxIdleTimeout   = "maxIdleTimeout"
+		zeroRTT = "zeroRTT"
 
 		backlog   = "backlog"
 		cipherKey = "cipherKey"
@@ -39,9 +38,7 @@ func (l *quicListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.cipherKey = []byte(key)
 	}
 
-	l.md.keepAlive = mdx.GetBool(md, keepAlive)
-	l.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
-	l.md.maxIdleTimeout = mdx.GetDuration(md, maxIdleTimeout)
+	l.md.zeroRTT = mdx.GetBool(md, zeroRTT)
 
 	return
 }
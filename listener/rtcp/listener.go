@@ -9,7 +9,10 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
+	"github.com/hxdcloud/gost-x/internal/util/bind"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	"github.com/hxdcloud/gost-x/internal/util/tunnel"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -23,7 +26,6 @@ type rtcpListener struct {
 	md      metadata
 	router  *chain.Router
 	logger  logger.Logger
-	closed  chan struct{}
 	options listener.Options
 }
 
@@ -33,7 +35,6 @@ func NewListener(opts ...listener.Option) listener.Listener {
 		opt(&options)
 	}
 	return &rtcpListener{
-		closed:  make(chan struct{}),
 		logger:  options.Logger,
 		options: options,
 	}
@@ -54,33 +55,35 @@ func (l *rtcpListener) Init(md md.Metadata) (err error) {
 		WithChain(l.options.Chain).
 		WithLogger(l.logger)
 
+	// the listener of the BIND session is not wrapped,
+	// so the re-bind can find the streams of the broken session.
+	l.ln = metrics.WrapListener(l.options.Service, bind.NewListener(l.bind, &bind.ListenConfig{
+		Service:    l.options.Service,
+		Network:    "tcp",
+		Addr:       l.laddr,
+		Tunnel:     l.md.tunnel,
+		BackoffMin: l.md.backoffMin,
+		BackoffMax: l.md.backoffMax,
+		Logger:     l.logger,
+	}))
+
 	return
 }
 
-func (l *rtcpListener) Accept() (conn net.Conn, err error) {
-	select {
-	case <-l.closed:
-		return nil, net.ErrClosed
-	default:
+func (l *rtcpListener) bind() (net.Listener, error) {
+	ctx := mux.ContextWithConfig(context.Background(), l.md.muxCfg)
+	if l.md.tunnel != "" {
+		// register the tunnel instead of binding the port.
+		ctx = tunnel.ContextWithID(ctx, l.md.tunnel)
 	}
+	return l.router.Bind(
+		ctx, "tcp", l.laddr.String(),
+		connector.MuxBindOption(true),
+	)
+}
 
-	if l.ln == nil {
-		l.ln, err = l.router.Bind(
-			context.Background(), "tcp", l.laddr.String(),
-			connector.MuxBindOption(true),
-		)
-		if err != nil {
-			return nil, listener.NewAcceptError(err)
-		}
-		l.ln = metrics.WrapListener(l.options.Service, l.ln)
-	}
-	conn, err = l.ln.Accept()
-	if err != nil {
-		l.ln.Close()
-		l.ln = nil
-		return nil, listener.NewAcceptError(err)
-	}
-	return
+func (l *rtcpListener) Accept() (conn net.Conn, err error) {
+	return l.ln.Accept()
 }
 
 func (l *rtcpListener) Addr() net.Addr {
@@ -88,15 +91,5 @@ func (l *rtcpListener) Addr() net.Addr {
 }
 
 func (l *rtcpListener) Close() error {
-	select {
-	case <-l.closed:
-	default:
-		close(l.closed)
-		if l.ln != nil {
-			l.ln.Close()
-			l.ln = nil
-		}
-	}
-
-	return nil
+	return l.ln.Close()
 }
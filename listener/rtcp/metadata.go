@@ -1,11 +1,32 @@
 package rtcp
 
 import (
+	"time"
+
 	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	mdx "github.com/hxdcloud/gost-x/metadata"
 )
 
-type metadata struct{}
+type metadata struct {
+	tunnel     string
+	backoffMin time.Duration
+	backoffMax time.Duration
+	muxCfg     *mux.Config
+}
 
 func (l *rtcpListener) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		tunnel     = "tunnel"
+		backoffMin = "backoffMin"
+		backoffMax = "backoffMax"
+	)
+
+	l.md.tunnel = mdx.GetString(md, tunnel)
+	l.md.backoffMin = mdx.GetDuration(md, backoffMin)
+	l.md.backoffMax = mdx.GetDuration(md, backoffMax)
+
+	// the keepalive pings (muxKeepAlive*) detect the silently dead BIND session.
+	l.md.muxCfg = mux.ConfigFromMetadata(md)
 	return
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	metrics "github.com/go-gost/core/metrics/wrapper"
+	"github.com/hxdcloud/gost-x/internal/util/bind"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -21,7 +22,6 @@ type rudpListener struct {
 	laddr   net.Addr
 	ln      net.Listener
 	router  *chain.Router
-	closed  chan struct{}
 	logger  logger.Logger
 	md      metadata
 	options listener.Options
@@ -33,7 +33,6 @@ func NewListener(opts ...listener.Option) listener.Listener {
 		opt(&options)
 	}
 	return &rudpListener{
-		closed:  make(chan struct{}),
 		logger:  options.Logger,
 		options: options,
 	}
@@ -54,33 +53,32 @@ func (l *rudpListener) Init(md md.Metadata) (err error) {
 		WithChain(l.options.Chain).
 		WithLogger(l.logger)
 
+	l.ln = bind.NewListener(l.bind, &bind.ListenConfig{
+		Service:    l.options.Service,
+		Network:    "udp",
+		Addr:       l.laddr,
+		BackoffMin: l.md.backoffMin,
+		BackoffMax: l.md.backoffMax,
+		Logger:     l.logger,
+	})
+
 	return
 }
 
-func (l *rudpListener) Accept() (conn net.Conn, err error) {
-	select {
-	case <-l.closed:
-		return nil, net.ErrClosed
-	default:
-	}
+func (l *rudpListener) bind() (net.Listener, error) {
+	return l.router.Bind(
+		context.Background(), "udp", l.laddr.String(),
+		connector.BacklogBindOption(l.md.backlog),
+		connector.UDPConnTTLBindOption(l.md.ttl),
+		connector.UDPDataBufferSizeBindOption(l.md.readBufferSize),
+		connector.UDPDataQueueSizeBindOption(l.md.readQueueSize),
+	)
+}
 
-	if l.ln == nil {
-		l.ln, err = l.router.Bind(
-			context.Background(), "udp", l.laddr.String(),
-			connector.BacklogBindOption(l.md.backlog),
-			connector.UDPConnTTLBindOption(l.md.ttl),
-			connector.UDPDataBufferSizeBindOption(l.md.readBufferSize),
-			connector.UDPDataQueueSizeBindOption(l.md.readQueueSize),
-		)
-		if err != nil {
-			return nil, listener.NewAcceptError(err)
-		}
-	}
+func (l *rudpListener) Accept() (conn net.Conn, err error) {
 	conn, err = l.ln.Accept()
 	if err != nil {
-		l.ln.Close()
-		l.ln = nil
-		return nil, listener.NewAcceptError(err)
+		return
 	}
 
 	if pc, ok := conn.(net.PacketConn); ok {
@@ -95,15 +93,5 @@ func (l *rudpListener) Addr() net.Addr {
 }
 
 func (l *rudpListener) Close() error {
-	select {
-	case <-l.closed:
-	default:
-		close(l.closed)
-		if l.ln != nil {
-			l.ln.Close()
-			l.ln = nil
-		}
-	}
-
-	return nil
+	return l.ln.Close()
 }
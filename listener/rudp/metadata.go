@@ -19,6 +19,8 @@ type metadata struct {
 	readBufferSize int
 	readQueueSize  int
 	backlog        int
+	backoffMin     time.Duration
+	backoffMax     time.Duration
 }
 
 func (l *rudpListener) parseMetadata(md mdata.Metadata) (err error) {
@@ -27,6 +29,8 @@ func (l *rudpListener) parseMetadata(md mdata.Metadata) (err error) {
 		readBufferSize = "readBufferSize"
 		readQueueSize  = "readQueueSize"
 		backlog        = "backlog"
+		backoffMin     = "backoffMin"
+		backoffMax     = "backoffMax"
 	)
 
 	l.md.ttl = mdx.GetDuration(md, ttl)
@@ -48,5 +52,8 @@ func (l *rudpListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.backlog = defaultBacklog
 	}
 
+	l.md.backoffMin = mdx.GetDuration(md, backoffMin)
+	l.md.backoffMax = mdx.GetDuration(md, backoffMax)
+
 	return
 }
@@ -11,9 +11,9 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
 	ssh_util "github.com/hxdcloud/gost-x/internal/util/ssh"
 	sshd_util "github.com/hxdcloud/gost-x/internal/util/sshd"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 	"golang.org/x/crypto/ssh"
 )
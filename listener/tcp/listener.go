@@ -6,7 +6,8 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -43,6 +44,9 @@ func (l *tcpListener) Init(md md.Metadata) (err error) {
 	}
 
 	l.ln = metrics.WrapListener(l.options.Service, ln)
+	if l.md.mux {
+		l.ln = mux.NewListener(l.ln, l.md.muxCfg, l.md.backlog, l.logger)
+	}
 
 	return
 }
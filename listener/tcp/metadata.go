@@ -1,12 +1,33 @@
 package tcp
 
 import (
-	md "github.com/go-gost/core/metadata"
+	mdata "github.com/go-gost/core/metadata"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+const (
+	defaultBacklog = 128
 )
 
 type metadata struct {
+	mux     bool
+	muxCfg  *mux.Config
+	backlog int
 }
 
-func (l *tcpListener) parseMetadata(md md.Metadata) (err error) {
+func (l *tcpListener) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		enableMux = "mux"
+		backlog   = "backlog"
+	)
+
+	l.md.mux = mdx.GetBool(md, enableMux)
+	l.md.muxCfg = mux.ConfigFromMetadata(md)
+
+	l.md.backlog = mdx.GetInt(md, backlog)
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
 	return
 }
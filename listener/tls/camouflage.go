@@ -0,0 +1,139 @@
+package tls
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/listener"
+	"github.com/go-gost/core/logger"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+)
+
+const (
+	recordHeaderLen = 5
+	maxRecordLen    = 1 << 14
+)
+
+// camouflageListener hands the connections authenticated by the ClientHello to the TLS server,
+// the others are forwarded to the camouflage server transparently,
+// so the active prober only sees the camouflage server.
+type camouflageListener struct {
+	net.Listener
+	tlsConfig *tls.Config
+	verifier  *tls_util.CamouflageVerifier
+	md        metadata
+	cqueue    chan net.Conn
+	errChan   chan error
+	logger    logger.Logger
+}
+
+func newCamouflageListener(ln net.Listener, tlsConfig *tls.Config, md metadata, logger logger.Logger) net.Listener {
+	tlsConfig = tlsConfig.Clone()
+	// the server certificate is encrypted in TLS1.3.
+	tlsConfig.MinVersion = tls.VersionTLS13
+
+	l := &camouflageListener{
+		Listener:  ln,
+		tlsConfig: tlsConfig,
+		verifier:  tls_util.NewCamouflageVerifier(md.camouflageKey, md.camouflageMaxTimeDiff),
+		md:        md,
+		cqueue:    make(chan net.Conn, md.backlog),
+		errChan:   make(chan error, 1),
+		logger:    logger,
+	}
+	go l.listenLoop()
+
+	return l
+}
+
+func (l *camouflageListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *camouflageListener) listenLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		go l.handle(conn)
+	}
+}
+
+func (l *camouflageListener) handle(conn net.Conn) {
+	br := bufio.NewReaderSize(conn, recordHeaderLen+maxRecordLen)
+
+	conn.SetReadDeadline(time.Now().Add(l.md.handshakeTimeout))
+	record, err := l.readRecord(br)
+	conn.SetReadDeadline(time.Time{})
+
+	if err == nil {
+		random, sessionID, err := tls_util.ParseClientHello(record)
+		if err == nil && l.verifier.Verify(random, sessionID) {
+			tc := tls.Server(netpkg.NewBufferReaderConn(conn, br), l.tlsConfig)
+			select {
+			case l.cqueue <- tc:
+			default:
+				tc.Close()
+				l.logger.Warnf("connection queue is full, client %s discarded", conn.RemoteAddr())
+			}
+			return
+		}
+	}
+
+	l.forward(netpkg.NewBufferReaderConn(conn, br))
+}
+
+// readRecord peeks the first TLS record.
+func (l *camouflageListener) readRecord(br *bufio.Reader) ([]byte, error) {
+	hdr, err := br.Peek(recordHeaderLen)
+	if err != nil {
+		return nil, err
+	}
+	if hdr[0] != 0x16 {
+		return nil, tls_util.ErrBadClientHello
+	}
+	length := int(binary.BigEndian.Uint16(hdr[3:5]))
+	if length > maxRecordLen {
+		return nil, tls_util.ErrBadClientHello
+	}
+	return br.Peek(recordHeaderLen + length)
+}
+
+func (l *camouflageListener) forward(conn net.Conn) {
+	defer conn.Close()
+
+	log := l.logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"dst":    l.md.camouflage,
+	})
+
+	d := net.Dialer{Timeout: l.md.handshakeTimeout}
+	cc, err := d.Dial("tcp", l.md.camouflage)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), l.md.camouflage)
+	netpkg.Transport(conn, cc, l.md.transportOptions...)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), l.md.camouflage)
+}
@@ -0,0 +1,247 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/listener"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+	utls "github.com/refraction-networking/utls"
+)
+
+func serverCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// recordConn records the data written to the connection.
+type recordConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.buf.Write(b)
+	return c.Conn.Write(b)
+}
+
+func TestCamouflageListener(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "camouflage")
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	cert := serverCert(t)
+	ln := NewListener(
+		listener.AddrOption("127.0.0.1:0"),
+		listener.TLSConfigOption(&tls.Config{Certificates: []tls.Certificate{cert}}),
+		listener.LoggerOption(xlogger.Nop()),
+	)
+	err := ln.Init(mdx.NewMetadata(map[string]any{
+		"camouflage":    srv.Listener.Addr().String(),
+		"camouflageKey": "secret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().String()
+
+	// the handler echoes the accepted connections.
+	accepted := make(chan struct{}, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- struct{}{}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	dialHello := func(t *testing.T, key string) (net.Conn, *recordConn) {
+		t.Helper()
+
+		h, err := tls_util.ClientHelloFromMetadata(mdx.NewMetadata(map[string]any{"camouflageKey": key}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc := &recordConn{Conn: c}
+		conn, err := h.Client(context.Background(), rc, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, rc
+	}
+
+	var hello []byte
+	t.Run("authenticated", func(t *testing.T) {
+		conn, rc := dialHello(t, "secret")
+		defer conn.Close()
+		// the first record is the ClientHello.
+		b := rc.buf.Bytes()
+		hello = append([]byte{}, b[:5+int(binary.BigEndian.Uint16(b[3:5]))]...)
+
+		uc, ok := conn.(*utls.UConn)
+		if !ok {
+			t.Fatalf("got %T", conn)
+		}
+		if !bytes.Equal(uc.ConnectionState().PeerCertificates[0].Raw, cert.Certificate[0]) {
+			t.Error("certificate is not of the listener")
+		}
+
+		if _, err := conn.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		b = make([]byte, 4)
+		if _, err := io.ReadFull(conn, b); err != nil || string(b) != "ping" {
+			t.Fatalf("got %q, %v", b, err)
+		}
+		select {
+		case <-accepted:
+		default:
+			t.Error("connection not accepted")
+		}
+		if n := atomic.LoadInt32(&conns); n != 0 {
+			t.Errorf("got %d camouflage connections, want 0", n)
+		}
+	})
+
+	tests := []struct {
+		name string
+		dial func(t *testing.T) net.Conn
+	}{
+		{
+			name: "plain tls",
+			dial: func(t *testing.T) net.Conn {
+				conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !conn.ConnectionState().PeerCertificates[0].Equal(srv.Certificate()) {
+					t.Error("certificate is not of the camouflage server")
+				}
+				return conn
+			},
+		},
+		{
+			name: "wrong key",
+			dial: func(t *testing.T) net.Conn {
+				conn, _ := dialHello(t, "other")
+				return conn
+			},
+		},
+		{
+			name: "plain http",
+			dial: func(t *testing.T) net.Conn {
+				conn, err := net.Dial("tcp", addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return conn
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := atomic.LoadInt32(&conns)
+			conn := tt.dial(t)
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+			b, _ := io.ReadAll(conn)
+			if tt.name == "plain http" {
+				// the camouflage server rejects the plain HTTP request on the TLS port.
+				if !bytes.Contains(b, []byte("400 Bad Request")) {
+					t.Errorf("got %q", b)
+				}
+			} else if !bytes.HasSuffix(b, []byte("camouflage")) {
+				t.Errorf("got %q", b)
+			}
+			if n := atomic.LoadInt32(&conns); n != before+1 {
+				t.Errorf("got %d camouflage connections, want %d", n, before+1)
+			}
+			select {
+			case <-accepted:
+				t.Error("connection accepted")
+			default:
+			}
+		})
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		before := atomic.LoadInt32(&conns)
+
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		// the replayed ClientHello is answered by the camouflage server.
+		if _, err := conn.Write(hello); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(conn, b); err != nil || b[0] != 0x16 {
+			t.Fatalf("got %x, %v", b, err)
+		}
+		if n := atomic.LoadInt32(&conns); n != before+1 {
+			t.Errorf("got %d camouflage connections, want %d", n, before+1)
+		}
+		select {
+		case <-accepted:
+			t.Error("connection accepted")
+		default:
+		}
+	})
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -46,7 +48,23 @@ func (l *tlsListener) Init(md md.Metadata) (err error) {
 	ln = metrics.WrapListener(l.options.Service, ln)
 	ln = admission.WrapListener(l.options.Admission, ln)
 
-	l.ln = tls.NewListener(ln, l.options.TLSConfig)
+	tlsConfig := l.options.TLSConfig
+	if l.md.certificates != nil {
+		// select the certificate by the SNI name
+		tlsConfig = l.md.certificates.ServerConfig(tlsConfig)
+	}
+	if len(l.md.clients) > 0 {
+		// only the client certificates with the matched names are accepted.
+		tlsConfig = tls_util.AllowClients(tlsConfig, l.md.clients)
+	}
+	if l.md.camouflage != "" {
+		l.ln = newCamouflageListener(ln, tlsConfig, l.md, l.logger)
+	} else {
+		l.ln = tls.NewListener(ln, tlsConfig)
+	}
+	if l.md.mux {
+		l.ln = mux.NewListener(l.ln, l.md.muxCfg, l.md.backlog, l.logger)
+	}
 
 	return
 }
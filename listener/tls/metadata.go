@@ -1,12 +1,75 @@
 package tls
 
 import (
+	"errors"
+	"time"
+
 	mdata "github.com/go-gost/core/metadata"
+	netpkg "github.com/hxdcloud/gost-x/internal/net"
+	"github.com/hxdcloud/gost-x/internal/util/mux"
+	tls_util "github.com/hxdcloud/gost-x/internal/util/tls"
+	mdx "github.com/hxdcloud/gost-x/metadata"
+)
+
+const (
+	defaultBacklog          = 128
+	defaultHandshakeTimeout = 10 * time.Second
 )
 
 type metadata struct {
+	certificates *tls_util.Certificates
+	clients      []string
+
+	camouflage            string
+	camouflageKey         []byte
+	camouflageMaxTimeDiff time.Duration
+	handshakeTimeout      time.Duration
+	backlog               int
+	// transportOptions are used to relay the connections to the camouflage server.
+	transportOptions []netpkg.TransportOption
+
+	mux    bool
+	muxCfg *mux.Config
 }
 
 func (l *tlsListener) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		clients = "clients"
+
+		camouflage            = "camouflage"
+		camouflageKey         = "camouflageKey"
+		camouflageMaxTimeDiff = "camouflageMaxTimeDiff"
+		handshakeTimeout      = "handshakeTimeout"
+		backlog               = "backlog"
+
+		enableMux = "mux"
+	)
+
+	l.md.clients = mdx.GetStrings(md, clients)
+
+	l.md.camouflage = mdx.GetString(md, camouflage)
+	if l.md.camouflage != "" {
+		key := mdx.GetString(md, camouflageKey)
+		if key == "" {
+			return errors.New("camouflageKey is required for the camouflage server")
+		}
+		l.md.camouflageKey = tls_util.CamouflageKey(key)
+	}
+	l.md.camouflageMaxTimeDiff = mdx.GetDuration(md, camouflageMaxTimeDiff)
+	l.md.handshakeTimeout = mdx.GetDuration(md, handshakeTimeout)
+	if l.md.handshakeTimeout <= 0 {
+		l.md.handshakeTimeout = defaultHandshakeTimeout
+	}
+	l.md.backlog = mdx.GetInt(md, backlog)
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+
+	l.md.transportOptions = netpkg.TransportOptionsFromMetadata(md)
+
+	l.md.mux = mdx.GetBool(md, enableMux)
+	l.md.muxCfg = mux.ConfigFromMetadata(md)
+
+	l.md.certificates, err = tls_util.CertificatesFromMetadata(md)
 	return
 }
@@ -47,6 +47,16 @@ func (c *conn) Close() (err error) {
 	return c.ifce.Close()
 }
 
+// device adds the addresses assigned by the server to the tun device.
+type device struct {
+	name    string
+	addAddr func(name string, ip net.IP) error
+}
+
+func (d *device) AddAddr(ip net.IP) error {
+	return d.addAddr(d.name, ip)
+}
+
 type metadataConn struct {
 	net.Conn
 	md mdata.Metadata
@@ -6,8 +6,8 @@ import (
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	mdata "github.com/go-gost/core/metadata"
-	metrics "github.com/go-gost/core/metrics/wrapper"
 	mdx "github.com/hxdcloud/gost-x/metadata"
+	metrics "github.com/hxdcloud/gost-x/metrics/wrapper"
 	"github.com/hxdcloud/gost-x/registry"
 )
 
@@ -74,6 +74,7 @@ func (l *tunListener) Init(md mdata.Metadata) (err error) {
 	c = metrics.WrapConn(l.options.Service, c)
 	c = withMetadata(mdx.NewMetadata(map[string]any{
 		"config": l.md.config,
+		"device": &device{name: ifce.Name(), addAddr: l.addAddr},
 	}), c)
 
 	l.cqueue <- c
@@ -81,6 +82,20 @@ func (l *tunListener) Init(md mdata.Metadata) (err error) {
 	return
 }
 
+// addrNet returns the network of the ip with the prefix length of the tun device network of the same family,
+// or the host network if there is none.
+func (l *tunListener) addrNet(ip net.IP) *net.IPNet {
+	s := l.md.config.Net6
+	if v := ip.To4(); v != nil {
+		ip = v
+		s = l.md.config.Net
+	}
+	if _, ipNet, err := net.ParseCIDR(s); err == nil && len(ipNet.IP) == len(ip) {
+		return &net.IPNet{IP: ip, Mask: ipNet.Mask}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+}
+
 func (l *tunListener) Accept() (net.Conn, error) {
 	select {
 	case conn := <-l.cqueue:
@@ -21,6 +21,7 @@ func (l *tunListener) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		name    = "name"
 		netKey  = "net"
+		net6    = "net6"
 		peer    = "peer"
 		mtu     = "mtu"
 		routes  = "routes"
@@ -30,6 +31,7 @@ func (l *tunListener) parseMetadata(md mdata.Metadata) (err error) {
 	config := &tun_util.Config{
 		Name:    mdx.GetString(md, name),
 		Net:     mdx.GetString(md, netKey),
+		Net6:    mdx.GetString(md, net6),
 		Peer:    mdx.GetString(md, peer),
 		MTU:     mdx.GetInt(md, mtu),
 		Gateway: mdx.GetString(md, gateway),
@@ -37,6 +37,17 @@ func (l *tunListener) createTun() (ifce *water.Interface, ip net.IP, err error)
 		return
 	}
 
+	if l.md.config.Net6 != "" {
+		cmd := fmt.Sprintf("ifconfig %s inet6 %s", ifce.Name(), l.md.config.Net6)
+		l.logger.Debug(cmd)
+
+		args := strings.Split(cmd, " ")
+		if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+			err = fmt.Errorf("%s: %v", cmd, er)
+			return
+		}
+	}
+
 	if err = l.addRoutes(ifce.Name(), l.md.config.Routes...); err != nil {
 		return
 	}
@@ -47,6 +58,9 @@ func (l *tunListener) createTun() (ifce *water.Interface, ip net.IP, err error)
 func (l *tunListener) addRoutes(ifName string, routes ...tun_util.Route) error {
 	for _, route := range routes {
 		cmd := fmt.Sprintf("route add -net %s -interface %s", route.Net.String(), ifName)
+		if route.Net.IP.To4() == nil {
+			cmd = fmt.Sprintf("route add -inet6 %s -interface %s", route.Net.String(), ifName)
+		}
 		l.logger.Debug(cmd)
 		args := strings.Split(cmd, " ")
 		if err := exec.Command(args[0], args[1:]...).Run(); err != nil {
@@ -55,3 +69,17 @@ func (l *tunListener) addRoutes(ifName string, routes ...tun_util.Route) error {
 	}
 	return nil
 }
+
+func (l *tunListener) addAddr(ifName string, ip net.IP) error {
+	// the tun device is point-to-point, the address is also the peer address.
+	cmd := fmt.Sprintf("ifconfig %s inet %s %s alias", ifName, l.addrNet(ip), ip)
+	if ip.To4() == nil {
+		cmd = fmt.Sprintf("ifconfig %s inet6 %s alias", ifName, l.addrNet(ip))
+	}
+	l.logger.Debug(cmd)
+	args := strings.Split(cmd, " ")
+	if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+		return fmt.Errorf("%s: %v", cmd, er)
+	}
+	return nil
+}
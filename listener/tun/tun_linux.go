@@ -44,6 +44,18 @@ func (l *tunListener) createTun() (ifce *water.Interface, ip net.IP, err error)
 		return
 	}
 
+	if l.md.config.Net6 != "" {
+		ip6, ipNet6, er := net.ParseCIDR(l.md.config.Net6)
+		if er != nil {
+			err = er
+			return
+		}
+		l.logger.Debugf("ip -6 address add %s dev %s", l.md.config.Net6, ifce.Name())
+		if err = link.SetLinkIp(ip6, ipNet6); err != nil {
+			return
+		}
+	}
+
 	l.logger.Debugf("ip link set dev %s up", ifce.Name())
 	if err = link.SetLinkUp(); err != nil {
 		return
@@ -58,10 +70,28 @@ func (l *tunListener) createTun() (ifce *water.Interface, ip net.IP, err error)
 
 func (l *tunListener) addRoutes(ifName string, routes ...tun_util.Route) error {
 	for _, route := range routes {
-		l.logger.Debugf("ip route add %s dev %s", route.Net.String(), ifName)
+		if route.Net.IP.To4() == nil {
+			l.logger.Debugf("ip -6 route add %s dev %s", route.Net.String(), ifName)
+		} else {
+			l.logger.Debugf("ip route add %s dev %s", route.Net.String(), ifName)
+		}
 		if err := netlink.AddRoute(route.Net.String(), "", "", ifName); err != nil && !errors.Is(err, syscall.EEXIST) {
 			return err
 		}
 	}
 	return nil
 }
+
+func (l *tunListener) addAddr(ifName string, ip net.IP) error {
+	link, err := tenus.NewLinkFrom(ifName)
+	if err != nil {
+		return err
+	}
+
+	ipNet := l.addrNet(ip)
+	l.logger.Debugf("ip address add %s dev %s", ipNet, ifName)
+	if err := link.SetLinkIp(ipNet.IP, ipNet); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return err
+	}
+	return nil
+}
@@ -39,6 +39,18 @@ func (l *tunListener) createTun() (ifce *water.Interface, ip net.IP, err error)
 		return
 	}
 
+	if l.md.config.Net6 != "" {
+		cmd := fmt.Sprintf("netsh interface ipv6 add address interface=%s address=%s store=active",
+			ifce.Name(), l.md.config.Net6)
+		l.logger.Debug(cmd)
+
+		args := strings.Split(cmd, " ")
+		if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+			err = fmt.Errorf("%s: %v", cmd, er)
+			return
+		}
+	}
+
 	if err = l.addRoutes(ifce.Name(), l.md.config.Gateway, l.md.config.Routes...); err != nil {
 		return
 	}
@@ -48,11 +60,11 @@ func (l *tunListener) createTun() (ifce *water.Interface, ip net.IP, err error)
 
 func (l *tunListener) addRoutes(ifName string, gw string, routes ...tun_util.Route) error {
 	for _, route := range routes {
-		l.deleteRoute(ifName, route.Net.String())
+		l.deleteRoute(ifName, route.Net)
 
-		cmd := fmt.Sprintf("netsh interface ip add route prefix=%s interface=%s store=active",
-			route.Net.String(), ifName)
-		if gw != "" {
+		cmd := fmt.Sprintf("netsh interface %s add route prefix=%s interface=%s store=active",
+			ipFamily(route.Net.IP), route.Net.String(), ifName)
+		if gw != "" && (net.ParseIP(gw).To4() == nil) == (route.Net.IP.To4() == nil) {
 			cmd += " nexthop=" + gw
 		}
 		l.logger.Debug(cmd)
@@ -64,14 +76,37 @@ func (l *tunListener) addRoutes(ifName string, gw string, routes ...tun_util.Rou
 	return nil
 }
 
-func (l *tunListener) deleteRoute(ifName string, route string) error {
-	cmd := fmt.Sprintf("netsh interface ip delete route prefix=%s interface=%s store=active",
-		route, ifName)
+func (l *tunListener) deleteRoute(ifName string, route net.IPNet) error {
+	cmd := fmt.Sprintf("netsh interface %s delete route prefix=%s interface=%s store=active",
+		ipFamily(route.IP), route.String(), ifName)
 	l.logger.Debug(cmd)
 	args := strings.Split(cmd, " ")
 	return exec.Command(args[0], args[1:]...).Run()
 }
 
+func ipFamily(ip net.IP) string {
+	if ip.To4() == nil {
+		return "ipv6"
+	}
+	return "ip"
+}
+
+func (l *tunListener) addAddr(ifName string, ip net.IP) error {
+	ipNet := l.addrNet(ip)
+	cmd := fmt.Sprintf("netsh interface ip add address name=%s addr=%s mask=%s",
+		ifName, ipNet.IP, ipMask(ipNet.Mask))
+	if ip.To4() == nil {
+		cmd = fmt.Sprintf("netsh interface ipv6 add address interface=%s address=%s store=active",
+			ifName, ipNet)
+	}
+	l.logger.Debug(cmd)
+	args := strings.Split(cmd, " ")
+	if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+		return fmt.Errorf("%s: %v", cmd, er)
+	}
+	return nil
+}
+
 func ipMask(mask net.IPMask) string {
 	return fmt.Sprintf("%d.%d.%d.%d", mask[0], mask[1], mask[2], mask[3])
 }
@@ -7,6 +7,45 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// Total DNS queries. Labels: host, service, upstream, qtype, rcode.
+	MetricDNSQueriesCounter metrics.MetricName = "gost_dns_queries_total"
+	// DNS query duration histogram. Labels: host, service, upstream, qtype.
+	MetricDNSQueryDurationObserver metrics.MetricName = "gost_dns_query_duration_seconds"
+	// Whether the BIND session of the reverse listener is connected. Labels: host, service.
+	MetricServiceBindConnectedGauge metrics.MetricName = "gost_service_bind_connected"
+	// Total reconnections of the BIND session of the reverse listener. Labels: host, service.
+	MetricServiceBindReconnectsCounter metrics.MetricName = "gost_service_bind_reconnects_total"
+	// Current number of UDP flows in the NAT table. Labels: host, service.
+	MetricServiceUDPFlowsGauge metrics.MetricName = "gost_service_udp_flows"
+	// Total UDP flows created. Labels: host, service.
+	MetricServiceUDPFlowsCounter metrics.MetricName = "gost_service_udp_flows_total"
+	// Total UDP datagrams dropped by the NAT table. Labels: host, service, reason.
+	MetricServiceUDPDroppedCounter metrics.MetricName = "gost_service_udp_dropped_total"
+	// Current number of QUIC connections. Labels: host, service.
+	MetricQUICConnectionsGauge metrics.MetricName = "gost_quic_connections"
+	// Total QUIC packets sent. Labels: host, service.
+	MetricQUICPacketsSentCounter metrics.MetricName = "gost_quic_packets_sent_total"
+	// Total QUIC packets declared lost. Labels: host, service.
+	MetricQUICPacketsLostCounter metrics.MetricName = "gost_quic_packets_lost_total"
+	// QUIC smoothed RTT histogram. Labels: host, service.
+	MetricQUICRTTObserver metrics.MetricName = "gost_quic_rtt_seconds"
+	// QUIC congestion window histogram. Labels: host, service.
+	MetricQUICCongestionWindowObserver metrics.MetricName = "gost_quic_cwnd_bytes"
+	// Total KCP segments sent, kcp-go keeps the SNMP counters per process. Labels: host.
+	MetricKCPOutSegsCounter metrics.MetricName = "gost_kcp_out_segments_total"
+	// Total KCP segments retransmitted. Labels: host.
+	MetricKCPRetransSegsCounter metrics.MetricName = "gost_kcp_retransmitted_segments_total"
+	// Total KCP segments inferred as lost. Labels: host.
+	MetricKCPLostSegsCounter metrics.MetricName = "gost_kcp_lost_segments_total"
+	// Total packets recovered by the KCP FEC. Labels: host.
+	MetricKCPFECRecoveredCounter metrics.MetricName = "gost_kcp_fec_recovered_total"
+	// Total incorrect packets recovered by the KCP FEC. Labels: host.
+	MetricKCPFECErrorsCounter metrics.MetricName = "gost_kcp_fec_errors_total"
+	// KCP smoothed RTT histogram. Labels: host, service.
+	MetricKCPRTTObserver metrics.MetricName = "gost_kcp_rtt_seconds"
+)
+
 type promMetrics struct {
 	host       string
 	gauges     map[metrics.MetricName]*prometheus.GaugeVec
@@ -31,6 +70,24 @@ func NewMetrics() metrics.Metrics {
 					Help: "Current in-flight requests",
 				},
 				[]string{"host", "service"}),
+			MetricServiceBindConnectedGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: string(MetricServiceBindConnectedGauge),
+					Help: "Whether the BIND session of the reverse listener is connected",
+				},
+				[]string{"host", "service"}),
+			MetricServiceUDPFlowsGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: string(MetricServiceUDPFlowsGauge),
+					Help: "Current number of UDP flows in the NAT table",
+				},
+				[]string{"host", "service"}),
+			MetricQUICConnectionsGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: string(MetricQUICConnectionsGauge),
+					Help: "Current number of QUIC connections",
+				},
+				[]string{"host", "service"}),
 		},
 		counters: map[metrics.MetricName]*prometheus.CounterVec{
 			metrics.MetricServiceRequestsCounter: prometheus.NewCounterVec(
@@ -63,6 +120,72 @@ func NewMetrics() metrics.Metrics {
 					Help: "Total chain errors",
 				},
 				[]string{"host", "chain", "node"}),
+			MetricDNSQueriesCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricDNSQueriesCounter),
+					Help: "Total DNS queries",
+				},
+				[]string{"host", "service", "upstream", "qtype", "rcode"}),
+			MetricServiceBindReconnectsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricServiceBindReconnectsCounter),
+					Help: "Total reconnections of the BIND session of the reverse listener",
+				},
+				[]string{"host", "service"}),
+			MetricServiceUDPFlowsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricServiceUDPFlowsCounter),
+					Help: "Total UDP flows created",
+				},
+				[]string{"host", "service"}),
+			MetricServiceUDPDroppedCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricServiceUDPDroppedCounter),
+					Help: "Total UDP datagrams dropped by the NAT table",
+				},
+				[]string{"host", "service", "reason"}),
+			MetricQUICPacketsSentCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricQUICPacketsSentCounter),
+					Help: "Total QUIC packets sent",
+				},
+				[]string{"host", "service"}),
+			MetricQUICPacketsLostCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricQUICPacketsLostCounter),
+					Help: "Total QUIC packets declared lost",
+				},
+				[]string{"host", "service"}),
+			MetricKCPOutSegsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricKCPOutSegsCounter),
+					Help: "Total KCP segments sent by the process",
+				},
+				[]string{"host"}),
+			MetricKCPRetransSegsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricKCPRetransSegsCounter),
+					Help: "Total KCP segments retransmitted by the process",
+				},
+				[]string{"host"}),
+			MetricKCPLostSegsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricKCPLostSegsCounter),
+					Help: "Total KCP segments inferred as lost by the process",
+				},
+				[]string{"host"}),
+			MetricKCPFECRecoveredCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricKCPFECRecoveredCounter),
+					Help: "Total packets recovered by the KCP FEC of the process",
+				},
+				[]string{"host"}),
+			MetricKCPFECErrorsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricKCPFECErrorsCounter),
+					Help: "Total incorrect packets recovered by the KCP FEC of the process",
+				},
+				[]string{"host"}),
 		},
 		histograms: map[metrics.MetricName]*prometheus.HistogramVec{
 			metrics.MetricServiceRequestsDurationObserver: prometheus.NewHistogramVec(
@@ -83,6 +206,40 @@ func NewMetrics() metrics.Metrics {
 					},
 				},
 				[]string{"host", "chain", "node"}),
+			MetricDNSQueryDurationObserver: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name: string(MetricDNSQueryDurationObserver),
+					Help: "Distribution of DNS query latencies",
+					Buckets: []float64{
+						.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+					},
+				},
+				[]string{"host", "service", "upstream", "qtype"}),
+			MetricQUICRTTObserver: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name: string(MetricQUICRTTObserver),
+					Help: "Distribution of QUIC smoothed RTT",
+					Buckets: []float64{
+						.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5,
+					},
+				},
+				[]string{"host", "service"}),
+			MetricQUICCongestionWindowObserver: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name:    string(MetricQUICCongestionWindowObserver),
+					Help:    "Distribution of QUIC congestion window in bytes",
+					Buckets: prometheus.ExponentialBuckets(16*1024, 2, 10),
+				},
+				[]string{"host", "service"}),
+			MetricKCPRTTObserver: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name: string(MetricKCPRTTObserver),
+					Help: "Distribution of KCP smoothed RTT",
+					Buckets: []float64{
+						.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5,
+					},
+				},
+				[]string{"host", "service"}),
 		},
 	}
 	for k := range m.gauges {
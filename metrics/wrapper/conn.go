@@ -0,0 +1,78 @@
+package wrapper
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/go-gost/core/metrics"
+)
+
+var (
+	errUnsupport = errors.New("unsupported operation")
+)
+
+// serverConn is a server side Conn with metrics supported.
+type serverConn struct {
+	net.Conn
+	service string
+}
+
+func WrapConn(service string, c net.Conn) net.Conn {
+	return &serverConn{
+		service: service,
+		Conn:    c,
+	}
+}
+
+// Unwrap returns the conn wrapped by WrapConn and its service,
+// the data transferred on the returned conn directly must be counted by AddTransferBytes.
+func Unwrap(c net.Conn) (net.Conn, string, bool) {
+	if sc, ok := c.(*serverConn); ok {
+		return sc.Conn, sc.service, true
+	}
+	return c, "", false
+}
+
+// AddTransferBytes adds the bytes read from (in) and written to (out) the conns of the service.
+func AddTransferBytes(service string, in, out int64) {
+	if in > 0 {
+		if counter := metrics.GetCounter(
+			metrics.MetricServiceTransferInputBytesCounter,
+			metrics.Labels{
+				"service": service,
+			}); counter != nil {
+			counter.Add(float64(in))
+		}
+	}
+	if out > 0 {
+		if counter := metrics.GetCounter(
+			metrics.MetricServiceTransferOutputBytesCounter,
+			metrics.Labels{
+				"service": service,
+			}); counter != nil {
+			counter.Add(float64(out))
+		}
+	}
+}
+
+func (c *serverConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	AddTransferBytes(c.service, int64(n), 0)
+	return
+}
+
+func (c *serverConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	AddTransferBytes(c.service, 0, int64(n))
+	return
+}
+
+func (c *serverConn) SyscallConn() (rc syscall.RawConn, err error) {
+	if sc, ok := c.Conn.(syscall.Conn); ok {
+		rc, err = sc.SyscallConn()
+		return
+	}
+	err = errUnsupport
+	return
+}
@@ -0,0 +1,35 @@
+package wrapper
+
+import (
+	"net"
+
+	"github.com/go-gost/core/metrics"
+)
+
+type listener struct {
+	service string
+	net.Listener
+}
+
+// WrapListener wraps the accepted conns of the listener by WrapConn.
+// Unlike the wrapper of the core, the wrapped conns can be unwrapped by Unwrap,
+// so that the relay can use the half-close and zero-copy of the underlying conns.
+func WrapListener(service string, ln net.Listener) net.Listener {
+	return &listener{
+		service:  service,
+		Listener: ln,
+	}
+}
+
+func (ln *listener) Accept() (net.Conn, error) {
+	c, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	// metrics is not enabled
+	if metrics.Global() == metrics.Noop() {
+		return c, nil
+	}
+	return WrapConn(ln.service, c), nil
+}
@@ -0,0 +1,6 @@
+package recorder
+
+const (
+	// RecorderServiceHandlerDNS records the DNS queries handled by the dns handler, in JSON format.
+	RecorderServiceHandlerDNS = "recorder.service.handler.dns"
+)
@@ -38,3 +38,16 @@ func (w *autherWrapper) Authenticate(user, password string) bool {
 	}
 	return v.Authenticate(user, password)
 }
+
+func (w *autherWrapper) AuthenticateIdentity(user string) bool {
+	v := w.r.get(w.name)
+	if v == nil {
+		return false
+	}
+	if ia, ok := v.(interface {
+		AuthenticateIdentity(user string) bool
+	}); ok {
+		return ia.AuthenticateIdentity(user)
+	}
+	return v.Authenticate(user, "")
+}
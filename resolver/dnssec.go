@@ -0,0 +1,675 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	resolver_util "github.com/hxdcloud/gost-x/internal/util/resolver"
+	"github.com/hxdcloud/gost-x/resolver/exchanger"
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultTrustAnchor is the DS record of the root zone KSK-2017.
+	DefaultTrustAnchor = ". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+
+	BogusReject = "reject"
+	BogusFlag   = "flag"
+
+	dnssecUDPSize = 4096
+	// maxZoneKeys is the maximum number of the zones whose keys are cached by the validator.
+	maxZoneKeys = 1024
+)
+
+var (
+	ErrBogus    = errors.New("dnssec: bogus answer")
+	errInsecure = errors.New("dnssec: insecure delegation")
+)
+
+// DNSSEC enables the DNSSEC validation of the answers from a nameserver.
+type DNSSEC struct {
+	// TrustAnchors are DS or DNSKEY records in zone file format,
+	// the root KSK is used if no trust anchor is specified.
+	TrustAnchors []string
+	// Bogus specifies how to handle the bogus answers,
+	// 'reject' (default) returns an error, 'flag' only logs the failure.
+	Bogus string
+}
+
+type rrsetKey struct {
+	name   string
+	rrtype uint16
+}
+
+type keysItem struct {
+	keys    []*dns.DNSKEY
+	err     error
+	expires time.Time
+}
+
+// validator validates the DNSSEC chain of trust from the trust anchors to the answers.
+// The DS and DNSKEY lookups go through the same exchanger (and chain) as the queries.
+type validator struct {
+	anchors map[string][]*dns.DS
+	bogus   string
+	ex      exchanger.Exchanger
+	ttl     time.Duration
+	cache   *resolver_util.Cache
+	keys    map[string]*keysItem
+	pruned  time.Time
+	mu      sync.Mutex
+	logger  logger.Logger
+}
+
+func newValidator(cfg *DNSSEC, ex exchanger.Exchanger, ttl time.Duration, cache *resolver_util.Cache, logger logger.Logger) (*validator, error) {
+	anchors := cfg.TrustAnchors
+	if len(anchors) == 0 {
+		anchors = []string{DefaultTrustAnchor}
+	}
+
+	v := &validator{
+		anchors: make(map[string][]*dns.DS),
+		bogus:   cfg.Bogus,
+		ex:      ex,
+		ttl:     ttl,
+		cache:   cache,
+		keys:    make(map[string]*keysItem),
+		logger:  logger,
+	}
+	for _, s := range anchors {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust anchor %q: %w", s, err)
+		}
+		var ds *dns.DS
+		switch t := rr.(type) {
+		case *dns.DS:
+			ds = t
+		case *dns.DNSKEY:
+			ds = t.ToDS(dns.SHA256)
+		}
+		if ds == nil {
+			return nil, fmt.Errorf("invalid trust anchor %q", s)
+		}
+		zone := dns.CanonicalName(ds.Hdr.Name)
+		v.anchors[zone] = append(v.anchors[zone], ds)
+	}
+
+	return v, nil
+}
+
+// Flag reports whether the bogus answers should be flagged rather than rejected.
+func (v *validator) Flag() bool {
+	return v.bogus == BogusFlag
+}
+
+// Validate validates all the RRsets in the answer section of the message,
+// or the authenticated denial of existence of a negative answer.
+// The unsigned RRsets are only accepted if they are proven to be under an insecure delegation.
+func (v *validator) Validate(ctx context.Context, m *dns.Msg) error {
+	m.AuthenticatedData = false
+
+	if len(m.Answer) == 0 && len(m.Question) > 0 &&
+		(m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError) {
+		q := m.Question[0]
+		secure, err := v.denial(ctx, m)
+		if err != nil {
+			return fmt.Errorf("%w: %s %s: %v", ErrBogus, q.Name, dns.TypeToString[q.Qtype], err)
+		}
+		m.AuthenticatedData = secure
+		return nil
+	}
+
+	rrsets, sigs := splitRRsets(m.Answer)
+
+	secure := true
+	for k, rrset := range rrsets {
+		if len(sigs[k]) == 0 {
+			insecure, err := v.insecure(ctx, k.name)
+			if err != nil {
+				return fmt.Errorf("%w: %s %s: %v", ErrBogus, k.name, dns.TypeToString[k.rrtype], err)
+			}
+			if !insecure {
+				return fmt.Errorf("%w: %s %s: missing signature", ErrBogus, k.name, dns.TypeToString[k.rrtype])
+			}
+			secure = false
+			continue
+		}
+
+		sig, err := v.verify(ctx, rrset, sigs[k])
+		if err != nil {
+			if errors.Is(err, errInsecure) {
+				secure = false
+				continue
+			}
+			return fmt.Errorf("%w: %s %s: %v", ErrBogus, k.name, dns.TypeToString[k.rrtype], err)
+		}
+
+		// the RRset is expanded from a wildcard, the name itself must be proven not to exist (RFC 4035 5.3.4).
+		if labels := nameLabels(k.name); int(sig.Labels) < labels {
+			if err := v.wildcard(ctx, m, k.name, int(sig.Labels)); err != nil {
+				return fmt.Errorf("%w: %s %s: %v", ErrBogus, k.name, dns.TypeToString[k.rrtype], err)
+			}
+		}
+	}
+
+	m.AuthenticatedData = secure && len(rrsets) > 0
+	return nil
+}
+
+// denial validates the NSEC or NSEC3 records in the authority section, which must prove
+// that the name (NXDOMAIN) or the type (NODATA) of the question does not exist.
+// The secure is false if the unsigned answer is proven to be under an insecure delegation.
+func (v *validator) denial(ctx context.Context, m *dns.Msg) (secure bool, err error) {
+	q := m.Question[0]
+	name := dns.CanonicalName(q.Name)
+
+	nsecs, nsec3s, err := v.nsecRecords(ctx, m.Ns)
+	if err != nil {
+		if errors.Is(err, errInsecure) {
+			return false, nil
+		}
+		return
+	}
+
+	if len(nsecs) == 0 && len(nsec3s) == 0 {
+		insecure, err := v.insecure(ctx, soaZone(m.Ns, name))
+		if err != nil {
+			return false, err
+		}
+		if !insecure {
+			return false, errors.New("missing denial of existence")
+		}
+		return false, nil
+	}
+
+	if m.Rcode == dns.RcodeNameError {
+		if !nsecDenyName(nsecs, name) && !nsec3DenyName(nsec3s, name) {
+			return false, errors.New("name existence not denied")
+		}
+	} else {
+		if !nsecDenyType(nsecs, name, q.Qtype) && !nsec3DenyType(nsec3s, name, q.Qtype) {
+			return false, errors.New("type existence not denied")
+		}
+	}
+	return true, nil
+}
+
+// wildcard validates the NSEC or NSEC3 records in the authority section, which must prove
+// that the name of the answer expanded from the wildcard with the labels does not exist.
+func (v *validator) wildcard(ctx context.Context, m *dns.Msg, name string, labels int) error {
+	nsecs, nsec3s, err := v.nsecRecords(ctx, m.Ns)
+	if err != nil {
+		return err
+	}
+
+	for _, nsec := range nsecs {
+		if nsecCover(nsec, name) {
+			return nil
+		}
+	}
+	// the next closer name of the closest encloser, which is the wildcard owner without the asterisk (RFC 5155 8.8).
+	if nsec3Cover(nsec3s, ancestor(name, labels+1)) {
+		return nil
+	}
+	return errors.New("wildcard expansion not proven")
+}
+
+// nsecRecords returns the NSEC and NSEC3 records of the RRs, the RRsets of them must be validated.
+func (v *validator) nsecRecords(ctx context.Context, rrs []dns.RR) (nsecs []*dns.NSEC, nsec3s []*dns.NSEC3, err error) {
+	rrsets, sigs := splitRRsets(rrs)
+	for k, rrset := range rrsets {
+		if k.rrtype != dns.TypeNSEC && k.rrtype != dns.TypeNSEC3 {
+			continue
+		}
+		if _, err = v.verify(ctx, rrset, sigs[k]); err != nil {
+			return
+		}
+		for _, rr := range rrset {
+			switch t := rr.(type) {
+			case *dns.NSEC:
+				nsecs = append(nsecs, t)
+			case *dns.NSEC3:
+				nsec3s = append(nsec3s, t)
+			}
+		}
+	}
+	return
+}
+
+// verify verifies the RRset with one of the signatures, using the validated keys of the signer zone.
+// The sig is the signature which the RRset is verified with.
+func (v *validator) verify(ctx context.Context, rrset []dns.RR, sigs []*dns.RRSIG) (sig *dns.RRSIG, err error) {
+	name := rrset[0].Header().Name
+	now := time.Now()
+
+	err = errors.New("no valid signature")
+	for _, s := range sigs {
+		if !dns.IsSubDomain(s.SignerName, name) {
+			continue
+		}
+		if !s.ValidityPeriod(now) {
+			err = fmt.Errorf("signature by %s expired", s.SignerName)
+			continue
+		}
+
+		keys, er := v.zoneKeys(ctx, dns.CanonicalName(s.SignerName))
+		if er != nil {
+			if errors.Is(er, errInsecure) {
+				return nil, er
+			}
+			err = er
+			continue
+		}
+
+		for _, key := range keys {
+			if key.KeyTag() != s.KeyTag || key.Algorithm != s.Algorithm {
+				continue
+			}
+			if er := s.Verify(key, rrset); er == nil {
+				return s, nil
+			} else {
+				err = er
+			}
+		}
+	}
+	return
+}
+
+// zoneKeys returns the DNSKEY RRset of the zone, validated by the DS RRset from the parent zone or the trust anchors.
+func (v *validator) zoneKeys(ctx context.Context, zone string) ([]*dns.DNSKEY, error) {
+	v.mu.Lock()
+	item := v.keys[zone]
+	v.mu.Unlock()
+	if item != nil && time.Now().Before(item.expires) {
+		return item.keys, item.err
+	}
+
+	keys, ttl, err := v.loadZoneKeys(ctx, zone)
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	if err == nil || errors.Is(err, errInsecure) {
+		now := time.Now()
+		v.mu.Lock()
+		if len(v.keys) >= maxZoneKeys || now.Sub(v.pruned) > time.Minute {
+			v.prune(now)
+		}
+		v.keys[zone] = &keysItem{
+			keys:    keys,
+			err:     err,
+			expires: now.Add(ttl),
+		}
+		v.mu.Unlock()
+	}
+
+	return keys, err
+}
+
+// prune removes the expired keys, and the random ones while the keys of maxZoneKeys zones are cached.
+func (v *validator) prune(now time.Time) {
+	v.pruned = now
+	for zone, item := range v.keys {
+		if !now.Before(item.expires) || len(v.keys) >= maxZoneKeys {
+			delete(v.keys, zone)
+		}
+	}
+}
+
+func (v *validator) loadZoneKeys(ctx context.Context, zone string) (keys []*dns.DNSKEY, ttl time.Duration, err error) {
+	dss := v.anchors[zone]
+	if len(dss) == 0 {
+		if zone == "." {
+			err = errors.New("no trust anchor for root zone")
+			return
+		}
+		var insecure bool
+		dss, insecure, err = v.delegation(ctx, zone)
+		if err != nil {
+			return
+		}
+		if insecure {
+			err = errInsecure
+			return
+		}
+	}
+
+	mr, err := v.query(ctx, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return
+	}
+	rrsets, sigs := splitRRsets(mr.Answer)
+	k := rrsetKey{name: zone, rrtype: dns.TypeDNSKEY}
+	rrset := rrsets[k]
+	if len(rrset) == 0 {
+		err = fmt.Errorf("no DNSKEY for %s", zone)
+		return
+	}
+
+	var trusted []*dns.DNSKEY
+	for _, rr := range rrset {
+		key := rr.(*dns.DNSKEY)
+		keys = append(keys, key)
+		if key.Flags&dns.ZONE == 0 {
+			continue
+		}
+		for _, ds := range dss {
+			kds := key.ToDS(ds.DigestType)
+			if kds != nil && kds.KeyTag == ds.KeyTag &&
+				strings.EqualFold(kds.Digest, ds.Digest) {
+				trusted = append(trusted, key)
+				break
+			}
+		}
+	}
+	if len(trusted) == 0 {
+		err = fmt.Errorf("no DNSKEY of %s matches DS", zone)
+		return
+	}
+
+	now := time.Now()
+	for _, sig := range sigs[k] {
+		if !sig.ValidityPeriod(now) {
+			continue
+		}
+		for _, key := range trusted {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			if sig.Verify(key, rrset) == nil {
+				ttl = time.Duration(rrset[0].Header().Ttl) * time.Second
+				return
+			}
+		}
+	}
+
+	err = fmt.Errorf("DNSKEY of %s not signed by a trusted key", zone)
+	return
+}
+
+// delegation looks up the validated DS RRset of the zone from its parent.
+// The insecure is true if the parent proves that no DS exists for a delegated zone.
+func (v *validator) delegation(ctx context.Context, zone string) (dss []*dns.DS, insecure bool, err error) {
+	mr, err := v.query(ctx, zone, dns.TypeDS)
+	if err != nil {
+		return
+	}
+
+	rrsets, sigs := splitRRsets(mr.Answer)
+	k := rrsetKey{name: zone, rrtype: dns.TypeDS}
+	if rrset := rrsets[k]; len(rrset) > 0 {
+		if _, err = v.verify(ctx, rrset, sigs[k]); err != nil {
+			return
+		}
+		for _, rr := range rrset {
+			dss = append(dss, rr.(*dns.DS))
+		}
+		return
+	}
+
+	// authenticated denial of existence
+	rrsets, sigs = splitRRsets(mr.Ns)
+	for k, rrset := range rrsets {
+		if k.rrtype != dns.TypeNSEC && k.rrtype != dns.TypeNSEC3 {
+			continue
+		}
+		if _, err = v.verify(ctx, rrset, sigs[k]); err != nil {
+			return
+		}
+		for _, rr := range rrset {
+			switch t := rr.(type) {
+			case *dns.NSEC:
+				if dns.CanonicalName(t.Hdr.Name) == zone &&
+					hasType(t.TypeBitMap, dns.TypeNS) &&
+					!hasType(t.TypeBitMap, dns.TypeDS) {
+					insecure = true
+				}
+			case *dns.NSEC3:
+				if t.Match(zone) &&
+					hasType(t.TypeBitMap, dns.TypeNS) &&
+					!hasType(t.TypeBitMap, dns.TypeDS) {
+					insecure = true
+				}
+				// opt-out
+				if t.Cover(zone) && t.Flags&0x01 != 0 {
+					insecure = true
+				}
+			}
+		}
+	}
+	return
+}
+
+// insecure reports whether the name is under an insecure delegation,
+// by walking the DS records from the top level domain down to the name.
+func (v *validator) insecure(ctx context.Context, name string) (bool, error) {
+	name = dns.CanonicalName(name)
+	indexes := dns.Split(name)
+	for i := len(indexes) - 1; i >= 0; i-- {
+		zone := name[indexes[i]:]
+		if len(v.anchors[zone]) > 0 {
+			continue
+		}
+		_, insecure, err := v.delegation(ctx, zone)
+		if errors.Is(err, errInsecure) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if insecure {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (v *validator) query(ctx context.Context, name string, qtype uint16) (mr *dns.Msg, err error) {
+	mq := &dns.Msg{}
+	mq.SetQuestion(name, qtype)
+	mq.SetEdns0(dnssecUDPSize, true)
+	mq.CheckingDisabled = true
+
+	// the raw answers are cached apart from the validated ones.
+	key := resolver_util.NewCacheKey(&mq.Question[0]) + ".CD"
+	if mr = v.cache.Load(key); mr != nil {
+		return
+	}
+
+	query, err := mq.Pack()
+	if err != nil {
+		return
+	}
+	reply, err := v.ex.Exchange(ctx, query)
+	if err != nil {
+		return
+	}
+	mr = &dns.Msg{}
+	if err = mr.Unpack(reply); err != nil {
+		return
+	}
+	if mr.Rcode != dns.RcodeSuccess {
+		err = fmt.Errorf("%s %s: %s", name, dns.TypeToString[qtype], dns.RcodeToString[mr.Rcode])
+		return
+	}
+
+	v.cache.Store(key, mr, v.ttl)
+	return
+}
+
+// CacheKey returns the cache key of the answers validated by the validator,
+// the flagged bogus answers must not be served to the nameservers rejecting them.
+func (v *validator) CacheKey(q *dns.Question) resolver_util.CacheKey {
+	if v.Flag() {
+		return resolver_util.NewCacheKey(q) + ".DO." + BogusFlag
+	}
+	return resolver_util.NewCacheKey(q) + ".DO." + BogusReject
+}
+
+func splitRRsets(rrs []dns.RR) (rrsets map[rrsetKey][]dns.RR, sigs map[rrsetKey][]*dns.RRSIG) {
+	rrsets = make(map[rrsetKey][]dns.RR)
+	sigs = make(map[rrsetKey][]*dns.RRSIG)
+	for _, rr := range rrs {
+		name := dns.CanonicalName(rr.Header().Name)
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			k := rrsetKey{name: name, rrtype: sig.TypeCovered}
+			sigs[k] = append(sigs[k], sig)
+			continue
+		}
+		k := rrsetKey{name: name, rrtype: rr.Header().Rrtype}
+		rrsets[k] = append(rrsets[k], rr)
+	}
+	return
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	for _, v := range bitmap {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// soaZone returns the owner of the SOA record for the name, or the name itself if not found.
+func soaZone(rrs []dns.RR, name string) string {
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok && dns.IsSubDomain(soa.Hdr.Name, name) {
+			return dns.CanonicalName(soa.Hdr.Name)
+		}
+	}
+	return name
+}
+
+// nsecDenyName reports whether the NSEC records prove that neither the name
+// nor the wildcard at its closest encloser exists (RFC 4035 5.4).
+func nsecDenyName(nsecs []*dns.NSEC, name string) bool {
+	for _, nsec := range nsecs {
+		if !nsecCover(nsec, name) {
+			continue
+		}
+
+		// the closest encloser is the longest common ancestor of the name with the owner or the next name.
+		n := dns.CompareDomainName(name, nsec.Hdr.Name)
+		if c := dns.CompareDomainName(name, nsec.NextDomain); c > n {
+			n = c
+		}
+		wildcard := "*."
+		if ce := ancestor(name, n); ce != "." {
+			wildcard += ce
+		}
+		for _, w := range nsecs {
+			if nsecCover(w, wildcard) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nsecDenyType reports whether the NSEC record of the name proves that the type does not exist.
+func nsecDenyType(nsecs []*dns.NSEC, name string, qtype uint16) bool {
+	for _, nsec := range nsecs {
+		if dns.CanonicalName(nsec.Hdr.Name) == name &&
+			!hasType(nsec.TypeBitMap, qtype) &&
+			!hasType(nsec.TypeBitMap, dns.TypeCNAME) {
+			return true
+		}
+	}
+	return false
+}
+
+// nsecCover reports whether the name falls between the owner and the next name of the NSEC record.
+func nsecCover(nsec *dns.NSEC, name string) bool {
+	owner, next := nsec.Hdr.Name, nsec.NextDomain
+	if compareNames(owner, next) < 0 {
+		return compareNames(owner, name) < 0 && compareNames(name, next) < 0
+	}
+	// the last NSEC record of the zone, the next name is the apex.
+	return compareNames(owner, name) < 0 || compareNames(name, next) < 0
+}
+
+// nsec3DenyName verifies the closest encloser proof (RFC 5155 8.4):
+// an NSEC3 record matches the closest encloser, and the next closer name
+// and the wildcard at the closest encloser are covered.
+func nsec3DenyName(nsec3s []*dns.NSEC3, name string) bool {
+	indexes := dns.Split(name)
+	for i := 1; i < len(indexes); i++ {
+		ce := name[indexes[i]:]
+		if !nsec3Match(nsec3s, ce) {
+			continue
+		}
+		return nsec3Cover(nsec3s, name[indexes[i-1]:]) && nsec3Cover(nsec3s, "*."+ce)
+	}
+	return false
+}
+
+// nsec3DenyType reports whether the NSEC3 record matching the name proves that the type does not exist.
+func nsec3DenyType(nsec3s []*dns.NSEC3, name string, qtype uint16) bool {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Match(name) &&
+			!hasType(nsec3.TypeBitMap, qtype) &&
+			!hasType(nsec3.TypeBitMap, dns.TypeCNAME) {
+			return true
+		}
+	}
+	return false
+}
+
+func nsec3Match(nsec3s []*dns.NSEC3, name string) bool {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nsec3Cover reports whether the name is covered by the NSEC3 records,
+// the Cover of the dns package also accepts the hash of the owner.
+func nsec3Cover(nsec3s []*dns.NSEC3, name string) bool {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Cover(name) && !nsec3.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameLabels returns the label count of the name for the Labels of RRSIG,
+// the leading asterisk label of a wildcard name is not counted.
+func nameLabels(name string) int {
+	n := dns.CountLabel(name)
+	if strings.HasPrefix(name, "*.") {
+		n--
+	}
+	return n
+}
+
+// ancestor returns the ancestor of the name with the n rightmost labels.
+func ancestor(name string, n int) string {
+	indexes := dns.Split(name)
+	if n <= 0 || len(indexes) == 0 {
+		return "."
+	}
+	if n >= len(indexes) {
+		return name
+	}
+	return name[indexes[len(indexes)-n]:]
+}
+
+// compareNames compares the names in the canonical order (RFC 4034 6.1).
+func compareNames(a, b string) int {
+	la, lb := dns.SplitDomainName(a), dns.SplitDomainName(b)
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(strings.ToLower(la[i]), strings.ToLower(lb[j])); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
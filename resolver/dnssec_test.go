@@ -0,0 +1,508 @@
+package resolver
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	resolver_util "github.com/hxdcloud/gost-x/internal/util/resolver"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	"github.com/miekg/dns"
+)
+
+const testZone = "example."
+
+// signer signs the RRsets of the test zone with a single key.
+type signer struct {
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newSigner(t *testing.T) *signer {
+	t.Helper()
+
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: testZone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signer{key: key, priv: priv.(crypto.Signer)}
+}
+
+// sign returns the RRset followed by its signature.
+func (s *signer) sign(t *testing.T, rrset ...dns.RR) []dns.RR {
+	t.Helper()
+
+	hdr := rrset[0].Header()
+	now := time.Now()
+	sig := &dns.RRSIG{
+		Hdr:         dns.RR_Header{Name: hdr.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: hdr.Ttl},
+		TypeCovered: hdr.Rrtype,
+		Algorithm:   s.key.Algorithm,
+		Labels:      uint8(dns.CountLabel(hdr.Name)),
+		OrigTtl:     hdr.Ttl,
+		Expiration:  uint32(now.Add(time.Hour).Unix()),
+		Inception:   uint32(now.Add(-time.Hour).Unix()),
+		KeyTag:      s.key.KeyTag(),
+		SignerName:  testZone,
+	}
+	if err := sig.Sign(s.priv, rrset); err != nil {
+		t.Fatal(err)
+	}
+	return append(rrset, sig)
+}
+
+// signWildcard returns the RRset expanded from the wildcard of the test zone followed by its signature.
+func (s *signer) signWildcard(t *testing.T, rrset ...dns.RR) []dns.RR {
+	t.Helper()
+
+	name := rrset[0].Header().Name
+	for _, rr := range rrset {
+		rr.Header().Name = "*." + testZone
+	}
+	rrs := s.sign(t, rrset...)
+	for _, rr := range rrs {
+		rr.Header().Name = name
+	}
+	return rrs
+}
+
+// zoneExchanger answers the queries from the messages by the name and type,
+// NXDOMAIN is returned for the unknown questions.
+type zoneExchanger map[rrsetKey]*dns.Msg
+
+func (ex zoneExchanger) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	mq := &dns.Msg{}
+	if err := mq.Unpack(query); err != nil {
+		return nil, err
+	}
+	q := mq.Question[0]
+
+	mr := &dns.Msg{}
+	if m := ex[rrsetKey{name: dns.CanonicalName(q.Name), rrtype: q.Qtype}]; m != nil {
+		mr = m.Copy()
+	} else {
+		mr.Rcode = dns.RcodeNameError
+	}
+	mr.SetReply(mq)
+	return mr.Pack()
+}
+
+func (ex zoneExchanger) String() string {
+	return "zone"
+}
+
+func newTestValidator(t *testing.T, s *signer, bogus string, ex zoneExchanger, cache *resolver_util.Cache) *validator {
+	t.Helper()
+
+	ex[rrsetKey{name: testZone, rrtype: dns.TypeDNSKEY}] = &dns.Msg{Answer: s.sign(t, s.key)}
+	v, err := newValidator(&DNSSEC{TrustAnchors: []string{s.key.String()}, Bogus: bogus},
+		ex, 0, cache, xlogger.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func newA(name, ip string) *dns.A {
+	return &dns.A{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.ParseIP(ip),
+	}
+}
+
+func newNSEC(name, next string, types ...uint16) *dns.NSEC {
+	return &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+		NextDomain: next,
+		TypeBitMap: types,
+	}
+}
+
+// newNSEC3Chain returns the NSEC3 records of the existing names of the test zone.
+func newNSEC3Chain(names ...string) []*dns.NSEC3 {
+	var hashes []string
+	for _, name := range names {
+		hashes = append(hashes, dns.HashName(name, dns.SHA1, 0, ""))
+	}
+	sort.Strings(hashes)
+
+	var rrs []*dns.NSEC3
+	for i, h := range hashes {
+		rrs = append(rrs, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(h) + "." + testZone, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+			Hash:       dns.SHA1,
+			NextDomain: hashes[(i+1)%len(hashes)],
+			TypeBitMap: []uint16{dns.TypeA},
+		})
+	}
+	return rrs
+}
+
+func newSOA() *dns.SOA {
+	return &dns.SOA{
+		Hdr:    dns.RR_Header{Name: testZone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+		Ns:     "ns." + testZone,
+		Mbox:   "admin." + testZone,
+		Serial: 1,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := newSigner(t)
+
+	signedNSEC3 := func(t *testing.T) (rrs []dns.RR) {
+		for _, rr := range newNSEC3Chain(testZone, "a."+testZone) {
+			rrs = append(rrs, s.sign(t, rr)...)
+		}
+		return
+	}
+
+	tests := []struct {
+		name    string
+		qname   string
+		qtype   uint16
+		msg     func(t *testing.T) *dns.Msg
+		wantErr bool
+		wantAD  bool
+	}{
+		{
+			name: "signed answer",
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Answer: s.sign(t, newA("www."+testZone, "192.0.2.1"))}
+			},
+			wantAD: true,
+		},
+		{
+			name: "tampered answer",
+			msg: func(t *testing.T) *dns.Msg {
+				rrs := s.sign(t, newA("www."+testZone, "192.0.2.1"))
+				rrs[0].(*dns.A).A = net.ParseIP("192.0.2.2")
+				return &dns.Msg{Answer: rrs}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsigned answer",
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Answer: []dns.RR{newA("www."+testZone, "192.0.2.1")}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcard answer without proof",
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Answer: s.signWildcard(t, newA("www."+testZone, "192.0.2.1"))}
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcard answer with nsec",
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{
+					Answer: s.signWildcard(t, newA("www."+testZone, "192.0.2.1")),
+					Ns:     s.sign(t, newNSEC("a."+testZone, "z."+testZone, dns.TypeA)),
+				}
+			},
+			wantAD: true,
+		},
+		{
+			name: "wildcard answer with nsec not covering name",
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{
+					Answer: s.signWildcard(t, newA("www."+testZone, "192.0.2.1")),
+					Ns:     s.sign(t, newNSEC("a."+testZone, "c."+testZone, dns.TypeA)),
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcard answer with nsec3",
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{
+					Answer: s.signWildcard(t, newA("www."+testZone, "192.0.2.1")),
+					Ns:     signedNSEC3(t),
+				}
+			},
+			wantAD: true,
+		},
+		{
+			name:  "wildcard answer with nsec3 of existing name",
+			qname: "a." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{
+					Answer: s.signWildcard(t, newA("a."+testZone, "192.0.2.1")),
+					Ns:     signedNSEC3(t),
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name:  "wildcard name answer",
+			qname: "*." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Answer: s.sign(t, newA("*."+testZone, "192.0.2.1"))}
+			},
+			wantAD: true,
+		},
+		{
+			name:  "nxdomain without proof",
+			qname: "b." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				m := &dns.Msg{Ns: []dns.RR{newSOA()}}
+				m.Rcode = dns.RcodeNameError
+				return m
+			},
+			wantErr: true,
+		},
+		{
+			name:  "nodata without proof",
+			qname: "www." + testZone,
+			qtype: dns.TypeAAAA,
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Ns: []dns.RR{newSOA()}}
+			},
+			wantErr: true,
+		},
+		{
+			name:  "nxdomain with nsec",
+			qname: "b." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				m := &dns.Msg{}
+				m.Rcode = dns.RcodeNameError
+				m.Ns = append(m.Ns, s.sign(t, newNSEC(testZone, "a."+testZone, dns.TypeSOA))...)
+				m.Ns = append(m.Ns, s.sign(t, newNSEC("a."+testZone, "c."+testZone, dns.TypeA))...)
+				return m
+			},
+			wantAD: true,
+		},
+		{
+			name:  "nxdomain with nsec not covering wildcard",
+			qname: "b." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				m := &dns.Msg{}
+				m.Rcode = dns.RcodeNameError
+				m.Ns = s.sign(t, newNSEC("a."+testZone, "c."+testZone, dns.TypeA))
+				return m
+			},
+			wantErr: true,
+		},
+		{
+			name:  "nxdomain with nsec not covering name",
+			qname: "d." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				m := &dns.Msg{}
+				m.Rcode = dns.RcodeNameError
+				m.Ns = append(m.Ns, s.sign(t, newNSEC(testZone, "a."+testZone, dns.TypeSOA))...)
+				m.Ns = append(m.Ns, s.sign(t, newNSEC("a."+testZone, "c."+testZone, dns.TypeA))...)
+				return m
+			},
+			wantErr: true,
+		},
+		{
+			name:  "nodata with nsec",
+			qname: "www." + testZone,
+			qtype: dns.TypeAAAA,
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Ns: s.sign(t, newNSEC("www."+testZone, "z."+testZone, dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC))}
+			},
+			wantAD: true,
+		},
+		{
+			name:  "nodata with nsec of the type",
+			qname: "www." + testZone,
+			qtype: dns.TypeAAAA,
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Ns: s.sign(t, newNSEC("www."+testZone, "z."+testZone, dns.TypeA, dns.TypeAAAA))}
+			},
+			wantErr: true,
+		},
+		{
+			name:  "nxdomain with nsec3",
+			qname: "b." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				m := &dns.Msg{Ns: signedNSEC3(t)}
+				m.Rcode = dns.RcodeNameError
+				return m
+			},
+			wantAD: true,
+		},
+		{
+			name:  "nxdomain of existing name with nsec3",
+			qname: "a." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				m := &dns.Msg{Ns: signedNSEC3(t)}
+				m.Rcode = dns.RcodeNameError
+				return m
+			},
+			wantErr: true,
+		},
+		{
+			name:  "nodata with nsec3",
+			qname: "a." + testZone,
+			qtype: dns.TypeAAAA,
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{Ns: signedNSEC3(t)}
+			},
+			wantAD: true,
+		},
+		{
+			name:  "tampered nsec3",
+			qname: "b." + testZone,
+			msg: func(t *testing.T) *dns.Msg {
+				m := &dns.Msg{Ns: signedNSEC3(t)}
+				m.Ns[0].(*dns.NSEC3).TypeBitMap = []uint16{dns.TypeA, dns.TypeAAAA}
+				m.Rcode = dns.RcodeNameError
+				return m
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := resolver_util.NewCache().WithLogger(xlogger.Nop())
+			v := newTestValidator(t, s, BogusReject, zoneExchanger{}, cache)
+
+			qname, qtype := tt.qname, tt.qtype
+			if qname == "" {
+				qname = "www." + testZone
+			}
+			if qtype == 0 {
+				qtype = dns.TypeA
+			}
+			m := tt.msg(t)
+			m.SetQuestion(qname, qtype)
+
+			err := v.Validate(context.Background(), m)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBogus) {
+					t.Fatalf("got %v, want %v", err, ErrBogus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.AuthenticatedData != tt.wantAD {
+				t.Errorf("AD: got %v, want %v", m.AuthenticatedData, tt.wantAD)
+			}
+		})
+	}
+}
+
+// TestResolveBogusCache checks that the bogus answer accepted by a flagging nameserver
+// is not served from the shared cache to a rejecting nameserver.
+func TestResolveBogusCache(t *testing.T) {
+	s := newSigner(t)
+
+	rrs := s.sign(t, newA("www."+testZone, "192.0.2.1"))
+	rrs[0].(*dns.A).A = net.ParseIP("192.0.2.2")
+	ex := zoneExchanger{
+		{name: "www." + testZone, rrtype: dns.TypeA}: &dns.Msg{Answer: rrs},
+	}
+
+	cache := resolver_util.NewCache().WithLogger(xlogger.Nop())
+	r := &resolver{
+		cache:   cache,
+		options: resolverOptions{logger: xlogger.Nop()},
+	}
+
+	tests := []struct {
+		bogus   string
+		wantErr bool
+	}{
+		{bogus: BogusFlag},
+		{bogus: BogusReject, wantErr: true},
+		{bogus: BogusFlag},
+	}
+	for _, tt := range tests {
+		server := &NameServer{
+			TTL:       time.Minute,
+			exchanger: ex,
+			validator: newTestValidator(t, s, tt.bogus, ex, cache),
+		}
+		ips, err := r.resolve(context.Background(), server, "www."+testZone)
+		if tt.wantErr {
+			if !errors.Is(err, ErrBogus) {
+				t.Errorf("%s: got %v, want %v", tt.bogus, err, ErrBogus)
+			}
+			continue
+		}
+		if err != nil || len(ips) != 1 {
+			t.Errorf("%s: got %v, %v", tt.bogus, ips, err)
+		}
+	}
+}
+
+func TestValidatorKeys(t *testing.T) {
+	s := newSigner(t)
+	cache := resolver_util.NewCache().WithLogger(xlogger.Nop())
+
+	tests := []struct {
+		name    string
+		expired int
+		live    int
+		want    int
+	}{
+		{
+			name: "empty",
+			want: 1,
+		},
+		{
+			name:    "expired",
+			expired: 10,
+			live:    10,
+			want:    11,
+		},
+		{
+			name: "full",
+			live: maxZoneKeys,
+			want: maxZoneKeys,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestValidator(t, s, BogusReject, zoneExchanger{}, cache)
+
+			now := time.Now()
+			for i := 0; i < tt.expired; i++ {
+				v.keys[fmt.Sprintf("expired%d.", i)] = &keysItem{expires: now.Add(-time.Second)}
+			}
+			for i := 0; i < tt.live; i++ {
+				v.keys[fmt.Sprintf("live%d.", i)] = &keysItem{expires: now.Add(time.Hour)}
+			}
+
+			keys, err := v.zoneKeys(context.Background(), testZone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 1 || keys[0].KeyTag() != s.key.KeyTag() {
+				t.Errorf("got %v, want %v", keys, s.key)
+			}
+			if len(v.keys) != tt.want {
+				t.Errorf("got %d zones, want %d", len(v.keys), tt.want)
+			}
+			if v.keys[testZone] == nil {
+				t.Errorf("keys of %s not cached", testZone)
+			}
+			for zone := range v.keys {
+				if strings.HasPrefix(zone, "expired") {
+					t.Errorf("expired keys of %s not removed", zone)
+				}
+			}
+		})
+	}
+}
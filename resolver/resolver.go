@@ -1,15 +1,20 @@
 package resolver
 
 import (
+	"bufio"
 	"context"
+	"io"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/logger"
 	resolverpkg "github.com/go-gost/core/resolver"
+	"github.com/hxdcloud/gost-x/internal/loader"
 	resolver_util "github.com/hxdcloud/gost-x/internal/util/resolver"
+	"github.com/hxdcloud/gost-x/registry"
 	"github.com/hxdcloud/gost-x/resolver/exchanger"
 	"github.com/miekg/dns"
 )
@@ -22,12 +27,20 @@ type NameServer struct {
 	ClientIP  net.IP
 	Prefer    string
 	Hostname  string // for TLS handshake verification
+	DNSSEC    *DNSSEC
 	exchanger exchanger.Exchanger
+	validator *validator
+	// config is the line of the loaded nameserver.
+	config string
 }
 
 type resolverOptions struct {
-	domain string
-	logger logger.Logger
+	domain      string
+	fileLoader  loader.Loader
+	redisLoader loader.Loader
+	httpLoader  loader.Loader
+	period      time.Duration
+	logger      logger.Logger
 }
 
 type ResolverOption func(opts *resolverOptions)
@@ -44,19 +57,72 @@ func LoggerResolverOption(logger logger.Logger) ResolverOption {
 	}
 }
 
+func ReloadPeriodResolverOption(period time.Duration) ResolverOption {
+	return func(opts *resolverOptions) {
+		opts.period = period
+	}
+}
+
+func FileLoaderResolverOption(fileLoader loader.Loader) ResolverOption {
+	return func(opts *resolverOptions) {
+		opts.fileLoader = fileLoader
+	}
+}
+
+func RedisLoaderResolverOption(redisLoader loader.Loader) ResolverOption {
+	return func(opts *resolverOptions) {
+		opts.redisLoader = redisLoader
+	}
+}
+
+func HTTPLoaderResolverOption(httpLoader loader.Loader) ResolverOption {
+	return func(opts *resolverOptions) {
+		opts.httpLoader = httpLoader
+	}
+}
+
 type resolver struct {
-	servers []NameServer
-	cache   *resolver_util.Cache
-	options resolverOptions
+	// static nameservers from config
+	nameservers []NameServer
+	// nameservers from the loaders, only accessed by reload
+	loaded     []NameServer
+	servers    []NameServer
+	mu         sync.RWMutex
+	cache      *resolver_util.Cache
+	cancelFunc context.CancelFunc
+	options    resolverOptions
 }
 
+// NewResolver creates a resolver with the static nameservers and
+// the nameservers loaded from the file, redis or http loaders.
+// The loaded nameservers are reloaded periodically if the reload period is set.
 func NewResolver(nameservers []NameServer, opts ...ResolverOption) (resolverpkg.Resolver, error) {
 	options := resolverOptions{}
 	for _, opt := range opts {
 		opt(&options)
 	}
 
-	var servers []NameServer
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	r := &resolver{
+		cache: resolver_util.NewCache().
+			WithLogger(options.logger),
+		cancelFunc: cancel,
+		options:    options,
+	}
+	r.nameservers = r.initServers(nameservers)
+
+	if err := r.reload(ctx); err != nil {
+		options.logger.Warnf("reload: %v", err)
+	}
+	if r.options.period > 0 {
+		go r.periodReload(ctx)
+	}
+
+	return r, nil
+}
+
+func (r *resolver) initServers(nameservers []NameServer) (servers []NameServer) {
 	for _, server := range nameservers {
 		addr := strings.TrimSpace(server.Addr)
 		if addr == "" {
@@ -67,27 +133,164 @@ func NewResolver(nameservers []NameServer, opts ...ResolverOption) (resolverpkg.
 			exchanger.RouterOption(
 				(&chain.Router{}).
 					WithChain(server.Chain).
-					WithLogger(options.logger),
+					WithLogger(r.options.logger),
 			),
 			exchanger.TimeoutOption(server.Timeout),
-			exchanger.LoggerOption(options.logger),
+			exchanger.LoggerOption(r.options.logger),
 		)
 		if err != nil {
-			options.logger.Warnf("parse %s: %v", server, err)
+			r.options.logger.Warnf("parse %s: %v", addr, err)
 			continue
 		}
 
 		server.exchanger = ex
+		if server.DNSSEC != nil {
+			v, err := newValidator(server.DNSSEC, ex, server.TTL, r.cache, r.options.logger)
+			if err != nil {
+				r.options.logger.Warnf("dnssec %s: %v", addr, err)
+				continue
+			}
+			server.validator = v
+		}
 		servers = append(servers, server)
 	}
-	cache := resolver_util.NewCache().
-		WithLogger(options.logger)
+	return
+}
+
+func (r *resolver) periodReload(ctx context.Context) error {
+	period := r.options.period
+	if period < time.Second {
+		period = time.Second
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.reload(ctx); err != nil {
+				r.options.logger.Warnf("reload: %v", err)
+				// return err
+			}
+			r.options.logger.Debugf("resolver reload done")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *resolver) reload(ctx context.Context) error {
+	v, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the unchanged nameservers are kept with their exchangers and DNSSEC keys.
+	prev := make(map[string]NameServer)
+	for _, server := range r.loaded {
+		prev[server.config] = server
+	}
+	var loaded []NameServer
+	for _, server := range v {
+		if ns, ok := prev[server.config]; ok {
+			loaded = append(loaded, ns)
+			continue
+		}
+		loaded = append(loaded, r.initServers([]NameServer{server})...)
+	}
+	r.loaded = loaded
+
+	servers := append([]NameServer{}, r.nameservers...)
+	servers = append(servers, loaded...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.servers = servers
+
+	return nil
+}
+
+func (r *resolver) load(ctx context.Context) (nameservers []NameServer, err error) {
+	if r.options.fileLoader != nil {
+		rd, er := r.options.fileLoader.Load(ctx)
+		if er != nil {
+			r.options.logger.Warnf("file loader: %v", er)
+		}
+		if v, _ := r.parseNameservers(rd); v != nil {
+			nameservers = append(nameservers, v...)
+		}
+	}
+	if r.options.redisLoader != nil {
+		rd, er := r.options.redisLoader.Load(ctx)
+		if er != nil {
+			r.options.logger.Warnf("redis loader: %v", er)
+		}
+		if v, _ := r.parseNameservers(rd); v != nil {
+			nameservers = append(nameservers, v...)
+		}
+	}
+	if r.options.httpLoader != nil {
+		rd, er := r.options.httpLoader.Load(ctx)
+		if er != nil {
+			r.options.logger.Warnf("http loader: %v", er)
+		}
+		if v, _ := r.parseNameservers(rd); v != nil {
+			nameservers = append(nameservers, v...)
+		}
+	}
+
+	return
+}
+
+// parseNameservers parses the nameservers, one per line, in the format:
+// addr [chain=<chain>] [prefer=<ipv4|ipv6>] [clientip=<ip>] [hostname=<hostname>] [ttl=<duration>] [timeout=<duration>] [dnssec=<reject|flag>]
+func (r *resolver) parseNameservers(rd io.Reader) (nameservers []NameServer, err error) {
+	if rd == nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(rd)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n := strings.IndexByte(line, '#'); n >= 0 {
+			line = line[:n]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		ns := NameServer{
+			Addr:   fields[0],
+			config: strings.Join(fields, " "),
+		}
+		for _, field := range fields[1:] {
+			k, v, _ := strings.Cut(field, "=")
+			switch strings.ToLower(k) {
+			case "chain":
+				ns.Chain = registry.ChainRegistry().Get(v)
+			case "prefer":
+				ns.Prefer = v
+			case "clientip":
+				ns.ClientIP = net.ParseIP(v)
+			case "hostname":
+				ns.Hostname = v
+			case "ttl":
+				ns.TTL, _ = time.ParseDuration(v)
+			case "timeout":
+				ns.Timeout, _ = time.ParseDuration(v)
+			case "dnssec":
+				ns.DNSSEC = &DNSSEC{
+					Bogus: v,
+				}
+			}
+		}
+		nameservers = append(nameservers, ns)
+	}
 
-	return &resolver{
-		servers: servers,
-		cache:   cache,
-		options: options,
-	}, nil
+	err = scanner.Err()
+	return
 }
 
 func (r *resolver) Resolve(ctx context.Context, network, host string) (ips []net.IP, err error) {
@@ -100,7 +303,11 @@ func (r *resolver) Resolve(ctx context.Context, network, host string) (ips []net
 		host = host + "." + r.options.domain
 	}
 
-	for _, server := range r.servers {
+	r.mu.RLock()
+	servers := r.servers
+	r.mu.RUnlock()
+
+	for _, server := range servers {
 		ips, err = r.resolve(ctx, &server, host)
 		if err != nil {
 			r.options.logger.Error(err)
@@ -139,13 +346,29 @@ func (r *resolver) resolve(ctx context.Context, server *NameServer, host string)
 
 func (r *resolver) resolveIPs(ctx context.Context, server *NameServer, mq *dns.Msg) (ips []net.IP, err error) {
 	key := resolver_util.NewCacheKey(&mq.Question[0])
+	if server.validator != nil {
+		key = server.validator.CacheKey(&mq.Question[0])
+		mq.SetEdns0(dnssecUDPSize, true)
+		mq.CheckingDisabled = true
+	}
+	resolver_util.AddSubnetOpt(mq, server.ClientIP)
+	key = key.WithSubnet(resolver_util.GetSubnetOpt(mq))
+
 	mr := r.cache.Load(key)
 	if mr == nil {
-		resolver_util.AddSubnetOpt(mq, server.ClientIP)
 		mr, err = r.exchange(ctx, server.exchanger, mq)
 		if err != nil {
 			return
 		}
+		if server.validator != nil {
+			if err = server.validator.Validate(ctx, mr); err != nil {
+				if !server.validator.Flag() {
+					return
+				}
+				r.options.logger.Warnf("%s: %v", mq.Question[0].Name, err)
+				err = nil
+			}
+		}
 		r.cache.Store(key, mr, server.TTL)
 	}
 
@@ -176,3 +399,17 @@ func (r *resolver) exchange(ctx context.Context, ex exchanger.Exchanger, mq *dns
 
 	return
 }
+
+func (r *resolver) Close() error {
+	r.cancelFunc()
+	if r.options.fileLoader != nil {
+		r.options.fileLoader.Close()
+	}
+	if r.options.redisLoader != nil {
+		r.options.redisLoader.Close()
+	}
+	if r.options.httpLoader != nil {
+		r.options.httpLoader.Close()
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package resolver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hxdcloud/gost-x/internal/loader"
+	"github.com/hxdcloud/gost-x/internal/loader/loadertest"
+	xlogger "github.com/hxdcloud/gost-x/logger"
+	"github.com/miekg/dns"
+)
+
+// dnsServer starts a UDP nameserver which answers the A queries with the ip.
+func dnsServer(t *testing.T, ip string) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := &dns.Msg{}
+			m.SetReply(r)
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP(ip),
+			})
+			w.WriteMsg(m)
+		}),
+	}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return "udp://" + pc.LocalAddr().String()
+}
+
+func TestParseNameservers(t *testing.T) {
+	r := &resolver{}
+	v, err := r.parseNameservers(strings.NewReader(`
+# comment
+udp://1.1.1.1:53
+tls://1.1.1.1:853  prefer=ipv6 clientip=192.168.1.1 hostname=one.one.one.one ttl=60s timeout=5s dnssec=flag # comment
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []NameServer{
+		{
+			Addr:   "udp://1.1.1.1:53",
+			config: "udp://1.1.1.1:53",
+		},
+		{
+			Addr:     "tls://1.1.1.1:853",
+			Prefer:   "ipv6",
+			ClientIP: net.ParseIP("192.168.1.1"),
+			Hostname: "one.one.one.one",
+			TTL:      time.Minute,
+			Timeout:  5 * time.Second,
+			DNSSEC:   &DNSSEC{Bogus: "flag"},
+			config:   "tls://1.1.1.1:853 prefer=ipv6 clientip=192.168.1.1 hostname=one.one.one.one ttl=60s timeout=5s dnssec=flag",
+		},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got %+v, want %+v", v, want)
+	}
+}
+
+func TestResolverReload(t *testing.T) {
+	ns1 := dnsServer(t, "192.168.1.1")
+	ns2 := dnsServer(t, "192.168.1.2")
+
+	file := filepath.Join(t.TempDir(), "nameservers")
+	var data atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, data.Load().(string))
+	}))
+	defer srv.Close()
+	rs := loadertest.NewRedisServer(t)
+
+	type state struct {
+		file  string
+		http  string
+		redis map[string]string
+		// servers are the configs of the loaded nameservers.
+		servers []string
+		// kept are the indices of the servers kept from the previous state.
+		kept []int
+		// ip is the address resolved for the host of the state.
+		ip string
+	}
+
+	tests := []struct {
+		name   string
+		opts   []ResolverOption
+		states []state
+	}{
+		{
+			name: "file",
+			opts: []ResolverOption{FileLoaderResolverOption(loader.FileLoader(file))},
+			states: []state{
+				{
+					file:    ns1 + "\n",
+					servers: []string{ns1},
+					ip:      "192.168.1.1",
+				},
+				{
+					file:    ns1 + " dnssec=flag\n" + ns1 + "\n",
+					servers: []string{ns1 + " dnssec=flag", ns1},
+					kept:    []int{1},
+					ip:      "192.168.1.1",
+				},
+				{
+					file:    ns2 + "\n" + ns1 + " dnssec=flag\n",
+					servers: []string{ns2, ns1 + " dnssec=flag"},
+					kept:    []int{1},
+					ip:      "192.168.1.2",
+				},
+				{
+					file:    ns2 + "  # comment\n" + ns1 + " dnssec=flag\n",
+					servers: []string{ns2, ns1 + " dnssec=flag"},
+					kept:    []int{0, 1},
+					ip:      "192.168.1.2",
+				},
+			},
+		},
+		{
+			name: "http",
+			opts: []ResolverOption{HTTPLoaderResolverOption(loader.HTTPLoader(srv.URL))},
+			states: []state{
+				{
+					http:    ns1 + "\n",
+					servers: []string{ns1},
+					ip:      "192.168.1.1",
+				},
+				{
+					http:    ns2 + "\n",
+					servers: []string{ns2},
+					ip:      "192.168.1.2",
+				},
+			},
+		},
+		{
+			name: "redis",
+			opts: []ResolverOption{RedisLoaderResolverOption(loader.RedisHashLoader(rs.Addr, loader.KeyRedisLoaderOption("resolver")))},
+			states: []state{
+				{
+					redis:   map[string]string{ns1: "ttl=30s"},
+					servers: []string{ns1 + " ttl=30s"},
+					ip:      "192.168.1.1",
+				},
+				{
+					redis:   map[string]string{ns1: "ttl=30s"},
+					servers: []string{ns1 + " ttl=30s"},
+					kept:    []int{0},
+					ip:      "192.168.1.1",
+				},
+				{
+					redis:   map[string]string{ns2: "ttl=30s"},
+					servers: []string{ns2 + " ttl=30s"},
+					ip:      "192.168.1.2",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append(tt.opts, LoggerResolverOption(xlogger.Nop()))
+
+			var r *resolver
+			var prev []NameServer
+			for i, s := range tt.states {
+				os.WriteFile(file, []byte(s.file), 0644)
+				data.Store(s.http)
+				rs.SetHash("resolver", s.redis)
+
+				if r == nil {
+					v, err := NewResolver(nil, opts...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					r = v.(*resolver)
+					defer r.Close()
+				} else if err := r.reload(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+
+				var servers []string
+				for _, server := range r.servers {
+					servers = append(servers, server.config)
+				}
+				if !reflect.DeepEqual(servers, s.servers) {
+					t.Fatalf("#%d: got %q, want %q", i, servers, s.servers)
+				}
+
+				// the kept servers reuse the exchanger and the DNSSEC validator.
+				for j, server := range r.servers {
+					kept := false
+					for _, v := range prev {
+						if v.exchanger == server.exchanger && v.validator == server.validator {
+							kept = true
+						}
+					}
+					want := false
+					for _, k := range s.kept {
+						want = want || k == j
+					}
+					if kept != want {
+						t.Errorf("#%d %s: got kept %v, want %v", i, server.config, kept, want)
+					}
+				}
+				prev = r.servers
+
+				// a new host each time, the answers of the previous servers are cached.
+				ips, err := r.Resolve(context.Background(), "ip", "host"+string(rune('a'+i))+".example.com")
+				if err != nil || len(ips) != 1 || ips[0].String() != s.ip {
+					t.Errorf("#%d: got %v, %v, want %s", i, ips, err, s.ip)
+				}
+			}
+		})
+	}
+}